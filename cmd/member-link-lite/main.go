@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
 	"member-link-lite/config"
 	_ "member-link-lite/docs"
 	"member-link-lite/internal/api/router"
 	database2 "member-link-lite/internal/database"
+	"member-link-lite/internal/services"
 	"member-link-lite/pkg/logger"
+	"member-link-lite/pkg/mail"
+	"member-link-lite/pkg/sms"
 	"member-link-lite/pkg/storage"
 )
 
@@ -30,6 +34,11 @@ import (
 // @name Authorization
 // @description JWT令牌认证，格式: Bearer {token}
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-Api-Key
+// @description 服务端签名调用，需同时携带X-Api-Timestamp、X-Api-Nonce、X-Api-Signature请求头
+
 func main() {
 	// 初始化配置
 	config.Init()
@@ -37,6 +46,16 @@ func main() {
 	// 初始化日志
 	logger.Init()
 
+	// 加载JWT签名密钥
+	if err := services.InitJWTKeys(); err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+
+	// 加载OIDC ID令牌签名密钥
+	if err := services.InitOIDCKeys(); err != nil {
+		log.Fatal("Failed to load OIDC keys:", err)
+	}
+
 	// 初始化数据库
 	if err := database2.Init(); err != nil {
 		log.Printf("Warning: Failed to initialize database: %v", err)
@@ -51,6 +70,11 @@ func main() {
 		if err := database2.CreateIndexes(database2.GetDB()); err != nil {
 			log.Printf("Warning: Failed to create database indexes: %v", err)
 		}
+
+		// 初始化内置角色和权限
+		if err := services.NewRBACService().EnsureDefaults(context.Background()); err != nil {
+			log.Printf("Warning: Failed to initialize roles and permissions: %v", err)
+		}
 	}
 
 	// 初始化Redis
@@ -63,6 +87,23 @@ func main() {
 		log.Printf("Warning: Failed to initialize storage: %v", err)
 	}
 
+	// 初始化短信发送器
+	if err := sms.InitSMS(); err != nil {
+		log.Printf("Warning: Failed to initialize SMS sender: %v", err)
+	}
+
+	// 初始化邮件发送器
+	if err := mail.InitMail(); err != nil {
+		log.Printf("Warning: Failed to initialize mailer: %v", err)
+	}
+
+	// 启动到期账号注销、过期导出文件清理、到期冻结释放及到期锁定解除任务
+	if database2.GetDB() != nil {
+		services.StartPrivacyJobs()
+		services.StartBalanceHoldJobs()
+		services.StartAccountUnlockJobs()
+	}
+
 	// 初始化路由
 	r := router.Init()
 
@@ -61,6 +61,12 @@ func setDefaults() {
 	viper.SetDefault("jwt.issuer", "memberlink-lite")
 	viper.SetDefault("jwt.access_token_ttl", 24)   // 小时
 	viper.SetDefault("jwt.refresh_token_ttl", 168) // 小时 (7天)
+	viper.SetDefault("jwt.algorithm", "HS256")     // HS256、RS256 或 ES256
+	viper.SetDefault("jwt.key_id", "")
+	viper.SetDefault("jwt.private_key_file", "")
+
+	// 轮换期间仍需接受的历史公钥（kid -> 公钥PEM文件路径）
+	// viper.SetDefault("jwt.verification_keys.key-2024", "./keys/key-2024.pub.pem")
 
 	// 日志配置
 	viper.SetDefault("log.level", "info")
@@ -100,6 +106,9 @@ func setDefaults() {
 	// viper.SetDefault("wechat.tenants.company1.app_id", "")
 	// viper.SetDefault("wechat.tenants.company1.app_secret", "")
 
+	// 第三方账号登录配置
+	viper.SetDefault("social.state_ttl", 600) // 网页授权state有效期（秒）
+
 	// 多租户配置
 	viper.SetDefault("tenant.enabled", false)
 	viper.SetDefault("tenant.header_name", "X-Tenant-ID")
@@ -118,6 +127,92 @@ func setDefaults() {
 	// viper.SetDefault("tenant.tenants.tenant2.domain", "tenant2.example.com")
 	// viper.SetDefault("tenant.tenants.tenant2.enabled", true)
 
+	// 登录安全配置
+	viper.SetDefault("security.login.max_account_failures", 5) // 单账号连续失败次数上限，达到后临时锁定
+	viper.SetDefault("security.login.max_ip_failures", 20)     // 单IP失败次数上限，达到后拒绝登录
+	viper.SetDefault("security.login.failure_window", 15)      // 失败计数窗口（分钟）
+	viper.SetDefault("security.login.lock_duration", 30)       // 临时锁定时长（分钟）
+	viper.SetDefault("security.login.delay_after", 3)          // 失败多少次后开始延迟响应
+	viper.SetDefault("security.login.delay_step", 500)         // 每次递增的延迟（毫秒）
+	viper.SetDefault("security.login.max_delay", 5000)         // 最大延迟（毫秒）
+	viper.SetDefault("security.login.unlock_interval", 60)     // 解除到期锁定的间隔（秒），0为不执行
+
+	// 角色权限配置
+	viper.SetDefault("rbac.admin_usernames", "") // 启动时授予管理员角色的用户名，多个用逗号分隔
+
+	// 服务端签名调用配置
+	viper.SetDefault("api_client.timestamp_tolerance", 300)                                              // 请求时间戳允许的偏差（秒）
+	viper.SetDefault("api_client.encryption_key", "memberlink-lite-api-client-key-change-in-production") // 签名密钥的加密密钥，生产环境必须修改
+
+	// OAuth2/OIDC授权配置
+	viper.SetDefault("oauth.issuer", "http://localhost:8080") // 签发方，需为对外可访问的地址
+	viper.SetDefault("oauth.authorization_page_url", "")      // 前端授权确认页地址，为空时使用接口地址
+	viper.SetDefault("oauth.code_ttl", 120)                   // 授权码有效期（秒）
+	viper.SetDefault("oauth.access_token_ttl", 60)            // 访问令牌及ID令牌有效期（分钟）
+
+	// OIDC ID令牌签名密钥，需为RS256或ES256；未配置私钥时不启用OIDC（不支持openid授权范围）
+	viper.SetDefault("oauth.oidc.algorithm", "ES256")
+	viper.SetDefault("oauth.oidc.key_id", "")
+	viper.SetDefault("oauth.oidc.private_key_file", "")
+	// viper.SetDefault("oauth.oidc.verification_keys.oidc-2024", "./keys/oidc-2024.pub.pem")
+
+	// 密码策略配置（可在 password_policy.tenants.<租户> 下按租户覆盖）
+	viper.SetDefault("password_policy.min_length", 6)                    // 最小长度
+	viper.SetDefault("password_policy.max_length", 64)                   // 最大长度
+	viper.SetDefault("password_policy.required_classes", "letter,digit") // 必须包含的字符类型：lower、upper、letter、digit、special
+	viper.SetDefault("password_policy.max_age_days", 0)                  // 密码有效期（天），0为永不过期
+	viper.SetDefault("password_policy.history_count", 0)                 // 禁止重复使用最近N次的密码，0为不限制
+
+	// 个人数据导出及账号注销配置
+	viper.SetDefault("privacy.deletion_grace_days", 15) // 申请注销后的冷静期（天），期间可撤销
+	viper.SetDefault("privacy.export_ttl_hours", 72)    // 导出文件及其中文件下载链接的有效期（小时）
+	viper.SetDefault("privacy.job_interval", 600)       // 执行到期注销、清理过期导出文件的间隔（秒），0为不执行
+
+	// 会员批量导入配置
+	viper.SetDefault("member_import.max_file_size", 20) // 导入文件大小上限（MB）
+	viper.SetDefault("member_import.max_rows", 100000)  // 单个文件最多数据行数
+	viper.SetDefault("member_import.batch_size", 500)   // 每批写入的会员数量
+
+	// 余额冻结配置
+	viper.SetDefault("balance_hold.expire_minutes", 30) // 冻结余额默认有效期（分钟），到期未扣款自动释放
+	viper.SetDefault("balance_hold.job_interval", 60)   // 释放到期冻结的间隔（秒），0为不执行
+
+	// 积分转账配置
+	viper.SetDefault("points_transfer.daily_limit", 10000)       // 每个会员每日最多转出积分（不含手续费），0为不限制
+	viper.SetDefault("points_transfer.daily_count", 10)          // 每个会员每日最多转账次数，0为不限制
+	viper.SetDefault("points_transfer.min_points_age_hours", 24) // 积分获得后需持有的时长（小时）才能转出，0为不限制
+	viper.SetDefault("points_transfer.fee_rate", 0.0)            // 手续费比例（如0.01为1%），向上取整，由转出会员额外支付并记为transfer_fee积分记录，0为不收取
+
+	// 两步验证配置
+	viper.SetDefault("mfa.issuer", "MemberLink")    // 身份验证器中显示的发行方名称
+	viper.SetDefault("mfa.pending_token_ttl", 5)    // 密码验证通过后完成两步验证的时限（分钟）
+	viper.SetDefault("mfa.max_attempts", 5)         // 单次登录最多尝试验证码次数
+	viper.SetDefault("mfa.recovery_code_count", 10) // 恢复码数量
+	viper.SetDefault("mfa.skew", 1)                 // 允许的时间步偏差（前后各N个30秒）
+
+	// 短信验证码配置
+	viper.SetDefault("sms.provider", "console")        // 短信服务商，console仅输出到日志
+	viper.SetDefault("sms.console_log_content", false) // console方式是否在日志中输出短信内容（含验证码），仅限本地开发开启
+	viper.SetDefault("sms.code_length", 6)             // 验证码位数
+	viper.SetDefault("sms.code_ttl", 300)              // 验证码有效期（秒）
+	viper.SetDefault("sms.send_interval", 60)          // 同一手机号发送间隔（秒）
+	viper.SetDefault("sms.phone_daily_limit", 10)      // 同一手机号每日发送上限
+	viper.SetDefault("sms.ip_hourly_limit", 20)        // 同一IP每小时发送上限
+	viper.SetDefault("sms.max_verify_attempts", 5)     // 单个验证码最多校验次数
+	viper.SetDefault("sms.login_template", "您的登录验证码为%s，%d分钟内有效，请勿泄露给他人。")
+
+	// 邮件配置
+	viper.SetDefault("mail.provider", "log")                                                    // 发送方式：log（日志/文件）、smtp
+	viper.SetDefault("mail.file_dir", "")                                                       // log方式下邮件文件保存目录，为空时仅输出日志
+	viper.SetDefault("mail.log_body", false)                                                    // log方式是否在日志中输出邮件正文（含重置密码链接），仅限本地开发开启
+	viper.SetDefault("mail.smtp.port", 587)                                                     // SMTP端口
+	viper.SetDefault("mail.smtp.use_tls", false)                                                // 是否使用隐式TLS
+	viper.SetDefault("mail.reset_password_url", "http://localhost:3000/reset-password")         // 重置密码页面地址
+	viper.SetDefault("mail.verify_email_url", "http://localhost:8080/api/v1/auth/email/verify") // 邮箱验证地址
+	viper.SetDefault("mail.reset_token_ttl", 30)                                                // 重置密码链接有效期（分钟）
+	viper.SetDefault("mail.verify_token_ttl", 1440)                                             // 邮箱验证链接有效期（分钟）
+	viper.SetDefault("mail.send_interval", 60)                                                  // 同一邮箱发送间隔（秒）
+
 	// CORS配置
 	viper.SetDefault("cors.allowed_origins", "http://localhost:3000,http://localhost:8080")
 	viper.SetDefault("cors.allow_credentials", true)
@@ -143,3 +238,13 @@ func GetBool(key string) bool {
 func GetFloat64(key string) float64 {
 	return viper.GetFloat64(key)
 }
+
+// GetStringMapString 获取字符串映射配置
+func GetStringMapString(key string) map[string]string {
+	return viper.GetStringMapString(key)
+}
+
+// GetStringMap 获取映射配置
+func GetStringMap(key string) map[string]interface{} {
+	return viper.GetStringMap(key)
+}
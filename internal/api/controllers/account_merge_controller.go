@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/models"
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// AccountMergeController 账号合并控制器
+type AccountMergeController struct {
+	mergeService services.AccountMergeService
+}
+
+// NewAccountMergeController 创建账号合并控制器
+func NewAccountMergeController() *AccountMergeController {
+	return &AccountMergeController{
+		mergeService: services.NewAccountMergeService(),
+	}
+}
+
+// Merge 合并会员账号
+// @Summary 合并会员账号
+// @Description 将被合并账号的余额、积分、余额/积分变动记录、文件及第三方账号转入主账号，并禁用被合并账号。两个账号绑定了同一登录方式的不同第三方账号时无法合并。需要member:write权限
+// @Tags 会员管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.MergeAccountsRequest true "合并信息"
+// @Success 200 {object} common.APIResponse{data=models.AccountMerge} "合并成功"
+// @Failure 400 {object} common.APIResponse "请求参数错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "用户不存在"
+// @Failure 409 {object} common.APIResponse "账号已被合并或禁用，或第三方账号冲突"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/users/merge [post]
+func (ctrl *AccountMergeController) Merge(c *gin.Context) {
+	operatorID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	var req services.MergeAccountsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return
+	}
+
+	record, err := ctrl.mergeService.Merge(c.Request.Context(), &req, models.AccountMergeReasonManual, operatorID)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "合并账号失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "合并成功", record)
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// APIClientController 服务端接入客户端控制器
+type APIClientController struct {
+	clientService services.APIClientService
+}
+
+// NewAPIClientController 创建服务端接入客户端控制器
+func NewAPIClientController() *APIClientController {
+	return &APIClientController{
+		clientService: services.NewAPIClientService(),
+	}
+}
+
+// CreateClient 创建接入客户端
+// @Summary 创建接入客户端
+// @Description 为内部服务创建签名调用凭证，密钥仅在创建时返回一次。可选授权范围：balance:write、points:write、asset:read。需要client:manage权限
+// @Tags 权限管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.CreateAPIClientRequest true "客户端信息"
+// @Success 200 {object} common.APIResponse{data=services.APIClientCredentials} "创建成功"
+// @Failure 400 {object} common.APIResponse "请求参数错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/api-clients [post]
+func (ctrl *APIClientController) CreateClient(c *gin.Context) {
+	operatorID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	var req services.CreateAPIClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return
+	}
+
+	credentials, err := ctrl.clientService.CreateClient(c.Request.Context(), &req, operatorID)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "创建客户端失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "创建成功", credentials)
+}
+
+// ListClients 获取接入客户端列表
+// @Summary 获取接入客户端列表
+// @Description 获取当前租户下的全部接入客户端（不含密钥）。需要client:manage权限
+// @Tags 权限管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse{data=[]models.APIClient} "获取成功"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/api-clients [get]
+func (ctrl *APIClientController) ListClients(c *gin.Context) {
+	clients, err := ctrl.clientService.ListClients(c.Request.Context())
+	if err != nil {
+		common.ErrorResponse(c, http.StatusInternalServerError, "获取客户端列表失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", clients)
+}
+
+// DisableClient 停用接入客户端
+// @Summary 停用接入客户端
+// @Description 停用后该客户端的签名请求将被拒绝。需要client:manage权限
+// @Tags 权限管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "客户端ID"
+// @Success 200 {object} common.APIResponse "停用成功"
+// @Failure 400 {object} common.APIResponse "客户端ID格式错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "客户端不存在"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/api-clients/{id}/disable [post]
+func (ctrl *APIClientController) DisableClient(c *gin.Context) {
+	clientID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.ErrorResponse(c, http.StatusBadRequest, "客户端ID格式错误", nil)
+		return
+	}
+
+	if err := ctrl.clientService.DisableClient(c.Request.Context(), clientID); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "停用客户端失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "停用成功", nil)
+}
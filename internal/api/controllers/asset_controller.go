@@ -1,6 +1,9 @@
 package controllers
 
 import (
+	"errors"
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/models"
 	"member-link-lite/internal/services"
 	"member-link-lite/pkg/common"
 	"strconv"
@@ -27,14 +30,15 @@ func NewAssetController(assetService services.AssetService) *AssetController {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param user_id query int false "会员ID（签名客户端调用时必填）"
 // @Success 200 {object} common.APIResponse "获取成功"
 // @Failure 401 {object} common.APIResponse "未授权：Token无效或过期"
 // @Failure 500 {object} common.APIResponse "服务器内部错误"
 // @Router /asset/info [get]
 func (c *AssetController) GetAssetInfo(ctx *gin.Context) {
-	userID := GetUserIDFromContext(ctx)
-	if userID == 0 {
-		common.Unauthorized(ctx, "未授权")
+	userID, ok := c.resolveTargetUserID(ctx)
+	if !ok {
 		return
 	}
 
@@ -49,76 +53,240 @@ func (c *AssetController) GetAssetInfo(ctx *gin.Context) {
 
 // ChangeBalance 余额变动
 // @Summary 处理用户余额变动
-// @Description 处理用户余额变动操作，支持充值、消费、退款、奖励、扣除等类型。使用事务确保数据一致性，余额不足时会返回错误
+// @Description 运营人员调整指定会员的余额，支持充值、消费、退款、奖励、扣除等类型。使用事务确保数据一致性，余额不足时会返回错误。需要balance:write权限，或使用拥有该授权范围的签名客户端调用
 // @Tags 资产管理
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param Idempotency-Key header string false "幂等键，请求体未提供idempotency_key时使用"
 // @Param request body services.ChangeBalanceRequest true "余额变动信息"
-// @Success 200 {object} common.APIResponse "操作成功"
+// @Success 200 {object} common.APIResponse{data=models.BalanceRecord} "操作成功，幂等键重复时返回首次的变动记录"
 // @Failure 400 {object} common.APIResponse "参数错误：金额格式错误、变动类型无效、余额不足等"
 // @Failure 401 {object} common.APIResponse "未授权：Token无效或过期"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 409 {object} common.APIResponse "幂等键已被参数不同的请求使用"
 // @Failure 500 {object} common.APIResponse "服务器内部错误"
 // @Router /asset/balance/change [post]
 func (c *AssetController) ChangeBalance(ctx *gin.Context) {
-	userID := GetUserIDFromContext(ctx)
-	if userID == 0 {
-		common.Unauthorized(ctx, "未授权")
-		return
+	if GetUserIDFromContext(ctx) == 0 {
+		if _, ok := middleware.GetAPIClient(ctx); !ok {
+			common.Unauthorized(ctx, "未授权")
+			return
+		}
 	}
 
+	// 目标用户由请求指定，路由已通过权限中间件限制为运营人员
 	var req services.ChangeBalanceRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		common.BadRequest(ctx, "参数错误: "+err.Error())
 		return
 	}
 
-	// 设置用户ID（从token中获取，确保安全）
-	req.UserID = userID
+	if !bindIdempotencyKey(ctx, &req.IdempotencyKey) {
+		return
+	}
 
-	if err := c.assetService.ChangeBalance(ctx.Request.Context(), &req); err != nil {
-		common.ServerError(ctx, err.Error())
+	record, err := c.assetService.ChangeBalance(ctx.Request.Context(), &req)
+	if err != nil {
+		assetChangeErrorResponse(ctx, err)
 		return
 	}
 
-	common.SuccessWithMessage(ctx, "操作成功", nil)
+	common.SuccessWithMessage(ctx, "操作成功", record)
 }
 
 // ChangePoints 积分变动
 // @Summary 处理用户积分变动
-// @Description 处理用户积分变动操作，支持获得、使用、过期、奖励、扣除等类型。支持设置积分过期时间，使用事务确保数据一致性
+// @Description 运营人员调整指定会员的积分，支持获得、使用、过期、奖励、扣除等类型。支持设置积分过期时间，使用事务确保数据一致性。需要points:write权限，或使用拥有该授权范围的签名客户端调用
 // @Tags 资产管理
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param Idempotency-Key header string false "幂等键，请求体未提供idempotency_key时使用"
 // @Param request body services.ChangePointsRequest true "积分变动信息"
-// @Success 200 {object} common.APIResponse "操作成功"
+// @Success 200 {object} common.APIResponse{data=models.PointsRecord} "操作成功，幂等键重复时返回首次的变动记录"
 // @Failure 400 {object} common.APIResponse "参数错误：数量格式错误、变动类型无效、积分不足等"
 // @Failure 401 {object} common.APIResponse "未授权：Token无效或过期"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 409 {object} common.APIResponse "幂等键已被参数不同的请求使用"
 // @Failure 500 {object} common.APIResponse "服务器内部错误"
 // @Router /asset/points/change [post]
 func (c *AssetController) ChangePoints(ctx *gin.Context) {
-	userID := GetUserIDFromContext(ctx)
-	if userID == 0 {
-		common.Unauthorized(ctx, "未授权")
-		return
+	if GetUserIDFromContext(ctx) == 0 {
+		if _, ok := middleware.GetAPIClient(ctx); !ok {
+			common.Unauthorized(ctx, "未授权")
+			return
+		}
 	}
 
+	// 目标用户由请求指定，路由已通过权限中间件限制为运营人员
+	// 充值、消费等快捷接口已预先绑定请求参数
 	var req services.ChangePointsRequest
+	if preset, exists := ctx.Get("points_request"); exists {
+		req = preset.(services.ChangePointsRequest)
+	} else if err := ctx.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(ctx, "参数错误: "+err.Error())
+		return
+	}
+
+	if !bindIdempotencyKey(ctx, &req.IdempotencyKey) {
+		return
+	}
+
+	record, err := c.assetService.ChangePoints(ctx.Request.Context(), &req)
+	if err != nil {
+		assetChangeErrorResponse(ctx, err)
+		return
+	}
+
+	common.SuccessWithMessage(ctx, "操作成功", record)
+}
+
+// HoldBalance 冻结余额
+// @Summary 冻结会员余额
+// @Description 下单时按订单冻结会员余额，冻结的金额不能再用于消费等扣减，生成状态为处理中的余额记录。到期未扣款自动释放。同一订单重复冻结相同金额时返回已有的冻结记录。需要balance:write权限，或使用拥有该授权范围的签名客户端调用
+// @Tags 资产管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param request body services.HoldBalanceRequest true "冻结信息"
+// @Success 200 {object} common.APIResponse{data=models.BalanceRecord} "冻结成功"
+// @Failure 400 {object} common.APIResponse "参数错误或可用余额不足"
+// @Failure 401 {object} common.APIResponse "未授权：Token无效或过期"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 409 {object} common.APIResponse "该订单已有冻结中的余额"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /asset/balance/hold [post]
+func (c *AssetController) HoldBalance(ctx *gin.Context) {
+	if GetUserIDFromContext(ctx) == 0 {
+		if _, ok := middleware.GetAPIClient(ctx); !ok {
+			common.Unauthorized(ctx, "未授权")
+			return
+		}
+	}
+
+	var req services.HoldBalanceRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		common.BadRequest(ctx, "参数错误: "+err.Error())
 		return
 	}
 
-	// 设置用户ID（从token中获取，确保安全）
-	req.UserID = userID
+	record, err := c.assetService.HoldBalance(ctx.Request.Context(), &req)
+	if err != nil {
+		assetChangeErrorResponse(ctx, err)
+		return
+	}
 
-	if err := c.assetService.ChangePoints(ctx.Request.Context(), &req); err != nil {
-		common.ServerError(ctx, err.Error())
+	common.SuccessWithMessage(ctx, "冻结成功", record)
+}
+
+// CaptureHold 冻结扣款
+// @Summary 扣款冻结的余额
+// @Description 订单确认后按订单扣款冻结的余额，扣款金额可小于冻结金额，未扣款的部分解除冻结。冻结记录变为已完成，金额为实际扣款金额。需要balance:write权限，或使用拥有该授权范围的签名客户端调用
+// @Tags 资产管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param request body services.CaptureHoldRequest true "扣款信息"
+// @Success 200 {object} common.APIResponse{data=models.BalanceRecord} "扣款成功"
+// @Failure 400 {object} common.APIResponse "参数错误或扣款金额超过冻结金额"
+// @Failure 401 {object} common.APIResponse "未授权：Token无效或过期"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "冻结记录不存在"
+// @Failure 409 {object} common.APIResponse "冻结已扣款、已释放或已过期"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /asset/balance/capture [post]
+func (c *AssetController) CaptureHold(ctx *gin.Context) {
+	if GetUserIDFromContext(ctx) == 0 {
+		if _, ok := middleware.GetAPIClient(ctx); !ok {
+			common.Unauthorized(ctx, "未授权")
+			return
+		}
+	}
+
+	var req services.CaptureHoldRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(ctx, "参数错误: "+err.Error())
+		return
+	}
+
+	record, err := c.assetService.CaptureHold(ctx.Request.Context(), &req)
+	if err != nil {
+		assetChangeErrorResponse(ctx, err)
 		return
 	}
 
-	common.SuccessWithMessage(ctx, "操作成功", nil)
+	common.SuccessWithMessage(ctx, "扣款成功", record)
+}
+
+// ReleaseHold 释放冻结
+// @Summary 释放冻结的余额
+// @Description 订单取消时按订单释放冻结的余额，冻结记录变为已取消。需要balance:write权限，或使用拥有该授权范围的签名客户端调用
+// @Tags 资产管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param request body services.ReleaseHoldRequest true "释放信息"
+// @Success 200 {object} common.APIResponse{data=models.BalanceRecord} "释放成功"
+// @Failure 400 {object} common.APIResponse "参数错误"
+// @Failure 401 {object} common.APIResponse "未授权：Token无效或过期"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "冻结记录不存在"
+// @Failure 409 {object} common.APIResponse "冻结已扣款或已释放"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /asset/balance/release [post]
+func (c *AssetController) ReleaseHold(ctx *gin.Context) {
+	if GetUserIDFromContext(ctx) == 0 {
+		if _, ok := middleware.GetAPIClient(ctx); !ok {
+			common.Unauthorized(ctx, "未授权")
+			return
+		}
+	}
+
+	var req services.ReleaseHoldRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(ctx, "参数错误: "+err.Error())
+		return
+	}
+
+	record, err := c.assetService.ReleaseHold(ctx.Request.Context(), &req)
+	if err != nil {
+		assetChangeErrorResponse(ctx, err)
+		return
+	}
+
+	common.SuccessWithMessage(ctx, "释放成功", record)
+}
+
+// bindIdempotencyKey 请求体未提供幂等键时使用Idempotency-Key请求头
+func bindIdempotencyKey(ctx *gin.Context, key *string) bool {
+	if *key == "" {
+		*key = ctx.GetHeader("Idempotency-Key")
+	}
+	if len(*key) > 64 {
+		common.BadRequest(ctx, "幂等键长度不能超过64个字符")
+		return false
+	}
+	return true
+}
+
+// assetChangeErrorResponse 资产变动错误响应，幂等键冲突返回409，其他业务错误按错误码返回
+func assetChangeErrorResponse(ctx *gin.Context, err error) {
+	if errors.Is(err, common.ErrIdempotencyConflict) {
+		common.Conflict(ctx, common.ErrIdempotencyConflict.Message)
+		return
+	}
+	var customErr *common.CustomError
+	if errors.As(err, &customErr) {
+		common.ErrorResponse(ctx, customErr.Code, customErr.Message, nil)
+		return
+	}
+	common.ServerError(ctx, err.Error())
 }
 
 // GetBalanceRecords 获取余额变动记录
@@ -128,6 +296,8 @@ func (c *AssetController) ChangePoints(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param user_id query int false "会员ID（签名客户端调用时必填）"
 // @Param page query int false "页码，从1开始" default(1) minimum(1)
 // @Param page_size query int false "每页数量，最大100" default(10) minimum(1) maximum(100)
 // @Param type query string false "变动类型筛选" Enums(recharge,consume,refund,reward,deduct)
@@ -139,9 +309,8 @@ func (c *AssetController) ChangePoints(ctx *gin.Context) {
 // @Failure 500 {object} common.APIResponse "服务器内部错误"
 // @Router /asset/balance/records [get]
 func (c *AssetController) GetBalanceRecords(ctx *gin.Context) {
-	userID := GetUserIDFromContext(ctx)
-	if userID == 0 {
-		common.Unauthorized(ctx, "未授权")
+	userID, ok := c.resolveTargetUserID(ctx)
+	if !ok {
 		return
 	}
 
@@ -172,6 +341,8 @@ func (c *AssetController) GetBalanceRecords(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param user_id query int false "会员ID（签名客户端调用时必填）"
 // @Param page query int false "页码，从1开始" default(1) minimum(1)
 // @Param page_size query int false "每页数量，最大100" default(10) minimum(1) maximum(100)
 // @Param type query string false "变动类型筛选" Enums(obtain,use,expire,reward,deduct)
@@ -183,9 +354,8 @@ func (c *AssetController) GetBalanceRecords(ctx *gin.Context) {
 // @Failure 500 {object} common.APIResponse "服务器内部错误"
 // @Router /asset/points/records [get]
 func (c *AssetController) GetPointsRecords(ctx *gin.Context) {
-	userID := GetUserIDFromContext(ctx)
-	if userID == 0 {
-		common.Unauthorized(ctx, "未授权")
+	userID, ok := c.resolveTargetUserID(ctx)
+	if !ok {
 		return
 	}
 
@@ -208,3 +378,29 @@ func (c *AssetController) GetPointsRecords(ctx *gin.Context) {
 
 	common.SuccessWithMessage(ctx, "获取成功", response)
 }
+
+// resolveTargetUserID 获取查询资产的目标用户
+// 会员查询自己的数据；签名客户端需拥有asset:read授权范围，并通过user_id参数指定会员
+func (c *AssetController) resolveTargetUserID(ctx *gin.Context) (uint64, bool) {
+	client, isClient := middleware.GetAPIClient(ctx)
+	if !isClient {
+		userID := GetUserIDFromContext(ctx)
+		if userID == 0 {
+			common.Unauthorized(ctx, "未授权")
+			return 0, false
+		}
+		return userID, true
+	}
+
+	if !client.HasScope(models.ScopeAssetRead) {
+		common.Forbidden(ctx, common.ErrPermissionDenied.Message)
+		return 0, false
+	}
+
+	userID, err := strconv.ParseUint(ctx.Query("user_id"), 10, 64)
+	if err != nil || userID == 0 {
+		common.BadRequest(ctx, "参数错误: 缺少user_id")
+		return 0, false
+	}
+	return userID, true
+}
@@ -3,8 +3,11 @@ package controllers
 import (
 	"context"
 	_ "member-link-lite/docs"
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/models"
 	"member-link-lite/internal/services"
 	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -13,7 +16,9 @@ import (
 
 // AuthController 认证控制器
 type AuthController struct {
-	userService services.UserService
+	userService  services.UserService
+	smsService   services.SMSService
+	emailService services.EmailService
 }
 
 // RefreshTokenRequest 刷新令牌请求
@@ -24,7 +29,9 @@ type RefreshTokenRequest struct {
 // NewAuthController 创建认证控制器
 func NewAuthController() *AuthController {
 	return &AuthController{
-		userService: services.NewUserService(),
+		userService:  services.NewUserService(),
+		smsService:   services.NewSMSService(),
+		emailService: services.NewEmailService(),
 	}
 }
 
@@ -71,12 +78,20 @@ func (ctrl *AuthController) Register(c *gin.Context) {
 	}
 
 	// 生成JWT Token
-	tokenResp, err := services.GenerateTokenPair(services.NewJWTService(), user.ID, user.Username)
+	tokenResp, err := ctrl.userService.IssueTokens(c.Request.Context(), user, models.LoginTypeRegister)
 	if err != nil {
 		common.ErrorResponse(c, http.StatusInternalServerError, "Token生成失败", err.Error())
 		return
 	}
 
+	// 异步发送邮箱验证邮件，发送失败不影响注册
+	ctx := context.WithoutCancel(c.Request.Context())
+	go func() {
+		if err := ctrl.emailService.SendVerificationEmail(ctx, user.ID); err != nil {
+			logger.WithField("user_id", user.ID).Warn("发送邮箱验证邮件失败: ", err)
+		}
+	}()
+
 	// 构建登录响应格式
 	loginResp := &services.LoginResponse{
 		User:   user,
@@ -89,14 +104,15 @@ func (ctrl *AuthController) Register(c *gin.Context) {
 
 // Login 用户登录
 // @Summary 用户登录
-// @Description 使用用户名和密码进行登录，返回用户信息和JWT令牌
+// @Description 使用账号（用户名、手机号或邮箱）和密码进行登录，返回用户信息和JWT令牌。连续登录失败会递增延迟，达到阈值后账号被临时锁定。启用两步验证的用户返回mfa_required和mfa_token，需调用 /auth/mfa/verify 完成登录
 // @Tags 认证管理
 // @Accept json
 // @Produce json
 // @Param request body services.LoginRequest true "登录信息"
 // @Success 200 {object} common.APIResponse{data=services.LoginResponse} "登录成功"
 // @Failure 400 {object} common.APIResponse "参数验证失败"
-// @Failure 403 {object} common.APIResponse "用户已被禁用"
+// @Failure 403 {object} common.APIResponse "用户已被禁用、已被临时锁定或密码已过期"
+// @Failure 429 {object} common.APIResponse "登录尝试过于频繁"
 // @Failure 500 {object} common.APIResponse "服务器内部错误"
 // @Router /auth/login [post]
 func (ctrl *AuthController) Login(c *gin.Context) {
@@ -127,6 +143,12 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 		return
 	}
 
+	// 需要两步验证时，完成验证后再更新登录信息
+	if loginResp.MFARequired {
+		common.SuccessResponse(c, "请完成两步验证", loginResp)
+		return
+	}
+
 	// 更新最后登录信息
 	clientIP := c.ClientIP()
 	go func() {
@@ -139,9 +161,57 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 	common.SuccessResponse(c, "登录成功", loginResp)
 }
 
+// VerifyMFA 两步验证登录
+// @Summary 两步验证登录
+// @Description 启用两步验证的用户登录（密码、短信验证码或第三方账号）后返回mfa_token，使用mfa_token和身份验证器中的动态口令（或恢复码）换取正式令牌
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param request body services.MFALoginRequest true "两步验证信息"
+// @Success 200 {object} common.APIResponse{data=services.LoginResponse} "登录成功"
+// @Failure 400 {object} common.APIResponse "验证码错误"
+// @Failure 401 {object} common.APIResponse "两步验证已过期"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /auth/mfa/verify [post]
+func (ctrl *AuthController) VerifyMFA(c *gin.Context) {
+	var req services.MFALoginRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return
+	}
+
+	loginResp, err := ctrl.userService.VerifyMFALogin(c.Request.Context(), &req)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "登录失败", err.Error())
+		return
+	}
+
+	// 更新最后登录信息
+	clientIP := c.ClientIP()
+	go func() {
+		ctx := context.Background()
+		ctrl.userService.UpdateLastLogin(ctx, loginResp.User.ID, clientIP)
+	}()
+
+	common.SuccessResponse(c, "登录成功", loginResp)
+}
+
 // RefreshToken 刷新JWT令牌
 // @Summary 刷新JWT令牌
-// @Description 使用有效的刷新令牌获取新的访问令牌和刷新令牌
+// @Description 使用有效的刷新令牌获取新的访问令牌和刷新令牌，刷新令牌仅能使用一次，重复使用将导致该登录会话的全部令牌失效
 // @Tags 认证管理
 // @Accept json
 // @Produce json
@@ -176,7 +246,7 @@ func (ctrl *AuthController) RefreshToken(c *gin.Context) {
 
 // Logout 用户登出
 // @Summary 用户登出
-// @Description 注销当前登录状态，在实际应用中可以将令牌加入黑名单
+// @Description 注销当前登录状态，吊销当前访问令牌及与其配对的刷新令牌
 // @Tags 认证管理
 // @Accept json
 // @Produce json
@@ -185,11 +255,120 @@ func (ctrl *AuthController) RefreshToken(c *gin.Context) {
 // @Failure 401 {object} common.APIResponse "未授权访问"
 // @Router /auth/logout [post]
 func (ctrl *AuthController) Logout(c *gin.Context) {
-	// 在实际应用中，可以将令牌加入黑名单
-	// 这里简单返回成功响应
+	// 从中间件获取当前令牌声明
+	claims, exists := middleware.GetJWTClaims(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	// 吊销令牌
+	if err := ctrl.userService.Logout(c.Request.Context(), claims); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "登出失败", err.Error())
+		return
+	}
+
 	common.SuccessResponse(c, "登出成功", nil)
 }
 
+// SendSMSCode 发送短信验证码
+// @Summary 发送短信验证码
+// @Description 向手机号发送登录验证码，同一手机号有发送间隔和每日上限，同一IP有每小时上限
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param request body services.SendSMSCodeRequest true "手机号"
+// @Success 200 {object} common.APIResponse "发送成功"
+// @Failure 400 {object} common.APIResponse "手机号格式不正确"
+// @Failure 429 {object} common.APIResponse "发送过于频繁或超过发送上限"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /auth/sms/send [post]
+func (ctrl *AuthController) SendSMSCode(c *gin.Context) {
+	var req services.SendSMSCodeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return
+	}
+
+	if err := ctrl.smsService.SendLoginCode(c.Request.Context(), req.Phone); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "验证码发送失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "验证码已发送", nil)
+}
+
+// SMSLogin 短信验证码登录
+// @Summary 短信验证码登录
+// @Description 使用手机号和短信验证码登录，手机号未注册时自动创建账号。验证码仅能使用一次。启用两步验证的用户返回mfa_required和mfa_token，需调用 /auth/mfa/verify 完成登录
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param request body services.SMSLoginRequest true "登录信息"
+// @Success 200 {object} common.APIResponse{data=services.SMSLoginResponse} "登录成功"
+// @Failure 400 {object} common.APIResponse "验证码错误或已过期"
+// @Failure 403 {object} common.APIResponse "用户已被禁用或已被临时锁定"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /auth/sms/login [post]
+func (ctrl *AuthController) SMSLogin(c *gin.Context) {
+	var req services.SMSLoginRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return
+	}
+
+	loginResp, err := ctrl.smsService.LoginWithCode(c.Request.Context(), &req)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "登录失败", err.Error())
+		return
+	}
+
+	// 需要两步验证时，完成验证后再更新登录信息
+	if loginResp.MFARequired {
+		common.SuccessResponse(c, "请完成两步验证", loginResp)
+		return
+	}
+
+	// 更新最后登录信息
+	clientIP := c.ClientIP()
+	go func() {
+		ctx := context.Background()
+		ctrl.userService.UpdateLastLogin(ctx, loginResp.User.ID, clientIP)
+	}()
+
+	common.SuccessResponse(c, "登录成功", loginResp)
+}
+
 // getValidationErrorMessage 获取验证错误消息
 func getValidationErrorMessage(fe validator.FieldError) string {
 	switch fe.Tag() {
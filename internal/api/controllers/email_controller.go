@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// EmailController 邮箱验证与找回密码控制器
+type EmailController struct {
+	emailService services.EmailService
+}
+
+// NewEmailController 创建邮箱验证与找回密码控制器
+func NewEmailController() *EmailController {
+	return &EmailController{
+		emailService: services.NewEmailService(),
+	}
+}
+
+// ForgotPassword 找回密码
+// @Summary 找回密码
+// @Description 向注册邮箱发送重置密码链接。为避免泄露邮箱是否注册，邮箱未注册时同样返回成功
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param request body services.ForgotPasswordRequest true "注册邮箱"
+// @Success 200 {object} common.APIResponse "发送成功"
+// @Failure 400 {object} common.APIResponse "参数验证失败"
+// @Failure 429 {object} common.APIResponse "发送过于频繁"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /auth/password/forgot [post]
+func (ctrl *EmailController) ForgotPassword(c *gin.Context) {
+	var req services.ForgotPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return
+	}
+
+	if err := ctrl.emailService.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "发送重置邮件失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "如果该邮箱已注册，重置密码邮件已发送", nil)
+}
+
+// ResetPassword 重置密码
+// @Summary 重置密码
+// @Description 使用重置密码邮件中的令牌设置新密码，令牌仅能使用一次。重置成功后该账号的全部登录会话失效
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param request body services.ResetPasswordRequest true "重置密码信息"
+// @Success 200 {object} common.APIResponse "重置成功"
+// @Failure 400 {object} common.APIResponse "链接无效或已过期、不符合密码策略或与最近使用过的密码相同"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /auth/password/reset [post]
+func (ctrl *EmailController) ResetPassword(c *gin.Context) {
+	var req services.ResetPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return
+	}
+
+	if err := ctrl.emailService.ResetPassword(c.Request.Context(), &req); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "重置密码失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "密码重置成功，请重新登录", nil)
+}
+
+// VerifyEmail 验证邮箱
+// @Summary 验证邮箱
+// @Description 邮箱验证邮件中的链接地址，验证成功后记录邮箱验证时间
+// @Tags 认证管理
+// @Produce json
+// @Param token query string true "验证令牌"
+// @Success 200 {object} common.APIResponse "验证成功"
+// @Failure 400 {object} common.APIResponse "链接无效或已过期"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /auth/email/verify [get]
+func (ctrl *EmailController) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		common.ErrorResponse(c, http.StatusBadRequest, "token是必填字段", nil)
+		return
+	}
+
+	if err := ctrl.emailService.VerifyEmail(c.Request.Context(), token); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "邮箱验证失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "邮箱验证成功", nil)
+}
+
+// SendVerificationEmail 发送邮箱验证邮件
+// @Summary 发送邮箱验证邮件
+// @Description 向当前用户的邮箱重新发送验证邮件
+// @Tags 会员管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse "发送成功"
+// @Failure 400 {object} common.APIResponse "邮箱已验证"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 429 {object} common.APIResponse "发送过于频繁"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/email/verification [post]
+func (ctrl *EmailController) SendVerificationEmail(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	if err := ctrl.emailService.SendVerificationEmail(c.Request.Context(), userID); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "发送验证邮件失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "验证邮件已发送", nil)
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LedgerController 资金账本控制器
+type LedgerController struct {
+	ledgerService services.LedgerService
+}
+
+// NewLedgerController 创建资金账本控制器
+func NewLedgerController() *LedgerController {
+	return &LedgerController{
+		ledgerService: services.NewLedgerService(),
+	}
+}
+
+// GetTrialBalance 获取试算平衡表
+// @Summary 获取试算平衡表
+// @Description 汇总当前租户各账户的借贷发生额及余额，校验借贷平衡，并逐个核对会员钱包账户余额与会员余额。需要ledger:read权限
+// @Tags 资金账本
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse{data=services.TrialBalance} "获取成功"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/ledger/trial-balance [get]
+func (ctrl *LedgerController) GetTrialBalance(c *gin.Context) {
+	report, err := ctrl.ledgerService.GetTrialBalance(c.Request.Context())
+	if err != nil {
+		common.ErrorResponse(c, http.StatusInternalServerError, "生成试算平衡表失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", report)
+}
+
+// ListJournalEntries 获取分录列表
+// @Summary 获取分录列表
+// @Description 分页获取当前租户的会计分录及明细，可按会员、业务类型及订单号筛选。需要ledger:read权限
+// @Tags 资金账本
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "页码，从1开始" default(1) minimum(1)
+// @Param page_size query int false "每页数量，最大100" default(10) minimum(1) maximum(100)
+// @Param user_id query int false "会员ID"
+// @Param type query string false "业务类型：recharge、consume、refund、reward、deduct、opening-期初建账、merge-账号合并"
+// @Param order_no query string false "关联订单号"
+// @Success 200 {object} common.APIResponse{data=common.PaginateResult{list=[]models.JournalEntry}} "获取成功"
+// @Failure 400 {object} common.APIResponse "请求参数错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/ledger/entries [get]
+func (ctrl *LedgerController) ListJournalEntries(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	req := &services.ListJournalEntriesRequest{
+		PageRequest: *common.NewPageRequest(page, pageSize),
+		Type:        c.Query("type"),
+		OrderNo:     c.Query("order_no"),
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.ParseUint(userID, 10, 64)
+		if err != nil {
+			common.ErrorResponse(c, http.StatusBadRequest, "会员ID格式错误", nil)
+			return
+		}
+		req.UserID = id
+	}
+
+	result, err := ctrl.ledgerService.ListJournalEntries(c.Request.Context(), req)
+	if err != nil {
+		common.ErrorResponse(c, http.StatusInternalServerError, "获取分录失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", result)
+}
+
+// PostOpeningBalances 期初建账
+// @Summary 期初建账
+// @Description 余额变动及账号合并时会自动为尚未建账的会员写入期初分录；本接口为上线账本前已有余额、之后没有余额变动的会员按当前余额写入期初分录（借记期初余额、贷记会员钱包），已建账的会员跳过，可重复执行。需要balance:write权限
+// @Tags 资金账本
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse "建账完成，返回建账的会员数"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/ledger/opening-balances [post]
+func (ctrl *LedgerController) PostOpeningBalances(c *gin.Context) {
+	posted, err := ctrl.ledgerService.PostOpeningBalances(c.Request.Context())
+	if err != nil {
+		common.ErrorResponse(c, http.StatusInternalServerError, "期初建账失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "建账完成", gin.H{"posted": posted})
+}
@@ -0,0 +1,503 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// MemberController 会员管理控制器（运营人员使用）
+type MemberController struct {
+	userService   services.UserService
+	importService services.MemberImportService
+}
+
+// NewMemberController 创建会员管理控制器
+func NewMemberController() *MemberController {
+	return &MemberController{
+		userService:   services.NewUserService(),
+		importService: services.NewMemberImportService(),
+	}
+}
+
+// List 获取会员列表
+// @Summary 获取会员列表
+// @Description 分页获取会员列表，支持按用户名、手机号、邮箱、昵称搜索，按状态、租户、注册时间及最后登录时间筛选。需要member:read权限
+// @Tags 会员管理
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "页码，从1开始" default(1) minimum(1)
+// @Param page_size query int false "每页数量，最大100" default(10) minimum(1) maximum(100)
+// @Param keyword query string false "关键字"
+// @Param status query int false "状态：0-待审核，1-正常，2-禁用，3-锁定" Enums(0,1,2,3)
+// @Param registered_from query string false "注册时间起" format(date-time)
+// @Param registered_to query string false "注册时间止" format(date-time)
+// @Param last_login_from query string false "最后登录时间起" format(date-time)
+// @Param last_login_to query string false "最后登录时间止" format(date-time)
+// @Success 200 {object} common.APIResponse{data=common.PaginateResult{list=[]models.User}} "获取成功"
+// @Failure 400 {object} common.APIResponse "参数错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /members [get]
+func (ctrl *MemberController) List(c *gin.Context) {
+	req, ok := bindListMembersQuery(c)
+	if !ok {
+		return
+	}
+
+	result, err := ctrl.userService.ListMembers(c.Request.Context(), req)
+	if err != nil {
+		memberErrorResponse(c, err, "获取会员列表失败")
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", result)
+}
+
+// Get 获取会员详情
+// @Summary 获取会员详情
+// @Description 获取会员信息及资产汇总（当前余额、积分及累计增减）。需要member:read权限
+// @Tags 会员管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "会员ID"
+// @Success 200 {object} common.APIResponse{data=services.MemberDetail} "获取成功"
+// @Failure 400 {object} common.APIResponse "会员ID格式错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "用户不存在"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /members/{id} [get]
+func (ctrl *MemberController) Get(c *gin.Context) {
+	id, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	detail, err := ctrl.userService.GetMemberDetail(c.Request.Context(), id)
+	if err != nil {
+		memberErrorResponse(c, err, "获取会员详情失败")
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", detail)
+}
+
+// Create 创建会员
+// @Summary 创建会员
+// @Description 运营人员创建会员，校验规则与注册相同，密码需满足租户密码策略，可指定初始状态。需要member:write权限
+// @Tags 会员管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.CreateMemberRequest true "会员信息"
+// @Success 200 {object} common.APIResponse{data=models.User} "创建成功"
+// @Failure 400 {object} common.APIResponse "参数验证失败"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 409 {object} common.APIResponse "用户名、手机号或邮箱已存在"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /members [post]
+func (ctrl *MemberController) Create(c *gin.Context) {
+	operatorID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	var req services.CreateMemberRequest
+	if !bindMemberRequest(c, &req) {
+		return
+	}
+
+	user, err := ctrl.userService.CreateMember(c.Request.Context(), &req, operatorID)
+	if err != nil {
+		memberErrorResponse(c, err, "创建会员失败")
+		return
+	}
+
+	common.SuccessResponse(c, "创建成功", user)
+}
+
+// Update 修改会员信息
+// @Summary 修改会员信息
+// @Description 修改会员昵称、手机号、邮箱及头像，邮箱变更后需重新验证。需要member:write权限
+// @Tags 会员管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "会员ID"
+// @Param request body services.UpdateProfileRequest true "会员信息"
+// @Success 200 {object} common.APIResponse{data=models.User} "更新成功"
+// @Failure 400 {object} common.APIResponse "参数验证失败"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "用户不存在"
+// @Failure 409 {object} common.APIResponse "手机号或邮箱已存在"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /members/{id} [put]
+func (ctrl *MemberController) Update(c *gin.Context) {
+	id, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateProfileRequest
+	if !bindMemberRequest(c, &req) {
+		return
+	}
+
+	user, err := ctrl.userService.UpdateMember(c.Request.Context(), id, &req)
+	if err != nil {
+		memberErrorResponse(c, err, "更新会员信息失败")
+		return
+	}
+
+	common.SuccessResponse(c, "更新成功", user)
+}
+
+// Delete 删除会员
+// @Summary 删除会员
+// @Description 软删除会员并注销其全部登录会话，余额、积分变动记录保留；已删除会员的用户名、手机号、邮箱不能再注册。需要member:write权限
+// @Tags 会员管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "会员ID"
+// @Success 200 {object} common.APIResponse "删除成功"
+// @Failure 400 {object} common.APIResponse "会员ID格式错误或不能删除自己的账号"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "用户不存在"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /members/{id} [delete]
+func (ctrl *MemberController) Delete(c *gin.Context) {
+	operatorID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	id, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.userService.DeleteMember(c.Request.Context(), id, operatorID); err != nil {
+		memberErrorResponse(c, err, "删除会员失败")
+		return
+	}
+
+	common.SuccessResponse(c, "删除成功", nil)
+}
+
+// UpdateStatus 修改会员状态
+// @Summary 修改会员状态
+// @Description 修改会员状态：0-待审核，1-正常，2-禁用，3-锁定。锁定不设到期时间，需手动恢复为正常；禁用或锁定后会员的全部登录会话失效。需要member:write权限
+// @Tags 会员管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "会员ID"
+// @Param request body services.UpdateMemberStatusRequest true "状态信息"
+// @Success 200 {object} common.APIResponse "修改成功"
+// @Failure 400 {object} common.APIResponse "参数验证失败或不能修改自己的账号状态"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "用户不存在"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /members/{id}/status [put]
+func (ctrl *MemberController) UpdateStatus(c *gin.Context) {
+	operatorID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	id, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateMemberStatusRequest
+	if !bindMemberRequest(c, &req) {
+		return
+	}
+
+	if err := ctrl.userService.UpdateMemberStatus(c.Request.Context(), id, &req, operatorID); err != nil {
+		memberErrorResponse(c, err, "修改会员状态失败")
+		return
+	}
+
+	common.SuccessResponse(c, "修改成功", nil)
+}
+
+// Statistics 获取会员统计
+// @Summary 获取会员统计
+// @Description 获取当前租户的会员总数、各状态数量、今日注册及近7天登录人数。需要member:read权限
+// @Tags 会员管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse{data=services.MemberStatistics} "获取成功"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /members/statistics [get]
+func (ctrl *MemberController) Statistics(c *gin.Context) {
+	stats, err := ctrl.userService.GetMemberStatistics(c.Request.Context())
+	if err != nil {
+		memberErrorResponse(c, err, "获取会员统计失败")
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", stats)
+}
+
+// Export 导出会员
+// @Summary 导出会员
+// @Description 按会员列表的筛选条件导出全部匹配的会员，支持CSV及XLSX格式，导出的文件可直接用于批量导入。需要member:read权限
+// @Tags 会员管理
+// @Produce text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security BearerAuth
+// @Param format query string false "文件格式" Enums(csv,xlsx) default(csv)
+// @Param keyword query string false "关键字"
+// @Param status query int false "状态：0-待审核，1-正常，2-禁用，3-锁定" Enums(0,1,2,3)
+// @Param registered_from query string false "注册时间起" format(date-time)
+// @Param registered_to query string false "注册时间止" format(date-time)
+// @Param last_login_from query string false "最后登录时间起" format(date-time)
+// @Param last_login_to query string false "最后登录时间止" format(date-time)
+// @Success 200 {file} file "导出文件"
+// @Failure 400 {object} common.APIResponse "参数错误或不支持的文件格式"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /members/export [get]
+func (ctrl *MemberController) Export(c *gin.Context) {
+	req, ok := bindListMembersQuery(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", services.MemberFileFormatCSV)
+	writeMemberFile(c, "members", format, "导出会员失败", func(w io.Writer) error {
+		return ctrl.userService.ExportMembers(c.Request.Context(), req, format, w)
+	})
+}
+
+// Import 批量导入会员
+// @Summary 批量导入会员
+// @Description 上传CSV或XLSX文件批量导入会员，导入在后台执行，通过导入任务查询进度。第一行为表头，必填列：username（用户名）、phone（手机号）、email（邮箱），可选列：nickname（昵称）、password（密码，可为旧系统bcrypt或MD5加盐哈希，为空时需通过找回密码或验证码登录）、status（状态）、balance（余额，元）、points（积分）。余额、积分不为0时写入期初变动记录。dry_run为true时仅校验不写入。需要member:write权限
+// @Tags 会员管理
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "导入文件（.csv或.xlsx）"
+// @Param dry_run formData bool false "仅校验不写入"
+// @Success 200 {object} common.APIResponse{data=models.MemberImport} "已创建导入任务"
+// @Failure 400 {object} common.APIResponse "文件为空、过大或格式不支持"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /members/imports [post]
+func (ctrl *MemberController) Import(c *gin.Context) {
+	operatorID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		common.ErrorResponse(c, http.StatusBadRequest, "请选择要导入的文件", nil)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.DefaultPostForm("dry_run", "false"))
+
+	file, err := header.Open()
+	if err != nil {
+		memberErrorResponse(c, common.ErrOpenFileFailed, "读取导入文件失败")
+		return
+	}
+	defer file.Close()
+
+	task, err := ctrl.importService.CreateImport(c.Request.Context(), header.Filename, file, dryRun, operatorID)
+	if err != nil {
+		memberErrorResponse(c, err, "创建导入任务失败")
+		return
+	}
+
+	common.SuccessResponse(c, "已创建导入任务", task)
+}
+
+// ListImports 获取导入任务列表
+// @Summary 获取导入任务列表
+// @Description 分页获取当前租户的会员导入任务。需要member:read权限
+// @Tags 会员管理
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "页码，从1开始" default(1) minimum(1)
+// @Param page_size query int false "每页数量，最大100" default(10) minimum(1) maximum(100)
+// @Success 200 {object} common.APIResponse{data=common.PaginateResult{list=[]models.MemberImport}} "获取成功"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /members/imports [get]
+func (ctrl *MemberController) ListImports(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	result, err := ctrl.importService.ListImports(c.Request.Context(), common.NewPageRequest(page, pageSize))
+	if err != nil {
+		memberErrorResponse(c, err, "获取导入任务失败")
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", result)
+}
+
+// GetImport 获取导入任务
+// @Summary 获取导入任务
+// @Description 获取导入任务的状态及成功、失败行数，状态：pending-等待处理，processing-处理中，completed-已完成，failed-失败。需要member:read权限
+// @Tags 会员管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "导入任务ID"
+// @Success 200 {object} common.APIResponse{data=models.MemberImport} "获取成功"
+// @Failure 400 {object} common.APIResponse "导入任务ID格式错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "导入任务不存在"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /members/imports/{id} [get]
+func (ctrl *MemberController) GetImport(c *gin.Context) {
+	importID, ok := parseIDParam(c, "导入任务ID格式错误")
+	if !ok {
+		return
+	}
+
+	task, err := ctrl.importService.GetImport(c.Request.Context(), importID)
+	if err != nil {
+		memberErrorResponse(c, err, "获取导入任务失败")
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", task)
+}
+
+// DownloadImportReport 下载导入错误报告
+// @Summary 下载导入错误报告
+// @Description 下载导入失败行的CSV报告，包含行号、用户名及错误原因。需要member:read权限
+// @Tags 会员管理
+// @Produce text/csv
+// @Security BearerAuth
+// @Param id path int true "导入任务ID"
+// @Success 200 {file} file "CSV文件"
+// @Failure 400 {object} common.APIResponse "导入任务ID格式错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "导入任务不存在或没有错误报告"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /members/imports/{id}/report [get]
+func (ctrl *MemberController) DownloadImportReport(c *gin.Context) {
+	importID, ok := parseIDParam(c, "导入任务ID格式错误")
+	if !ok {
+		return
+	}
+
+	reader, task, err := ctrl.importService.OpenImportReport(c.Request.Context(), importID)
+	if err != nil {
+		memberErrorResponse(c, err, "下载错误报告失败")
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, -1, "text/csv; charset=utf-8", reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="import-%d-report.csv"`, task.ID),
+	})
+}
+
+// bindListMembersQuery 解析会员列表查询参数，会员列表及导出共用
+func bindListMembersQuery(c *gin.Context) (*services.ListMembersRequest, bool) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	req := &services.ListMembersRequest{
+		PageRequest:    *common.NewPageRequest(page, pageSize),
+		Keyword:        c.Query("keyword"),
+		RegisteredFrom: c.Query("registered_from"),
+		RegisteredTo:   c.Query("registered_to"),
+		LastLoginFrom:  c.Query("last_login_from"),
+		LastLoginTo:    c.Query("last_login_to"),
+	}
+	if statusStr := c.Query("status"); statusStr != "" {
+		status, err := strconv.ParseInt(statusStr, 10, 8)
+		if err != nil {
+			common.ErrorResponse(c, http.StatusBadRequest, "状态格式错误", nil)
+			return nil, false
+		}
+		s := int8(status)
+		req.Status = &s
+	}
+	return req, true
+}
+
+// writeMemberFile 以附件形式返回导出文件
+// 写入文件内容前出错时返回错误响应，写入过程中出错只能记录日志
+func writeMemberFile(c *gin.Context, name, format, message string, write func(w io.Writer) error) {
+	if err := services.ValidateMemberFileFormat(format); err != nil {
+		memberErrorResponse(c, err, message)
+		return
+	}
+
+	c.Header("Content-Type", services.MemberFileContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, name, time.Now().Format("20060102150405"), format))
+
+	if err := write(c.Writer); err != nil {
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Disposition")
+			c.Writer.Header().Del("Content-Type")
+			memberErrorResponse(c, err, message)
+			return
+		}
+		logger.WithFields(logrus.Fields{
+			"path":  c.Request.URL.Path,
+			"error": err.Error(),
+		}).Error(message)
+	}
+}
+
+// bindMemberRequest 绑定会员管理请求参数
+func bindMemberRequest(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return false
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return false
+	}
+	return true
+}
+
+// memberErrorResponse 返回会员管理错误响应
+func memberErrorResponse(c *gin.Context, err error, message string) {
+	if customErr, ok := err.(*common.CustomError); ok {
+		common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+		return
+	}
+	common.ErrorResponse(c, http.StatusInternalServerError, message, err.Error())
+}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// MFAController 两步验证控制器
+type MFAController struct {
+	mfaService services.MFAService
+}
+
+// NewMFAController 创建两步验证控制器
+func NewMFAController() *MFAController {
+	return &MFAController{
+		mfaService: services.NewMFAService(),
+	}
+}
+
+// GetStatus 获取两步验证状态
+// @Summary 获取两步验证状态
+// @Description 获取当前用户是否已启用两步验证及剩余可用恢复码数量
+// @Tags 会员管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse{data=services.MFAStatus} "获取成功"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/mfa [get]
+func (ctrl *MFAController) GetStatus(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	status, err := ctrl.mfaService.GetStatus(c.Request.Context(), userID)
+	if err != nil {
+		common.ErrorResponse(c, http.StatusInternalServerError, "获取两步验证状态失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", status)
+}
+
+// Setup 开始绑定两步验证
+// @Summary 开始绑定两步验证
+// @Description 生成TOTP密钥和otpauth://地址，客户端将地址展示为二维码供身份验证器App扫描。需调用确认接口后才会启用
+// @Tags 会员管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse{data=services.MFASetupResponse} "获取成功"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 409 {object} common.APIResponse "两步验证已启用"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/mfa/setup [post]
+func (ctrl *MFAController) Setup(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	resp, err := ctrl.mfaService.Setup(c.Request.Context(), userID)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "生成两步验证密钥失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", resp)
+}
+
+// Enable 确认启用两步验证
+// @Summary 确认启用两步验证
+// @Description 输入身份验证器App中的动态口令确认绑定，启用成功后返回恢复码，恢复码仅显示一次
+// @Tags 会员管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.MFACodeRequest true "动态口令"
+// @Success 200 {object} common.APIResponse{data=services.RecoveryCodesResponse} "启用成功"
+// @Failure 400 {object} common.APIResponse "验证码错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 409 {object} common.APIResponse "两步验证已启用"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/mfa/enable [post]
+func (ctrl *MFAController) Enable(c *gin.Context) {
+	userID, req, ok := ctrl.bindCodeRequest(c)
+	if !ok {
+		return
+	}
+
+	resp, err := ctrl.mfaService.Enable(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "启用两步验证失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "两步验证已启用", resp)
+}
+
+// Disable 关闭两步验证
+// @Summary 关闭两步验证
+// @Description 使用动态口令或恢复码关闭两步验证，同时删除全部恢复码
+// @Tags 会员管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.MFACodeRequest true "动态口令或恢复码"
+// @Success 200 {object} common.APIResponse "关闭成功"
+// @Failure 400 {object} common.APIResponse "验证码错误或未启用两步验证"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/mfa/disable [post]
+func (ctrl *MFAController) Disable(c *gin.Context) {
+	userID, req, ok := ctrl.bindCodeRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.mfaService.Disable(c.Request.Context(), userID, req.Code); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "关闭两步验证失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "两步验证已关闭", nil)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+// @Summary 重新生成恢复码
+// @Description 使用动态口令重新生成恢复码，旧恢复码全部失效
+// @Tags 会员管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.MFACodeRequest true "动态口令"
+// @Success 200 {object} common.APIResponse{data=services.RecoveryCodesResponse} "生成成功"
+// @Failure 400 {object} common.APIResponse "验证码错误或未启用两步验证"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/mfa/recovery-codes [post]
+func (ctrl *MFAController) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, req, ok := ctrl.bindCodeRequest(c)
+	if !ok {
+		return
+	}
+
+	resp, err := ctrl.mfaService.RegenerateRecoveryCodes(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "生成恢复码失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "生成成功", resp)
+}
+
+// bindCodeRequest 获取当前用户并绑定验证码请求
+func (ctrl *MFAController) bindCodeRequest(c *gin.Context) (uint64, *services.MFACodeRequest, bool) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return 0, nil, false
+	}
+
+	var req services.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return 0, nil, false
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return 0, nil, false
+	}
+
+	return userID, &req, true
+}
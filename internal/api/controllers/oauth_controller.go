@@ -0,0 +1,341 @@
+package controllers
+
+import (
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// OAuthController OAuth2/OIDC授权控制器
+type OAuthController struct {
+	oauthService services.OAuthService
+}
+
+// NewOAuthController 创建OAuth2/OIDC授权控制器
+func NewOAuthController() *OAuthController {
+	return &OAuthController{
+		oauthService: services.NewOAuthService(),
+	}
+}
+
+// PrepareAuthorization 获取授权确认信息
+// @Summary 获取授权确认信息
+// @Description 前端授权确认页携带第三方应用的授权请求参数调用，校验通过后返回应用信息及申请的授权范围。仅支持response_type=code且必须使用PKCE（S256）
+// @Tags OAuth授权
+// @Produce json
+// @Security BearerAuth
+// @Param response_type query string true "固定为code"
+// @Param client_id query string true "应用ID"
+// @Param redirect_uri query string true "回调地址，须与登记的完全一致"
+// @Param scope query string true "授权范围，空格分隔"
+// @Param state query string false "应用状态值，原样回传"
+// @Param nonce query string false "ID令牌防重放随机串"
+// @Param code_challenge query string true "PKCE挑战值"
+// @Param code_challenge_method query string true "固定为S256"
+// @Success 200 {object} common.APIResponse{data=services.AuthorizePrompt} "校验通过"
+// @Failure 400 {object} common.APIResponse "授权请求参数错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Router /oauth/authorize [get]
+func (ctrl *OAuthController) PrepareAuthorization(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	var req services.AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		common.ErrorResponse(c, http.StatusBadRequest, "授权请求参数不完整", nil)
+		return
+	}
+
+	prompt, err := ctrl.oauthService.PrepareAuthorization(c.Request.Context(), userID, &req)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "校验授权请求失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "校验通过", prompt)
+}
+
+// Authorize 确认授权
+// @Summary 确认授权
+// @Description 会员同意或拒绝第三方应用的授权请求，返回携带授权码（或access_denied错误）的回调地址，由前端完成跳转
+// @Tags OAuth授权
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.ConsentRequest true "授权请求参数及确认结果"
+// @Success 200 {object} common.APIResponse{data=services.AuthorizeResult} "授权完成"
+// @Failure 400 {object} common.APIResponse "授权请求参数错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /oauth/authorize [post]
+func (ctrl *OAuthController) Authorize(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	var req services.ConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return
+	}
+
+	// 以会员令牌的签发时间作为认证时间（ID令牌auth_time）
+	authTime := time.Now()
+	if claims, ok := c.Get("jwt_claims"); ok {
+		if jwtClaims, ok := claims.(*services.JWTClaims); ok && jwtClaims.IssuedAt != nil {
+			authTime = jwtClaims.IssuedAt.Time
+		}
+	}
+
+	result, err := ctrl.oauthService.Authorize(c.Request.Context(), userID, authTime, &req)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "授权失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "授权完成", result)
+}
+
+// Token 令牌端点
+// @Summary 令牌端点
+// @Description 标准OAuth2令牌端点。支持authorization_code（需code_verifier）和refresh_token两种授权类型；机密客户端通过HTTP Basic或表单参数提供密钥。响应为标准OAuth2格式，不使用统一响应结构
+// @Tags OAuth授权
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "授权类型"
+// @Param code formData string false "授权码"
+// @Param redirect_uri formData string false "回调地址"
+// @Param code_verifier formData string false "PKCE校验值"
+// @Param refresh_token formData string false "刷新令牌"
+// @Param client_id formData string false "应用ID"
+// @Param client_secret formData string false "应用密钥"
+// @Success 200 {object} services.OAuthTokenResponse "令牌"
+// @Failure 400 {object} services.OAuthError "请求错误"
+// @Failure 401 {object} services.OAuthError "应用身份验证失败"
+// @Router /oauth/token [post]
+func (ctrl *OAuthController) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var req services.OAuthTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, &services.OAuthError{Code: "invalid_request"})
+		return
+	}
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		req.ClientID = clientID
+		req.ClientSecret = clientSecret
+	}
+
+	resp, err := ctrl.oauthService.Token(c.Request.Context(), &req)
+	if err != nil {
+		ctrl.oauthErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// UserInfo 获取用户信息
+// @Summary 获取用户信息
+// @Description OIDC用户信息端点，使用第三方应用的访问令牌调用，按授权范围返回会员信息。响应为标准OIDC格式
+// @Tags OAuth授权
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} services.UserInfoResponse "用户信息"
+// @Failure 401 {object} services.OAuthError "访问令牌无效"
+// @Failure 403 {object} services.OAuthError "授权范围不足"
+// @Router /userinfo [get]
+func (ctrl *OAuthController) UserInfo(c *gin.Context) {
+	authHeader := c.GetHeader("Authorization")
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, &services.OAuthError{Code: "invalid_token"})
+		return
+	}
+
+	info, err := ctrl.oauthService.UserInfo(c.Request.Context(), parts[1])
+	if err != nil {
+		if oauthErr, ok := err.(*services.OAuthError); ok {
+			c.Header("WWW-Authenticate", `Bearer error="`+oauthErr.Code+`"`)
+		}
+		ctrl.oauthErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// Discovery 获取OIDC发现文档
+// @Summary 获取OIDC发现文档
+// @Description 发布授权端点、令牌端点、公钥地址及支持的能力，供第三方应用自动配置。未配置OIDC签名密钥时返回404
+// @Tags OAuth授权
+// @Produce json
+// @Success 200 {object} services.OIDCDiscovery "发现文档"
+// @Router /.well-known/openid-configuration [get]
+func (ctrl *OAuthController) Discovery(c *gin.Context) {
+	discovery := ctrl.oauthService.Discovery()
+	if discovery == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用OIDC"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, discovery)
+}
+
+// JWKS 获取ID令牌验证公钥
+// @Summary 获取ID令牌验证公钥
+// @Description 以JWKS格式发布OIDC ID令牌的签名公钥（含轮换期间仍有效的历史公钥），与会员令牌的签名密钥相互独立。未配置OIDC签名密钥时返回404
+// @Tags OAuth授权
+// @Produce json
+// @Success 200 {object} services.JWKSet "公钥集合"
+// @Router /.well-known/oidc-jwks.json [get]
+func (ctrl *OAuthController) JWKS(c *gin.Context) {
+	jwks := ctrl.oauthService.JWKS()
+	if jwks == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用OIDC"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, jwks)
+}
+
+// CreateClient 登记第三方应用
+// @Summary 登记第三方应用
+// @Description 登记OAuth2/OIDC第三方应用，密钥仅在登记时返回一次；公开客户端不发放密钥。可选授权范围：openid（需启用OIDC）、profile、email、phone。需要client:manage权限
+// @Tags 权限管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.CreateOAuthClientRequest true "应用信息"
+// @Success 200 {object} common.APIResponse{data=services.OAuthClientCredentials} "登记成功"
+// @Failure 400 {object} common.APIResponse "请求参数错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/oauth-clients [post]
+func (ctrl *OAuthController) CreateClient(c *gin.Context) {
+	operatorID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	var req services.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return
+	}
+
+	credentials, err := ctrl.oauthService.CreateClient(c.Request.Context(), &req, operatorID)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "登记应用失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "登记成功", credentials)
+}
+
+// ListClients 获取第三方应用列表
+// @Summary 获取第三方应用列表
+// @Description 获取当前租户下的全部第三方应用（不含密钥）。需要client:manage权限
+// @Tags 权限管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse{data=[]models.OAuthClient} "获取成功"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/oauth-clients [get]
+func (ctrl *OAuthController) ListClients(c *gin.Context) {
+	clients, err := ctrl.oauthService.ListClients(c.Request.Context())
+	if err != nil {
+		common.ErrorResponse(c, http.StatusInternalServerError, "获取应用列表失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", clients)
+}
+
+// DisableClient 停用第三方应用
+// @Summary 停用第三方应用
+// @Description 停用后该应用无法发起授权及换取令牌。需要client:manage权限
+// @Tags 权限管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "应用ID"
+// @Success 200 {object} common.APIResponse "停用成功"
+// @Failure 400 {object} common.APIResponse "应用ID格式错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "应用不存在"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/oauth-clients/{id}/disable [post]
+func (ctrl *OAuthController) DisableClient(c *gin.Context) {
+	clientID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.ErrorResponse(c, http.StatusBadRequest, "应用ID格式错误", nil)
+		return
+	}
+
+	if err := ctrl.oauthService.DisableClient(c.Request.Context(), clientID); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "停用应用失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "停用成功", nil)
+}
+
+// oauthErrorResponse 以OAuth2标准格式返回错误
+func (ctrl *OAuthController) oauthErrorResponse(c *gin.Context, err error) {
+	if oauthErr, ok := err.(*services.OAuthError); ok {
+		c.JSON(oauthErr.Status, oauthErr)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, &services.OAuthError{Code: "server_error"})
+}
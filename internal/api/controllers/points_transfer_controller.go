@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PointsTransferController 积分转账控制器
+type PointsTransferController struct {
+	transferService services.PointsTransferService
+}
+
+// NewPointsTransferController 创建积分转账控制器
+func NewPointsTransferController() *PointsTransferController {
+	return &PointsTransferController{
+		transferService: services.NewPointsTransferService(),
+	}
+}
+
+// Transfer 积分转账
+// @Summary 积分转账
+// @Description 当前会员向同一租户的其他会员转账积分，转出与转入在同一事务中完成，双方各生成一条类型为transfer、通过transfer_id关联的积分记录。受每日转账次数及积分限额、积分持有时长限制，可配置按比例收取手续费（由转出会员额外支付，另记一条类型为transfer_fee的积分记录）。转出或收款会员未激活、被禁用或锁定时不能转账。提供幂等键时，相同幂等键及参数的重复请求返回首次的转账结果
+// @Tags 积分管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.TransferPointsRequest true "转账信息"
+// @Param Idempotency-Key header string false "幂等键，请求体未提供idempotency_key时使用"
+// @Success 200 {object} common.APIResponse{data=services.PointsTransferResult} "转账成功"
+// @Failure 400 {object} common.APIResponse "参数错误、积分不足、超过每日限额或收款会员未激活、被禁用"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "转出会员未激活、被禁用或锁定"
+// @Failure 404 {object} common.APIResponse "收款会员不存在"
+// @Failure 409 {object} common.APIResponse "幂等键已被参数不同的请求使用"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /points/transfer [post]
+func (ctrl *PointsTransferController) Transfer(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	var req services.TransferPointsRequest
+	if !bindMemberRequest(c, &req) || !bindIdempotencyKey(c, &req.IdempotencyKey) {
+		return
+	}
+
+	result, err := ctrl.transferService.Transfer(c.Request.Context(), userID, &req)
+	if err != nil {
+		memberErrorResponse(c, err, "积分转账失败")
+		return
+	}
+
+	common.SuccessResponse(c, "转账成功", result)
+}
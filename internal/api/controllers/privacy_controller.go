@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"fmt"
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrivacyController 个人数据导出及账号注销控制器
+type PrivacyController struct {
+	privacyService services.PrivacyService
+}
+
+// NewPrivacyController 创建个人数据导出及账号注销控制器
+func NewPrivacyController() *PrivacyController {
+	return &PrivacyController{
+		privacyService: services.NewPrivacyService(),
+	}
+}
+
+// RequestExport 申请导出个人数据
+// @Summary 申请导出个人数据
+// @Description 异步生成包含个人资料、余额及积分流水、上传文件信息及下载链接的ZIP文件，通过查询接口获取生成状态。已有生成中的任务时返回该任务
+// @Tags 用户管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse{data=models.DataExport} "已提交"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/export [post]
+func (ctrl *PrivacyController) RequestExport(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	export, err := ctrl.privacyService.RequestExport(c.Request.Context(), userID)
+	if err != nil {
+		common.ErrorResponse(c, http.StatusInternalServerError, "申请导出失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "已提交，请稍后查询导出结果", export)
+}
+
+// GetExport 查询个人数据导出结果
+// @Summary 查询个人数据导出结果
+// @Description 查询导出任务状态：pending-生成中，completed-已完成，failed-失败，expired-已过期。完成后在有效期内可下载
+// @Tags 用户管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "导出任务ID"
+// @Success 200 {object} common.APIResponse{data=models.DataExport} "获取成功"
+// @Failure 400 {object} common.APIResponse "参数错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 404 {object} common.APIResponse "导出记录不存在"
+// @Router /user/export/{id} [get]
+func (ctrl *PrivacyController) GetExport(c *gin.Context) {
+	userID, exportID, ok := exportParams(c)
+	if !ok {
+		return
+	}
+
+	export, err := ctrl.privacyService.GetExport(c.Request.Context(), userID, exportID)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "获取导出结果失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", export)
+}
+
+// DownloadExport 下载个人数据导出文件
+// @Summary 下载个人数据导出文件
+// @Description 下载已生成的个人数据ZIP文件
+// @Tags 用户管理
+// @Produce application/zip
+// @Security BearerAuth
+// @Param id path int true "导出任务ID"
+// @Success 200 {file} file "ZIP文件"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 404 {object} common.APIResponse "导出记录不存在"
+// @Failure 409 {object} common.APIResponse "导出文件尚未生成或已过期"
+// @Router /user/export/{id}/download [get]
+func (ctrl *PrivacyController) DownloadExport(c *gin.Context) {
+	userID, exportID, ok := exportParams(c)
+	if !ok {
+		return
+	}
+
+	reader, export, err := ctrl.privacyService.OpenExport(c.Request.Context(), userID, exportID)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "下载失败", err.Error())
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, export.Size, "application/zip", reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="member-data-%d.zip"`, export.ID),
+	})
+}
+
+// RequestDeletion 申请注销账号
+// @Summary 申请注销账号
+// @Description 申请注销当前账号，冷静期内可撤销。余额须为零且无冻结金额；冷静期结束时余额未清零则撤销申请。注销后清除个人信息、删除上传的文件及第三方账号绑定，剩余积分清零，余额及积分流水保留用于审计
+// @Tags 用户管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse{data=services.AccountDeletionInfo} "已申请注销"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 409 {object} common.APIResponse "账号已申请注销或余额未清零"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/account [delete]
+func (ctrl *PrivacyController) RequestDeletion(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	info, err := ctrl.privacyService.RequestDeletion(c.Request.Context(), userID)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "申请注销失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "已申请注销", info)
+}
+
+// CancelDeletion 撤销注销申请
+// @Summary 撤销注销申请
+// @Description 冷静期内撤销账号注销申请
+// @Tags 用户管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse "已撤销"
+// @Failure 400 {object} common.APIResponse "账号未申请注销"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/account/cancel-deletion [post]
+func (ctrl *PrivacyController) CancelDeletion(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	if err := ctrl.privacyService.CancelDeletion(c.Request.Context(), userID); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "撤销注销失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "已撤销", nil)
+}
+
+// exportParams 解析当前用户及导出任务ID
+func exportParams(c *gin.Context) (uint64, uint64, bool) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return 0, 0, false
+	}
+
+	exportID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.ErrorResponse(c, http.StatusBadRequest, "导出任务ID格式错误", nil)
+		return 0, 0, false
+	}
+	return userID, exportID, true
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// RBACController 角色权限控制器
+type RBACController struct {
+	rbacService services.RBACService
+}
+
+// NewRBACController 创建角色权限控制器
+func NewRBACController() *RBACController {
+	return &RBACController{
+		rbacService: services.NewRBACService(),
+	}
+}
+
+// ListRoles 获取角色列表
+// @Summary 获取角色列表
+// @Description 获取全部角色及其权限。需要role:manage权限
+// @Tags 权限管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse{data=[]models.Role} "获取成功"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/roles [get]
+func (ctrl *RBACController) ListRoles(c *gin.Context) {
+	roles, err := ctrl.rbacService.ListRoles(c.Request.Context())
+	if err != nil {
+		common.ErrorResponse(c, http.StatusInternalServerError, "获取角色列表失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", roles)
+}
+
+// GetUserRoles 获取用户角色
+// @Summary 获取用户角色
+// @Description 获取指定用户拥有的角色编码。需要role:manage权限
+// @Tags 权限管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} common.APIResponse{data=[]string} "获取成功"
+// @Failure 400 {object} common.APIResponse "用户ID格式错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/users/{id}/roles [get]
+func (ctrl *RBACController) GetUserRoles(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	roles, err := ctrl.rbacService.GetUserRoles(c.Request.Context(), userID)
+	if err != nil {
+		common.ErrorResponse(c, http.StatusInternalServerError, "获取用户角色失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", roles)
+}
+
+// GrantRole 授予角色
+// @Summary 授予角色
+// @Description 为指定用户授予角色，用户重新登录或刷新令牌后生效。需要role:manage权限
+// @Tags 权限管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "用户ID"
+// @Param request body services.GrantRoleRequest true "角色信息"
+// @Success 200 {object} common.APIResponse "授予成功"
+// @Failure 400 {object} common.APIResponse "请求参数错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "用户或角色不存在"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/users/{id}/roles [post]
+func (ctrl *RBACController) GrantRole(c *gin.Context) {
+	operatorID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req services.GrantRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return
+	}
+
+	if err := ctrl.rbacService.GrantRole(c.Request.Context(), userID, req.Role, operatorID); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "授予角色失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "授予成功", nil)
+}
+
+// RevokeRole 回收角色
+// @Summary 回收角色
+// @Description 回收指定用户的角色，同时注销该用户的全部登录会话使旧令牌立即失效。需要role:manage权限
+// @Tags 权限管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "用户ID"
+// @Param role path string true "角色编码"
+// @Success 200 {object} common.APIResponse "回收成功"
+// @Failure 400 {object} common.APIResponse "用户ID格式错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 403 {object} common.APIResponse "权限不足"
+// @Failure 404 {object} common.APIResponse "角色不存在"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /admin/users/{id}/roles/{role} [delete]
+func (ctrl *RBACController) RevokeRole(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.rbacService.RevokeRole(c.Request.Context(), userID, c.Param("role")); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "回收角色失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "回收成功", nil)
+}
+
+// parseUserIDParam 解析路径中的用户ID
+func parseUserIDParam(c *gin.Context) (uint64, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || userID == 0 {
+		common.ErrorResponse(c, http.StatusBadRequest, "用户ID格式错误", nil)
+		return 0, false
+	}
+	return userID, true
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionController 登录会话控制器
+type SessionController struct {
+	sessionService services.SessionService
+}
+
+// NewSessionController 创建登录会话控制器
+func NewSessionController() *SessionController {
+	return &SessionController{
+		sessionService: services.NewSessionService(),
+	}
+}
+
+// ListSessions 获取登录会话列表
+// @Summary 获取登录会话列表
+// @Description 获取当前用户所有有效的登录会话，包括设备、IP、登录时间和最后活跃时间，current标识当前会话
+// @Tags 会员管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse{data=[]services.SessionInfo} "获取成功"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/sessions [get]
+func (ctrl *SessionController) ListSessions(c *gin.Context) {
+	claims, exists := middleware.GetJWTClaims(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	sessions, err := ctrl.sessionService.ListSessions(c.Request.Context(), claims.UserID, claims.FamilyID)
+	if err != nil {
+		common.ErrorResponse(c, http.StatusInternalServerError, "获取会话列表失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", sessions)
+}
+
+// RevokeSession 注销指定会话
+// @Summary 注销指定会话
+// @Description 注销指定的登录会话，该会话的访问令牌和刷新令牌立即失效
+// @Tags 会员管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "会话ID"
+// @Success 200 {object} common.APIResponse "注销成功"
+// @Failure 400 {object} common.APIResponse "参数错误"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 404 {object} common.APIResponse "会话不存在"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/sessions/{id} [delete]
+func (ctrl *SessionController) RevokeSession(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.ErrorResponse(c, http.StatusBadRequest, "会话ID格式错误", nil)
+		return
+	}
+
+	if err := ctrl.sessionService.RevokeSession(c.Request.Context(), userID, sessionID); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "注销会话失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "注销成功", nil)
+}
+
+// RevokeOtherSessions 注销其他设备
+// @Summary 注销其他设备
+// @Description 注销除当前会话外的所有登录会话
+// @Tags 会员管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse{data=map[string]int64} "注销成功"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/sessions/logout-others [post]
+func (ctrl *SessionController) RevokeOtherSessions(c *gin.Context) {
+	claims, exists := middleware.GetJWTClaims(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	count, err := ctrl.sessionService.RevokeOtherSessions(c.Request.Context(), claims.UserID, claims.FamilyID)
+	if err != nil {
+		common.ErrorResponse(c, http.StatusInternalServerError, "注销其他设备失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "注销成功", map[string]int64{"revoked": count})
+}
@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"context"
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// SocialController 第三方账号登录控制器
+type SocialController struct {
+	socialService services.SocialLoginService
+	userService   services.UserService
+}
+
+// NewSocialController 创建第三方账号登录控制器
+func NewSocialController() *SocialController {
+	return &SocialController{
+		socialService: services.NewSocialLoginService(),
+		userService:   services.NewUserService(),
+	}
+}
+
+// Providers 获取可用的第三方登录方式
+// @Summary 获取可用的第三方登录方式
+// @Description 获取当前租户已启用的第三方登录方式，如wechat_mini、wechat_web、alipay及配置的通用OAuth2登录
+// @Tags 认证管理
+// @Produce json
+// @Param X-Tenant-ID header string false "租户ID" default(default)
+// @Success 200 {object} common.APIResponse{data=[]string} "获取成功"
+// @Router /auth/social/providers [get]
+func (ctrl *SocialController) Providers(c *gin.Context) {
+	common.SuccessResponse(c, "获取成功", ctrl.socialService.Providers(c.Request.Context()))
+}
+
+// AuthURL 获取第三方登录授权地址
+// @Summary 获取第三方登录授权地址
+// @Description 获取网页跳转授权地址及state，前端跳转到该地址，第三方回调后携带code和state调用登录接口
+// @Tags 认证管理
+// @Produce json
+// @Param provider path string true "登录方式"
+// @Param X-Tenant-ID header string false "租户ID" default(default)
+// @Success 200 {object} common.APIResponse{data=services.SocialAuthURLResponse} "获取成功"
+// @Failure 400 {object} common.APIResponse "不支持的登录方式"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /auth/social/{provider}/authorize [get]
+func (ctrl *SocialController) AuthURL(c *gin.Context) {
+	ctrl.authURL(c, 0)
+}
+
+// Login 第三方账号登录
+// @Summary 第三方账号登录
+// @Description 使用第三方授权码登录，未绑定的第三方账号自动注册新账号；提供方返回的UnionID与已绑定账号相同时登录到同一账号。启用两步验证的用户返回mfa_required和mfa_token，需调用 /auth/mfa/verify 完成登录
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param provider path string true "登录方式"
+// @Param X-Tenant-ID header string false "租户ID" default(default)
+// @Param request body services.SocialLoginRequest true "授权码及state"
+// @Success 200 {object} common.APIResponse{data=services.SocialLoginResponse} "登录成功"
+// @Failure 400 {object} common.APIResponse "不支持的登录方式或state无效"
+// @Failure 401 {object} common.APIResponse "第三方授权失败"
+// @Failure 403 {object} common.APIResponse "用户已被禁用或已被临时锁定"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /auth/social/{provider}/login [post]
+func (ctrl *SocialController) Login(c *gin.Context) {
+	req, ok := bindSocialLoginRequest(c)
+	if !ok {
+		return
+	}
+
+	loginResp, err := ctrl.socialService.Login(c.Request.Context(), c.Param("provider"), req)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "登录失败", err.Error())
+		return
+	}
+
+	// 需要两步验证时，完成验证后再更新登录信息
+	if loginResp.MFARequired {
+		common.SuccessResponse(c, "请完成两步验证", loginResp)
+		return
+	}
+
+	// 更新最后登录信息
+	clientIP := c.ClientIP()
+	go func() {
+		ctx := context.Background()
+		ctrl.userService.UpdateLastLogin(ctx, loginResp.User.ID, clientIP)
+	}()
+
+	common.SuccessResponse(c, "登录成功", loginResp)
+}
+
+// ListIdentities 获取已绑定的第三方账号
+// @Summary 获取已绑定的第三方账号
+// @Description 获取当前用户已绑定的第三方登录账号
+// @Tags 用户管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} common.APIResponse{data=[]models.UserIdentity} "获取成功"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/identities [get]
+func (ctrl *SocialController) ListIdentities(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	identities, err := ctrl.socialService.ListIdentities(c.Request.Context(), userID)
+	if err != nil {
+		common.ErrorResponse(c, http.StatusInternalServerError, "获取绑定账号失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", identities)
+}
+
+// BindAuthURL 获取绑定第三方账号的授权地址
+// @Summary 获取绑定第三方账号的授权地址
+// @Description 获取用于绑定的网页跳转授权地址，生成的state仅能由当前用户使用
+// @Tags 用户管理
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "登录方式"
+// @Success 200 {object} common.APIResponse{data=services.SocialAuthURLResponse} "获取成功"
+// @Failure 400 {object} common.APIResponse "不支持的登录方式"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Router /user/identities/{provider}/authorize [get]
+func (ctrl *SocialController) BindAuthURL(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	ctrl.authURL(c, userID)
+}
+
+// Bind 绑定第三方账号
+// @Summary 绑定第三方账号
+// @Description 使用第三方授权码将第三方账号绑定到当前用户，每种登录方式只能绑定一个账号
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "登录方式"
+// @Param request body services.SocialLoginRequest true "授权码及state"
+// @Success 200 {object} common.APIResponse{data=models.UserIdentity} "绑定成功"
+// @Failure 400 {object} common.APIResponse "不支持的登录方式或state无效"
+// @Failure 401 {object} common.APIResponse "未授权访问或第三方授权失败"
+// @Failure 409 {object} common.APIResponse "已绑定该登录方式或第三方账号已绑定其他用户"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/identities/{provider} [post]
+func (ctrl *SocialController) Bind(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	req, ok := bindSocialLoginRequest(c)
+	if !ok {
+		return
+	}
+
+	identity, err := ctrl.socialService.Bind(c.Request.Context(), userID, c.Param("provider"), req)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "绑定失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "绑定成功", identity)
+}
+
+// Unbind 解绑第三方账号
+// @Summary 解绑第三方账号
+// @Description 解绑当前用户的第三方账号。唯一的登录方式在未绑定手机号且未验证邮箱时不能解绑
+// @Tags 用户管理
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "登录方式"
+// @Success 200 {object} common.APIResponse "解绑成功"
+// @Failure 400 {object} common.APIResponse "唯一的登录方式不能解绑"
+// @Failure 401 {object} common.APIResponse "未授权访问"
+// @Failure 404 {object} common.APIResponse "未绑定该登录方式"
+// @Failure 500 {object} common.APIResponse "服务器内部错误"
+// @Router /user/identities/{provider} [delete]
+func (ctrl *SocialController) Unbind(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+		return
+	}
+
+	if err := ctrl.socialService.Unbind(c.Request.Context(), userID, c.Param("provider")); err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "解绑失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "解绑成功", nil)
+}
+
+// authURL 生成授权地址，userID为0时用于登录
+func (ctrl *SocialController) authURL(c *gin.Context, userID uint64) {
+	resp, err := ctrl.socialService.AuthURL(c.Request.Context(), c.Param("provider"), userID)
+	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			return
+		}
+		common.ErrorResponse(c, http.StatusInternalServerError, "获取授权地址失败", err.Error())
+		return
+	}
+
+	common.SuccessResponse(c, "获取成功", resp)
+}
+
+// bindSocialLoginRequest 绑定第三方登录请求参数
+func bindSocialLoginRequest(c *gin.Context) (*services.SocialLoginRequest, bool) {
+	var req services.SocialLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := common.NewValidationErrors()
+			for _, fieldError := range validationErrors {
+				errors.Add(fieldError.Field(), getValidationErrorMessage(fieldError))
+			}
+			common.ErrorResponse(c, http.StatusBadRequest, "参数验证失败", errors.Errors)
+			return nil, false
+		}
+		common.ErrorResponse(c, http.StatusBadRequest, "请求参数格式错误", nil)
+		return nil, false
+	}
+	return &req, true
+}
@@ -112,14 +112,14 @@ func (ctrl *UserController) UpdateProfile(c *gin.Context) {
 
 // ChangePassword 修改密码
 // @Summary 修改密码
-// @Description 修改用户登录密码，需要验证旧密码，新密码需要满足强度要求
+// @Description 修改用户登录密码，需要验证旧密码，新密码需要满足租户密码策略且不能与最近使用过的密码相同
 // @Tags 会员管理
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param request body services.ChangePasswordRequest true "密码信息"
 // @Success 200 {object} common.APIResponse "修改成功"
-// @Failure 400 {object} common.APIResponse "参数验证失败或旧密码错误"
+// @Failure 400 {object} common.APIResponse "参数验证失败、旧密码错误、不符合密码策略或与最近使用过的密码相同"
 // @Failure 401 {object} common.APIResponse "未授权访问"
 // @Failure 404 {object} common.APIResponse "用户不存在"
 // @Failure 500 {object} common.APIResponse "服务器内部错误"
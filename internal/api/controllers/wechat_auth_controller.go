@@ -1,7 +1,6 @@
 package controllers
 
 import (
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"member-link-lite/internal/api/middleware"
 	"member-link-lite/internal/models"
@@ -12,16 +11,14 @@ import (
 // WeChatAuthController 微信授权登录控制器
 type WeChatAuthController struct {
 	wechatService *services.WeChatAuthService
-	userService   services.UserService
-	jwtService    services.JWTService
+	socialService services.SocialLoginService
 }
 
 // NewWeChatAuthController 创建微信授权登录控制器
 func NewWeChatAuthController() *WeChatAuthController {
 	return &WeChatAuthController{
 		wechatService: services.NewWeChatAuthService(),
-		userService:   services.NewUserService(),
-		jwtService:    services.NewJWTService(),
+		socialService: services.NewSocialLoginService(),
 	}
 }
 
@@ -54,32 +51,8 @@ func (c *WeChatAuthController) HandleMiniProgramLogin(ctx *gin.Context) {
 		return
 	}
 
-	// 查找或创建用户
-	user, isNewUser, err := c.findOrCreateUser(ctx, wechatUserInfo, tenantID)
-	if err != nil {
-		common.ServerError(ctx, "用户处理失败: "+err.Error())
-		return
-	}
-
-	// 生成JWT令牌
-	tokenResponse, err := services.GenerateTokenPair(c.jwtService, user.ID, user.Username)
-	if err != nil {
-		common.ServerError(ctx, "令牌生成失败: "+err.Error())
-		return
-	}
-
-	response := WeChatLoginResponse{
-		Tokens: &TokenInfo{
-			AccessToken:  tokenResponse.AccessToken,
-			RefreshToken: tokenResponse.RefreshToken,
-			ExpiresIn:    tokenResponse.ExpiresIn,
-		},
-		User:       user,
-		IsNewUser:  isNewUser,
-		WeChatInfo: wechatUserInfo,
-	}
-
-	common.SuccessWithMessage(ctx, "登录成功", response)
+	// 通过绑定的第三方身份查找或创建用户并签发令牌
+	c.loginWithIdentity(ctx, wechatUserInfo)
 }
 
 // GetPhoneNumber 获取微信手机号
@@ -120,7 +93,7 @@ func (c *WeChatAuthController) GetPhoneNumber(ctx *gin.Context) {
 
 // HandleMiniProgramLoginWithPhone 处理微信小程序登录（包含手机号）
 // @Summary 处理微信小程序登录（包含手机号）
-// @Description 处理微信小程序登录，同时获取手机号，避免双账号问题
+// @Description 处理微信小程序登录，同时获取手机号，避免双账号问题。手机号已属于其他账号时，将微信账号合并到该账号后登录
 // @Tags 微信授权
 // @Accept json
 // @Produce json
@@ -164,185 +137,54 @@ func (c *WeChatAuthController) HandleMiniProgramLoginWithPhone(ctx *gin.Context)
 	// 3. 设置手机号到用户信息中
 	wechatUserInfo.Phone = phoneNumber
 
-	// 4. 查找或创建用户（优先通过手机号查找）
-	user, isNewUser, err := c.findOrCreateUserWithPhone(ctx, wechatUserInfo, tenantID)
+	// 4. 查找或创建用户（优先通过手机号查找）并签发令牌
+	c.loginWithIdentity(ctx, wechatUserInfo)
+}
+
+// loginWithIdentity 使用微信身份登录并返回令牌
+func (c *WeChatAuthController) loginWithIdentity(ctx *gin.Context, wechatUserInfo *services.WeChatUserInfo) {
+	result, err := c.socialService.LoginWithIdentity(ctx.Request.Context(), wechatUserInfo.Identity())
 	if err != nil {
+		if customErr, ok := err.(*common.CustomError); ok {
+			common.ErrorResponse(ctx, customErr.Code, customErr.Message, nil)
+			return
+		}
 		common.ServerError(ctx, "用户处理失败: "+err.Error())
 		return
 	}
 
-	// 5. 生成JWT令牌
-	tokenResponse, err := services.GenerateTokenPair(c.jwtService, user.ID, user.Username)
-	if err != nil {
-		common.ServerError(ctx, "令牌生成失败: "+err.Error())
+	// 需要两步验证时只返回临时令牌
+	if result.MFARequired {
+		common.SuccessWithMessage(ctx, "请完成两步验证", WeChatLoginResponse{
+			MFARequired: true,
+			MFAToken:    result.MFAToken,
+		})
 		return
 	}
 
 	response := WeChatLoginResponse{
 		Tokens: &TokenInfo{
-			AccessToken:  tokenResponse.AccessToken,
-			RefreshToken: tokenResponse.RefreshToken,
-			ExpiresIn:    tokenResponse.ExpiresIn,
+			AccessToken:  result.Tokens.AccessToken,
+			RefreshToken: result.Tokens.RefreshToken,
+			ExpiresIn:    result.Tokens.ExpiresIn,
 		},
-		User:       user,
-		IsNewUser:  isNewUser,
+		User:       result.User,
+		IsNewUser:  result.IsNewUser,
 		WeChatInfo: wechatUserInfo,
 	}
 
 	common.SuccessWithMessage(ctx, "登录成功", response)
 }
 
-// findOrCreateUser 查找或创建用户
-func (c *WeChatAuthController) findOrCreateUser(ctx *gin.Context, wechatUserInfo *services.WeChatUserInfo, tenantID string) (*models.User, bool, error) {
-	// 优先通过OpenID查找用户
-	if wechatUserInfo.OpenID != "" {
-		existingUser, err := c.userService.GetByWeChatOpenID(ctx.Request.Context(), wechatUserInfo.OpenID)
-		if err == nil {
-			// 用户已存在，更新用户信息
-			if wechatUserInfo.Nickname != "" || wechatUserInfo.Avatar != "" {
-				updateReq := &services.UpdateProfileRequest{
-					Nickname: wechatUserInfo.Nickname,
-					Avatar:   wechatUserInfo.Avatar,
-				}
-				c.userService.UpdateProfile(ctx.Request.Context(), existingUser.ID, updateReq)
-			}
-			return existingUser, false, nil
-		}
-	}
-
-	// 生成唯一的用户名（微信 + OpenID的hash值，确保长度合适）
-	hash := 0
-	for _, char := range wechatUserInfo.OpenID {
-		hash = (hash*31 + int(char)) % 100000000
-	}
-	username := fmt.Sprintf("wx_%d", hash%1000000) // 格式：wx_123456
-
-	// 尝试查找现有用户
-	existingUser, err := c.userService.GetByUsername(ctx.Request.Context(), username)
-	if err == nil {
-		// 用户已存在，更新用户信息
-		if wechatUserInfo.Nickname != "" || wechatUserInfo.Avatar != "" {
-			updateReq := &services.UpdateProfileRequest{
-				Nickname: wechatUserInfo.Nickname,
-				Avatar:   wechatUserInfo.Avatar,
-			}
-			c.userService.UpdateProfile(ctx.Request.Context(), existingUser.ID, updateReq)
-		}
-		return existingUser, false, nil
-	}
-
-	// 用户不存在，创建新用户
-	// 为微信用户生成唯一的手机号和邮箱
-	defaultPhone := fmt.Sprintf("138%08d", hash%100000000)
-	defaultEmail := fmt.Sprintf("wx_%d@miniprogram.com", hash%1000000)
-
-	// 生成符合要求的密码
-	passwordNum := hash % 1000000
-	defaultPassword := fmt.Sprintf("wechat%d", passwordNum)
-
-	registerReq := &services.RegisterRequest{
-		Username:      username,
-		Password:      defaultPassword,
-		Nickname:      wechatUserInfo.Nickname,
-		Phone:         defaultPhone,
-		Email:         defaultEmail,
-		WeChatOpenID:  wechatUserInfo.OpenID,
-		WeChatUnionID: wechatUserInfo.UnionID,
-	}
-
-	newUser, err := c.userService.Register(ctx.Request.Context(), registerReq)
-	if err != nil {
-		return nil, false, err
-	}
-
-	// 更新头像
-	if wechatUserInfo.Avatar != "" {
-		updateReq := &services.UpdateProfileRequest{
-			Avatar: wechatUserInfo.Avatar,
-		}
-		c.userService.UpdateProfile(ctx.Request.Context(), newUser.ID, updateReq)
-	}
-
-	return newUser, true, nil
-}
-
-// findOrCreateUserWithPhone 查找或创建用户（优先通过手机号）
-func (c *WeChatAuthController) findOrCreateUserWithPhone(ctx *gin.Context, wechatUserInfo *services.WeChatUserInfo, tenantID string) (*models.User, bool, error) {
-	// 1. 优先通过手机号查找用户
-	if wechatUserInfo.Phone != "" {
-		existingUser, err := c.userService.GetByPhone(ctx.Request.Context(), wechatUserInfo.Phone)
-		if err == nil {
-			// 用户已存在，更新微信信息
-			updateReq := &services.UpdateProfileRequest{
-				Nickname:      wechatUserInfo.Nickname,
-				Avatar:        wechatUserInfo.Avatar,
-				WeChatOpenID:  wechatUserInfo.OpenID,
-				WeChatUnionID: wechatUserInfo.UnionID,
-			}
-			c.userService.UpdateProfile(ctx.Request.Context(), existingUser.ID, updateReq)
-			return existingUser, false, nil
-		}
-	}
-
-	// 2. 通过OpenID查找用户
-	if wechatUserInfo.OpenID != "" {
-		existingUser, err := c.userService.GetByWeChatOpenID(ctx.Request.Context(), wechatUserInfo.OpenID)
-		if err == nil {
-			// 用户已存在，更新手机号
-			updateReq := &services.UpdateProfileRequest{
-				Phone: wechatUserInfo.Phone,
-			}
-			c.userService.UpdateProfile(ctx.Request.Context(), existingUser.ID, updateReq)
-			return existingUser, false, nil
-		}
-	}
-
-	// 3. 创建新用户
-	hash := 0
-	for _, char := range wechatUserInfo.OpenID {
-		hash = (hash*31 + int(char)) % 100000000
-	}
-	username := fmt.Sprintf("wx_%d", hash%1000000)
-
-	// 生成默认邮箱
-	defaultEmail := fmt.Sprintf("wx_%d@miniprogram.com", hash%1000000)
-
-	// 生成符合要求的密码
-	passwordNum := hash % 1000000
-	defaultPassword := fmt.Sprintf("wechat%d", passwordNum)
-
-	registerReq := &services.RegisterRequest{
-		Username:      username,
-		Password:      defaultPassword,
-		Nickname:      wechatUserInfo.Nickname,
-		Phone:         wechatUserInfo.Phone,
-		Email:         defaultEmail,
-		WeChatOpenID:  wechatUserInfo.OpenID,
-		WeChatUnionID: wechatUserInfo.UnionID,
-	}
-
-	newUser, err := c.userService.Register(ctx.Request.Context(), registerReq)
-	if err != nil {
-		return nil, false, err
-	}
-
-	// 更新头像
-	if wechatUserInfo.Avatar != "" {
-		updateReq := &services.UpdateProfileRequest{
-			Avatar: wechatUserInfo.Avatar,
-		}
-		c.userService.UpdateProfile(ctx.Request.Context(), newUser.ID, updateReq)
-	}
-
-	return newUser, true, nil
-}
-
 // WeChatLoginResponse 微信登录响应
+// 用户启用两步验证时只返回MFARequired和MFAToken，需调用 /auth/mfa/verify 完成登录
 type WeChatLoginResponse struct {
-	Tokens     *TokenInfo               `json:"tokens"`      // 令牌信息
-	User       *models.User             `json:"user"`        // 用户信息
-	IsNewUser  bool                     `json:"is_new_user"` // 是否为新用户
-	WeChatInfo *services.WeChatUserInfo `json:"wechat_info"` // 微信用户信息
+	Tokens      *TokenInfo               `json:"tokens,omitempty"`       // 令牌信息
+	User        *models.User             `json:"user,omitempty"`         // 用户信息
+	IsNewUser   bool                     `json:"is_new_user"`            // 是否为新用户
+	WeChatInfo  *services.WeChatUserInfo `json:"wechat_info,omitempty"`  // 微信用户信息
+	MFARequired bool                     `json:"mfa_required,omitempty"` // 是否需要两步验证
+	MFAToken    string                   `json:"mfa_token,omitempty"`    // 两步验证临时令牌
 }
 
 // PhoneNumberResponse 手机号响应
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"member-link-lite/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WellKnownController 公开元数据控制器
+type WellKnownController struct {
+	jwtService services.JWTService
+}
+
+// NewWellKnownController 创建公开元数据控制器
+func NewWellKnownController() *WellKnownController {
+	return &WellKnownController{
+		jwtService: services.NewJWTService(),
+	}
+}
+
+// JWKS 获取令牌验证公钥
+// @Summary 获取令牌验证公钥
+// @Description 以JWKS格式发布当前及轮换期间仍有效的令牌签名公钥，供其他服务验证会员令牌（使用HS256时返回空集合）
+// @Tags 认证管理
+// @Produce json
+// @Success 200 {object} services.JWKSet "公钥集合"
+// @Router /.well-known/jwks.json [get]
+func (ctrl *WellKnownController) JWKS(c *gin.Context) {
+	// JWKS为标准格式，不使用统一响应结构包装
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, ctrl.jwtService.JWKS())
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"member-link-lite/internal/models"
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSignedBodySize 签名请求体大小上限
+const maxSignedBodySize = 1 << 20
+
+// SignedClientAuth 服务端签名认证中间件
+// 校验 X-Api-Key、X-Api-Timestamp、X-Api-Nonce、X-Api-Signature 请求头，通过后以客户端所属租户处理请求
+func SignedClientAuth() gin.HandlerFunc {
+	clientService := services.NewAPIClientService()
+
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSignedBodySize))
+		if err != nil {
+			common.ErrorResponse(c, http.StatusBadRequest, "请求体过大或读取失败", nil)
+			c.Abort()
+			return
+		}
+		// 还原请求体供后续绑定参数
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		client, err := clientService.VerifyRequest(c.Request.Context(), &services.SignedRequest{
+			KeyID:     c.GetHeader(services.HeaderAPIKeyID),
+			Timestamp: c.GetHeader(services.HeaderAPITimestamp),
+			Nonce:     c.GetHeader(services.HeaderAPINonce),
+			Signature: c.GetHeader(services.HeaderAPISignature),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.RequestURI(),
+			Body:      body,
+		})
+		if err != nil {
+			if customErr, ok := err.(*common.CustomError); ok {
+				common.ErrorResponse(c, customErr.Code, customErr.Message, nil)
+			} else {
+				common.ErrorResponse(c, http.StatusUnauthorized, "签名验证失败", nil)
+			}
+			c.Abort()
+			return
+		}
+
+		// 客户端只能操作所属租户的数据
+		c.Set("api_client", client)
+		c.Set("tenant_id", client.TenantID)
+		ctx := context.WithValue(c.Request.Context(), "tenant_id", client.TenantID)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// JWTOrSignedClientAuth 会员令牌或服务端签名认证中间件
+// 携带 X-Api-Key 请求头时按签名请求验证，否则按JWT验证
+func JWTOrSignedClientAuth() gin.HandlerFunc {
+	jwtAuth := JWTAuth()
+	signedAuth := SignedClientAuth()
+
+	return func(c *gin.Context) {
+		if c.GetHeader(services.HeaderAPIKeyID) != "" {
+			signedAuth(c)
+			return
+		}
+		jwtAuth(c)
+	}
+}
+
+// GetAPIClient 从上下文获取发起签名请求的客户端
+func GetAPIClient(c *gin.Context) (*models.APIClient, bool) {
+	if client, exists := c.Get("api_client"); exists {
+		if apiClient, ok := client.(*models.APIClient); ok {
+			return apiClient, true
+		}
+	}
+	return nil, false
+}
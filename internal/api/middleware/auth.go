@@ -45,12 +45,27 @@ func JWTAuth() gin.HandlerFunc {
 		}
 
 		// 检查令牌类型
-		if claims.Type != "access" {
+		if claims.Type != services.TokenTypeAccess {
 			common.ErrorResponse(c, http.StatusUnauthorized, "令牌类型错误", nil)
 			c.Abort()
 			return
 		}
 
+		// 检查令牌是否已被吊销（吊销状态无法确认时拒绝访问）
+		revoked, err := jwtService.IsTokenRevoked(c.Request.Context(), claims)
+		if err != nil || revoked {
+			common.ErrorResponse(c, common.ErrTokenRevoked.Code, common.ErrTokenRevoked.Message, nil)
+			c.Abort()
+			return
+		}
+
+		// 令牌只能在所属租户下使用，请求指定的租户与令牌不一致时拒绝访问
+		if !bindTokenTenant(c, claims) {
+			common.ErrorResponse(c, common.ErrTenantMismatch.Code, common.ErrTenantMismatch.Message, nil)
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存储到上下文
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
@@ -84,7 +99,18 @@ func OptionalJWTAuth() gin.HandlerFunc {
 			return
 		}
 
-		if claims.Type == "access" {
+		if claims.Type != services.TokenTypeAccess {
+			c.Next()
+			return
+		}
+
+		// 令牌与请求的租户不一致时按未登录处理
+		if !bindTokenTenant(c, claims) {
+			c.Next()
+			return
+		}
+
+		if revoked, err := jwtService.IsTokenRevoked(c.Request.Context(), claims); err == nil && !revoked {
 			c.Set("user_id", claims.UserID)
 			c.Set("username", claims.Username)
 			c.Set("jwt_claims", claims)
@@ -94,6 +120,21 @@ func OptionalJWTAuth() gin.HandlerFunc {
 	}
 }
 
+// bindTokenTenant 将请求的租户绑定为令牌所属租户
+// 令牌未携带租户，或请求通过Header、Query指定了其他租户时返回false
+func bindTokenTenant(c *gin.Context, claims *services.JWTClaims) bool {
+	if claims.TenantID == "" {
+		return false
+	}
+	if requested := requestedTenantID(c); requested != "" && requested != claims.TenantID {
+		return false
+	}
+	if GetSimpleTenantID(c) != claims.TenantID {
+		setTenantID(c, claims.TenantID)
+	}
+	return true
+}
+
 // GetCurrentUserID 从上下文获取当前用户ID
 func GetCurrentUserID(c *gin.Context) (uint64, bool) {
 	if userID, exists := c.Get("user_id"); exists {
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"member-link-lite/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientInfo 客户端信息中间件
+// 将IP、User-Agent和设备信息写入请求上下文，供服务层记录登录会话
+func ClientInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userAgent := c.GetHeader("User-Agent")
+
+		// 客户端可通过X-Device-Name自报设备名称
+		device := c.GetHeader("X-Device-Name")
+		if device == "" {
+			device = services.DetectDevice(userAgent)
+		}
+		if len(device) > 100 {
+			device = device[:100]
+		}
+		if len(userAgent) > 500 {
+			userAgent = userAgent[:500]
+		}
+
+		ctx := services.WithClientInfo(c.Request.Context(), &services.ClientInfo{
+			IP:        c.ClientIP(),
+			UserAgent: userAgent,
+			Device:    device,
+		})
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
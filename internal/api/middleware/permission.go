@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"member-link-lite/internal/services"
+	"member-link-lite/pkg/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission 权限校验中间件
+// 需在JWTAuth或SignedClientAuth之后使用，会员令牌按携带的角色判断（令牌已绑定所属租户），签名客户端按授权范围判断
+func RequirePermission(permission string) gin.HandlerFunc {
+	rbacService := services.NewRBACService()
+
+	return func(c *gin.Context) {
+		if client, ok := GetAPIClient(c); ok {
+			if !client.HasScope(permission) {
+				common.ErrorResponse(c, common.ErrPermissionDenied.Code, common.ErrPermissionDenied.Message, nil)
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		claims, exists := GetJWTClaims(c)
+		if !exists {
+			common.ErrorResponse(c, http.StatusUnauthorized, "未授权访问", nil)
+			c.Abort()
+			return
+		}
+
+		allowed, err := rbacService.HasPermission(c.Request.Context(), claims.Roles, permission)
+		if err != nil {
+			common.ErrorResponse(c, http.StatusInternalServerError, "权限校验失败", nil)
+			c.Abort()
+			return
+		}
+		if !allowed {
+			common.ErrorResponse(c, common.ErrPermissionDenied.Code, common.ErrPermissionDenied.Message, nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -17,33 +17,47 @@ func SimpleTenantMiddleware() gin.HandlerFunc {
 		}
 
 		// 从请求中提取租户ID（优先级：Header > Query > 默认值）
-		headerName := config.GetString("tenant.header_name")
-		if headerName == "" {
-			headerName = "X-Tenant-ID" // 默认Header名称
-		}
-
-		queryName := config.GetString("tenant.query_name")
-		if queryName == "" {
-			queryName = "tenant_id" // 默认Query参数名称
-		}
-
-		tenantID := c.GetHeader(headerName)
-		if tenantID == "" {
-			tenantID = c.Query(queryName)
-		}
+		tenantID := requestedTenantID(c)
 		if tenantID == "" || !isSimpleValidTenantID(tenantID) {
 			tenantID = "default"
 		}
 
-		// 设置到上下文
-		c.Set("tenant_id", tenantID)
+		setTenantID(c, tenantID)
+		c.Next()
+	}
+}
 
-		// 传递到标准ctx，方便服务层读取
-		ctx := context.WithValue(c.Request.Context(), "tenant_id", tenantID)
-		c.Request = c.Request.WithContext(ctx)
+// requestedTenantID 获取请求通过Header或Query指定的租户ID，未启用多租户或未指定时返回空
+func requestedTenantID(c *gin.Context) string {
+	if !config.GetBool("tenant.enabled") {
+		return ""
+	}
 
-		c.Next()
+	headerName := config.GetString("tenant.header_name")
+	if headerName == "" {
+		headerName = "X-Tenant-ID" // 默认Header名称
+	}
+
+	queryName := config.GetString("tenant.query_name")
+	if queryName == "" {
+		queryName = "tenant_id" // 默认Query参数名称
+	}
+
+	tenantID := c.GetHeader(headerName)
+	if tenantID == "" {
+		tenantID = c.Query(queryName)
 	}
+	return tenantID
+}
+
+// setTenantID 设置当前请求的租户ID
+func setTenantID(c *gin.Context, tenantID string) {
+	// 设置到上下文
+	c.Set("tenant_id", tenantID)
+
+	// 传递到标准ctx，方便服务层读取
+	ctx := context.WithValue(c.Request.Context(), "tenant_id", tenantID)
+	c.Request = c.Request.WithContext(ctx)
 }
 
 // isSimpleValidTenantID 简化的租户ID验证
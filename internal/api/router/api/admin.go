@@ -0,0 +1,65 @@
+package api
+
+import (
+	"member-link-lite/internal/api/controllers"
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAdminRoutes 注册后台管理相关路由
+func RegisterAdminRoutes(rg *gin.RouterGroup) {
+	rbacController := controllers.NewRBACController()
+	clientController := controllers.NewAPIClientController()
+	oauthController := controllers.NewOAuthController()
+	mergeController := controllers.NewAccountMergeController()
+	ledgerController := controllers.NewLedgerController()
+
+	admin := rg.Group("/admin")
+	admin.Use(middleware.JWTAuth()) // 所有后台路由都需要认证
+	{
+		// 角色管理
+		roles := admin.Group("", middleware.RequirePermission(models.PermissionRoleManage))
+		{
+			// 角色列表
+			roles.GET("/roles", rbacController.ListRoles)
+
+			// 用户角色
+			roles.GET("/users/:id/roles", rbacController.GetUserRoles)
+
+			// 授予角色
+			roles.POST("/users/:id/roles", rbacController.GrantRole)
+
+			// 回收角色
+			roles.DELETE("/users/:id/roles/:role", rbacController.RevokeRole)
+		}
+
+		// 账号合并
+		admin.POST("/users/merge", middleware.RequirePermission(models.PermissionMemberWrite), mergeController.Merge)
+
+		// 资金账本
+		ledger := admin.Group("/ledger")
+		{
+			ledger.GET("/trial-balance", middleware.RequirePermission(models.PermissionLedgerRead), ledgerController.GetTrialBalance)
+			ledger.GET("/entries", middleware.RequirePermission(models.PermissionLedgerRead), ledgerController.ListJournalEntries)
+			ledger.POST("/opening-balances", middleware.RequirePermission(models.PermissionBalanceWrite), ledgerController.PostOpeningBalances)
+		}
+
+		// 服务端接入客户端管理
+		clients := admin.Group("/api-clients", middleware.RequirePermission(models.PermissionClientManage))
+		{
+			clients.GET("", clientController.ListClients)
+			clients.POST("", clientController.CreateClient)
+			clients.POST("/:id/disable", clientController.DisableClient)
+		}
+
+		// OAuth2/OIDC第三方应用管理
+		oauthClients := admin.Group("/oauth-clients", middleware.RequirePermission(models.PermissionClientManage))
+		{
+			oauthClients.GET("", oauthController.ListClients)
+			oauthClients.POST("", oauthController.CreateClient)
+			oauthClients.POST("/:id/disable", oauthController.DisableClient)
+		}
+	}
+}
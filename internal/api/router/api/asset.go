@@ -4,6 +4,7 @@ import (
 	"member-link-lite/internal/api/controllers"
 	"member-link-lite/internal/api/middleware"
 	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
 	"member-link-lite/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -15,9 +16,9 @@ func RegisterAssetRoutes(rg *gin.RouterGroup) {
 	assetService := services.NewAssetService(database.GetDB())
 	assetController := controllers.NewAssetController(assetService)
 
-	// 资产管理路由组（需要认证）
+	// 资产管理路由组（需要认证，支持会员令牌或服务端签名调用）
 	asset := rg.Group("/asset")
-	asset.Use(middleware.JWTAuth()) // 添加JWT认证中间件
+	asset.Use(middleware.JWTOrSignedClientAuth())
 	{
 		// 获取资产信息
 		asset.GET("/info", assetController.GetAssetInfo)
@@ -25,8 +26,12 @@ func RegisterAssetRoutes(rg *gin.RouterGroup) {
 		// 余额管理
 		balance := asset.Group("/balance")
 		{
-			// 余额变动
-			balance.POST("/change", assetController.ChangeBalance)
+			// 余额变动（仅运营人员）
+			balance.POST("/change", middleware.RequirePermission(models.PermissionBalanceWrite), assetController.ChangeBalance)
+			// 下单冻结、确认扣款及取消释放（仅运营人员）
+			balance.POST("/hold", middleware.RequirePermission(models.PermissionBalanceWrite), assetController.HoldBalance)
+			balance.POST("/capture", middleware.RequirePermission(models.PermissionBalanceWrite), assetController.CaptureHold)
+			balance.POST("/release", middleware.RequirePermission(models.PermissionBalanceWrite), assetController.ReleaseHold)
 			// 获取余额变动记录
 			balance.GET("/records", assetController.GetBalanceRecords)
 		}
@@ -34,8 +39,8 @@ func RegisterAssetRoutes(rg *gin.RouterGroup) {
 		// 积分管理
 		points := asset.Group("/points")
 		{
-			// 积分变动
-			points.POST("/change", assetController.ChangePoints)
+			// 积分变动（仅运营人员）
+			points.POST("/change", middleware.RequirePermission(models.PermissionPointsWrite), assetController.ChangePoints)
 			// 获���积分变动记录
 			points.GET("/records", assetController.GetPointsRecords)
 		}
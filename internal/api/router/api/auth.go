@@ -2,6 +2,7 @@ package api
 
 import (
 	"member-link-lite/internal/api/controllers"
+	"member-link-lite/internal/api/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -9,6 +10,8 @@ import (
 // RegisterAuthRoutes 注册认证相关路由
 func RegisterAuthRoutes(rg *gin.RouterGroup) {
 	authController := controllers.NewAuthController()
+	emailController := controllers.NewEmailController()
+	socialController := controllers.NewSocialController()
 
 	auth := rg.Group("/auth")
 	{
@@ -18,11 +21,29 @@ func RegisterAuthRoutes(rg *gin.RouterGroup) {
 		// 用户登录
 		auth.POST("/login", authController.Login)
 
+		// 两步验证登录
+		auth.POST("/mfa/verify", authController.VerifyMFA)
+
 		// 刷新令牌
 		auth.POST("/refresh", authController.RefreshToken)
 
 		// 用户登出
-		auth.POST("/logout", authController.Logout)
+		auth.POST("/logout", middleware.JWTAuth(), authController.Logout)
+
+		// 短信验证码
+		auth.POST("/sms/send", authController.SendSMSCode)
+		auth.POST("/sms/login", authController.SMSLogin)
+
+		// 第三方账号登录
+		auth.GET("/social/providers", socialController.Providers)
+		auth.GET("/social/:provider/authorize", socialController.AuthURL)
+		auth.POST("/social/:provider/login", socialController.Login)
+
+		// 找回密码
+		auth.POST("/password/forgot", emailController.ForgotPassword)
+		auth.POST("/password/reset", emailController.ResetPassword)
 
+		// 邮箱验证链接
+		auth.GET("/email/verify", emailController.VerifyEmail)
 	}
 }
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/models"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -27,16 +29,16 @@ func RegisterLevelRoutes(rg *gin.RouterGroup) {
 			})
 		})
 
-		// 创建等级
-		level.POST("", func(c *gin.Context) {
+		// 创建等级（仅运营人员）
+		level.POST("", middleware.JWTAuth(), middleware.RequirePermission(models.PermissionLevelWrite), func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
 				"message": "创建等级接口",
 				"module":  "level",
 			})
 		})
 
-		// 更新等级
-		level.PUT("/:id", func(c *gin.Context) {
+		// 更新等级（仅运营人员）
+		level.PUT("/:id", middleware.JWTAuth(), middleware.RequirePermission(models.PermissionLevelWrite), func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
 				"message": "更新等级接口",
 				"module":  "level",
@@ -44,8 +46,8 @@ func RegisterLevelRoutes(rg *gin.RouterGroup) {
 			})
 		})
 
-		// 删除等级
-		level.DELETE("/:id", func(c *gin.Context) {
+		// 删除等级（仅运营人员）
+		level.DELETE("/:id", middleware.JWTAuth(), middleware.RequirePermission(models.PermissionLevelWrite), func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
 				"message": "删除等级接口",
 				"module":  "level",
@@ -74,6 +76,7 @@ func RegisterLevelRoutes(rg *gin.RouterGroup) {
 
 	// 会员等级升级
 	memberLevel := rg.Group("/member-level")
+	memberLevel.Use(middleware.JWTAuth())
 	{
 		// 获取会员当前等级
 		memberLevel.GET("/current", func(c *gin.Context) {
@@ -83,8 +86,8 @@ func RegisterLevelRoutes(rg *gin.RouterGroup) {
 			})
 		})
 
-		// 等级升级
-		memberLevel.POST("/upgrade", func(c *gin.Context) {
+		// 等级升级（仅运营人员）
+		memberLevel.POST("/upgrade", middleware.RequirePermission(models.PermissionLevelWrite), func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
 				"message": "等级升级接口",
 				"module":  "level",
@@ -1,101 +1,93 @@
 package api
 
 import (
-	"net/http"
+	"member-link-lite/internal/api/controllers"
+	"member-link-lite/internal/api/middleware"
+	"member-link-lite/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 // RegisterMemberRoutes 注册会员相关路由
 func RegisterMemberRoutes(rg *gin.RouterGroup) {
+	memberController := controllers.NewMemberController()
+	segmentController := controllers.NewSegmentController()
+	userController := controllers.NewUserController()
+
+	// 会员管理仅限运营人员
 	member := rg.Group("/members")
+	member.Use(middleware.JWTAuth())
 	{
 		// 获取会员列表
-		member.GET("", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "获取会员列表接口",
-				"module":  "member",
-			})
-		})
+		member.GET("", middleware.RequirePermission(models.PermissionMemberRead), memberController.List)
+
+		// 导出会员
+		member.GET("/export", middleware.RequirePermission(models.PermissionMemberRead), memberController.Export)
+
+		// 批量导入会员
+		member.POST("/imports", middleware.RequirePermission(models.PermissionMemberWrite), memberController.Import)
+		member.GET("/imports", middleware.RequirePermission(models.PermissionMemberRead), memberController.ListImports)
+		member.GET("/imports/:id", middleware.RequirePermission(models.PermissionMemberRead), memberController.GetImport)
+		member.GET("/imports/:id/report", middleware.RequirePermission(models.PermissionMemberRead), memberController.DownloadImportReport)
+
+		// 获取会员统计信息
+		member.GET("/statistics", middleware.RequirePermission(models.PermissionMemberRead), memberController.Statistics)
 
 		// 获取会员详情
-		member.GET("/:id", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "获取会员详情接口",
-				"module":  "member",
-				"id":      c.Param("id"),
-			})
-		})
+		member.GET("/:id", middleware.RequirePermission(models.PermissionMemberRead), memberController.Get)
 
 		// 创建会员
-		member.POST("", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "创建会员接口",
-				"module":  "member",
-			})
-		})
+		member.POST("", middleware.RequirePermission(models.PermissionMemberWrite), memberController.Create)
 
 		// 更新会员信息
-		member.PUT("/:id", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "更新会员信息接口",
-				"module":  "member",
-				"id":      c.Param("id"),
-			})
-		})
+		member.PUT("/:id", middleware.RequirePermission(models.PermissionMemberWrite), memberController.Update)
 
 		// 删除会员
-		member.DELETE("/:id", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "删除会员接口",
-				"module":  "member",
-				"id":      c.Param("id"),
-			})
-		})
+		member.DELETE("/:id", middleware.RequirePermission(models.PermissionMemberWrite), memberController.Delete)
 
 		// 会员状态管理
-		member.PUT("/:id/status", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "会员状态管理接口",
-				"module":  "member",
-				"id":      c.Param("id"),
-			})
-		})
+		member.PUT("/:id/status", middleware.RequirePermission(models.PermissionMemberWrite), memberController.UpdateStatus)
 
-		// 获取会员统计信息
-		member.GET("/statistics", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "获取会员统计信息接口",
-				"module":  "member",
-			})
-		})
+		// 获取会员的标签
+		member.GET("/:id/tags", middleware.RequirePermission(models.PermissionMemberRead), segmentController.GetMemberTags)
+
+		// 会员标签管理
+		tags := member.Group("/tags")
+		{
+			tags.GET("", middleware.RequirePermission(models.PermissionMemberRead), segmentController.ListTags)
+			tags.POST("", middleware.RequirePermission(models.PermissionMemberWrite), segmentController.CreateTag)
+			tags.PUT("/:id", middleware.RequirePermission(models.PermissionMemberWrite), segmentController.UpdateTag)
+			tags.DELETE("/:id", middleware.RequirePermission(models.PermissionMemberWrite), segmentController.DeleteTag)
+			tags.POST("/:id/members", middleware.RequirePermission(models.PermissionMemberWrite), segmentController.TagMembers)
+			tags.DELETE("/:id/members", middleware.RequirePermission(models.PermissionMemberWrite), segmentController.UntagMembers)
+		}
+
+		// 会员分群管理
+		segments := member.Group("/segments")
+		{
+			segments.GET("", middleware.RequirePermission(models.PermissionMemberRead), segmentController.ListSegments)
+			segments.POST("", middleware.RequirePermission(models.PermissionMemberWrite), segmentController.CreateSegment)
+			segments.POST("/preview", middleware.RequirePermission(models.PermissionMemberRead), segmentController.PreviewRule)
+			segments.GET("/:id", middleware.RequirePermission(models.PermissionMemberRead), segmentController.GetSegment)
+			segments.PUT("/:id", middleware.RequirePermission(models.PermissionMemberWrite), segmentController.UpdateSegment)
+			segments.DELETE("/:id", middleware.RequirePermission(models.PermissionMemberWrite), segmentController.DeleteSegment)
+			segments.GET("/:id/preview", middleware.RequirePermission(models.PermissionMemberRead), segmentController.PreviewSegment)
+			segments.GET("/:id/members", middleware.RequirePermission(models.PermissionMemberRead), segmentController.ListSegmentMembers)
+			segments.GET("/:id/export", middleware.RequirePermission(models.PermissionMemberRead), segmentController.ExportSegmentMembers)
+		}
 	}
 
-	// 会员个人中心相关路由
+	// 会员个人中心相关路由（与 /user 下的接口相同）
 	profile := rg.Group("/profile")
+	profile.Use(middleware.JWTAuth())
 	{
 		// 获取个人信息
-		profile.GET("", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "获取个人信息接口",
-				"module":  "member",
-			})
-		})
+		profile.GET("", userController.GetProfile)
 
 		// 更新个人信息
-		profile.PUT("", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "更新个人信息接口",
-				"module":  "member",
-			})
-		})
+		profile.PUT("", userController.UpdateProfile)
 
 		// 上传头像
-		profile.POST("/avatar", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "上传头像接口",
-				"module":  "member",
-			})
-		})
+		profile.POST("/avatar", userController.UploadAvatar)
 	}
 }
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"member-link-lite/internal/api/controllers"
+	"member-link-lite/internal/api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterOAuthRoutes 注册OAuth2/OIDC授权相关路由
+func RegisterOAuthRoutes(rg *gin.RouterGroup) {
+	oauthController := controllers.NewOAuthController()
+
+	oauth := rg.Group("/oauth")
+	{
+		// 授权确认（会员登录后由前端授权确认页调用）
+		oauth.GET("/authorize", middleware.JWTAuth(), oauthController.PrepareAuthorization)
+		oauth.POST("/authorize", middleware.JWTAuth(), oauthController.Authorize)
+
+		// 令牌端点（由第三方应用服务端调用）
+		oauth.POST("/token", oauthController.Token)
+	}
+
+	// 用户信息端点（使用第三方应用的访问令牌）
+	rg.GET("/userinfo", oauthController.UserInfo)
+	rg.POST("/userinfo", oauthController.UserInfo)
+}
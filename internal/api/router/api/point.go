@@ -4,6 +4,7 @@ import (
 	"member-link-lite/internal/api/controllers"
 	"member-link-lite/internal/api/middleware"
 	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
 	"member-link-lite/internal/services"
 	"net/http"
 
@@ -15,6 +16,7 @@ func RegisterPointRoutes(rg *gin.RouterGroup) {
 	// 创建资产服务和控制器实例（用于积分管理）
 	assetService := services.NewAssetService(database.GetDB())
 	assetController := controllers.NewAssetController(assetService)
+	transferController := controllers.NewPointsTransferController()
 
 	point := rg.Group("/points")
 	point.Use(middleware.JWTAuth()) // 添加JWT认证中间件
@@ -25,11 +27,11 @@ func RegisterPointRoutes(rg *gin.RouterGroup) {
 		// 获取积分余额（资产信息）
 		point.GET("/balance", assetController.GetAssetInfo)
 
-		// 积分变动（统一接口）
-		point.POST("/change", assetController.ChangePoints)
+		// 积分变动（统一接口，仅运营人员）
+		point.POST("/change", middleware.RequirePermission(models.PermissionPointsWrite), assetController.ChangePoints)
 
-		// 积分充值（获得积分）
-		point.POST("/recharge", func(c *gin.Context) {
+		// 积分充值（获得积分，仅运营人员）
+		point.POST("/recharge", middleware.RequirePermission(models.PermissionPointsWrite), func(c *gin.Context) {
 			// 设置变动类型为获得
 			var req services.ChangePointsRequest
 			if err := c.ShouldBindJSON(&req); err != nil {
@@ -41,8 +43,8 @@ func RegisterPointRoutes(rg *gin.RouterGroup) {
 			assetController.ChangePoints(c)
 		})
 
-		// 积分消费（使用积分）
-		point.POST("/consume", func(c *gin.Context) {
+		// 积分消费（使用积分，仅运营人员）
+		point.POST("/consume", middleware.RequirePermission(models.PermissionPointsWrite), func(c *gin.Context) {
 			// 设置变动类型为使用
 			var req services.ChangePointsRequest
 			if err := c.ShouldBindJSON(&req); err != nil {
@@ -55,13 +57,8 @@ func RegisterPointRoutes(rg *gin.RouterGroup) {
 			assetController.ChangePoints(c)
 		})
 
-		// 积分转账（暂时返回提示信息）
-		point.POST("/transfer", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "积分转账功能待开发",
-				"module":  "point",
-			})
-		})
+		// 积分转账（会员间转账）
+		point.POST("/transfer", transferController.Transfer)
 
 		// 积分兑换（暂时返回提示信息）
 		point.POST("/exchange", func(c *gin.Context) {
@@ -92,7 +89,7 @@ func RegisterPointRoutes(rg *gin.RouterGroup) {
 		})
 
 		// 创建积分规则
-		pointRules.POST("", func(c *gin.Context) {
+		pointRules.POST("", middleware.JWTAuth(), middleware.RequirePermission(models.PermissionPointsWrite), func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
 				"message": "创建积分规则接口",
 				"module":  "point",
@@ -100,7 +97,7 @@ func RegisterPointRoutes(rg *gin.RouterGroup) {
 		})
 
 		// 更新积分规则
-		pointRules.PUT("/:id", func(c *gin.Context) {
+		pointRules.PUT("/:id", middleware.JWTAuth(), middleware.RequirePermission(models.PermissionPointsWrite), func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
 				"message": "更新积分规则接口",
 				"module":  "point",
@@ -109,7 +106,7 @@ func RegisterPointRoutes(rg *gin.RouterGroup) {
 		})
 
 		// 删除积分规则
-		pointRules.DELETE("/:id", func(c *gin.Context) {
+		pointRules.DELETE("/:id", middleware.JWTAuth(), middleware.RequirePermission(models.PermissionPointsWrite), func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
 				"message": "删除积分规则接口",
 				"module":  "point",
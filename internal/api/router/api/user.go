@@ -9,6 +9,11 @@ import (
 // RegisterUserRoutes 注册用户相关路由
 func RegisterUserRoutes(rg *gin.RouterGroup) {
 	userController := controllers.NewUserController()
+	sessionController := controllers.NewSessionController()
+	emailController := controllers.NewEmailController()
+	mfaController := controllers.NewMFAController()
+	socialController := controllers.NewSocialController()
+	privacyController := controllers.NewPrivacyController()
 
 	user := rg.Group("/user")
 	user.Use(middleware.JWTAuth()) // 所有用户路由都需要认证
@@ -24,5 +29,39 @@ func RegisterUserRoutes(rg *gin.RouterGroup) {
 
 		// 上传头像
 		user.POST("/avatar", userController.UploadAvatar)
+
+		// 发送邮箱验证邮件
+		user.POST("/email/verification", emailController.SendVerificationEmail)
+
+		// 两步验证
+		user.GET("/mfa", mfaController.GetStatus)
+		user.POST("/mfa/setup", mfaController.Setup)
+		user.POST("/mfa/enable", mfaController.Enable)
+		user.POST("/mfa/disable", mfaController.Disable)
+		user.POST("/mfa/recovery-codes", mfaController.RegenerateRecoveryCodes)
+
+		// 第三方账号绑定
+		user.GET("/identities", socialController.ListIdentities)
+		user.GET("/identities/:provider/authorize", socialController.BindAuthURL)
+		user.POST("/identities/:provider", socialController.Bind)
+		user.DELETE("/identities/:provider", socialController.Unbind)
+
+		// 个人数据导出
+		user.POST("/export", privacyController.RequestExport)
+		user.GET("/export/:id", privacyController.GetExport)
+		user.GET("/export/:id/download", privacyController.DownloadExport)
+
+		// 注销账号
+		user.DELETE("/account", privacyController.RequestDeletion)
+		user.POST("/account/cancel-deletion", privacyController.CancelDeletion)
+
+		// 登录会话列表
+		user.GET("/sessions", sessionController.ListSessions)
+
+		// 注销其他设备
+		user.POST("/sessions/logout-others", sessionController.RevokeOtherSessions)
+
+		// 注销指定会话
+		user.DELETE("/sessions/:id", sessionController.RevokeSession)
 	}
 }
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"member-link-lite/internal/api/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterWellKnownRoutes 注册公开元数据路由（挂载在根路径下）
+func RegisterWellKnownRoutes(rg *gin.RouterGroup) {
+	wellKnownController := controllers.NewWellKnownController()
+	oauthController := controllers.NewOAuthController()
+
+	wellKnown := rg.Group("/.well-known")
+	{
+		// 令牌验证公钥
+		wellKnown.GET("/jwks.json", wellKnownController.JWKS)
+
+		// OIDC发现文档
+		wellKnown.GET("/openid-configuration", oauthController.Discovery)
+
+		// OIDC ID令牌验证公钥
+		wellKnown.GET("/oidc-jwks.json", oauthController.JWKS)
+	}
+}
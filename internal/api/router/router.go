@@ -26,6 +26,7 @@ func Init() *gin.Engine {
 	r.Use(middleware2.Logger())       // 日志中间件
 	r.Use(middleware2.ErrorHandler()) // 错误处理中间件
 	r.Use(middleware2.CORS())         // 跨域中间件
+	r.Use(middleware2.ClientInfo())   // 客户端信息中间件
 
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
@@ -70,6 +71,9 @@ func Init() *gin.Engine {
 	// Swagger文档
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// 公开元数据（JWKS等）
+	api2.RegisterWellKnownRoutes(&r.RouterGroup)
+
 	// API路由组（应用租户中间件，支持多租户）
 	v1 := r.Group("/api/v1")
 	if config.GetBool("tenant.enabled") {
@@ -86,6 +90,8 @@ func Init() *gin.Engine {
 		api2.RegisterPointRoutes(v1)  // 积分模块路由
 		api2.RegisterLevelRoutes(v1)  // 等级模块路由
 		api2.RegisterCommonRoutes(v1) // 通用模块路由
+		api2.RegisterAdminRoutes(v1)  // 后台管理路由
+		api2.RegisterOAuthRoutes(v1)  // OAuth2/OIDC授权路由
 
 		// 微信授权登录路由
 		if config.GetBool("wechat.enabled") {
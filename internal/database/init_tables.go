@@ -16,7 +16,27 @@ func InitTables(db *gorm.DB) error {
 		&models.User{},
 		&models.BalanceRecord{},
 		&models.PointsRecord{},
+		&models.PointsTransfer{},
 		&models.File{},
+		&models.UserSession{},
+		&models.UserMFA{},
+		&models.UserRecoveryCode{},
+		&models.Role{},
+		&models.Permission{},
+		&models.UserRole{},
+		&models.APIClient{},
+		&models.OAuthClient{},
+		&models.OAuthConsent{},
+		&models.UserIdentity{},
+		&models.AccountMerge{},
+		&models.DataExport{},
+		&models.PasswordHistory{},
+		&models.MemberTag{},
+		&models.UserTag{},
+		&models.Segment{},
+		&models.MemberImport{},
+		&models.JournalEntry{},
+		&models.LedgerPosting{},
 	)
 
 	if err != nil {
@@ -24,10 +44,96 @@ func InitTables(db *gorm.DB) error {
 		return err
 	}
 
+	if err := CreateIdempotencyIndexes(db); err != nil {
+		logger.Error("Failed to create idempotency indexes:", err)
+		return err
+	}
+
+	if err := CreateIdentityIndexes(db); err != nil {
+		logger.Error("Failed to create identity indexes:", err)
+		return err
+	}
+
+	// 迁移旧版用户表中的微信身份
+	migrated, err := MigrateLegacyWeChatIdentities(db)
+	if err != nil {
+		logger.Error("Failed to migrate legacy WeChat identities:", err)
+		return err
+	}
+	if migrated > 0 {
+		logger.WithField("count", migrated).Info("Migrated legacy WeChat identities")
+	}
+
 	logger.Info("Database tables initialized successfully")
 	return nil
 }
 
+// idempotencyIndexes 余额、积分变动记录及积分转账记录的幂等键唯一索引
+// tenant_id定义在BaseModel中，无法通过字段标签声明组合索引，因此在迁移后单独创建
+var idempotencyIndexes = []struct {
+	model interface{}
+	table string
+	name  string
+}{
+	{&models.BalanceRecord{}, "m_balance_records", "idx_balance_records_tenant_idempotency"},
+	{&models.PointsRecord{}, "m_points_records", "idx_points_records_tenant_idempotency"},
+	{&models.PointsTransfer{}, "m_points_transfers", "idx_points_transfers_tenant_idempotency"},
+}
+
+// CreateIdempotencyIndexes 创建(tenant_id, idempotency_key)唯一索引，保证同一租户内幂等键只能使用一次
+// 未提供幂等键的记录为NULL，不受唯一索引约束；索引已存在时跳过，可重复执行
+func CreateIdempotencyIndexes(db *gorm.DB) error {
+	for _, index := range idempotencyIndexes {
+		if db.Migrator().HasIndex(index.model, index.name) {
+			continue
+		}
+		if err := db.Exec("CREATE UNIQUE INDEX " + index.name + " ON " + index.table + " (tenant_id, idempotency_key)").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// identityProviderUIDIndex 第三方身份唯一索引
+const identityProviderUIDIndex = "idx_user_identities_tenant_provider_uid"
+
+// CreateIdentityIndexes 创建(tenant_id, provider, provider_user_id)唯一索引，同一第三方账号在不同租户可分别绑定会员
+// tenant_id定义在BaseModel中，无法通过字段标签声明组合索引，因此在迁移后单独创建；索引已存在时跳过，可重复执行
+func CreateIdentityIndexes(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&models.UserIdentity{}, identityProviderUIDIndex) {
+		return nil
+	}
+	return db.Exec("CREATE UNIQUE INDEX " + identityProviderUIDIndex + " ON m_user_identities (tenant_id, provider, provider_user_id)").Error
+}
+
+// MigrateLegacyWeChatIdentities 将旧版用户表中的微信OpenID/UnionID迁移到第三方身份表
+// 旧字段不再使用，仅在字段仍存在时迁移；已迁移的OpenID会跳过，可重复执行
+func MigrateLegacyWeChatIdentities(db *gorm.DB) (int64, error) {
+	if !db.Migrator().HasColumn(&models.User{}, "wechat_openid") {
+		return 0, nil
+	}
+
+	unionID := "''"
+	if db.Migrator().HasColumn(&models.User{}, "wechat_unionid") {
+		unionID = "COALESCE(u.wechat_unionid, '')"
+	}
+
+	// 同一租户内同一OpenID对应多个用户时保留最早注册的用户
+	result := db.Exec(`
+		INSERT INTO m_user_identities (created_at, updated_at, status, tenant_id, user_id, provider, provider_user_id, union_id, nickname, avatar)
+		SELECT u.created_at, u.updated_at, 1, u.tenant_id, u.id, ?, u.wechat_openid, `+unionID+`, u.nickname, u.avatar
+		FROM m_users u
+		WHERE u.wechat_openid IS NOT NULL AND u.wechat_openid <> '' AND u.deleted_at IS NULL
+		  AND u.id = (SELECT MIN(u2.id) FROM m_users u2 WHERE u2.wechat_openid = u.wechat_openid AND u2.tenant_id = u.tenant_id AND u2.deleted_at IS NULL)
+		  AND NOT EXISTS (
+		    SELECT 1 FROM m_user_identities i
+		    WHERE i.provider = ? AND ((i.provider_user_id = u.wechat_openid AND i.tenant_id = u.tenant_id) OR i.user_id = u.id)
+		  )`,
+		models.IdentityProviderWeChatMini, models.IdentityProviderWeChatMini)
+
+	return result.RowsAffected, result.Error
+}
+
 // CreateIndexes 创建必要的索引
 func CreateIndexes(db *gorm.DB) error {
 	logger.Info("Creating database indexes...")
@@ -36,8 +142,6 @@ func CreateIndexes(db *gorm.DB) error {
 	indexes := []string{
 		"CREATE INDEX IF NOT EXISTS idx_users_status_tenant ON m_users(status, tenant_id)",
 		"CREATE INDEX IF NOT EXISTS idx_users_last_time ON m_users(last_time)",
-		"CREATE INDEX IF NOT EXISTS idx_users_wechat_openid ON m_users(wechat_openid)",
-		"CREATE INDEX IF NOT EXISTS idx_users_wechat_unionid ON m_users(wechat_unionid)",
 
 		// 余额记录表索引
 		"CREATE INDEX IF NOT EXISTS idx_balance_records_user_created ON m_balance_records(user_id, created_at DESC)",
@@ -56,6 +160,9 @@ func CreateIndexes(db *gorm.DB) error {
 		"CREATE INDEX IF NOT EXISTS idx_files_status_tenant ON m_files(status, tenant_id)",
 		"CREATE INDEX IF NOT EXISTS idx_files_hash ON m_files(hash)",
 		"CREATE INDEX IF NOT EXISTS idx_files_mime_type ON m_files(mime_type)",
+
+		// 登录会话表索引
+		"CREATE INDEX IF NOT EXISTS idx_user_sessions_user_seen ON m_user_sessions(user_id, last_seen_at DESC)",
 	}
 
 	for _, indexSQL := range indexes {
@@ -112,3 +112,59 @@ func TestInitTables(t *testing.T) {
 		assert.GreaterOrEqual(t, len(pointsRecords), 1)
 	})
 }
+
+func TestMigrateLegacyWeChatIdentities(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.User{}, &models.UserIdentity{}))
+
+	t.Run("旧字段不存在时跳过", func(t *testing.T) {
+		migrated, err := MigrateLegacyWeChatIdentities(db)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), migrated)
+	})
+
+	// 模拟旧版用户表中的微信字段
+	assert.NoError(t, db.Exec("ALTER TABLE m_users ADD COLUMN wechat_openid VARCHAR(100)").Error)
+	assert.NoError(t, db.Exec("ALTER TABLE m_users ADD COLUMN wechat_unionid VARCHAR(100)").Error)
+
+	users := []*models.User{
+		{Username: "legacy1", Email: "legacy1@example.com", Phone: "13800000001", Password: "x", Nickname: "旧用户1"},
+		{Username: "legacy2", Email: "legacy2@example.com", Phone: "13800000002", Password: "x"},
+		{Username: "legacy3", Email: "legacy3@example.com", Phone: "13800000003", Password: "x"},
+		{Username: "plain", Email: "plain@example.com", Phone: "13800000004", Password: "x"},
+	}
+	for _, user := range users {
+		assert.NoError(t, db.Create(user).Error)
+	}
+	db.Exec("UPDATE m_users SET wechat_openid = ?, wechat_unionid = ? WHERE id = ?", "openid_1", "union_1", users[0].ID)
+	db.Exec("UPDATE m_users SET wechat_openid = ? WHERE id = ?", "openid_2", users[1].ID)
+	// 重复的OpenID只迁移最早的用户
+	db.Exec("UPDATE m_users SET wechat_openid = ? WHERE id = ?", "openid_1", users[2].ID)
+
+	t.Run("迁移微信身份", func(t *testing.T) {
+		migrated, err := MigrateLegacyWeChatIdentities(db)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), migrated)
+
+		var identity models.UserIdentity
+		err = db.Where("provider = ? AND provider_user_id = ?", models.IdentityProviderWeChatMini, "openid_1").First(&identity).Error
+		assert.NoError(t, err)
+		assert.Equal(t, users[0].ID, identity.UserID)
+		assert.Equal(t, "union_1", identity.UnionID)
+		assert.Equal(t, "旧用户1", identity.Nickname)
+		assert.Equal(t, "default", identity.TenantID)
+	})
+
+	t.Run("重复执行不重复迁移", func(t *testing.T) {
+		migrated, err := MigrateLegacyWeChatIdentities(db)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), migrated)
+
+		var count int64
+		db.Model(&models.UserIdentity{}).Count(&count)
+		assert.Equal(t, int64(2), count)
+	})
+}
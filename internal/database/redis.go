@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"member-link-lite/config"
+	"member-link-lite/pkg/cache"
 	"member-link-lite/pkg/logger"
 	"time"
 
@@ -12,6 +13,13 @@ import (
 
 var RDB *redis.Client
 
+var (
+	// redisReady Redis是否已连接成功
+	redisReady bool
+	// memoryCache Redis不可用时使用的进程内缓存
+	memoryCache = cache.NewMemoryCache()
+)
+
 // InitRedis 初始化Redis连接
 func InitRedis() error {
 	RDB = redis.NewClient(&redis.Options{
@@ -30,6 +38,7 @@ func InitRedis() error {
 		return err
 	}
 
+	redisReady = true
 	logger.Info("Redis connected successfully")
 	return nil
 }
@@ -39,6 +48,15 @@ func GetRedis() *redis.Client {
 	return RDB
 }
 
+// GetCache 获取缓存实例
+// Redis连接成功时使用Redis，否则回退到进程内缓存（单机部署和测试场景）
+func GetCache() cache.Cache {
+	if RDB != nil && redisReady {
+		return cache.NewRedisCache(RDB)
+	}
+	return memoryCache
+}
+
 // CloseRedis 关闭Redis连接
 func CloseRedis() error {
 	return RDB.Close()
@@ -0,0 +1,29 @@
+package models
+
+// AccountMerge 账号合并记录
+// 记录被合并账号转入主账号的资产及数据，用于审计和问题追溯
+type AccountMerge struct {
+	BaseModel
+	PrimaryUserID   uint64 `json:"primary_user_id" gorm:"not null;index;comment:保留的主账号ID"`
+	SecondaryUserID uint64 `json:"secondary_user_id" gorm:"not null;uniqueIndex;comment:被合并的账号ID"`
+	Reason          string `json:"reason" gorm:"size:32;not null;comment:合并原因"`
+	Balance         int64  `json:"balance" gorm:"default:0;comment:转入余额(分为单位)"`
+	Points          int64  `json:"points" gorm:"default:0;comment:转入积分"`
+	BalanceRecords  int64  `json:"balance_records" gorm:"default:0;comment:转移的余额记录数"`
+	PointsRecords   int64  `json:"points_records" gorm:"default:0;comment:转移的积分记录数"`
+	Files           int64  `json:"files" gorm:"default:0;comment:转移的文件数"`
+	Identities      int64  `json:"identities" gorm:"default:0;comment:转移的第三方账号数"`
+	OperatorID      uint64 `json:"operator_id" gorm:"default:0;comment:操作人ID，系统自动合并时为0"`
+	Remark          string `json:"remark" gorm:"size:255;comment:备注"`
+}
+
+// TableName 指定表名
+func (AccountMerge) TableName() string {
+	return "m_account_merges"
+}
+
+// AccountMergeReason 账号合并原因
+const (
+	AccountMergeReasonWeChatPhone = "wechat_phone" // 微信登录授权的手机号已属于其他账号
+	AccountMergeReasonManual      = "manual"       // 运营人员手动合并
+)
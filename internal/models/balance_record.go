@@ -9,13 +9,18 @@ import (
 // BalanceRecord 余额变动记录
 type BalanceRecord struct {
 	BaseModel
-	UserID       uint64 `json:"user_id" gorm:"not null;index;comment:用户ID"`
-	Amount       int64  `json:"amount" gorm:"not null;comment:变动金额(分为单位)"`
-	Type         string `json:"type" gorm:"size:20;not null;index;comment:变动类型"`
-	Remark       string `json:"remark" gorm:"size:255;comment:备注"`
-	BalanceAfter int64  `json:"balance_after" gorm:"not null;comment:变动后余额(分为单位)"`
-	OrderNo      string `json:"order_no" gorm:"size:64;index;comment:关联订单号"`
-	User         *User  `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	UserID         uint64  `json:"user_id" gorm:"not null;index;comment:用户ID"`
+	Amount         int64   `json:"amount" gorm:"not null;comment:变动金额(分为单位)"`
+	Type           string  `json:"type" gorm:"size:20;not null;index;comment:变动类型"`
+	Remark         string  `json:"remark" gorm:"size:255;comment:备注"`
+	BalanceAfter   int64   `json:"balance_after" gorm:"not null;comment:变动后余额(分为单位)"`
+	OrderNo        string  `json:"order_no" gorm:"size:64;index;comment:关联订单号"`
+	IdempotencyKey *string `json:"idempotency_key,omitempty" gorm:"size:64;comment:幂等键"` // 幂等键，与租户ID组成唯一索引，未提供时为NULL
+	RequestHash    string  `json:"-" gorm:"size:64;comment:请求参数摘要，用于识别幂等键的冲突使用"`
+	// 冻结记录：冻结时状态为处理中，扣款后为已完成（金额为实际扣款金额），释放或过期后为已取消
+	HoldAmount int64      `json:"hold_amount,omitempty" gorm:"default:0;comment:冻结金额(分为单位)，仅冻结记录"`
+	ExpireTime *time.Time `json:"expire_time,omitempty" gorm:"index;comment:冻结到期时间"`
+	User       *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
 // BalanceType 余额变动类型常量
@@ -29,7 +34,7 @@ const (
 
 // BalanceRecordStatus 余额记录状态
 const (
-	BalanceRecordStatusPending   = 0 // 处理中
+	BalanceRecordStatusPending   = 0 // 处理中（余额冻结中）
 	BalanceRecordStatusCompleted = 1 // 已完成
 	BalanceRecordStatusFailed    = 2 // 失败
 	BalanceRecordStatusCancelled = 3 // 已取消
@@ -73,6 +78,16 @@ func (br *BalanceRecord) IsValidType() bool {
 	return false
 }
 
+// IsHold 判断是否为冻结记录
+func (br *BalanceRecord) IsHold() bool {
+	return br.HoldAmount > 0
+}
+
+// IsHoldExpired 判断冻结是否已到期
+func (br *BalanceRecord) IsHoldExpired() bool {
+	return br.ExpireTime != nil && time.Now().After(*br.ExpireTime)
+}
+
 // GetAmountFloat 获取变动金额的浮点数表示（元）
 func (br *BalanceRecord) GetAmountFloat() float64 {
 	return float64(br.Amount) / 100.0
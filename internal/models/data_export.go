@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// DataExport 个人数据导出任务
+// 导出文件为ZIP压缩包，保存在存储适配器中，过期后删除
+type DataExport struct {
+	BaseModel
+	UserID      uint64     `json:"user_id" gorm:"not null;index;comment:用户ID"`
+	State       string     `json:"state" gorm:"size:20;not null;index;comment:导出状态"`
+	Path        string     `json:"-" gorm:"size:500;comment:存储路径"`
+	Size        int64      `json:"size" gorm:"default:0;comment:文件大小(字节)"`
+	Error       string     `json:"error,omitempty" gorm:"size:255;comment:失败原因"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" gorm:"comment:生成完成时间"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" gorm:"index;comment:过期时间"`
+}
+
+// TableName 指定表名
+func (DataExport) TableName() string {
+	return "m_data_exports"
+}
+
+// DataExportState 导出状态常量
+const (
+	DataExportStatePending   = "pending"   // 生成中
+	DataExportStateCompleted = "completed" // 已完成
+	DataExportStateFailed    = "failed"    // 失败
+	DataExportStateExpired   = "expired"   // 已过期
+)
+
+// IsDownloadable 检查导出文件是否可下载
+func (e *DataExport) IsDownloadable(now time.Time) bool {
+	return e.State == DataExportStateCompleted && e.ExpiresAt != nil && now.Before(*e.ExpiresAt)
+}
@@ -0,0 +1,97 @@
+package models
+
+// JournalEntry 会计分录
+// 每次会员余额变动写入一条分录，分录下的明细借贷方合计必须相等
+type JournalEntry struct {
+	BaseModel
+	Type            string          `json:"type" gorm:"size:20;not null;index;comment:业务类型"`
+	UserID          uint64          `json:"user_id" gorm:"index;default:0;comment:关联会员ID"`
+	BalanceRecordID uint64          `json:"balance_record_id" gorm:"index;default:0;comment:关联余额变动记录ID"`
+	OrderNo         string          `json:"order_no" gorm:"size:64;index;comment:关联订单号"`
+	Amount          int64           `json:"amount" gorm:"not null;comment:分录金额(分为单位)"`
+	Description     string          `json:"description" gorm:"size:255;comment:摘要"`
+	Postings        []LedgerPosting `json:"postings,omitempty" gorm:"foreignKey:EntryID"`
+}
+
+// TableName 指定表名
+func (JournalEntry) TableName() string {
+	return "m_journal_entries"
+}
+
+// IsBalanced 检查分录借贷是否平衡，每条明细只能记入借方或贷方之一
+func (e *JournalEntry) IsBalanced() bool {
+	if len(e.Postings) < 2 {
+		return false
+	}
+	var debit, credit int64
+	for _, posting := range e.Postings {
+		if posting.Debit < 0 || posting.Credit < 0 || (posting.Debit > 0) == (posting.Credit > 0) {
+			return false
+		}
+		debit += posting.Debit
+		credit += posting.Credit
+	}
+	return debit == credit
+}
+
+// LedgerPosting 分录明细，记入一个账户的借方或贷方
+type LedgerPosting struct {
+	BaseModel
+	EntryID uint64 `json:"entry_id" gorm:"not null;index;comment:分录ID"`
+	Account string `json:"account" gorm:"size:30;not null;index;comment:账户编码"`
+	UserID  uint64 `json:"user_id" gorm:"index;default:0;comment:会员ID，仅会员钱包账户按会员分户"`
+	Debit   int64  `json:"debit" gorm:"not null;default:0;comment:借方金额(分为单位)"`
+	Credit  int64  `json:"credit" gorm:"not null;default:0;comment:贷方金额(分为单位)"`
+}
+
+// TableName 指定表名
+func (LedgerPosting) TableName() string {
+	return "m_ledger_postings"
+}
+
+// 分录业务类型，余额变动产生的分录使用余额变动类型
+const (
+	JournalTypeOpening = "opening" // 期初建账（导入或上线账本前的存量余额）
+	JournalTypeMerge   = "merge"   // 账号合并转移余额
+)
+
+// 账户编码
+const (
+	LedgerAccountMemberWallet   = "member_wallet"   // 会员钱包，按会员分户
+	LedgerAccountPlatformCash   = "platform_cash"   // 平台资金
+	LedgerAccountPromotions     = "promotions"      // 营销费用
+	LedgerAccountRefundsPayable = "refunds_payable" // 应付退款
+	LedgerAccountOpeningBalance = "opening_balance" // 期初余额
+	LedgerAccountSalesRevenue   = "sales_revenue"   // 消费收入
+)
+
+// 账户类别
+const (
+	LedgerCategoryAsset     = "asset"     // 资产
+	LedgerCategoryLiability = "liability" // 负债
+	LedgerCategoryEquity    = "equity"    // 权益
+	LedgerCategoryRevenue   = "revenue"   // 收入
+	LedgerCategoryExpense   = "expense"   // 费用
+)
+
+// LedgerAccount 账户定义
+type LedgerAccount struct {
+	Code     string `json:"code"`     // 账户编码
+	Name     string `json:"name"`     // 账户名称
+	Category string `json:"category"` // 账户类别
+}
+
+// IsDebitNormal 检查账户余额是否在借方（资产、费用类账户）
+func (a LedgerAccount) IsDebitNormal() bool {
+	return a.Category == LedgerCategoryAsset || a.Category == LedgerCategoryExpense
+}
+
+// LedgerAccounts 会计科目表
+var LedgerAccounts = []LedgerAccount{
+	{Code: LedgerAccountMemberWallet, Name: "会员钱包", Category: LedgerCategoryLiability},
+	{Code: LedgerAccountPlatformCash, Name: "平台资金", Category: LedgerCategoryAsset},
+	{Code: LedgerAccountPromotions, Name: "营销费用", Category: LedgerCategoryExpense},
+	{Code: LedgerAccountRefundsPayable, Name: "应付退款", Category: LedgerCategoryLiability},
+	{Code: LedgerAccountOpeningBalance, Name: "期初余额", Category: LedgerCategoryEquity},
+	{Code: LedgerAccountSalesRevenue, Name: "消费收入", Category: LedgerCategoryRevenue},
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// MemberImport 会员批量导入任务
+// 上传的文件及错误报告保存在存储适配器中，导入在后台异步执行
+type MemberImport struct {
+	BaseModel
+	Filename    string     `json:"filename" gorm:"size:255;not null;comment:原始文件名"`
+	Format      string     `json:"format" gorm:"size:10;not null;comment:文件格式(csv/xlsx)"`
+	DryRun      bool       `json:"dry_run" gorm:"default:false;comment:是否仅校验不写入"`
+	State       string     `json:"state" gorm:"size:20;not null;index;comment:导入状态"`
+	SourcePath  string     `json:"-" gorm:"size:500;comment:上传文件存储路径"`
+	ReportPath  string     `json:"-" gorm:"size:500;comment:错误报告存储路径"`
+	TotalRows   int        `json:"total_rows" gorm:"default:0;comment:数据行数"`
+	SuccessRows int        `json:"success_rows" gorm:"default:0;comment:成功行数（试导入时为校验通过的行数）"`
+	FailedRows  int        `json:"failed_rows" gorm:"default:0;comment:失败行数"`
+	Error       string     `json:"error,omitempty" gorm:"size:255;comment:失败原因"`
+	CreatedBy   uint64     `json:"created_by" gorm:"default:0;comment:操作人ID"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" gorm:"comment:完成时间"`
+}
+
+// TableName 指定表名
+func (MemberImport) TableName() string {
+	return "m_member_imports"
+}
+
+// MemberImportState 导入状态常量
+const (
+	MemberImportStatePending    = "pending"    // 等待处理
+	MemberImportStateProcessing = "processing" // 处理中
+	MemberImportStateCompleted  = "completed"  // 已完成（部分行失败时可下载错误报告）
+	MemberImportStateFailed     = "failed"     // 失败（文件无法解析等）
+)
+
+// HasReport 检查是否有错误报告
+func (m *MemberImport) HasReport() bool {
+	return m.State == MemberImportStateCompleted && m.ReportPath != ""
+}
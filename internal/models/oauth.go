@@ -0,0 +1,72 @@
+package models
+
+import (
+	"strings"
+)
+
+// OAuthClient 第三方应用（OAuth2/OIDC客户端）
+// 合作方H5应用通过授权码+PKCE流程使用会员账号登录
+type OAuthClient struct {
+	BaseModel
+	ClientID     string `json:"client_id" gorm:"size:64;not null;uniqueIndex;comment:客户端ID"`
+	SecretHash   string `json:"-" gorm:"size:64;comment:客户端密钥SHA256摘要，公开客户端为空"`
+	Name         string `json:"name" gorm:"size:100;not null;comment:应用名称"`
+	LogoURL      string `json:"logo_url" gorm:"size:255;comment:应用图标"`
+	RedirectURIs string `json:"redirect_uris" gorm:"type:text;comment:回调地址，多个用换行分隔"`
+	Scopes       string `json:"scopes" gorm:"size:255;comment:允许申请的授权范围，多个用空格分隔"`
+	Public       bool   `json:"public" gorm:"default:false;comment:是否为公开客户端（无密钥，仅依赖PKCE）"`
+	CreatedBy    uint64 `json:"created_by" gorm:"default:0;comment:创建人ID"`
+}
+
+// TableName 指定表名
+func (OAuthClient) TableName() string {
+	return "m_oauth_clients"
+}
+
+// RedirectURIList 获取已登记的回调地址
+func (c *OAuthClient) RedirectURIList() []string {
+	var uris []string
+	for _, uri := range strings.Split(c.RedirectURIs, "\n") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+// AllowsRedirectURI 检查回调地址是否已登记（完全匹配）
+func (c *OAuthClient) AllowsRedirectURI(redirectURI string) bool {
+	for _, uri := range c.RedirectURIList() {
+		if uri == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeList 获取允许申请的授权范围
+func (c *OAuthClient) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// OAuthConsent 会员对第三方应用的授权记录
+// 已授权的范围再次申请时无需重复确认
+type OAuthConsent struct {
+	BaseModel
+	UserID   uint64 `json:"user_id" gorm:"not null;uniqueIndex:idx_oauth_consents_user_client;comment:用户ID"`
+	ClientID string `json:"client_id" gorm:"size:64;not null;uniqueIndex:idx_oauth_consents_user_client;comment:客户端ID"`
+	Scopes   string `json:"scopes" gorm:"size:255;comment:已授权的范围，多个用空格分隔"`
+}
+
+// TableName 指定表名
+func (OAuthConsent) TableName() string {
+	return "m_oauth_consents"
+}
+
+// OIDC授权范围
+const (
+	OAuthScopeOpenID  = "openid"  // 签发ID令牌
+	OAuthScopeProfile = "profile" // 昵称、用户名、头像
+	OAuthScopeEmail   = "email"   // 邮箱及验证状态
+	OAuthScopePhone   = "phone"   // 手机号
+)
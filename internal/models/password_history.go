@@ -0,0 +1,14 @@
+package models
+
+// PasswordHistory 历史密码
+// 修改或重置密码时保存新密码的哈希，用于禁止重复使用最近用过的密码
+type PasswordHistory struct {
+	BaseModel
+	UserID   uint64 `json:"user_id" gorm:"not null;index;comment:用户ID"`
+	Password string `json:"-" gorm:"size:255;not null;comment:密码哈希"`
+}
+
+// TableName 指定表名
+func (PasswordHistory) TableName() string {
+	return "m_password_histories"
+}
@@ -9,23 +9,28 @@ import (
 // PointsRecord 积分变动记录
 type PointsRecord struct {
 	BaseModel
-	UserID      uint64     `json:"user_id" gorm:"not null;index;comment:用户ID"`
-	Quantity    int64      `json:"quantity" gorm:"not null;comment:变动数量"`
-	Type        string     `json:"type" gorm:"size:20;not null;index;comment:变动类型"`
-	Remark      string     `json:"remark" gorm:"size:255;comment:备注"`
-	PointsAfter int64      `json:"points_after" gorm:"not null;comment:变动后积分"`
-	OrderNo     string     `json:"order_no" gorm:"size:64;index;comment:关联订单号"`
-	ExpireTime  *time.Time `json:"expire_time" gorm:"comment:过期时间"`
-	User        *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	UserID         uint64     `json:"user_id" gorm:"not null;index;comment:用户ID"`
+	Quantity       int64      `json:"quantity" gorm:"not null;comment:变动数量"`
+	Type           string     `json:"type" gorm:"size:20;not null;index;comment:变动类型"`
+	Remark         string     `json:"remark" gorm:"size:255;comment:备注"`
+	PointsAfter    int64      `json:"points_after" gorm:"not null;comment:变动后积分"`
+	OrderNo        string     `json:"order_no" gorm:"size:64;index;comment:关联订单号"`
+	ExpireTime     *time.Time `json:"expire_time" gorm:"comment:过期时间"`
+	IdempotencyKey *string    `json:"idempotency_key,omitempty" gorm:"size:64;comment:幂等键"` // 幂等键，与租户ID组成唯一索引，未提供时为NULL
+	RequestHash    string     `json:"-" gorm:"size:64;comment:请求参数摘要，用于识别幂等键的冲突使用"`
+	TransferID     uint64     `json:"transfer_id,omitempty" gorm:"index;default:0;comment:积分转账ID，转出及转入记录相同"`
+	User           *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
 // PointsType 积分变动类型常量
 const (
-	PointsTypeObtain = "obtain" // 获得
-	PointsTypeUse    = "use"    // 使用
-	PointsTypeExpire = "expire" // 过期
-	PointsTypeReward = "reward" // 奖励
-	PointsTypeDeduct = "deduct" // 扣除
+	PointsTypeObtain      = "obtain"       // 获得
+	PointsTypeUse         = "use"          // 使用
+	PointsTypeExpire      = "expire"       // 过期
+	PointsTypeReward      = "reward"       // 奖励
+	PointsTypeDeduct      = "deduct"       // 扣除
+	PointsTypeTransfer    = "transfer"     // 会员间转账，转出为负数、转入为正数
+	PointsTypeTransferFee = "transfer_fee" // 转账手续费，由转出会员支付，记为负数
 )
 
 // PointsRecordStatus 积分记录状态
@@ -65,6 +70,8 @@ func (pr *PointsRecord) IsValidType() bool {
 		PointsTypeExpire,
 		PointsTypeReward,
 		PointsTypeDeduct,
+		PointsTypeTransfer,
+		PointsTypeTransferFee,
 	}
 
 	for _, validType := range validTypes {
@@ -77,12 +84,13 @@ func (pr *PointsRecord) IsValidType() bool {
 
 // IsIncome 判断是否为收入类型
 func (pr *PointsRecord) IsIncome() bool {
-	return pr.Type == PointsTypeObtain || pr.Type == PointsTypeReward
+	return pr.Type == PointsTypeObtain || pr.Type == PointsTypeReward || (pr.Type == PointsTypeTransfer && pr.Quantity > 0)
 }
 
 // IsExpense 判断是否为支出类型
 func (pr *PointsRecord) IsExpense() bool {
-	return pr.Type == PointsTypeUse || pr.Type == PointsTypeDeduct || pr.Type == PointsTypeExpire
+	return pr.Type == PointsTypeUse || pr.Type == PointsTypeDeduct || pr.Type == PointsTypeExpire ||
+		(pr.Type == PointsTypeTransfer && pr.Quantity < 0) || pr.Type == PointsTypeTransferFee
 }
 
 // IsExpired 判断积分是否已过期
@@ -106,6 +114,13 @@ func (pr *PointsRecord) GetTypeDescription() string {
 		return "奖励"
 	case PointsTypeDeduct:
 		return "扣除"
+	case PointsTypeTransfer:
+		if pr.Quantity > 0 {
+			return "转入"
+		}
+		return "转出"
+	case PointsTypeTransferFee:
+		return "转账手续费"
 	default:
 		return "未知"
 	}
@@ -0,0 +1,20 @@
+package models
+
+// PointsTransfer 积分转账记录
+// 转出会员与收款会员各有一条类型为transfer的积分记录，手续费另记一条转出会员的transfer_fee记录，均通过TransferID关联到本记录
+type PointsTransfer struct {
+	BaseModel
+	FromUserID uint64 `json:"from_user_id" gorm:"not null;index;comment:转出会员ID"`
+	ToUserID   uint64 `json:"to_user_id" gorm:"not null;index;comment:收款会员ID"`
+	Amount     int64  `json:"amount" gorm:"not null;comment:到账积分"`
+	Fee        int64  `json:"fee" gorm:"default:0;comment:手续费积分，由转出会员额外支付"`
+	Remark     string `json:"remark" gorm:"size:255;comment:备注"`
+	// 幂等键，与租户ID组成唯一索引，未提供时为NULL
+	IdempotencyKey *string `json:"idempotency_key,omitempty" gorm:"size:64;comment:幂等键"`
+	RequestHash    string  `json:"-" gorm:"size:64;comment:请求参数摘要，用于识别幂等键的冲突使用"`
+}
+
+// TableName 指定表名
+func (PointsTransfer) TableName() string {
+	return "m_points_transfers"
+}
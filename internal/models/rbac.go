@@ -0,0 +1,62 @@
+package models
+
+// Role 角色
+// 未分配任何角色的用户为普通会员，仅能访问自己的数据
+type Role struct {
+	BaseModel
+	Code        string       `json:"code" gorm:"size:50;not null;uniqueIndex;comment:角色编码"`
+	Name        string       `json:"name" gorm:"size:50;not null;comment:角色名称"`
+	Description string       `json:"description" gorm:"size:255;comment:角色描述"`
+	Permissions []Permission `json:"permissions,omitempty" gorm:"many2many:m_role_permissions;"`
+}
+
+// TableName 指定表名
+func (Role) TableName() string {
+	return "m_roles"
+}
+
+// Permission 权限
+// 权限编码格式为 资源:操作，如 balance:write
+type Permission struct {
+	BaseModel
+	Code        string `json:"code" gorm:"size:100;not null;uniqueIndex;comment:权限编码"`
+	Name        string `json:"name" gorm:"size:50;not null;comment:权限名称"`
+	Description string `json:"description" gorm:"size:255;comment:权限描述"`
+}
+
+// TableName 指定表名
+func (Permission) TableName() string {
+	return "m_permissions"
+}
+
+// UserRole 用户角色关联
+type UserRole struct {
+	BaseModel
+	UserID    uint64 `json:"user_id" gorm:"not null;uniqueIndex:idx_user_roles_user_role;comment:用户ID"`
+	RoleID    uint64 `json:"role_id" gorm:"not null;uniqueIndex:idx_user_roles_user_role;index;comment:角色ID"`
+	GrantedBy uint64 `json:"granted_by" gorm:"default:0;comment:授权人ID，0表示系统"`
+	Role      *Role  `json:"role,omitempty" gorm:"foreignKey:RoleID"`
+}
+
+// TableName 指定表名
+func (UserRole) TableName() string {
+	return "m_user_roles"
+}
+
+// 内置角色编码
+const (
+	RoleAdmin    = "admin"    // 管理员，拥有全部权限
+	RoleOperator = "operator" // 运营人员，可管理会员资产、等级和会员信息
+)
+
+// 内置权限编码
+const (
+	PermissionBalanceWrite = "balance:write" // 调整会员余额
+	PermissionPointsWrite  = "points:write"  // 调整会员积分、维护积分规则
+	PermissionLevelWrite   = "level:write"   // 维护等级及调整会员等级
+	PermissionMemberRead   = "member:read"   // 查看会员列表和详情
+	PermissionMemberWrite  = "member:write"  // 创建、修改、禁用会员
+	PermissionRoleManage   = "role:manage"   // 分配和回收角色
+	PermissionClientManage = "client:manage" // 管理服务端接入客户端
+	PermissionLedgerRead   = "ledger:read"   // 查看资金账本及试算平衡表
+)
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// MemberTag 会员标签
+// 由运营人员手动给会员打标签，可在分群规则中使用
+type MemberTag struct {
+	BaseModel
+	Name        string `json:"name" gorm:"size:50;not null;index;comment:标签名称"`
+	Color       string `json:"color" gorm:"size:20;comment:显示颜色"`
+	Description string `json:"description" gorm:"size:255;comment:标签描述"`
+	MemberCount int64  `json:"member_count" gorm:"-"`
+}
+
+// TableName 指定表名
+func (MemberTag) TableName() string {
+	return "m_member_tags"
+}
+
+// UserTag 会员标签关联
+type UserTag struct {
+	BaseModel
+	UserID   uint64 `json:"user_id" gorm:"not null;uniqueIndex:idx_user_tags_user_tag;comment:用户ID"`
+	TagID    uint64 `json:"tag_id" gorm:"not null;uniqueIndex:idx_user_tags_user_tag;index;comment:标签ID"`
+	TaggedBy uint64 `json:"tagged_by" gorm:"default:0;comment:操作人ID"`
+}
+
+// TableName 指定表名
+func (UserTag) TableName() string {
+	return "m_user_tags"
+}
+
+// Segment 会员分群
+// 分群规则为JSON规则树，查询时编译为会员查询条件，成员随会员数据实时变化
+type Segment struct {
+	BaseModel
+	Name        string       `json:"name" gorm:"size:50;not null;comment:分群名称"`
+	Description string       `json:"description" gorm:"size:255;comment:分群描述"`
+	Rules       string       `json:"-" gorm:"type:text;not null;comment:分群规则(JSON)"`
+	Rule        *SegmentRule `json:"rules" gorm:"-"`
+	CreatedBy   uint64       `json:"created_by" gorm:"default:0;comment:创建人ID"`
+}
+
+// TableName 指定表名
+func (Segment) TableName() string {
+	return "m_segments"
+}
+
+// BeforeSave GORM钩子：保存前序列化分群规则
+func (s *Segment) BeforeSave(tx *gorm.DB) error {
+	if s.Rule == nil {
+		return nil
+	}
+	data, err := json.Marshal(s.Rule)
+	if err != nil {
+		return err
+	}
+	s.Rules = string(data)
+	return nil
+}
+
+// AfterFind GORM钩子：查询后解析分群规则
+func (s *Segment) AfterFind(tx *gorm.DB) error {
+	if s.Rules == "" {
+		return nil
+	}
+	s.Rule = &SegmentRule{}
+	return json.Unmarshal([]byte(s.Rules), s.Rule)
+}
+
+// SegmentRule 分群规则节点
+// 条件组使用Logic组合子规则；条件使用Field、Operator、Value描述，统计类字段可通过Types、Days限定统计的变动类型和最近天数
+type SegmentRule struct {
+	Logic    string          `json:"logic,omitempty"`    // and、or，条件组使用
+	Rules    []SegmentRule   `json:"rules,omitempty"`    // 子规则，条件组使用
+	Field    string          `json:"field,omitempty"`    // 字段
+	Operator string          `json:"operator,omitempty"` // 比较方式
+	Value    json.RawMessage `json:"value,omitempty"`    // 比较值
+	Types    []string        `json:"types,omitempty"`    // 统计的变动类型（可选）
+	Days     int             `json:"days,omitempty"`     // 统计最近N天的变动（可选）
+}
+
+// IsGroup 检查是否为条件组
+func (r *SegmentRule) IsGroup() bool {
+	return r.Field == ""
+}
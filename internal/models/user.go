@@ -10,18 +10,21 @@ import (
 // User 会员模型
 type User struct {
 	BaseModel
-	Username      string     `json:"username" gorm:"uniqueIndex;size:50;not null;comment:用户名"`
-	Password      string     `json:"-" gorm:"size:100;not null;comment:密码"`
-	Nickname      string     `json:"nickname" gorm:"size:50;comment:昵称"`
-	Avatar        string     `json:"avatar" gorm:"size:255;comment:头像URL"`
-	Phone         string     `json:"phone" gorm:"uniqueIndex;size:20;comment:手机号"`
-	Email         string     `json:"email" gorm:"uniqueIndex;size:100;comment:邮箱"`
-	WeChatOpenID  string     `json:"wechat_openid" gorm:"column:wechat_openid;index;size:100;comment:微信OpenID"`
-	WeChatUnionID string     `json:"wechat_unionid" gorm:"column:wechat_unionid;index;size:100;comment:微信UnionID"`
-	Balance       int64      `json:"balance" gorm:"default:0;comment:余额(分为单位)"`
-	Points        int64      `json:"points" gorm:"default:0;comment:积分"`
-	LastIP        string     `json:"last_ip" gorm:"size:45;comment:最后登录IP"`
-	LastTime      *time.Time `json:"last_time" gorm:"comment:最后登录时间"`
+	Username          string     `json:"username" gorm:"uniqueIndex;size:50;not null;comment:用户名"`
+	Password          string     `json:"-" gorm:"size:100;not null;comment:密码"`
+	Nickname          string     `json:"nickname" gorm:"size:50;comment:昵称"`
+	Avatar            string     `json:"avatar" gorm:"size:255;comment:头像URL"`
+	Phone             string     `json:"phone" gorm:"uniqueIndex;size:20;default:null;comment:手机号，未绑定时为NULL"`
+	Email             string     `json:"email" gorm:"uniqueIndex;size:100;default:null;comment:邮箱，未填写时为NULL"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at,omitempty" gorm:"comment:邮箱验证时间"`
+	Balance           int64      `json:"balance" gorm:"default:0;comment:余额(分为单位)"`
+	FrozenBalance     int64      `json:"frozen_balance" gorm:"default:0;comment:冻结余额(分为单位)，包含在余额中"`
+	Points            int64      `json:"points" gorm:"default:0;comment:积分"`
+	LastIP            string     `json:"last_ip" gorm:"size:45;comment:最后登录IP"`
+	LastTime          *time.Time `json:"last_time" gorm:"comment:最后登录时间"`
+	LockedUntil       *time.Time `json:"locked_until,omitempty" gorm:"comment:临时锁定截止时间"`
+	DeletionAt        *time.Time `json:"deletion_at,omitempty" gorm:"index;comment:申请注销后的生效时间"`
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty" gorm:"comment:最近一次设置密码的时间"`
 }
 
 // UserStatus 会员状态常量
@@ -70,11 +73,21 @@ func (u *User) CheckPassword(password string) bool {
 	return utils.CheckPassword(password, u.Password)
 }
 
+// NeedsRehash 检查密码哈希是否为旧系统格式或使用了过时的加密参数
+func (u *User) NeedsRehash() bool {
+	return utils.NeedsRehash(u.Password, DefaultPasswordConfig)
+}
+
 // GetBalanceFloat 获取余额的浮点数表示（元）
 func (u *User) GetBalanceFloat() float64 {
 	return float64(u.Balance) / 100.0
 }
 
+// AvailableBalance 获取可用余额（分），即余额减去冻结余额
+func (u *User) AvailableBalance() int64 {
+	return u.Balance - u.FrozenBalance
+}
+
 // SetBalanceFloat 设置余额（元）
 func (u *User) SetBalanceFloat(amount float64) {
 	u.Balance = int64(amount * 100)
@@ -90,6 +103,16 @@ func (u *User) IsLocked() bool {
 	return u.Status == UserStatusLocked
 }
 
+// IsLockExpired 检查临时锁定是否已到期
+func (u *User) IsLockExpired(now time.Time) bool {
+	return u.IsLocked() && u.LockedUntil != nil && !now.Before(*u.LockedUntil)
+}
+
+// IsEmailVerified 检查邮箱是否已验证
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
 // UpdateLastLogin 更新最后登录信息
 func (u *User) UpdateLastLogin(ip string) {
 	now := time.Now()
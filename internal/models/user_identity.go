@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// UserIdentity 第三方登录身份
+// 一个会员可绑定多个第三方账号（微信小程序、微信公众号、支付宝等），每种登录方式最多绑定一个
+// 同一第三方账号在每个租户内只能绑定一个会员，(tenant_id, provider, provider_user_id)唯一索引在迁移后单独创建
+type UserIdentity struct {
+	BaseModel
+	UserID         uint64     `json:"user_id" gorm:"not null;uniqueIndex:idx_user_identities_user_provider;comment:用户ID"`
+	Provider       string     `json:"provider" gorm:"size:32;not null;uniqueIndex:idx_user_identities_user_provider;comment:登录方式"`
+	ProviderUserID string     `json:"provider_user_id" gorm:"size:128;not null;comment:第三方用户标识（如OpenID）"`
+	UnionID        string     `json:"union_id,omitempty" gorm:"size:128;index;comment:跨应用统一标识（如微信UnionID）"`
+	Nickname       string     `json:"nickname" gorm:"size:100;comment:第三方昵称"`
+	Avatar         string     `json:"avatar" gorm:"size:255;comment:第三方头像"`
+	LastLoginAt    *time.Time `json:"last_login_at,omitempty" gorm:"comment:最后登录时间"`
+}
+
+// TableName 指定表名
+func (UserIdentity) TableName() string {
+	return "m_user_identities"
+}
+
+// 内置登录方式
+// 通用OAuth2登录方式以配置中的名称作为标识
+const (
+	IdentityProviderWeChatMini = "wechat_mini" // 微信小程序
+	IdentityProviderWeChatWeb  = "wechat_web"  // 微信公众号网页授权
+	IdentityProviderAlipay     = "alipay"      // 支付宝
+)
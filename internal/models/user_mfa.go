@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// UserMFA 会员两步验证（TOTP）配置
+// 开始绑定时生成密钥，用户使用身份验证器输入动态口令确认后才启用
+type UserMFA struct {
+	BaseModel
+	UserID       uint64     `json:"user_id" gorm:"not null;uniqueIndex;comment:用户ID"`
+	Secret       string     `json:"-" gorm:"size:64;not null;comment:TOTP密钥"`
+	EnabledAt    *time.Time `json:"enabled_at" gorm:"comment:启用时间"`
+	LastUsedStep int64      `json:"-" gorm:"default:0;comment:最近一次使用的时间步，用于防重放"`
+}
+
+// TableName 指定表名
+func (UserMFA) TableName() string {
+	return "m_user_mfa"
+}
+
+// IsEnabled 检查两步验证是否已启用
+func (m *UserMFA) IsEnabled() bool {
+	return m.EnabledAt != nil
+}
+
+// UserRecoveryCode 两步验证恢复码
+// 恢复码仅保存哈希，每个恢复码只能使用一次
+type UserRecoveryCode struct {
+	BaseModel
+	UserID   uint64     `json:"user_id" gorm:"not null;index;comment:用户ID"`
+	CodeHash string     `json:"-" gorm:"size:255;not null;comment:恢复码哈希"`
+	UsedAt   *time.Time `json:"used_at" gorm:"comment:使用时间"`
+}
+
+// TableName 指定表名
+func (UserRecoveryCode) TableName() string {
+	return "m_user_recovery_codes"
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserSession 会员登录会话
+// 每次登录对应一个令牌族，刷新令牌轮换时沿用同一会话
+type UserSession struct {
+	BaseModel
+	UserID     uint64     `json:"user_id" gorm:"not null;index;comment:用户ID"`
+	FamilyID   string     `json:"-" gorm:"size:64;not null;uniqueIndex;comment:令牌族ID"`
+	LoginType  string     `json:"login_type" gorm:"size:20;comment:登录方式"`
+	Device     string     `json:"device" gorm:"size:100;comment:设备"`
+	UserAgent  string     `json:"user_agent" gorm:"size:500;comment:User-Agent"`
+	IP         string     `json:"ip" gorm:"size:45;comment:IP地址"`
+	LastSeenAt time.Time  `json:"last_seen_at" gorm:"comment:最后活跃时间"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" gorm:"comment:注销时间"`
+}
+
+// LoginType 登录方式常量
+const (
+	LoginTypePassword = "password" // 密码登录
+	LoginTypeRegister = "register" // 注册后自动登录
+	LoginTypeWeChat   = "wechat"   // 微信登录
+	LoginTypeSMS      = "sms"      // 短信验证码登录
+	LoginTypeOAuth    = "oauth"    // 第三方应用授权登录
+	LoginTypeSocial   = "social"   // 第三方账号登录（微信公众号、支付宝等）
+)
+
+// SessionStatus 会话状态常量
+const (
+	SessionStatusRevoked = 0 // 已注销
+	SessionStatusActive  = 1 // 有效
+)
+
+// TableName 指定表名
+func (UserSession) TableName() string {
+	return "m_user_sessions"
+}
+
+// IsRevoked 检查会话是否已注销
+func (s *UserSession) IsRevoked() bool {
+	return s.RevokedAt != nil || s.Status == SessionStatusRevoked
+}
+
+// ScopeActiveSessions 查询有效会话
+func ScopeActiveSessions(db *gorm.DB) *gorm.DB {
+	return db.Where("status = ? AND revoked_at IS NULL", SessionStatusActive)
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AccountMergeService 账号合并服务接口
+type AccountMergeService interface {
+	// 将被合并账号的资产及数据转入主账号，并禁用被合并账号
+	Merge(ctx context.Context, req *MergeAccountsRequest, reason string, operatorID uint64) (*models.AccountMerge, error)
+}
+
+// MergeAccountsRequest 账号合并请求
+// @Description 账号合并请求参数，被合并账号的余额、积分、变动记录、文件及第三方账号转入主账号
+type MergeAccountsRequest struct {
+	PrimaryUserID   uint64 `json:"primary_user_id" binding:"required" example:"1" description:"保留的主账号ID"`
+	SecondaryUserID uint64 `json:"secondary_user_id" binding:"required" example:"2" description:"被合并的账号ID，合并后禁用"`
+	Remark          string `json:"remark" binding:"max=255" example:"同一会员重复注册" description:"备注（可选）"`
+}
+
+// accountMergeServiceImpl 账号合并服务实现
+type accountMergeServiceImpl struct {
+	db         *gorm.DB
+	jwtService JWTService
+}
+
+// NewAccountMergeService 创建账号合并服务实例
+func NewAccountMergeService() AccountMergeService {
+	return &accountMergeServiceImpl{
+		db:         database.GetDB(),
+		jwtService: NewJWTService(),
+	}
+}
+
+// Merge 合并账号
+// 在同一事务中转移余额、积分、余额/积分变动记录、文件及第三方账号，写入合并记录并禁用被合并账号。
+// 转移的变动记录保留原有的变动后余额/积分，合并前后的资产变化以合并记录为准
+func (s *accountMergeServiceImpl) Merge(ctx context.Context, req *MergeAccountsRequest, reason string, operatorID uint64) (*models.AccountMerge, error) {
+	if req.PrimaryUserID == req.SecondaryUserID {
+		return nil, common.ErrMergeSameAccount
+	}
+
+	tenantID := database.GetTenantIDFromContext(ctx)
+	record := &models.AccountMerge{
+		PrimaryUserID:   req.PrimaryUserID,
+		SecondaryUserID: req.SecondaryUserID,
+		Reason:          reason,
+		OperatorID:      operatorID,
+		Remark:          req.Remark,
+	}
+	record.TenantID = tenantID
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 按ID顺序锁定两个账号（仅允许合并当前租户下的账号），避免并发合并时死锁
+		// SQLite驱动忽略行锁子句，由数据库级写锁保证事务串行
+		users := make(map[uint64]*models.User, 2)
+		ids := []uint64{req.PrimaryUserID, req.SecondaryUserID}
+		if ids[0] > ids[1] {
+			ids[0], ids[1] = ids[1], ids[0]
+		}
+		for _, id := range ids {
+			var user models.User
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Scopes(models.ScopeByTenant(tenantID)).
+				First(&user, id).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return common.ErrUserNotFound
+				}
+				return fmt.Errorf("查询用户失败: %w", err)
+			}
+			users[id] = &user
+		}
+		primary, secondary := users[req.PrimaryUserID], users[req.SecondaryUserID]
+
+		if primary.Status == models.UserStatusDisabled || secondary.Status == models.UserStatusDisabled {
+			return common.ErrMergeAccountInactive
+		}
+
+		// 每种登录方式只能绑定一个第三方账号，两个账号绑定了同一登录方式时无法合并
+		var conflicts int64
+		if err := tx.Model(&models.UserIdentity{}).
+			Where("user_id = ? AND provider IN (?)", primary.ID,
+				tx.Model(&models.UserIdentity{}).Select("provider").Where("user_id = ?", secondary.ID)).
+			Count(&conflicts).Error; err != nil {
+			return fmt.Errorf("查询绑定账号失败: %w", err)
+		}
+		if conflicts > 0 {
+			return common.ErrMergeIdentityConflict
+		}
+
+		moves := []struct {
+			model interface{}
+			count *int64
+			name  string
+		}{
+			{&models.BalanceRecord{}, &record.BalanceRecords, "余额记录"},
+			{&models.PointsRecord{}, &record.PointsRecords, "积分记录"},
+			{&models.File{}, &record.Files, "文件"},
+			{&models.UserIdentity{}, &record.Identities, "第三方账号"},
+		}
+		for _, move := range moves {
+			result := tx.Unscoped().Model(move.model).
+				Where("user_id = ?", secondary.ID).
+				Update("user_id", primary.ID)
+			if result.Error != nil {
+				return fmt.Errorf("转移%s失败: %w", move.name, result.Error)
+			}
+			*move.count = result.RowsAffected
+		}
+
+		// 尚未建账的账号先按合并前的余额建账
+		if err := ensureOpeningBalance(tx, tenantID, secondary.ID, secondary.Balance); err != nil {
+			return err
+		}
+		if err := ensureOpeningBalance(tx, tenantID, primary.ID, primary.Balance); err != nil {
+			return err
+		}
+
+		record.Balance = secondary.Balance
+		record.Points = secondary.Points
+		// 冻结记录已随余额记录转移，冻结余额一并转入主账号
+		if err := tx.Model(primary).Updates(map[string]interface{}{
+			"balance":        gorm.Expr("balance + ?", secondary.Balance),
+			"frozen_balance": gorm.Expr("frozen_balance + ?", secondary.FrozenBalance),
+			"points":         gorm.Expr("points + ?", secondary.Points),
+		}).Error; err != nil {
+			return fmt.Errorf("更新主账号资产失败: %w", err)
+		}
+
+		// 被合并账号的钱包余额转入主账号钱包
+		entry := &models.JournalEntry{
+			Type:        models.JournalTypeMerge,
+			UserID:      primary.ID,
+			Description: fmt.Sprintf("账号合并转入，被合并账号ID: %d", secondary.ID),
+		}
+		entry.TenantID = tenantID
+		if err := postWalletEntry(tx, entry, secondary.Balance, models.LedgerPosting{
+			Account: models.LedgerAccountMemberWallet,
+			UserID:  secondary.ID,
+		}); err != nil {
+			return err
+		}
+
+		if err := tx.Model(secondary).Updates(map[string]interface{}{
+			"balance":        0,
+			"frozen_balance": 0,
+			"points":         0,
+			"status":         models.UserStatusDisabled,
+		}).Error; err != nil {
+			return fmt.Errorf("禁用被合并账号失败: %w", err)
+		}
+
+		if err := tx.Create(record).Error; err != nil {
+			return fmt.Errorf("创建合并记录失败: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 被合并账号已禁用，注销其全部会话使已签发的令牌立即失效
+	sessions := &sessionServiceImpl{db: s.db, jwtService: s.jwtService}
+	if _, err := sessions.RevokeAllSessions(ctx, req.SecondaryUserID); err != nil {
+		logger.WithFields(logrus.Fields{
+			"user_id": req.SecondaryUserID,
+			"error":   err.Error(),
+		}).Warn("注销被合并账号的会话失败")
+	}
+
+	logger.WithFields(logrus.Fields{
+		"primary_user_id":   record.PrimaryUserID,
+		"secondary_user_id": record.SecondaryUserID,
+		"reason":            record.Reason,
+		"operator_id":       record.OperatorID,
+	}).Info("账号合并完成")
+
+	return record, nil
+}
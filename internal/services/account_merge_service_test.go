@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// createMergeTestUser 创建带资产、记录、文件及第三方账号的测试用户
+func createMergeTestUser(t *testing.T, db *gorm.DB, name, phone string, balance, points int64, provider string) *models.User {
+	user := &models.User{Username: name, Password: "x", Email: name + "@example.com", Phone: phone, Balance: balance, Points: points}
+	require.NoError(t, db.Create(user).Error)
+
+	require.NoError(t, db.Create(&models.BalanceRecord{UserID: user.ID, Amount: balance, Type: models.BalanceTypeRecharge, BalanceAfter: balance}).Error)
+	require.NoError(t, db.Create(&models.PointsRecord{UserID: user.ID, Quantity: points, Type: models.PointsTypeObtain, PointsAfter: points}).Error)
+	require.NoError(t, db.Create(&models.File{UserID: user.ID, Filename: name + ".png", Path: name, URL: name, Size: 1}).Error)
+	if provider != "" {
+		require.NoError(t, db.Create(&models.UserIdentity{UserID: user.ID, Provider: provider, ProviderUserID: name + "_openid"}).Error)
+	}
+	return user
+}
+
+func TestAccountMergeService_Merge(t *testing.T) {
+	config.Init()
+	logger.Init()
+	db := setupTestDB(t)
+	service := &accountMergeServiceImpl{db: db, jwtService: NewJWTService()}
+	ctx := context.Background()
+
+	primary := createMergeTestUser(t, db, "primary", "13800138010", 1000, 50, "")
+	secondary := createMergeTestUser(t, db, "secondary", "13800138011", 500, 30, models.IdentityProviderWeChatMini)
+
+	t.Run("合并账号", func(t *testing.T) {
+		record, err := service.Merge(ctx, &MergeAccountsRequest{PrimaryUserID: primary.ID, SecondaryUserID: secondary.ID}, models.AccountMergeReasonManual, 99)
+		require.NoError(t, err)
+		assert.Equal(t, int64(500), record.Balance)
+		assert.Equal(t, int64(30), record.Points)
+		assert.Equal(t, int64(1), record.BalanceRecords)
+		assert.Equal(t, int64(1), record.PointsRecords)
+		assert.Equal(t, int64(1), record.Files)
+		assert.Equal(t, int64(1), record.Identities)
+		assert.Equal(t, uint64(99), record.OperatorID)
+
+		var merged, absorbed models.User
+		require.NoError(t, db.First(&merged, primary.ID).Error)
+		require.NoError(t, db.First(&absorbed, secondary.ID).Error)
+		assert.Equal(t, int64(1500), merged.Balance)
+		assert.Equal(t, int64(80), merged.Points)
+		assert.Equal(t, int64(0), absorbed.Balance)
+		assert.Equal(t, int64(0), absorbed.Points)
+		assert.Equal(t, int8(models.UserStatusDisabled), absorbed.Status)
+
+		var count int64
+		db.Model(&models.BalanceRecord{}).Where("user_id = ?", primary.ID).Count(&count)
+		assert.Equal(t, int64(2), count)
+		db.Model(&models.PointsRecord{}).Where("user_id = ?", primary.ID).Count(&count)
+		assert.Equal(t, int64(2), count)
+		db.Model(&models.File{}).Where("user_id = ?", primary.ID).Count(&count)
+		assert.Equal(t, int64(2), count)
+		db.Model(&models.UserIdentity{}).Where("user_id = ?", primary.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+
+		var audit models.AccountMerge
+		require.NoError(t, db.Where("secondary_user_id = ?", secondary.ID).First(&audit).Error)
+		assert.Equal(t, primary.ID, audit.PrimaryUserID)
+	})
+
+	t.Run("被合并账号不能再次合并", func(t *testing.T) {
+		_, err := service.Merge(ctx, &MergeAccountsRequest{PrimaryUserID: primary.ID, SecondaryUserID: secondary.ID}, models.AccountMergeReasonManual, 0)
+		assert.Equal(t, common.ErrMergeAccountInactive, err)
+	})
+
+	t.Run("不能合并同一账号", func(t *testing.T) {
+		_, err := service.Merge(ctx, &MergeAccountsRequest{PrimaryUserID: primary.ID, SecondaryUserID: primary.ID}, models.AccountMergeReasonManual, 0)
+		assert.Equal(t, common.ErrMergeSameAccount, err)
+	})
+
+	t.Run("账号不存在", func(t *testing.T) {
+		_, err := service.Merge(ctx, &MergeAccountsRequest{PrimaryUserID: primary.ID, SecondaryUserID: 9999}, models.AccountMergeReasonManual, 0)
+		assert.Equal(t, common.ErrUserNotFound, err)
+	})
+
+	t.Run("第三方账号冲突时不做任何修改", func(t *testing.T) {
+		other := createMergeTestUser(t, db, "other", "13800138012", 200, 10, models.IdentityProviderWeChatMini)
+
+		_, err := service.Merge(ctx, &MergeAccountsRequest{PrimaryUserID: primary.ID, SecondaryUserID: other.ID}, models.AccountMergeReasonManual, 0)
+		assert.Equal(t, common.ErrMergeIdentityConflict, err)
+
+		var unchanged models.User
+		require.NoError(t, db.First(&unchanged, other.ID).Error)
+		assert.Equal(t, int64(200), unchanged.Balance)
+		assert.Equal(t, int8(models.UserStatusActive), unchanged.Status)
+	})
+}
+
+func TestSocialLoginService_MergeOnPhoneCollision(t *testing.T) {
+	service, db := setupSocialLoginService(t)
+	ctx := context.Background()
+
+	// 先通过微信登录自动注册，再使用已注册账号的手机号授权登录
+	wechatLogin, err := service.LoginWithIdentity(ctx, &ExternalIdentity{Provider: models.IdentityProviderWeChatMini, ProviderUserID: "openid_collide"})
+	require.NoError(t, err)
+	require.True(t, wechatLogin.IsNewUser)
+	require.NoError(t, db.Model(wechatLogin.User).Update("points", 40).Error)
+
+	phoneUser := &models.User{Username: "phoneuser", Password: "x", Email: "phone@example.com", Phone: "13800138020", Points: 60}
+	require.NoError(t, db.Create(phoneUser).Error)
+
+	resp, err := service.LoginWithIdentity(ctx, &ExternalIdentity{
+		Provider:       models.IdentityProviderWeChatMini,
+		ProviderUserID: "openid_collide",
+		Phone:          "13800138020",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, phoneUser.ID, resp.User.ID)
+
+	var identity models.UserIdentity
+	require.NoError(t, db.Where("provider_user_id = ?", "openid_collide").First(&identity).Error)
+	assert.Equal(t, phoneUser.ID, identity.UserID)
+
+	var merged, absorbed models.User
+	require.NoError(t, db.First(&merged, phoneUser.ID).Error)
+	require.NoError(t, db.First(&absorbed, wechatLogin.User.ID).Error)
+	assert.Equal(t, int64(100), merged.Points)
+	assert.Equal(t, int8(models.UserStatusDisabled), absorbed.Status)
+
+	var audit models.AccountMerge
+	require.NoError(t, db.Where("secondary_user_id = ?", absorbed.ID).First(&audit).Error)
+	assert.Equal(t, models.AccountMergeReasonWeChatPhone, audit.Reason)
+
+	// 合并后再次登录直接进入主账号
+	again, err := service.LoginWithIdentity(ctx, &ExternalIdentity{Provider: models.IdentityProviderWeChatMini, ProviderUserID: "openid_collide"})
+	require.NoError(t, err)
+	assert.Equal(t, phoneUser.ID, again.User.ID)
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"strings"
+	"time"
+)
+
+// 一次性链接令牌用途
+const (
+	ActionPasswordReset = "password_reset" // 重置密码
+	ActionVerifyEmail   = "verify_email"   // 验证邮箱
+)
+
+// 一次性链接令牌使用记录缓存键前缀
+const actionTokenUsedKeyPrefix = "action:used:"
+
+// ActionTokenClaims 一次性链接令牌内容
+type ActionTokenClaims struct {
+	Action    string `json:"act"`
+	UserID    uint64 `json:"uid"`
+	TenantID  string `json:"tid"`
+	Subject   string `json:"sub,omitempty"` // 绑定的业务数据，如邮箱地址或密码指纹
+	ID        string `json:"jti"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// actionTokenSigner 一次性链接令牌签发与校验
+// 令牌格式为 base64url(payload).base64url(HMAC-SHA256)，使用记录保存在缓存中保证只能使用一次
+type actionTokenSigner struct {
+	secret []byte
+	cache  cache.Cache
+}
+
+// newActionTokenSigner 创建一次性链接令牌签发器
+func newActionTokenSigner(secret string, c cache.Cache) *actionTokenSigner {
+	return &actionTokenSigner{secret: []byte(secret), cache: c}
+}
+
+// Issue 签发令牌
+func (s *actionTokenSigner) Issue(action string, userID uint64, tenantID, subject string, ttl time.Duration) (string, error) {
+	id, err := NewTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(&ActionTokenClaims{
+		Action:    action,
+		UserID:    userID,
+		TenantID:  tenantID,
+		Subject:   subject,
+		ID:        id,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("生成令牌失败: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(action, encoded), nil
+}
+
+// Parse 校验令牌签名、用途和有效期，不消耗令牌
+func (s *actionTokenSigner) Parse(action, token string) (*ActionTokenClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(action, encoded))) {
+		return nil, common.ErrInvalidLinkToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, common.ErrInvalidLinkToken
+	}
+
+	var claims ActionTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, common.ErrInvalidLinkToken
+	}
+	if claims.Action != action || claims.ID == "" || time.Now().Unix() >= claims.ExpiresAt {
+		return nil, common.ErrInvalidLinkToken
+	}
+
+	return &claims, nil
+}
+
+// Consume 标记令牌已使用，令牌已被使用过时返回错误
+func (s *actionTokenSigner) Consume(ctx context.Context, claims *ActionTokenClaims) error {
+	ttl := time.Until(time.Unix(claims.ExpiresAt, 0))
+	if ttl <= 0 {
+		return common.ErrInvalidLinkToken
+	}
+
+	first, err := s.cache.SetNX(ctx, actionTokenUsedKeyPrefix+claims.ID, "1", ttl)
+	if err != nil {
+		return fmt.Errorf("记录令牌使用失败: %w", err)
+	}
+	if !first {
+		return common.ErrInvalidLinkToken
+	}
+	return nil
+}
+
+// sign 计算签名，不同用途使用不同的签名上下文，防止令牌跨用途使用
+func (s *actionTokenSigner) sign(action, encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(action + "." + encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// passwordFingerprint 密码哈希指纹，修改密码后旧的重置链接随之失效
+func passwordFingerprint(passwordHash string) string {
+	sum := sha256.Sum256([]byte(passwordHash))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"member-link-lite/config"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 签名请求头
+const (
+	HeaderAPIKeyID     = "X-Api-Key"
+	HeaderAPITimestamp = "X-Api-Timestamp"
+	HeaderAPINonce     = "X-Api-Nonce"
+	HeaderAPISignature = "X-Api-Signature"
+)
+
+// apiNonceKeyPrefix 请求随机串缓存键前缀
+const apiNonceKeyPrefix = "api:nonce:"
+
+// APIClientService 服务端接入客户端服务接口
+type APIClientService interface {
+	// 创建客户端，密钥仅在此时返回
+	CreateClient(ctx context.Context, req *CreateAPIClientRequest, createdBy uint64) (*APIClientCredentials, error)
+	// 获取客户端列表
+	ListClients(ctx context.Context) ([]*models.APIClient, error)
+	// 停用客户端
+	DisableClient(ctx context.Context, clientID uint64) error
+	// 验证签名请求，返回发起请求的客户端
+	VerifyRequest(ctx context.Context, req *SignedRequest) (*models.APIClient, error)
+}
+
+// CreateAPIClientRequest 创建客户端请求
+type CreateAPIClientRequest struct {
+	Name   string   `json:"name" binding:"required,max=100" example:"订单服务"`                       // 客户端名称
+	Scopes []string `json:"scopes" binding:"required,min=1" example:"balance:write,points:write"` // 授权范围
+}
+
+// APIClientCredentials 客户端凭证
+type APIClientCredentials struct {
+	Client *models.APIClient `json:"client"`
+	KeyID  string            `json:"key_id"`
+	Secret string            `json:"secret"` // 仅返回一次，请妥善保存
+}
+
+// SignedRequest 待验证的签名请求
+type SignedRequest struct {
+	KeyID     string
+	Timestamp string
+	Nonce     string
+	Signature string
+	Method    string
+	Path      string // 请求路径（含查询参数）
+	Body      []byte
+}
+
+// APIClientConfig 签名验证配置
+type APIClientConfig struct {
+	TimestampTolerance time.Duration // 允许的时间偏差
+	EncryptionKey      []byte        // 签名密钥的加密密钥
+}
+
+// LoadAPIClientConfig 读取签名验证配置
+func LoadAPIClientConfig() APIClientConfig {
+	return APIClientConfig{
+		TimestampTolerance: time.Duration(config.GetInt("api_client.timestamp_tolerance")) * time.Second,
+		EncryptionKey:      utils.DeriveEncryptionKey(config.GetString("api_client.encryption_key")),
+	}
+}
+
+// apiClientScopes 允许授予客户端的授权范围
+var apiClientScopes = []string{
+	models.ScopeBalanceWrite,
+	models.ScopePointsWrite,
+	models.ScopeAssetRead,
+}
+
+// apiClientServiceImpl 服务端接入客户端服务实现
+type apiClientServiceImpl struct {
+	db    *gorm.DB
+	cache cache.Cache
+	cfg   APIClientConfig
+}
+
+// NewAPIClientService 创建服务端接入客户端服务实例
+func NewAPIClientService() APIClientService {
+	return &apiClientServiceImpl{
+		db:    database.GetDB(),
+		cache: database.GetCache(),
+		cfg:   LoadAPIClientConfig(),
+	}
+}
+
+// CreateClient 创建客户端
+func (s *apiClientServiceImpl) CreateClient(ctx context.Context, req *CreateAPIClientRequest, createdBy uint64) (*APIClientCredentials, error) {
+	for _, scope := range req.Scopes {
+		if !containsString(apiClientScopes, scope) {
+			return nil, common.NewCustomError(common.CodeBadRequest, "不支持的授权范围: "+scope)
+		}
+	}
+
+	keyID, err := randomHex(12)
+	if err != nil {
+		return nil, fmt.Errorf("生成密钥ID失败: %w", err)
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, fmt.Errorf("生成密钥失败: %w", err)
+	}
+
+	// 签名密钥与密钥等价，只保存加密后的密文，读取数据库不足以伪造签名
+	encryptedKey, err := utils.EncryptString(s.cfg.EncryptionKey, APISigningKey(secret))
+	if err != nil {
+		return nil, fmt.Errorf("加密签名密钥失败: %w", err)
+	}
+
+	client := &models.APIClient{
+		Name:                req.Name,
+		KeyID:               "ak_" + keyID,
+		EncryptedSigningKey: encryptedKey,
+		Scopes:              strings.Join(req.Scopes, ","),
+		CreatedBy:           createdBy,
+	}
+	client.TenantID = database.GetTenantIDFromContext(ctx)
+
+	if err := s.db.WithContext(ctx).Create(client).Error; err != nil {
+		return nil, fmt.Errorf("创建客户端失败: %w", err)
+	}
+
+	return &APIClientCredentials{
+		Client: client,
+		KeyID:  client.KeyID,
+		Secret: secret,
+	}, nil
+}
+
+// ListClients 获取客户端列表
+func (s *apiClientServiceImpl) ListClients(ctx context.Context) ([]*models.APIClient, error) {
+	var clients []*models.APIClient
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(database.GetTenantIDFromContext(ctx))).
+		Order("id DESC").
+		Find(&clients).Error; err != nil {
+		return nil, fmt.Errorf("查询客户端失败: %w", err)
+	}
+	return clients, nil
+}
+
+// DisableClient 停用客户端
+func (s *apiClientServiceImpl) DisableClient(ctx context.Context, clientID uint64) error {
+	result := s.db.WithContext(ctx).
+		Model(&models.APIClient{}).
+		Scopes(models.ScopeByTenant(database.GetTenantIDFromContext(ctx))).
+		Where("id = ?", clientID).
+		Update("status", models.StatusDisabled)
+	if result.Error != nil {
+		return fmt.Errorf("停用客户端失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return common.ErrAPIClientNotFound
+	}
+	return nil
+}
+
+// VerifyRequest 验证签名请求
+// 依次校验时间戳、客户端状态、签名，最后登记随机串防止重放
+func (s *apiClientServiceImpl) VerifyRequest(ctx context.Context, req *SignedRequest) (*models.APIClient, error) {
+	if req.KeyID == "" || req.Timestamp == "" || req.Nonce == "" || req.Signature == "" {
+		return nil, common.ErrInvalidSignature
+	}
+	if len(req.Nonce) < 8 || len(req.Nonce) > 64 {
+		return nil, common.ErrInvalidSignature
+	}
+
+	ts, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return nil, common.ErrInvalidSignature
+	}
+	skew := time.Since(time.Unix(ts, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > s.cfg.TimestampTolerance {
+		return nil, common.ErrRequestExpired
+	}
+
+	var client models.APIClient
+	if err := s.db.WithContext(ctx).Where("key_id = ?", req.KeyID).First(&client).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, common.ErrInvalidSignature
+		}
+		return nil, fmt.Errorf("查询客户端失败: %w", err)
+	}
+	if !client.IsActive() {
+		return nil, common.ErrAPIClientDisabled
+	}
+
+	signingKey, err := utils.DecryptString(s.cfg.EncryptionKey, client.EncryptedSigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("解密签名密钥失败: %w", err)
+	}
+
+	expected := SignAPIRequest(signingKey, req.Method, req.Path, req.Timestamp, req.Nonce, req.Body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(req.Signature))) {
+		return nil, common.ErrInvalidSignature
+	}
+
+	// 随机串在时间偏差窗口内唯一，窗口外的请求已被时间戳校验拒绝
+	first, err := s.cache.SetNX(ctx, apiNonceKeyPrefix+client.KeyID+":"+req.Nonce, "1", 2*s.cfg.TimestampTolerance)
+	if err != nil {
+		return nil, fmt.Errorf("记录请求随机串失败: %w", err)
+	}
+	if !first {
+		return nil, common.ErrNonceReused
+	}
+
+	// 记录最后调用时间，失败不影响本次请求
+	now := time.Now()
+	s.db.WithContext(ctx).Model(&client).UpdateColumn("last_used_at", now)
+	client.LastUsedAt = &now
+
+	return &client, nil
+}
+
+// APISigningKey 由客户端密钥计算签名密钥
+// 签名密钥为密钥SHA256摘要的十六进制字符串，客户端使用该值签名；服务端保存其加密密文
+func APISigningKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// SignAPIRequest 计算请求签名
+// 待签名字符串为 METHOD\nPATH\nTIMESTAMP\nNONCE\nHEX(SHA256(BODY))，使用HMAC-SHA256签名后取十六进制
+func SignAPIRequest(signingKey, method, path, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	payload := strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomHex 生成指定字节数的随机十六进制字符串
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// containsString 检查切片是否包含指定字符串
+func containsString(list []string, target string) bool {
+	for _, item := range list {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedRequest 按客户端的方式构造签名请求
+func signedRequest(keyID, secret, nonce string, ts time.Time, body string) *SignedRequest {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	return &SignedRequest{
+		KeyID:     keyID,
+		Timestamp: timestamp,
+		Nonce:     nonce,
+		Signature: SignAPIRequest(APISigningKey(secret), "POST", "/api/v1/asset/points/change", timestamp, nonce, []byte(body)),
+		Method:    "POST",
+		Path:      "/api/v1/asset/points/change",
+		Body:      []byte(body),
+	}
+}
+
+func TestAPIClientService_VerifyRequest(t *testing.T) {
+	config.Init()
+	db := setupTestDB(t)
+	service := &apiClientServiceImpl{db: db, cache: cache.NewMemoryCache(), cfg: LoadAPIClientConfig()}
+	ctx := context.Background()
+
+	_, err := service.CreateClient(ctx, &CreateAPIClientRequest{Name: "订单服务", Scopes: []string{"user:delete"}}, 1)
+	assert.Error(t, err)
+
+	credentials, err := service.CreateClient(ctx, &CreateAPIClientRequest{
+		Name:   "订单服务",
+		Scopes: []string{models.ScopePointsWrite, models.ScopeBalanceWrite},
+	}, 1)
+	require.NoError(t, err)
+	assert.NotContains(t, credentials.Client.EncryptedSigningKey, APISigningKey(credentials.Secret))
+
+	body := `{"user_id":1,"quantity":100,"type":"obtain"}`
+
+	// 正常签名请求
+	client, err := service.VerifyRequest(ctx, signedRequest(credentials.KeyID, credentials.Secret, "nonce-0001", time.Now(), body))
+	require.NoError(t, err)
+	assert.True(t, client.HasScope(models.ScopePointsWrite))
+	assert.False(t, client.HasScope(models.ScopeAssetRead))
+	assert.NotNil(t, client.LastUsedAt)
+
+	// 重放同一请求
+	_, err = service.VerifyRequest(ctx, signedRequest(credentials.KeyID, credentials.Secret, "nonce-0001", time.Now(), body))
+	assert.Equal(t, common.ErrNonceReused, err)
+
+	// 篡改请求体
+	req := signedRequest(credentials.KeyID, credentials.Secret, "nonce-0002", time.Now(), body)
+	req.Body = []byte(`{"user_id":1,"quantity":100000,"type":"obtain"}`)
+	_, err = service.VerifyRequest(ctx, req)
+	assert.Equal(t, common.ErrInvalidSignature, err)
+
+	// 篡改路径
+	req = signedRequest(credentials.KeyID, credentials.Secret, "nonce-0003", time.Now(), body)
+	req.Path = "/api/v1/asset/balance/change"
+	_, err = service.VerifyRequest(ctx, req)
+	assert.Equal(t, common.ErrInvalidSignature, err)
+
+	// 错误密钥
+	_, err = service.VerifyRequest(ctx, signedRequest(credentials.KeyID, "wrong-secret", "nonce-0004", time.Now(), body))
+	assert.Equal(t, common.ErrInvalidSignature, err)
+
+	// 时间戳超出允许偏差
+	_, err = service.VerifyRequest(ctx, signedRequest(credentials.KeyID, credentials.Secret, "nonce-0005", time.Now().Add(-10*time.Minute), body))
+	assert.Equal(t, common.ErrRequestExpired, err)
+
+	// 停用后拒绝调用
+	require.NoError(t, service.DisableClient(ctx, credentials.Client.ID))
+	_, err = service.VerifyRequest(ctx, signedRequest(credentials.KeyID, credentials.Secret, "nonce-0006", time.Now(), body))
+	assert.Equal(t, common.ErrAPIClientDisabled, err)
+}
@@ -2,21 +2,38 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"gorm.io/gorm"
+	"member-link-lite/config"
+	"member-link-lite/internal/database"
 	"member-link-lite/internal/models"
 	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
 	"member-link-lite/pkg/utils"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 // AssetService 资产服务接口
 type AssetService interface {
 	// 获取用户资产信息
 	GetAssetInfo(ctx context.Context, userID uint64) (*AssetInfo, error)
-	// 余额变动
-	ChangeBalance(ctx context.Context, req *ChangeBalanceRequest) error
-	// 积分变动
-	ChangePoints(ctx context.Context, req *ChangePointsRequest) error
+	// 余额变动，返回变动记录；幂等键重复时返回首次创建的记录
+	ChangeBalance(ctx context.Context, req *ChangeBalanceRequest) (*models.BalanceRecord, error)
+	// 积分变动，返回变动记录；幂等键重复时返回首次创建的记录
+	ChangePoints(ctx context.Context, req *ChangePointsRequest) (*models.PointsRecord, error)
+	// 冻结余额，生成处理中的冻结记录；同一订单重复冻结相同金额时返回已有的冻结记录
+	HoldBalance(ctx context.Context, req *HoldBalanceRequest) (*models.BalanceRecord, error)
+	// 按订单扣款冻结的余额，支持部分扣款，未扣款的部分解除冻结
+	CaptureHold(ctx context.Context, req *CaptureHoldRequest) (*models.BalanceRecord, error)
+	// 按订单释放冻结的余额
+	ReleaseHold(ctx context.Context, req *ReleaseHoldRequest) (*models.BalanceRecord, error)
+	// 释放所有租户中到期未扣款的冻结，返回释放的数量
+	ExpireHolds(ctx context.Context) (int, error)
 	// 获取余额变动记录
 	GetBalanceRecords(ctx context.Context, userID uint64, req *GetRecordsRequest) (*common.PaginateResult, error)
 	// 获取积分变动记录
@@ -26,32 +43,75 @@ type AssetService interface {
 // AssetInfo 资产信息
 // @Description 用户资产信息，包含余额和积分
 type AssetInfo struct {
-	Balance      int64   `json:"balance" example:"10000" description:"余额(分为单位)"`               // 余额(分)
-	BalanceFloat float64 `json:"balance_float" example:"100.00" description:"余额(元为单位，便于前端显示)"` // 余额(元)
-	Points       int64   `json:"points" example:"500" description:"积分数量"`                      // 积分
+	Balance          int64   `json:"balance" example:"10000" description:"余额(分为单位)，包含冻结余额"`        // 余额(分)
+	BalanceFloat     float64 `json:"balance_float" example:"100.00" description:"余额(元为单位，便于前端显示)"` // 余额(元)
+	FrozenBalance    int64   `json:"frozen_balance" example:"2000" description:"冻结余额(分为单位)"`       // 冻结余额(分)
+	AvailableBalance int64   `json:"available_balance" example:"8000" description:"可用余额(分为单位)"`    // 可用余额(分)
+	Points           int64   `json:"points" example:"500" description:"积分数量"`                      // 积分
 }
 
 // ChangeBalanceRequest 余额变动请求
 // @Description 余额变动请求参数，用于处理用户余额的增减操作
 type ChangeBalanceRequest struct {
-	UserID  uint64 `json:"user_id" binding:"required" example:"1" description:"用户ID（系统自动填充，无需传入）"`
+	UserID  uint64 `json:"user_id" binding:"required" example:"1" description:"目标用户ID，由运营人员指定"`
 	Amount  int64  `json:"amount" binding:"required" example:"1000" description:"变动金额(分为单位)，正数为增加，负数为减少"` // 变动金额(分)
 	Type    string `json:"type" binding:"required" example:"recharge" enums:"recharge,consume,refund,reward,deduct" description:"变动类型：recharge-充值，consume-消费，refund-退款，reward-奖励，deduct-扣除"`
 	Remark  string `json:"remark" example:"用户充值" description:"变动备注说明"`
 	OrderNo string `json:"order_no" example:"ORDER20240101001" description:"关联订单号（可选）"`
+	// 幂等键，同一租户内唯一，重试时使用相同的幂等键及参数可避免重复变动
+	IdempotencyKey string `json:"idempotency_key" binding:"omitempty,max=64" example:"ORDER20240101001-pay" description:"幂等键（可选），相同幂等键及参数的重复请求返回首次的变动记录，参数不同则拒绝"`
 }
 
 // ChangePointsRequest 积分变动请求
 // @Description 积分变动请求参数，用于处理用户积分的增减操作
 type ChangePointsRequest struct {
-	UserID     uint64 `json:"user_id" binding:"required" example:"1" description:"用户ID（系统自动填充，无需传入）"`
+	UserID     uint64 `json:"user_id" binding:"required" example:"1" description:"目标用户ID，由运营人员指定"`
 	Quantity   int64  `json:"quantity" binding:"required" example:"100" description:"变动数量，正数为增加，负数为减少"` // 变动数量
 	Type       string `json:"type" binding:"required" example:"obtain" enums:"obtain,use,expire,reward,deduct" description:"变动类型：obtain-获得，use-使用，expire-过期，reward-奖励，deduct-扣除"`
 	Remark     string `json:"remark" example:"签到奖励" description:"变动备注说明"`
 	OrderNo    string `json:"order_no" example:"ORDER20240101001" description:"关联订单号（可选）"`
 	ExpireDays int    `json:"expire_days" example:"365" description:"过期天数，0表示永不过期"` // 过期天数，0表示永不过期
+	// 幂等键，同一租户内唯一，重试时使用相同的幂等键及参数可避免重复变动
+	IdempotencyKey string `json:"idempotency_key" binding:"omitempty,max=64" example:"ORDER20240101001-points" description:"幂等键（可选），相同幂等键及参数的重复请求返回首次的变动记录，参数不同则拒绝"`
+}
+
+// HoldBalanceRequest 冻结余额请求
+// @Description 下单时预留会员余额，订单确认后扣款，取消时释放
+type HoldBalanceRequest struct {
+	UserID        uint64 `json:"user_id" binding:"required" example:"1" description:"目标用户ID，由运营人员指定"`
+	OrderNo       string `json:"order_no" binding:"required,max=64" example:"ORDER20240101001" description:"关联订单号，同一租户内一个订单只能有一笔冻结中的余额"`
+	Amount        int64  `json:"amount" binding:"required,min=1" example:"2000" description:"冻结金额(分为单位)"`
+	Remark        string `json:"remark" example:"下单冻结" description:"备注说明"`
+	ExpireMinutes int    `json:"expire_minutes" binding:"omitempty,min=1,max=10080" example:"30" description:"冻结有效期（分钟，最长7天），到期未扣款自动释放，不传时使用默认配置"`
+}
+
+// CaptureHoldRequest 冻结扣款请求
+// @Description 按订单扣款冻结的余额，扣款金额可小于冻结金额，剩余部分解除冻结
+type CaptureHoldRequest struct {
+	OrderNo string `json:"order_no" binding:"required,max=64" example:"ORDER20240101001" description:"关联订单号"`
+	Amount  int64  `json:"amount" binding:"omitempty,min=1" example:"1500" description:"扣款金额(分为单位)，不传时扣除全部冻结金额"`
+	Remark  string `json:"remark" example:"订单确认扣款" description:"备注说明"`
+}
+
+// ReleaseHoldRequest 释放冻结请求
+// @Description 按订单释放冻结的余额
+type ReleaseHoldRequest struct {
+	OrderNo string `json:"order_no" binding:"required,max=64" example:"ORDER20240101001" description:"关联订单号"`
+	Remark  string `json:"remark" example:"订单取消" description:"备注说明"`
 }
 
+// defaultHoldExpiry 未配置时冻结余额的默认有效期
+const defaultHoldExpiry = 30 * time.Minute
+
+// maxHoldExpireMinutes 冻结余额的最长有效期（分钟）
+const maxHoldExpireMinutes = 7 * 24 * 60
+
+// holdExpiryBatchSize 每批查询的到期冻结数量
+const holdExpiryBatchSize = 100
+
+// errHoldUserNotFound 冻结记录对应的会员已不存在
+var errHoldUserNotFound = errors.New("用户不存在")
+
 // GetRecordsRequest 获取记录请求
 // @Description 获取变动记录的查询参数，支持分页和筛选
 type GetRecordsRequest struct {
@@ -63,20 +123,48 @@ type GetRecordsRequest struct {
 
 // assetService 资产服务实现
 type assetService struct {
-	db *gorm.DB
+	db         *gorm.DB
+	holdExpiry time.Duration // 冻结余额的默认有效期
 }
 
 // NewAssetService 创建资产服务实例
 func NewAssetService(db *gorm.DB) AssetService {
+	holdExpiry := time.Duration(config.GetInt("balance_hold.expire_minutes")) * time.Minute
+	if holdExpiry <= 0 {
+		holdExpiry = defaultHoldExpiry
+	}
 	return &assetService{
-		db: db,
+		db:         db,
+		holdExpiry: holdExpiry,
 	}
 }
 
+// StartBalanceHoldJobs 启动后台任务，定期释放到期未扣款的冻结余额
+func StartBalanceHoldJobs() {
+	interval := time.Duration(config.GetInt("balance_hold.job_interval")) * time.Second
+	if interval <= 0 {
+		return
+	}
+
+	service := NewAssetService(database.GetDB())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := service.ExpireHolds(context.Background()); err != nil {
+				logger.Error("释放到期冻结余额失败:", err)
+			}
+		}
+	}()
+}
+
 // GetAssetInfo 获取用户资产信息
 func (s *assetService) GetAssetInfo(ctx context.Context, userID uint64) (*AssetInfo, error) {
 	var user models.User
-	if err := s.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(database.GetTenantIDFromContext(ctx))).
+		First(&user, userID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("用户不存在")
 		}
@@ -84,14 +172,17 @@ func (s *assetService) GetAssetInfo(ctx context.Context, userID uint64) (*AssetI
 	}
 
 	return &AssetInfo{
-		Balance:      user.Balance,
-		BalanceFloat: user.GetBalanceFloat(),
-		Points:       user.Points,
+		Balance:          user.Balance,
+		BalanceFloat:     user.GetBalanceFloat(),
+		FrozenBalance:    user.FrozenBalance,
+		AvailableBalance: user.AvailableBalance(),
+		Points:           user.Points,
 	}, nil
 }
 
 // ChangeBalance 余额变动
-func (s *assetService) ChangeBalance(ctx context.Context, req *ChangeBalanceRequest) error {
+// 提供幂等键时，相同幂等键及参数的重复请求直接返回首次创建的记录，参数不同则返回ErrIdempotencyConflict
+func (s *assetService) ChangeBalance(ctx context.Context, req *ChangeBalanceRequest) (*models.BalanceRecord, error) {
 	// 验证变动类型
 	validTypes := []string{
 		models.BalanceTypeRecharge,
@@ -109,51 +200,69 @@ func (s *assetService) ChangeBalance(ctx context.Context, req *ChangeBalanceRequ
 		}
 	}
 	if !isValid {
-		return fmt.Errorf("无效的变动类型: %s", req.Type)
+		return nil, fmt.Errorf("无效的变动类型: %s", req.Type)
+	}
+	if len(req.IdempotencyKey) > 64 {
+		return nil, fmt.Errorf("幂等键长度不能超过64个字符")
 	}
 
-	// 使用事务处理余额变动
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// 锁定用户记录
-		var user models.User
-		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&user, req.UserID).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return fmt.Errorf("用户不存在")
-			}
-			return fmt.Errorf("查询用户失败: %w", err)
-		}
-
-		// 检查余额是否足够（对于支出类型）
-		if req.Amount < 0 && user.Balance+req.Amount < 0 {
-			return fmt.Errorf("余额不足")
+	requestHash := idempotencyHash(req.UserID, req.Amount, req.Type, req.Remark, req.OrderNo)
+	if req.IdempotencyKey != "" {
+		if record, err := s.findBalanceRecordByKey(ctx, req.IdempotencyKey, requestHash); err != nil || record != nil {
+			return record, err
 		}
+	}
 
-		// 更新用户余额
-		newBalance := user.Balance + req.Amount
-		if err := tx.Model(&user).Update("balance", newBalance).Error; err != nil {
-			return fmt.Errorf("更新用户余额失败: %w", err)
+	// 使用事务处理余额变动
+	var record *models.BalanceRecord
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 原子更新余额（仅允许操作当前租户下的用户）
+		user, err := applyUserAssetDelta(tx, database.GetTenantIDFromContext(ctx), req.UserID, "balance", req.Amount, errors.New("余额不足"))
+		if err != nil {
+			return err
 		}
+		newBalance := user.Balance
 
 		// 创建余额变动记录
-		record := &models.BalanceRecord{
-			UserID:       req.UserID,
-			Amount:       req.Amount,
-			Type:         req.Type,
-			Remark:       req.Remark,
-			BalanceAfter: newBalance,
-			OrderNo:      req.OrderNo,
+		record = &models.BalanceRecord{
+			BaseModel:      models.BaseModel{TenantID: user.TenantID},
+			UserID:         req.UserID,
+			Amount:         req.Amount,
+			Type:           req.Type,
+			Remark:         req.Remark,
+			BalanceAfter:   newBalance,
+			OrderNo:        req.OrderNo,
+			IdempotencyKey: optionalString(req.IdempotencyKey),
+			RequestHash:    requestHash,
 		}
 
 		if err := tx.Create(record).Error; err != nil {
 			return fmt.Errorf("创建余额变动记录失败: %w", err)
 		}
 
+		// 按复式记账写入会员钱包与对方账户的分录
+		if err := postBalanceRecord(tx, record, record.Type, balanceCounterAccounts[record.Type]); err != nil {
+			return err
+		}
+
 		return nil
 	})
+	if err != nil {
+		// 并发的重复请求已先提交时，唯一索引拒绝本次写入，返回已提交的记录
+		if req.IdempotencyKey != "" {
+			if existing, findErr := s.findBalanceRecordByKey(ctx, req.IdempotencyKey, requestHash); existing != nil || errors.Is(findErr, common.ErrIdempotencyConflict) {
+				return existing, findErr
+			}
+		}
+		return nil, err
+	}
+
+	return record, nil
 }
 
 // ChangePoints 积分变动
-func (s *assetService) ChangePoints(ctx context.Context, req *ChangePointsRequest) error {
+// 提供幂等键时，相同幂等键及参数的重复请求直接返回首次创建的记录，参数不同则返回ErrIdempotencyConflict
+func (s *assetService) ChangePoints(ctx context.Context, req *ChangePointsRequest) (*models.PointsRecord, error) {
 	// 验证变动类型
 	validTypes := []string{
 		models.PointsTypeObtain,
@@ -171,39 +280,40 @@ func (s *assetService) ChangePoints(ctx context.Context, req *ChangePointsReques
 		}
 	}
 	if !isValid {
-		return fmt.Errorf("无效的变动类型: %s", req.Type)
+		return nil, fmt.Errorf("无效的变动类型: %s", req.Type)
+	}
+	if len(req.IdempotencyKey) > 64 {
+		return nil, fmt.Errorf("幂等键长度不能超过64个字符")
 	}
 
-	// 使用事务处理积分变动
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// 锁定用户记录
-		var user models.User
-		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&user, req.UserID).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return fmt.Errorf("用户不存在")
-			}
-			return fmt.Errorf("查询用户失败: %w", err)
-		}
-
-		// 检查积分是否足够（对于支出类型）
-		if req.Quantity < 0 && user.Points+req.Quantity < 0 {
-			return fmt.Errorf("积分不足")
+	requestHash := idempotencyHash(req.UserID, req.Quantity, req.Type, req.Remark, req.OrderNo, req.ExpireDays)
+	if req.IdempotencyKey != "" {
+		if record, err := s.findPointsRecordByKey(ctx, req.IdempotencyKey, requestHash); err != nil || record != nil {
+			return record, err
 		}
+	}
 
-		// 更新用户积分
-		newPoints := user.Points + req.Quantity
-		if err := tx.Model(&user).Update("points", newPoints).Error; err != nil {
-			return fmt.Errorf("更新用户积分失败: %w", err)
+	// 使用事务处理积分变动
+	var record *models.PointsRecord
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 原子更新积分（仅允许操作当前租户下的用户）
+		user, err := applyUserAssetDelta(tx, database.GetTenantIDFromContext(ctx), req.UserID, "points", req.Quantity, errors.New("积分不足"))
+		if err != nil {
+			return err
 		}
+		newPoints := user.Points
 
 		// 创建积分变动记录
-		record := &models.PointsRecord{
-			UserID:      req.UserID,
-			Quantity:    req.Quantity,
-			Type:        req.Type,
-			Remark:      req.Remark,
-			PointsAfter: newPoints,
-			OrderNo:     req.OrderNo,
+		record = &models.PointsRecord{
+			BaseModel:      models.BaseModel{TenantID: user.TenantID},
+			UserID:         req.UserID,
+			Quantity:       req.Quantity,
+			Type:           req.Type,
+			Remark:         req.Remark,
+			PointsAfter:    newPoints,
+			OrderNo:        req.OrderNo,
+			IdempotencyKey: optionalString(req.IdempotencyKey),
+			RequestHash:    requestHash,
 		}
 
 		// 设置过期时间
@@ -217,6 +327,417 @@ func (s *assetService) ChangePoints(ctx context.Context, req *ChangePointsReques
 
 		return nil
 	})
+	if err != nil {
+		// 并发的重复请求已先提交时，唯一索引拒绝本次写入，返回已提交的记录
+		if req.IdempotencyKey != "" {
+			if existing, findErr := s.findPointsRecordByKey(ctx, req.IdempotencyKey, requestHash); existing != nil || errors.Is(findErr, common.ErrIdempotencyConflict) {
+				return existing, findErr
+			}
+		}
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// applyUserAssetDelta 在事务中原子更新用户余额或积分，返回更新后的用户
+// 余额检查与更新在同一条条件UPDATE中完成，不先读后写：MySQL由UPDATE持有的行锁、SQLite由数据库写锁保证并发变动依次执行，
+// 不会丢失更新；扣减时余额或积分不足则不更新任何行并返回insufficient，冻结的余额不可扣减
+func applyUserAssetDelta(tx *gorm.DB, tenantID string, userID uint64, column string, delta int64, insufficient error) (*models.User, error) {
+	query := tx.Model(&models.User{}).
+		Scopes(models.ScopeByTenant(tenantID)).
+		Where("id = ?", userID)
+	if delta < 0 {
+		available := column
+		if column == "balance" {
+			available = "balance - frozen_balance"
+		}
+		query = query.Where(available+" + ? >= 0", delta)
+	}
+	result := query.Update(column, gorm.Expr(column+" + ?", delta))
+	if result.Error != nil {
+		return nil, fmt.Errorf("更新用户资产失败: %w", result.Error)
+	}
+
+	// 当前事务已持有该行的写锁，读取到的即为本次更新后的值
+	var user models.User
+	if err := tx.Scopes(models.ScopeByTenant(tenantID)).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("用户不存在")
+		}
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, insufficient
+	}
+	return &user, nil
+}
+
+// HoldBalance 冻结余额
+// 冻结的金额仍计入余额，但不能再用于其他扣减；冻结记录状态为处理中，余额变动类型为消费，在扣款前不记账
+func (s *assetService) HoldBalance(ctx context.Context, req *HoldBalanceRequest) (*models.BalanceRecord, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("冻结金额必须大于0")
+	}
+	if req.OrderNo == "" {
+		return nil, fmt.Errorf("订单号不能为空")
+	}
+	if req.ExpireMinutes > maxHoldExpireMinutes {
+		return nil, fmt.Errorf("冻结有效期不能超过%d分钟", maxHoldExpireMinutes)
+	}
+	tenantID := database.GetTenantIDFromContext(ctx)
+	expiry := s.holdExpiry
+	if req.ExpireMinutes > 0 {
+		expiry = time.Duration(req.ExpireMinutes) * time.Minute
+	}
+
+	// 订单已有冻结中的余额时，会员及金额一致视为重试，返回已有的冻结记录
+	if hold, err := s.findPendingHold(ctx, tenantID, req); err != nil || hold != nil {
+		return hold, err
+	}
+
+	var record *models.BalanceRecord
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先更新会员冻结余额，MySQL下持有会员行锁后再次检查订单是否已冻结，避免并发的重复冻结
+		user, err := applyUserHoldDelta(tx, tenantID, req.UserID, 0, req.Amount, common.ErrInsufficientBalance)
+		if err != nil {
+			return err
+		}
+
+		var exists int64
+		if err := tx.Model(&models.BalanceRecord{}).
+			Scopes(models.ScopeByTenant(tenantID), models.ScopeByOrderNo(req.OrderNo)).
+			Where("hold_amount > 0 AND status = ?", models.BalanceRecordStatusPending).
+			Count(&exists).Error; err != nil {
+			return fmt.Errorf("查询冻结记录失败: %w", err)
+		}
+		if exists > 0 {
+			return common.ErrBalanceHoldExists
+		}
+
+		expireTime := time.Now().Add(expiry)
+		record = &models.BalanceRecord{
+			BaseModel:    models.BaseModel{TenantID: user.TenantID},
+			UserID:       req.UserID,
+			Amount:       -req.Amount,
+			Type:         models.BalanceTypeConsume,
+			Remark:       req.Remark,
+			BalanceAfter: user.Balance,
+			OrderNo:      req.OrderNo,
+			HoldAmount:   req.Amount,
+			ExpireTime:   &expireTime,
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return fmt.Errorf("创建冻结记录失败: %w", err)
+		}
+		// 创建时状态为0会被设置为默认的正常状态，创建后再更新为处理中
+		if err := tx.Model(record).Update("status", models.BalanceRecordStatusPending).Error; err != nil {
+			return fmt.Errorf("创建冻结记录失败: %w", err)
+		}
+		record.Status = models.BalanceRecordStatusPending
+
+		return nil
+	})
+	if err != nil {
+		// 并发的重复冻结已先提交时，返回已提交的冻结记录
+		if errors.Is(err, common.ErrBalanceHoldExists) {
+			if hold, findErr := s.findPendingHold(ctx, tenantID, req); hold != nil || findErr != nil {
+				return hold, findErr
+			}
+		}
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// findPendingHold 查询订单冻结中的记录
+// 不存在时返回nil；会员或金额与请求不一致时返回ErrBalanceHoldExists
+func (s *assetService) findPendingHold(ctx context.Context, tenantID string, req *HoldBalanceRequest) (*models.BalanceRecord, error) {
+	hold, err := s.findBalanceHold(ctx, s.db, tenantID, req.OrderNo)
+	if errors.Is(err, common.ErrBalanceHoldNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != models.BalanceRecordStatusPending {
+		return nil, nil
+	}
+	if hold.UserID != req.UserID || hold.HoldAmount != req.Amount {
+		return nil, common.ErrBalanceHoldExists
+	}
+	return hold, nil
+}
+
+// CaptureHold 冻结扣款
+// 扣除实际扣款金额并解除全部冻结，冻结记录变为已完成，金额为实际扣款金额，并按消费写入分录
+func (s *assetService) CaptureHold(ctx context.Context, req *CaptureHoldRequest) (*models.BalanceRecord, error) {
+	if req.Amount < 0 {
+		return nil, fmt.Errorf("扣款金额不能为负数")
+	}
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	var hold *models.BalanceRecord
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		hold, err = s.findBalanceHold(ctx, tx, tenantID, req.OrderNo)
+		if err != nil {
+			return err
+		}
+		if hold.Status != models.BalanceRecordStatusPending {
+			return common.ErrBalanceHoldClosed
+		}
+		if hold.IsHoldExpired() {
+			return common.ErrBalanceHoldExpired
+		}
+
+		amount := req.Amount
+		if amount == 0 {
+			amount = hold.HoldAmount
+		}
+		if amount > hold.HoldAmount {
+			return common.ErrCaptureExceedsHold
+		}
+
+		user, err := applyUserHoldDelta(tx, tenantID, hold.UserID, -amount, -hold.HoldAmount, common.ErrBalanceHoldClosed)
+		if err != nil {
+			return err
+		}
+
+		hold.Amount = -amount
+		hold.BalanceAfter = user.Balance
+		hold.Status = models.BalanceRecordStatusCompleted
+		if req.Remark != "" {
+			hold.Remark = req.Remark
+		}
+		if err := closeBalanceHold(tx, hold); err != nil {
+			return err
+		}
+
+		// 扣款后按消费写入会员钱包与平台资金的分录
+		return postBalanceRecord(tx, hold, models.BalanceTypeConsume, balanceCounterAccounts[models.BalanceTypeConsume])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// ReleaseHold 释放冻结
+func (s *assetService) ReleaseHold(ctx context.Context, req *ReleaseHoldRequest) (*models.BalanceRecord, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	var hold *models.BalanceRecord
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		hold, err = s.findBalanceHold(ctx, tx, tenantID, req.OrderNo)
+		if err != nil {
+			return err
+		}
+		if hold.Status != models.BalanceRecordStatusPending {
+			return common.ErrBalanceHoldClosed
+		}
+		if req.Remark != "" {
+			hold.Remark = req.Remark
+		}
+		return releaseBalanceHold(tx, hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// ExpireHolds 释放到期未扣款的冻结
+// 后台任务按ID分批处理所有租户的到期冻结，单条释放失败时记录日志并继续处理后续批次，避免失败的冻结阻塞其他冻结；
+// 会员已注销时同样释放其冻结余额，会员记录已不存在时直接关闭冻结
+func (s *assetService) ExpireHolds(ctx context.Context) (int, error) {
+	now := time.Now()
+	expired := 0
+	lastID := uint64(0)
+	for {
+		var holds []*models.BalanceRecord
+		if err := s.db.WithContext(ctx).
+			Where("hold_amount > 0 AND status = ? AND expire_time <= ? AND id > ?", models.BalanceRecordStatusPending, now, lastID).
+			Order("id ASC").
+			Limit(holdExpiryBatchSize).
+			Find(&holds).Error; err != nil {
+			return expired, fmt.Errorf("查询到期冻结失败: %w", err)
+		}
+
+		for _, hold := range holds {
+			lastID = hold.ID
+			hold.Remark = "冻结到期自动释放"
+			err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return releaseBalanceHold(tx, hold)
+			})
+			if errors.Is(err, errHoldUserNotFound) {
+				hold.Remark = "会员不存在，冻结已关闭"
+				hold.Status = models.BalanceRecordStatusCancelled
+				err = closeBalanceHold(s.db.WithContext(ctx), hold)
+			}
+			if err != nil {
+				// 到期前已被扣款或释放
+				if errors.Is(err, common.ErrBalanceHoldClosed) {
+					continue
+				}
+				logger.WithFields(logrus.Fields{
+					"record_id": hold.ID,
+					"error":     err.Error(),
+				}).Error("释放到期冻结失败")
+				continue
+			}
+			expired++
+		}
+
+		if len(holds) < holdExpiryBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// findBalanceHold 查询订单最近的冻结记录
+func (s *assetService) findBalanceHold(ctx context.Context, db *gorm.DB, tenantID, orderNo string) (*models.BalanceRecord, error) {
+	var hold models.BalanceRecord
+	if err := db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(tenantID), models.ScopeByOrderNo(orderNo)).
+		Where("hold_amount > 0").
+		Order("id DESC").
+		First(&hold).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.ErrBalanceHoldNotFound
+		}
+		return nil, fmt.Errorf("查询冻结记录失败: %w", err)
+	}
+	return &hold, nil
+}
+
+// releaseBalanceHold 在事务中解除冻结，冻结记录变为已取消
+func releaseBalanceHold(tx *gorm.DB, hold *models.BalanceRecord) error {
+	user, err := applyUserHoldDelta(tx, hold.TenantID, hold.UserID, 0, -hold.HoldAmount, common.ErrBalanceHoldClosed)
+	if err != nil {
+		return err
+	}
+	hold.BalanceAfter = user.Balance
+	hold.Status = models.BalanceRecordStatusCancelled
+	return closeBalanceHold(tx, hold)
+}
+
+// closeBalanceHold 将处理中的冻结记录更新为最终状态
+// 仅更新仍为处理中的记录，并发的扣款、释放只有一个生效，其余返回ErrBalanceHoldClosed并回滚
+func closeBalanceHold(tx *gorm.DB, hold *models.BalanceRecord) error {
+	result := tx.Model(&models.BalanceRecord{}).
+		Where("id = ? AND status = ?", hold.ID, models.BalanceRecordStatusPending).
+		Updates(map[string]interface{}{
+			"amount":        hold.Amount,
+			"balance_after": hold.BalanceAfter,
+			"remark":        hold.Remark,
+			"status":        hold.Status,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("更新冻结记录失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return common.ErrBalanceHoldClosed
+	}
+	return nil
+}
+
+// applyUserHoldDelta 在事务中原子更新用户余额及冻结余额，返回更新后的用户
+// 增加冻结时要求可用余额足够；扣款、解除冻结时要求冻结余额足够，否则不更新任何行并返回insufficient。
+// 解除冻结时包含已注销的会员，避免其冻结无法释放；会员不存在时返回errHoldUserNotFound
+func applyUserHoldDelta(tx *gorm.DB, tenantID string, userID uint64, balanceDelta, frozenDelta int64, insufficient error) (*models.User, error) {
+	users := func() *gorm.DB {
+		db := tx.Scopes(models.ScopeByTenant(tenantID))
+		if balanceDelta == 0 && frozenDelta < 0 {
+			db = db.Unscoped()
+		}
+		return db
+	}
+
+	query := users().Model(&models.User{}).Where("id = ?", userID)
+	if frozenDelta > 0 {
+		query = query.Where("balance - frozen_balance >= ?", frozenDelta)
+	} else {
+		query = query.Where("frozen_balance + ? >= 0", frozenDelta)
+	}
+	result := query.Updates(map[string]interface{}{
+		"balance":        gorm.Expr("balance + ?", balanceDelta),
+		"frozen_balance": gorm.Expr("frozen_balance + ?", frozenDelta),
+	})
+	if result.Error != nil {
+		return nil, fmt.Errorf("更新用户资产失败: %w", result.Error)
+	}
+
+	var user models.User
+	if err := users().First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errHoldUserNotFound
+		}
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, insufficient
+	}
+	return &user, nil
+}
+
+// findBalanceRecordByKey 按幂等键查询当前租户的余额变动记录
+// 记录不存在时返回nil；参数摘要不一致说明幂等键被不同的请求使用，返回ErrIdempotencyConflict
+func (s *assetService) findBalanceRecordByKey(ctx context.Context, key, requestHash string) (*models.BalanceRecord, error) {
+	var record models.BalanceRecord
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(database.GetTenantIDFromContext(ctx))).
+		Where("idempotency_key = ?", key).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询余额变动记录失败: %w", err)
+	}
+	if record.RequestHash != requestHash {
+		return nil, common.ErrIdempotencyConflict
+	}
+	return &record, nil
+}
+
+// findPointsRecordByKey 按幂等键查询当前租户的积分变动记录
+// 记录不存在时返回nil；参数摘要不一致说明幂等键被不同的请求使用，返回ErrIdempotencyConflict
+func (s *assetService) findPointsRecordByKey(ctx context.Context, key, requestHash string) (*models.PointsRecord, error) {
+	var record models.PointsRecord
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(database.GetTenantIDFromContext(ctx))).
+		Where("idempotency_key = ?", key).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询积分变动记录失败: %w", err)
+	}
+	if record.RequestHash != requestHash {
+		return nil, common.ErrIdempotencyConflict
+	}
+	return &record, nil
+}
+
+// idempotencyHash 计算请求参数摘要，用于判断幂等键的重复请求参数是否一致
+func idempotencyHash(values ...interface{}) string {
+	hash := sha256.New()
+	for _, value := range values {
+		fmt.Fprintf(hash, "%v\x00", value)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// optionalString 空字符串转换为nil，用于可为NULL的唯一索引字段
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
 }
 
 // GetBalanceRecords 获取余额变动记录
@@ -231,6 +752,7 @@ func (s *assetService) GetBalanceRecords(ctx context.Context, userID uint64, req
 	// 构建查询条件
 	conditions := []func(*gorm.DB) *gorm.DB{
 		models.ScopeByUserID(userID),
+		models.ScopeByTenant(database.GetTenantIDFromContext(ctx)),
 		models.ScopeActive,
 		models.ScopeOrderByCreatedAt(true),
 	}
@@ -276,6 +798,7 @@ func (s *assetService) GetPointsRecords(ctx context.Context, userID uint64, req
 	// 构建查询条件
 	conditions := []func(*gorm.DB) *gorm.DB{
 		models.ScopePointsByUserID(userID),
+		models.ScopeByTenant(database.GetTenantIDFromContext(ctx)),
 		models.ScopeActive,
 		models.ScopeOrderByPointsCreatedAt(true),
 	}
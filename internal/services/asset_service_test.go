@@ -2,12 +2,17 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"member-link-lite/internal/database"
 	"member-link-lite/internal/models"
 	"member-link-lite/pkg/common"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -28,7 +33,7 @@ func (suite *AssetServiceTestSuite) SetupSuite() {
 	suite.Require().NoError(err)
 
 	// 自动迁移表结构
-	err = db.AutoMigrate(&models.User{}, &models.BalanceRecord{}, &models.PointsRecord{})
+	err = db.AutoMigrate(&models.User{}, &models.BalanceRecord{}, &models.PointsRecord{}, &models.JournalEntry{}, &models.LedgerPosting{})
 	suite.Require().NoError(err)
 
 	suite.db = db
@@ -44,9 +49,9 @@ func (suite *AssetServiceTestSuite) TearDownSuite() {
 // SetupTest 每个测试前的设置
 func (suite *AssetServiceTestSuite) SetupTest() {
 	// 清理测试数据
-	suite.db.Exec("DELETE FROM balance_records")
-	suite.db.Exec("DELETE FROM points_records")
-	suite.db.Exec("DELETE FROM users")
+	suite.db.Exec("DELETE FROM m_balance_records")
+	suite.db.Exec("DELETE FROM m_points_records")
+	suite.db.Exec("DELETE FROM m_users")
 
 	// 创建测试用户
 	suite.testUser = &models.User{
@@ -92,7 +97,7 @@ func (suite *AssetServiceTestSuite) TestChangeBalance() {
 		OrderNo: "ORDER001",
 	}
 
-	err := suite.assetService.ChangeBalance(ctx, rechargeReq)
+	_, err := suite.assetService.ChangeBalance(ctx, rechargeReq)
 	suite.Require().NoError(err)
 
 	// 验证用户余额更新
@@ -118,7 +123,7 @@ func (suite *AssetServiceTestSuite) TestChangeBalance() {
 		OrderNo: "ORDER002",
 	}
 
-	err = suite.assetService.ChangeBalance(ctx, consumeReq)
+	_, err = suite.assetService.ChangeBalance(ctx, consumeReq)
 	suite.Require().NoError(err)
 
 	// 验证用户余额更新
@@ -133,7 +138,7 @@ func (suite *AssetServiceTestSuite) TestChangeBalance() {
 		Remark: "余额不足测试",
 	}
 
-	err = suite.assetService.ChangeBalance(ctx, insufficientReq)
+	_, err = suite.assetService.ChangeBalance(ctx, insufficientReq)
 	suite.Require().Error(err)
 	assert.Contains(suite.T(), err.Error(), "余额不足")
 
@@ -145,7 +150,7 @@ func (suite *AssetServiceTestSuite) TestChangeBalance() {
 		Remark: "无效类型测试",
 	}
 
-	err = suite.assetService.ChangeBalance(ctx, invalidReq)
+	_, err = suite.assetService.ChangeBalance(ctx, invalidReq)
 	suite.Require().Error(err)
 	assert.Contains(suite.T(), err.Error(), "无效的变动类型")
 }
@@ -164,7 +169,7 @@ func (suite *AssetServiceTestSuite) TestChangePoints() {
 		ExpireDays: 30,
 	}
 
-	err := suite.assetService.ChangePoints(ctx, obtainReq)
+	_, err := suite.assetService.ChangePoints(ctx, obtainReq)
 	suite.Require().NoError(err)
 
 	// 验证用户积分更新
@@ -191,7 +196,7 @@ func (suite *AssetServiceTestSuite) TestChangePoints() {
 		OrderNo:  "ORDER004",
 	}
 
-	err = suite.assetService.ChangePoints(ctx, useReq)
+	_, err = suite.assetService.ChangePoints(ctx, useReq)
 	suite.Require().NoError(err)
 
 	// 验证用户积分更新
@@ -206,7 +211,7 @@ func (suite *AssetServiceTestSuite) TestChangePoints() {
 		Remark:   "积分不足测试",
 	}
 
-	err = suite.assetService.ChangePoints(ctx, insufficientReq)
+	_, err = suite.assetService.ChangePoints(ctx, insufficientReq)
 	suite.Require().Error(err)
 	assert.Contains(suite.T(), err.Error(), "积分不足")
 
@@ -218,7 +223,7 @@ func (suite *AssetServiceTestSuite) TestChangePoints() {
 		Remark:   "无效类型测试",
 	}
 
-	err = suite.assetService.ChangePoints(ctx, invalidReq)
+	_, err = suite.assetService.ChangePoints(ctx, invalidReq)
 	suite.Require().Error(err)
 	assert.Contains(suite.T(), err.Error(), "无效的变动类型")
 }
@@ -268,14 +273,14 @@ func (suite *AssetServiceTestSuite) TestGetBalanceRecords() {
 	suite.Require().NoError(err)
 	assert.NotNil(suite.T(), result)
 	assert.Equal(suite.T(), int64(3), result.Total)
-	assert.Len(suite.T(), result.List, 3)
+	assert.Len(suite.T(), *result.List.(*[]models.BalanceRecord), 3)
 
 	// 测试按类型筛选
 	req.Type = models.BalanceTypeRecharge
 	result, err = suite.assetService.GetBalanceRecords(ctx, suite.testUser.ID, req)
 	suite.Require().NoError(err)
 	assert.Equal(suite.T(), int64(2), result.Total)
-	assert.Len(suite.T(), result.List, 2)
+	assert.Len(suite.T(), *result.List.(*[]models.BalanceRecord), 2)
 
 	// 测试分页
 	req.Type = ""
@@ -283,7 +288,7 @@ func (suite *AssetServiceTestSuite) TestGetBalanceRecords() {
 	result, err = suite.assetService.GetBalanceRecords(ctx, suite.testUser.ID, req)
 	suite.Require().NoError(err)
 	assert.Equal(suite.T(), int64(3), result.Total)
-	assert.Len(suite.T(), result.List, 2)
+	assert.Len(suite.T(), *result.List.(*[]models.BalanceRecord), 2)
 	assert.Equal(suite.T(), 2, result.Pages)
 
 	// 测试时间范围筛选
@@ -349,14 +354,14 @@ func (suite *AssetServiceTestSuite) TestGetPointsRecords() {
 	suite.Require().NoError(err)
 	assert.NotNil(suite.T(), result)
 	assert.Equal(suite.T(), int64(3), result.Total)
-	assert.Len(suite.T(), result.List, 3)
+	assert.Len(suite.T(), *result.List.(*[]models.PointsRecord), 3)
 
 	// 测试按类型筛选
 	req.Type = models.PointsTypeObtain
 	result, err = suite.assetService.GetPointsRecords(ctx, suite.testUser.ID, req)
 	suite.Require().NoError(err)
 	assert.Equal(suite.T(), int64(1), result.Total)
-	assert.Len(suite.T(), result.List, 1)
+	assert.Len(suite.T(), *result.List.(*[]models.PointsRecord), 1)
 
 	// 测试分页
 	req.Type = ""
@@ -364,7 +369,7 @@ func (suite *AssetServiceTestSuite) TestGetPointsRecords() {
 	result, err = suite.assetService.GetPointsRecords(ctx, suite.testUser.ID, req)
 	suite.Require().NoError(err)
 	assert.Equal(suite.T(), int64(3), result.Total)
-	assert.Len(suite.T(), result.List, 2)
+	assert.Len(suite.T(), *result.List.(*[]models.PointsRecord), 2)
 	assert.Equal(suite.T(), 2, result.Pages)
 }
 
@@ -387,7 +392,8 @@ func (suite *AssetServiceTestSuite) TestConcurrentBalanceChange() {
 				Remark:  "并发测试",
 				OrderNo: "CONCURRENT_" + string(rune(index)),
 			}
-			done <- suite.assetService.ChangeBalance(ctx, req)
+			_, err := suite.assetService.ChangeBalance(ctx, req)
+			done <- err
 		}(i)
 	}
 
@@ -427,7 +433,7 @@ func (suite *AssetServiceTestSuite) TestTransactionRollback() {
 		OrderNo: "ROLLBACK_TEST",
 	}
 
-	err := suite.assetService.ChangeBalance(ctx, req)
+	_, err := suite.assetService.ChangeBalance(ctx, req)
 	suite.Require().Error(err)
 	assert.Contains(suite.T(), err.Error(), "用户不存在")
 
@@ -449,3 +455,365 @@ func NewPageRequest(page, pageSize int) *common.PageRequest {
 		PageSize: pageSize,
 	}
 }
+
+func TestAssetService_IdempotentChangeBalance(t *testing.T) {
+	userService, users := setupMemberService(t, 1)
+	service := NewAssetService(userService.db)
+	ctx := context.Background()
+
+	req := &ChangeBalanceRequest{UserID: users[0].ID, Amount: 1000, Type: models.BalanceTypeRecharge, OrderNo: "ORDER001", IdempotencyKey: "ORDER001-pay"}
+	first, err := service.ChangeBalance(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "ORDER001-pay", *first.IdempotencyKey)
+
+	// 重复请求返回首次的记录，不重复入账
+	retry, err := service.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: users[0].ID, Amount: 1000, Type: models.BalanceTypeRecharge, OrderNo: "ORDER001", IdempotencyKey: "ORDER001-pay"})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, retry.ID)
+	assert.Equal(t, int64(1000), retry.BalanceAfter)
+
+	// 参数不同的请求被拒绝
+	_, err = service.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: users[0].ID, Amount: 2000, Type: models.BalanceTypeRecharge, OrderNo: "ORDER001", IdempotencyKey: "ORDER001-pay"})
+	assert.Equal(t, common.ErrIdempotencyConflict, err)
+
+	var user models.User
+	require.NoError(t, userService.db.First(&user, users[0].ID).Error)
+	assert.Equal(t, int64(1000), user.Balance)
+
+	// 未提供幂等键的请求不受限制
+	for i := 0; i < 2; i++ {
+		_, err = service.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: users[0].ID, Amount: 100, Type: models.BalanceTypeRecharge, OrderNo: "ORDER002"})
+		require.NoError(t, err)
+	}
+
+	// 其他租户可使用相同的幂等键
+	other := &models.User{Username: "other", Phone: "13900139000", Email: "other@example.com", Password: "x"}
+	other.TenantID = "company1"
+	require.NoError(t, userService.db.Create(other).Error)
+	tenantCtx := context.WithValue(ctx, "tenant_id", "company1")
+	_, err = service.ChangeBalance(tenantCtx, &ChangeBalanceRequest{UserID: other.ID, Amount: 500, Type: models.BalanceTypeRecharge, IdempotencyKey: "ORDER001-pay"})
+	require.NoError(t, err)
+
+	// 唯一索引在数据库层面约束同一租户内的幂等键
+	key := "ORDER001-pay"
+	duplicate := &models.BalanceRecord{UserID: users[0].ID, Amount: 1000, Type: models.BalanceTypeRecharge, BalanceAfter: 2000, IdempotencyKey: &key}
+	duplicate.TenantID = "default"
+	assert.Error(t, userService.db.Create(duplicate).Error)
+}
+
+func TestAssetService_IdempotentChangePoints(t *testing.T) {
+	userService, users := setupMemberService(t, 1)
+	service := NewAssetService(userService.db)
+	ctx := context.Background()
+
+	req := ChangePointsRequest{UserID: users[0].ID, Quantity: 100, Type: models.PointsTypeObtain, ExpireDays: 30, IdempotencyKey: "SIGNIN-20261016"}
+	first, err := service.ChangePoints(ctx, &req)
+	require.NoError(t, err)
+
+	retryReq := req
+	retry, err := service.ChangePoints(ctx, &retryReq)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, retry.ID)
+
+	conflictReq := req
+	conflictReq.ExpireDays = 60
+	_, err = service.ChangePoints(ctx, &conflictReq)
+	assert.Equal(t, common.ErrIdempotencyConflict, err)
+
+	var count int64
+	userService.db.Model(&models.PointsRecord{}).Where("user_id = ?", users[0].ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	var user models.User
+	require.NoError(t, userService.db.First(&user, users[0].ID).Error)
+	assert.Equal(t, int64(100), user.Points)
+}
+
+func TestAssetService_HoldCaptureRelease(t *testing.T) {
+	userService, users := setupMemberService(t, 1)
+	service := NewAssetService(userService.db)
+	ledger := &ledgerServiceImpl{db: userService.db}
+	ctx := context.Background()
+
+	_, err := service.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: users[0].ID, Amount: 10000, Type: models.BalanceTypeRecharge})
+	require.NoError(t, err)
+
+	hold, err := service.HoldBalance(ctx, &HoldBalanceRequest{UserID: users[0].ID, OrderNo: "ORDER001", Amount: 6000})
+	require.NoError(t, err)
+	assert.Equal(t, int8(models.BalanceRecordStatusPending), hold.Status)
+	assert.Equal(t, int64(-6000), hold.Amount)
+	require.NotNil(t, hold.ExpireTime)
+
+	var stored models.BalanceRecord
+	require.NoError(t, userService.db.First(&stored, hold.ID).Error)
+	assert.Equal(t, int8(models.BalanceRecordStatusPending), stored.Status)
+
+	info, err := service.GetAssetInfo(ctx, users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000), info.Balance)
+	assert.Equal(t, int64(6000), info.FrozenBalance)
+	assert.Equal(t, int64(4000), info.AvailableBalance)
+
+	// 重复冻结相同订单及金额返回已有的冻结，金额不同被拒绝
+	retry, err := service.HoldBalance(ctx, &HoldBalanceRequest{UserID: users[0].ID, OrderNo: "ORDER001", Amount: 6000})
+	require.NoError(t, err)
+	assert.Equal(t, hold.ID, retry.ID)
+	_, err = service.HoldBalance(ctx, &HoldBalanceRequest{UserID: users[0].ID, OrderNo: "ORDER001", Amount: 1000})
+	assert.Equal(t, common.ErrBalanceHoldExists, err)
+
+	// 冻结的余额不能再用于冻结或扣减
+	_, err = service.HoldBalance(ctx, &HoldBalanceRequest{UserID: users[0].ID, OrderNo: "ORDER002", Amount: 5000})
+	assert.Equal(t, common.ErrInsufficientBalance, err)
+	_, err = service.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: users[0].ID, Amount: -5000, Type: models.BalanceTypeConsume})
+	assert.Error(t, err)
+
+	// 部分扣款，剩余冻结解除
+	_, err = service.CaptureHold(ctx, &CaptureHoldRequest{OrderNo: "ORDER001", Amount: 7000})
+	assert.Equal(t, common.ErrCaptureExceedsHold, err)
+	captured, err := service.CaptureHold(ctx, &CaptureHoldRequest{OrderNo: "ORDER001", Amount: 4500})
+	require.NoError(t, err)
+	assert.Equal(t, int8(models.BalanceRecordStatusCompleted), captured.Status)
+	assert.Equal(t, int64(-4500), captured.Amount)
+	assert.Equal(t, int64(6000), captured.HoldAmount)
+	assert.Equal(t, int64(5500), captured.BalanceAfter)
+
+	_, err = service.CaptureHold(ctx, &CaptureHoldRequest{OrderNo: "ORDER001"})
+	assert.Equal(t, common.ErrBalanceHoldClosed, err)
+	_, err = service.ReleaseHold(ctx, &ReleaseHoldRequest{OrderNo: "ORDER001"})
+	assert.Equal(t, common.ErrBalanceHoldClosed, err)
+
+	// 释放冻结
+	_, err = service.HoldBalance(ctx, &HoldBalanceRequest{UserID: users[0].ID, OrderNo: "ORDER002", Amount: 5000})
+	require.NoError(t, err)
+	released, err := service.ReleaseHold(ctx, &ReleaseHoldRequest{OrderNo: "ORDER002", Remark: "订单取消"})
+	require.NoError(t, err)
+	assert.Equal(t, int8(models.BalanceRecordStatusCancelled), released.Status)
+	assert.Equal(t, "订单取消", released.Remark)
+
+	_, err = service.ReleaseHold(ctx, &ReleaseHoldRequest{OrderNo: "ORDER404"})
+	assert.Equal(t, common.ErrBalanceHoldNotFound, err)
+
+	var user models.User
+	require.NoError(t, userService.db.First(&user, users[0].ID).Error)
+	assert.Equal(t, int64(5500), user.Balance)
+	assert.Equal(t, int64(0), user.FrozenBalance)
+
+	// 仅扣款记账，冻结及释放不影响账本
+	report, err := ledger.GetTrialBalance(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.Balanced)
+	assert.True(t, report.WalletsMatched)
+	assert.Equal(t, int64(5500), report.WalletLedgerTotal)
+
+	// 会员的资产统计不包含处理中及已取消的冻结
+	var consumed int64
+	userService.db.Model(&models.BalanceRecord{}).Scopes(models.ScopeActive).
+		Where("user_id = ? AND amount < 0", users[0].ID).Select("COALESCE(SUM(amount), 0)").Scan(&consumed)
+	assert.Equal(t, int64(-4500), consumed)
+}
+
+func TestAssetService_ExpireHolds(t *testing.T) {
+	userService, users := setupMemberService(t, 2)
+	service := NewAssetService(userService.db)
+	ctx := context.Background()
+
+	for _, user := range users {
+		require.NoError(t, userService.db.Model(user).Update("balance", 3000).Error)
+	}
+	expired, err := service.HoldBalance(ctx, &HoldBalanceRequest{UserID: users[0].ID, OrderNo: "ORDER001", Amount: 2000})
+	require.NoError(t, err)
+	_, err = service.HoldBalance(ctx, &HoldBalanceRequest{UserID: users[1].ID, OrderNo: "ORDER002", Amount: 1000, ExpireMinutes: 60})
+	require.NoError(t, err)
+
+	// 冻结已到期
+	require.NoError(t, userService.db.Model(&models.BalanceRecord{}).Where("id = ?", expired.ID).
+		Update("expire_time", time.Now().Add(-time.Minute)).Error)
+
+	// 到期后不能扣款
+	_, err = service.CaptureHold(ctx, &CaptureHoldRequest{OrderNo: "ORDER001"})
+	assert.Equal(t, common.ErrBalanceHoldExpired, err)
+
+	count, err := service.ExpireHolds(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = service.ExpireHolds(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	var record models.BalanceRecord
+	require.NoError(t, userService.db.First(&record, expired.ID).Error)
+	assert.Equal(t, int8(models.BalanceRecordStatusCancelled), record.Status)
+
+	var first, second models.User
+	require.NoError(t, userService.db.First(&first, users[0].ID).Error)
+	require.NoError(t, userService.db.First(&second, users[1].ID).Error)
+	assert.Equal(t, int64(0), first.FrozenBalance)
+	assert.Equal(t, int64(3000), first.Balance)
+	assert.Equal(t, int64(1000), second.FrozenBalance)
+
+	// 其他租户的订单不可见
+	_, err = service.CaptureHold(context.WithValue(ctx, "tenant_id", "company1"), &CaptureHoldRequest{OrderNo: "ORDER002"})
+	assert.Equal(t, common.ErrBalanceHoldNotFound, err)
+
+	// 有效期超过上限
+	_, err = service.HoldBalance(ctx, &HoldBalanceRequest{UserID: users[0].ID, OrderNo: "ORDER003", Amount: 100, ExpireMinutes: maxHoldExpireMinutes + 1})
+	assert.Error(t, err)
+}
+
+func TestAssetService_ExpireHoldsOfDeletedUsers(t *testing.T) {
+	userService, users := setupMemberService(t, 2)
+	service := NewAssetService(userService.db)
+	ctx := context.Background()
+
+	var holds []*models.BalanceRecord
+	for i, user := range users {
+		require.NoError(t, userService.db.Model(user).Update("balance", 3000).Error)
+		hold, err := service.HoldBalance(ctx, &HoldBalanceRequest{UserID: user.ID, OrderNo: fmt.Sprintf("ORDER%03d", i), Amount: 1000})
+		require.NoError(t, err)
+		holds = append(holds, hold)
+	}
+	require.NoError(t, userService.db.Model(&models.BalanceRecord{}).Where("hold_amount > 0").
+		Update("expire_time", time.Now().Add(-time.Minute)).Error)
+
+	// 已注销的会员释放冻结余额，会员记录已不存在时直接关闭冻结
+	require.NoError(t, userService.db.Delete(users[0]).Error)
+	require.NoError(t, userService.db.Unscoped().Delete(users[1]).Error)
+
+	count, err := service.ExpireHolds(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = service.ExpireHolds(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	for _, hold := range holds {
+		var record models.BalanceRecord
+		require.NoError(t, userService.db.First(&record, hold.ID).Error)
+		assert.Equal(t, int8(models.BalanceRecordStatusCancelled), record.Status)
+	}
+	var deleted models.User
+	require.NoError(t, userService.db.Unscoped().First(&deleted, users[0].ID).Error)
+	assert.Equal(t, int64(0), deleted.FrozenBalance)
+}
+
+// setupConcurrentAssetDB 创建文件数据库，多个连接并发写入时由SQLite的数据库写锁排队（内存数据库每个连接相互独立）
+func setupConcurrentAssetDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "asset.db")+"?_busy_timeout=10000&_journal_mode=WAL"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.BalanceRecord{}, &models.PointsRecord{}, &models.PointsTransfer{}, &models.JournalEntry{}, &models.LedgerPosting{}))
+	require.NoError(t, database.CreateIdempotencyIndexes(db))
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(8)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// TestAssetService_ConcurrentChanges 并发充值与扣减，验证不丢失更新且余额、积分不会为负
+func TestAssetService_ConcurrentChanges(t *testing.T) {
+	db := setupConcurrentAssetDB(t)
+	service := NewAssetService(db)
+	ctx := context.Background()
+
+	user := &models.User{Username: "concurrent", Password: "x", Phone: "13800000009", Email: "concurrent@example.com", Balance: 1000, Points: 1000}
+	require.NoError(t, db.Create(user).Error)
+
+	// 每3个请求中2个扣减100、1个充值50，扣减总额远超初始余额
+	const workers = 60
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var insufficientBalance, insufficientPoints int
+	for i := 0; i < workers; i++ {
+		amount, balanceType, pointsType := int64(-100), models.BalanceTypeConsume, models.PointsTypeUse
+		if i%3 == 0 {
+			amount, balanceType, pointsType = 50, models.BalanceTypeRecharge, models.PointsTypeObtain
+		}
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := service.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: user.ID, Amount: amount, Type: balanceType})
+			if err != nil {
+				assert.Equal(t, "余额不足", err.Error())
+				mu.Lock()
+				insufficientBalance++
+				mu.Unlock()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := service.ChangePoints(ctx, &ChangePointsRequest{UserID: user.ID, Quantity: amount, Type: pointsType})
+			if err != nil {
+				assert.Equal(t, "积分不足", err.Error())
+				mu.Lock()
+				insufficientPoints++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var result models.User
+	require.NoError(t, db.First(&result, user.ID).Error)
+
+	// 按写入顺序回放变动记录：每条记录的变动后余额等于上一条加变动金额，说明变动依次执行且没有丢失
+	var balanceRecords []models.BalanceRecord
+	require.NoError(t, db.Where("user_id = ?", user.ID).Order("id ASC").Find(&balanceRecords).Error)
+	assert.Equal(t, workers-insufficientBalance, len(balanceRecords))
+	balance := int64(1000)
+	for _, record := range balanceRecords {
+		balance += record.Amount
+		require.Equal(t, balance, record.BalanceAfter)
+		require.GreaterOrEqual(t, record.BalanceAfter, int64(0))
+	}
+	assert.Equal(t, balance, result.Balance)
+	assert.Greater(t, insufficientBalance, 0)
+
+	var pointsRecords []models.PointsRecord
+	require.NoError(t, db.Where("user_id = ?", user.ID).Order("id ASC").Find(&pointsRecords).Error)
+	assert.Equal(t, workers-insufficientPoints, len(pointsRecords))
+	points := int64(1000)
+	for _, record := range pointsRecords {
+		points += record.Quantity
+		require.Equal(t, points, record.PointsAfter)
+		require.GreaterOrEqual(t, record.PointsAfter, int64(0))
+	}
+	assert.Equal(t, points, result.Points)
+	assert.Greater(t, insufficientPoints, 0)
+}
+
+// TestAssetService_ConcurrentDrain 并发扣减超过余额时恰好扣到0
+func TestAssetService_ConcurrentDrain(t *testing.T) {
+	db := setupConcurrentAssetDB(t)
+	service := NewAssetService(db)
+	ctx := context.Background()
+
+	user := &models.User{Username: "drain", Password: "x", Phone: "13800000010", Email: "drain@example.com", Balance: 1000}
+	require.NoError(t, db.Create(user).Error)
+
+	const workers = 30
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: user.ID, Amount: -100, Type: models.BalanceTypeConsume})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	assert.Equal(t, 10, succeeded)
+
+	var result models.User
+	require.NoError(t, db.First(&result, user.ID).Error)
+	assert.Equal(t, int64(0), result.Balance)
+}
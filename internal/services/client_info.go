@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"strings"
+)
+
+// ClientInfo 客户端信息
+type ClientInfo struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Device    string `json:"device"`
+}
+
+// WithClientInfo 将客户端信息写入上下文
+func WithClientInfo(ctx context.Context, info *ClientInfo) context.Context {
+	return context.WithValue(ctx, "client_info", info)
+}
+
+// GetClientInfoFromContext 从上下文获取客户端信息
+func GetClientInfoFromContext(ctx context.Context) *ClientInfo {
+	if info, ok := ctx.Value("client_info").(*ClientInfo); ok && info != nil {
+		return info
+	}
+	return &ClientInfo{}
+}
+
+// DetectDevice 根据User-Agent识别设备类型
+func DetectDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return "unknown"
+	case strings.Contains(ua, "miniprogram"):
+		return "WeChat MiniProgram"
+	case strings.Contains(ua, "micromessenger"):
+		return "WeChat"
+	case strings.Contains(ua, "iphone"):
+		return "iPhone"
+	case strings.Contains(ua, "ipad"):
+		return "iPad"
+	case strings.Contains(ua, "android"):
+		return "Android"
+	case strings.Contains(ua, "windows"):
+		return "Windows"
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os"):
+		return "Mac"
+	case strings.Contains(ua, "linux"):
+		return "Linux"
+	default:
+		return "other"
+	}
+}
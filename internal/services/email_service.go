@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"member-link-lite/config"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 邮件发送间隔缓存键前缀
+const mailIntervalKeyPrefix = "mail:interval:"
+
+// EmailService 邮箱验证与找回密码服务接口
+type EmailService interface {
+	// 发送邮箱验证邮件
+	SendVerificationEmail(ctx context.Context, userID uint64) error
+	// 通过验证链接验证邮箱
+	VerifyEmail(ctx context.Context, token string) error
+	// 发送重置密码邮件，邮箱未注册时同样返回成功
+	ForgotPassword(ctx context.Context, email string) error
+	// 通过重置链接重置密码，并注销用户的全部会话
+	ResetPassword(ctx context.Context, req *ResetPasswordRequest) error
+}
+
+// EmailConfig 邮件链接配置
+type EmailConfig struct {
+	ResetPasswordURL string        // 重置密码页面地址
+	VerifyEmailURL   string        // 邮箱验证地址
+	ResetTokenTTL    time.Duration // 重置密码链接有效期
+	VerifyTokenTTL   time.Duration // 邮箱验证链接有效期
+	SendInterval     time.Duration // 同一邮箱发送间隔
+	Secret           string        // 链接签名密钥
+}
+
+// ForgotPasswordRequest 找回密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"test@example.com"`
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required" example:"newpassword123"`
+}
+
+// emailServiceImpl 邮箱验证与找回密码服务实现
+type emailServiceImpl struct {
+	db       *gorm.DB
+	mailer   mail.Mailer
+	cache    cache.Cache
+	sessions SessionService
+	tokens   *actionTokenSigner
+	cfg      *EmailConfig
+}
+
+// LoadEmailConfig 从配置文件加载邮件链接配置
+func LoadEmailConfig() *EmailConfig {
+	return &EmailConfig{
+		ResetPasswordURL: config.GetString("mail.reset_password_url"),
+		VerifyEmailURL:   config.GetString("mail.verify_email_url"),
+		ResetTokenTTL:    time.Duration(config.GetInt("mail.reset_token_ttl")) * time.Minute,
+		VerifyTokenTTL:   time.Duration(config.GetInt("mail.verify_token_ttl")) * time.Minute,
+		SendInterval:     time.Duration(config.GetInt("mail.send_interval")) * time.Second,
+		Secret:           config.GetString("jwt.secret"),
+	}
+}
+
+// NewEmailService 创建邮箱验证与找回密码服务实例
+func NewEmailService() EmailService {
+	cfg := LoadEmailConfig()
+	c := database.GetCache()
+	return &emailServiceImpl{
+		db:       database.GetDB(),
+		mailer:   mail.GetMailer(),
+		cache:    c,
+		sessions: NewSessionService(),
+		tokens:   newActionTokenSigner(cfg.Secret, c),
+		cfg:      cfg,
+	}
+}
+
+// SendVerificationEmail 发送邮箱验证邮件
+func (s *emailServiceImpl) SendVerificationEmail(ctx context.Context, userID uint64) error {
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	var user models.User
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeActive).
+		Where("id = ? AND tenant_id = ?", userID, tenantID).
+		First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return common.ErrUserNotFound
+		}
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	if user.Email == "" {
+		return common.ErrInvalidEmail
+	}
+	if user.IsEmailVerified() {
+		return common.ErrEmailAlreadyVerified
+	}
+
+	if err := s.checkInterval(ctx, ActionVerifyEmail, tenantID, user.Email); err != nil {
+		return err
+	}
+
+	// 令牌绑定邮箱地址，修改邮箱后旧链接失效
+	token, err := s.tokens.Issue(ActionVerifyEmail, user.ID, tenantID, strings.ToLower(user.Email), s.cfg.VerifyTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("%s，您好：\n\n请点击以下链接验证您的邮箱，链接%s内有效：\n\n%s\n\n如果这不是您本人的操作，请忽略此邮件。\n",
+		user.Nickname, formatTTL(s.cfg.VerifyTokenTTL), buildLink(s.cfg.VerifyEmailURL, token))
+
+	if err := s.mailer.Send(ctx, &mail.Message{To: user.Email, Subject: "验证您的邮箱", Body: body}); err != nil {
+		return common.ErrMailSendFailed
+	}
+	return nil
+}
+
+// VerifyEmail 通过验证链接验证邮箱
+func (s *emailServiceImpl) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := s.tokens.Parse(ActionVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).
+		Where("id = ? AND tenant_id = ?", claims.UserID, claims.TenantID).
+		First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return common.ErrInvalidLinkToken
+		}
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	if !strings.EqualFold(user.Email, claims.Subject) {
+		return common.ErrInvalidLinkToken
+	}
+	if user.IsEmailVerified() {
+		return nil
+	}
+
+	if err := s.tokens.Consume(ctx, claims); err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ? AND tenant_id = ? AND email = ?", user.ID, user.TenantID, user.Email).
+		Update("email_verified_at", time.Now()).Error
+}
+
+// ForgotPassword 发送重置密码邮件
+func (s *emailServiceImpl) ForgotPassword(ctx context.Context, email string) error {
+	if err := validateEmail(email); err != nil {
+		return common.ErrInvalidEmail
+	}
+
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	// 发送间隔在查询用户前检查，避免通过响应差异判断邮箱是否注册
+	if err := s.checkInterval(ctx, ActionPasswordReset, tenantID, email); err != nil {
+		return err
+	}
+
+	var user models.User
+	err := s.db.WithContext(ctx).
+		Scopes(models.ScopeActive).
+		Where("email = ? AND tenant_id = ?", email, tenantID).
+		First(&user).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	// 令牌绑定当前密码指纹，密码修改后旧链接失效
+	token, err := s.tokens.Issue(ActionPasswordReset, user.ID, tenantID, passwordFingerprint(user.Password), s.cfg.ResetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("%s，您好：\n\n我们收到了重置您账号密码的请求，请点击以下链接设置新密码，链接%s内有效且仅能使用一次：\n\n%s\n\n如果这不是您本人的操作，请忽略此邮件，您的密码不会被修改。\n",
+		user.Nickname, formatTTL(s.cfg.ResetTokenTTL), buildLink(s.cfg.ResetPasswordURL, token))
+
+	if err := s.mailer.Send(ctx, &mail.Message{To: user.Email, Subject: "重置密码", Body: body}); err != nil {
+		return common.ErrMailSendFailed
+	}
+	return nil
+}
+
+// ResetPassword 通过重置链接重置密码
+func (s *emailServiceImpl) ResetPassword(ctx context.Context, req *ResetPasswordRequest) error {
+	claims, err := s.tokens.Parse(ActionPasswordReset, req.Token)
+	if err != nil {
+		return err
+	}
+
+	// 验证新密码是否符合用户所属租户的密码策略
+	policy := LoadPasswordPolicy(claims.TenantID)
+	if err := policy.Validate(req.NewPassword); err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeActive).
+		Where("id = ? AND tenant_id = ?", claims.UserID, claims.TenantID).
+		First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return common.ErrInvalidLinkToken
+		}
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	if passwordFingerprint(user.Password) != claims.Subject {
+		return common.ErrInvalidLinkToken
+	}
+
+	// 不能使用最近使用过的密码，检查通过后才使链接失效
+	if err := policy.CheckReuse(ctx, s.db, &user, req.NewPassword); err != nil {
+		return err
+	}
+
+	if err := s.tokens.Consume(ctx, claims); err != nil {
+		return err
+	}
+
+	// 加密新密码
+	newUser := &models.User{}
+	if err := newUser.HashPassword(req.NewPassword); err != nil {
+		return fmt.Errorf("密码加密失败: %w", err)
+	}
+
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND password = ?", user.ID, user.Password).
+			Updates(passwordUpdates(newUser.Password, time.Now()))
+		if result.Error != nil {
+			return fmt.Errorf("重置密码失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return common.ErrInvalidLinkToken
+		}
+		return policy.Record(tx, &user, newUser.Password)
+	}); err != nil {
+		return err
+	}
+
+	// 密码重置后注销全部登录会话
+	if _, err := s.sessions.RevokeAllSessions(ctx, user.ID); err != nil {
+		return fmt.Errorf("注销会话失败: %w", err)
+	}
+
+	return nil
+}
+
+// checkInterval 检查同一邮箱的发送间隔
+func (s *emailServiceImpl) checkInterval(ctx context.Context, action, tenantID, email string) error {
+	if s.cfg.SendInterval <= 0 {
+		return nil
+	}
+
+	key := mailIntervalKeyPrefix + action + ":" + tenantID + ":" + strings.ToLower(email)
+	ok, err := s.cache.SetNX(ctx, key, "1", s.cfg.SendInterval)
+	if err != nil {
+		return fmt.Errorf("检查发送间隔失败: %w", err)
+	}
+	if !ok {
+		return common.ErrMailSendTooFrequent
+	}
+	return nil
+}
+
+// buildLink 在地址后追加token参数
+func buildLink(base, token string) string {
+	separator := "?"
+	if strings.Contains(base, "?") {
+		separator = "&"
+	}
+	return base + separator + "token=" + url.QueryEscape(token)
+}
+
+// formatTTL 格式化有效期
+func formatTTL(ttl time.Duration) string {
+	if ttl >= time.Hour && ttl%time.Hour == 0 {
+		return fmt.Sprintf("%d小时", int(ttl/time.Hour))
+	}
+	return fmt.Sprintf("%d分钟", int(ttl/time.Minute))
+}
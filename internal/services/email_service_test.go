@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/mail"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestEmailService 创建使用日志发送器的邮件服务
+func setupTestEmailService(t *testing.T) (*emailServiceImpl, *userServiceImpl, *mail.LogMailer) {
+	config.Init()
+	db := setupTestDB(t)
+	memCache := cache.NewMemoryCache()
+	mailer := mail.NewLogMailer(t.TempDir(), false)
+	jwtService := NewJWTService()
+
+	cfg := &EmailConfig{
+		ResetPasswordURL: "http://localhost:3000/reset-password",
+		VerifyEmailURL:   "http://localhost:8080/api/v1/auth/email/verify",
+		ResetTokenTTL:    30 * time.Minute,
+		VerifyTokenTTL:   time.Hour,
+		Secret:           "test-secret",
+	}
+	service := &emailServiceImpl{
+		db:       db,
+		mailer:   mailer,
+		cache:    memCache,
+		sessions: &sessionServiceImpl{db: db, jwtService: jwtService},
+		tokens:   newActionTokenSigner(cfg.Secret, memCache),
+		cfg:      cfg,
+	}
+	userService := &userServiceImpl{db: db, jwtService: jwtService, cache: memCache}
+	return service, userService, mailer
+}
+
+// lastMailToken 从最后一封邮件的链接中提取令牌
+func lastMailToken(t *testing.T, mailer *mail.LogMailer, to string) string {
+	msg, ok := mailer.LastMessage(to)
+	require.True(t, ok)
+	link := regexp.MustCompile(`https?://\S+`).FindString(msg.Body)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	token := u.Query().Get("token")
+	require.NotEmpty(t, token)
+	return token
+}
+
+func TestEmailService_ResetPassword(t *testing.T) {
+	service, userService, mailer := setupTestEmailService(t)
+	ctx := context.Background()
+
+	user, err := userService.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+	loginResp, err := userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "password123"})
+	require.NoError(t, err)
+
+	// 未注册邮箱同样返回成功，但不发送邮件
+	require.NoError(t, service.ForgotPassword(ctx, "nobody@example.com"))
+	_, sent := mailer.LastMessage("nobody@example.com")
+	assert.False(t, sent)
+
+	require.NoError(t, service.ForgotPassword(ctx, "test@example.com"))
+	token := lastMailToken(t, mailer, "test@example.com")
+
+	// 新密码需满足强度要求
+	err = service.ResetPassword(ctx, &ResetPasswordRequest{Token: token, NewPassword: "123"})
+	assert.Error(t, err)
+
+	// 篡改的令牌无效
+	err = service.ResetPassword(ctx, &ResetPasswordRequest{Token: token + "x", NewPassword: "newpassword123"})
+	assert.Equal(t, common.ErrInvalidLinkToken, err)
+
+	require.NoError(t, service.ResetPassword(ctx, &ResetPasswordRequest{Token: token, NewPassword: "newpassword123"}))
+
+	// 令牌仅能使用一次
+	err = service.ResetPassword(ctx, &ResetPasswordRequest{Token: token, NewPassword: "another123"})
+	assert.Equal(t, common.ErrInvalidLinkToken, err)
+
+	// 新密码生效，旧密码失效
+	_, err = userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "password123"})
+	assert.Equal(t, common.ErrInvalidPassword, err)
+	_, err = userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "newpassword123"})
+	assert.NoError(t, err)
+
+	// 重置前的会话已注销
+	_, err = userService.RefreshToken(ctx, loginResp.Tokens.RefreshToken)
+	assert.Equal(t, common.ErrTokenRevoked, err)
+
+	var count int64
+	service.db.Model(&models.UserSession{}).
+		Scopes(models.ScopeActiveSessions).
+		Where("user_id = ?", user.ID).
+		Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestEmailService_ResetTokenInvalidAfterPasswordChange(t *testing.T) {
+	service, userService, mailer := setupTestEmailService(t)
+	ctx := context.Background()
+
+	user, err := userService.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, service.ForgotPassword(ctx, "test@example.com"))
+	token := lastMailToken(t, mailer, "test@example.com")
+
+	// 发送频率限制
+	service.cfg.SendInterval = time.Minute
+	require.NoError(t, service.ForgotPassword(ctx, "test@example.com"))
+	assert.Equal(t, common.ErrMailSendTooFrequent, service.ForgotPassword(ctx, "test@example.com"))
+
+	// 修改密码后旧的重置链接失效
+	require.NoError(t, userService.ChangePassword(ctx, user.ID, &ChangePasswordRequest{
+		OldPassword: "password123",
+		NewPassword: "changed123",
+	}))
+	err = service.ResetPassword(ctx, &ResetPasswordRequest{Token: token, NewPassword: "newpassword123"})
+	assert.Equal(t, common.ErrInvalidLinkToken, err)
+}
+
+func TestEmailService_VerifyEmail(t *testing.T) {
+	service, userService, mailer := setupTestEmailService(t)
+	ctx := context.Background()
+
+	user, err := userService.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+	assert.False(t, user.IsEmailVerified())
+
+	require.NoError(t, service.SendVerificationEmail(ctx, user.ID))
+	token := lastMailToken(t, mailer, "test@example.com")
+
+	// 篡改的令牌无效，且令牌不能跨用途使用
+	assert.Equal(t, common.ErrInvalidLinkToken, service.VerifyEmail(ctx, token+"x"))
+	_, err = service.tokens.Parse(ActionPasswordReset, token)
+	assert.Equal(t, common.ErrInvalidLinkToken, err)
+
+	require.NoError(t, service.VerifyEmail(ctx, token))
+
+	verified, err := userService.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, verified.IsEmailVerified())
+
+	// 已验证的邮箱不再发送验证邮件
+	assert.Equal(t, common.ErrEmailAlreadyVerified, service.SendVerificationEmail(ctx, user.ID))
+
+	// 修改邮箱后需重新验证
+	require.NoError(t, userService.UpdateProfile(ctx, user.ID, &UpdateProfileRequest{Email: "new@example.com"}))
+	changed, err := userService.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, changed.IsEmailVerified())
+
+	// 旧邮箱的验证链接失效
+	assert.Equal(t, common.ErrInvalidLinkToken, service.VerifyEmail(ctx, token))
+}
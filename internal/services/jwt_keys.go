@@ -0,0 +1,366 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"member-link-lite/config"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtKeyConfig JWT密钥配置
+type jwtKeyConfig struct {
+	Algorithm        string            // 签名算法：HS256、RS256、ES256
+	Secret           string            // HS256共享密钥
+	KeyID            string            // 当前签名密钥ID
+	PrivateKeyFile   string            // 当前签名私钥PEM文件
+	VerificationKeys map[string]string // 历史验证公钥：kid -> 公钥PEM文件
+}
+
+// JWK JSON Web Key
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// jwtVerificationKey 验证密钥
+type jwtVerificationKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    interface{}
+}
+
+// jwtKeySet JWT密钥集合
+type jwtKeySet struct {
+	method       jwt.SigningMethod
+	kid          string
+	signingKey   interface{}
+	verification map[string]*jwtVerificationKey
+}
+
+var (
+	jwtKeysMu      sync.RWMutex
+	currentJWTKey  *jwtKeySet
+	currentOIDCKey *jwtKeySet
+)
+
+// InitJWTKeys 根据配置加载JWT密钥
+// 应在服务启动时调用，密钥配置错误时返回错误
+func InitJWTKeys() error {
+	keys, err := loadJWTKeySet(jwtKeyConfigFromConfig())
+	if err != nil {
+		return err
+	}
+
+	jwtKeysMu.Lock()
+	currentJWTKey = keys
+	jwtKeysMu.Unlock()
+	return nil
+}
+
+// getJWTKeySet 获取当前JWT密钥集合
+// 未调用InitJWTKeys时回退为使用jwt.secret的HS256密钥
+func getJWTKeySet() *jwtKeySet {
+	jwtKeysMu.RLock()
+	keys := currentJWTKey
+	jwtKeysMu.RUnlock()
+	if keys != nil {
+		return keys
+	}
+
+	return newHMACKeySet(config.GetString("jwt.secret"), config.GetString("jwt.key_id"))
+}
+
+// InitOIDCKeys 根据配置加载OIDC ID令牌签名密钥
+// 未配置oauth.oidc.private_key_file时不启用OIDC；ID令牌需由第三方验证，不允许使用HS256
+func InitOIDCKeys() error {
+	keys, err := loadOIDCKeySet(oidcKeyConfigFromConfig())
+	if err != nil {
+		return err
+	}
+
+	jwtKeysMu.Lock()
+	currentOIDCKey = keys
+	jwtKeysMu.Unlock()
+	return nil
+}
+
+// getOIDCKeySet 获取OIDC ID令牌签名密钥，未启用OIDC时返回nil
+func getOIDCKeySet() *jwtKeySet {
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+	return currentOIDCKey
+}
+
+// oidcKeyConfigFromConfig 从配置文件读取OIDC密钥配置
+func oidcKeyConfigFromConfig() *jwtKeyConfig {
+	return &jwtKeyConfig{
+		Algorithm:        config.GetString("oauth.oidc.algorithm"),
+		KeyID:            config.GetString("oauth.oidc.key_id"),
+		PrivateKeyFile:   config.GetString("oauth.oidc.private_key_file"),
+		VerificationKeys: config.GetStringMapString("oauth.oidc.verification_keys"),
+	}
+}
+
+// loadOIDCKeySet 加载OIDC ID令牌签名密钥，未配置私钥时返回nil
+func loadOIDCKeySet(cfg *jwtKeyConfig) (*jwtKeySet, error) {
+	if cfg.PrivateKeyFile == "" {
+		return nil, nil
+	}
+
+	algorithm := strings.ToUpper(cfg.Algorithm)
+	if algorithm != jwt.SigningMethodRS256.Alg() && algorithm != jwt.SigningMethodES256.Alg() {
+		return nil, fmt.Errorf("OIDC ID令牌只支持RS256或ES256签名: %s", cfg.Algorithm)
+	}
+	if cfg.KeyID == "" {
+		return nil, errors.New("启用OIDC时 oauth.oidc.key_id 不能为空")
+	}
+	return loadJWTKeySet(cfg)
+}
+
+// jwtKeyConfigFromConfig 从配置文件读取JWT密钥配置
+func jwtKeyConfigFromConfig() *jwtKeyConfig {
+	return &jwtKeyConfig{
+		Algorithm:        config.GetString("jwt.algorithm"),
+		Secret:           config.GetString("jwt.secret"),
+		KeyID:            config.GetString("jwt.key_id"),
+		PrivateKeyFile:   config.GetString("jwt.private_key_file"),
+		VerificationKeys: config.GetStringMapString("jwt.verification_keys"),
+	}
+}
+
+// loadJWTKeySet 加载JWT密钥集合
+func loadJWTKeySet(cfg *jwtKeyConfig) (*jwtKeySet, error) {
+	algorithm := strings.ToUpper(cfg.Algorithm)
+	if algorithm == "" || algorithm == jwt.SigningMethodHS256.Alg() {
+		if cfg.Secret == "" {
+			return nil, errors.New("jwt.secret 不能为空")
+		}
+		return newHMACKeySet(cfg.Secret, cfg.KeyID), nil
+	}
+
+	if cfg.KeyID == "" {
+		return nil, errors.New("使用非对称签名时 jwt.key_id 不能为空")
+	}
+	if cfg.PrivateKeyFile == "" {
+		return nil, errors.New("使用非对称签名时 jwt.private_key_file 不能为空")
+	}
+
+	data, err := os.ReadFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取JWT私钥失败: %w", err)
+	}
+	privateKey, err := parsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析JWT私钥失败: %w", err)
+	}
+
+	var publicKey crypto.PublicKey
+	switch algorithm {
+	case jwt.SigningMethodRS256.Alg():
+		key, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("RS256 需要RSA私钥")
+		}
+		publicKey = &key.PublicKey
+	case jwt.SigningMethodES256.Alg():
+		key, ok := privateKey.(*ecdsa.PrivateKey)
+		if !ok || key.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 需要P-256曲线的ECDSA私钥")
+		}
+		publicKey = &key.PublicKey
+	default:
+		return nil, fmt.Errorf("不支持的JWT签名算法: %s", cfg.Algorithm)
+	}
+
+	method := jwt.GetSigningMethod(algorithm)
+	keys := &jwtKeySet{
+		method:       method,
+		kid:          cfg.KeyID,
+		signingKey:   privateKey,
+		verification: make(map[string]*jwtVerificationKey),
+	}
+	keys.verification[cfg.KeyID] = &jwtVerificationKey{kid: cfg.KeyID, method: method, key: publicKey}
+
+	// 加载轮换期间仍有效的历史公钥
+	for kid, file := range cfg.VerificationKeys {
+		if kid == cfg.KeyID {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取JWT验证公钥 %s 失败: %w", kid, err)
+		}
+		verifyKey, err := parseVerificationKeyPEM(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("解析JWT验证公钥 %s 失败: %w", kid, err)
+		}
+		keys.verification[kid] = verifyKey
+	}
+
+	return keys, nil
+}
+
+// newHMACKeySet 创建HS256密钥集合
+func newHMACKeySet(secret, kid string) *jwtKeySet {
+	key := []byte(secret)
+	return &jwtKeySet{
+		method:     jwt.SigningMethodHS256,
+		kid:        kid,
+		signingKey: key,
+		verification: map[string]*jwtVerificationKey{
+			kid: {kid: kid, method: jwt.SigningMethodHS256, key: key},
+		},
+	}
+}
+
+// keyFunc 根据令牌头部的kid选择验证密钥
+func (k *jwtKeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		// 兼容未携带kid的历史令牌，使用当前签名密钥验证
+		kid = k.kid
+	}
+
+	verifyKey, ok := k.verification[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %s", kid)
+	}
+
+	// 验证签名方法，防止算法混淆攻击
+	if token.Method.Alg() != verifyKey.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return verifyKey.key, nil
+}
+
+// sign 使用当前签名密钥签名
+func (k *jwtKeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.kid != "" {
+		token.Header["kid"] = k.kid
+	}
+	return token.SignedString(k.signingKey)
+}
+
+// jwks 导出全部公钥（对称密钥不对外发布）
+func (k *jwtKeySet) jwks() *JWKSet {
+	set := &JWKSet{Keys: []JWK{}}
+
+	kids := make([]string, 0, len(k.verification))
+	for kid := range k.verification {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	for _, kid := range kids {
+		verifyKey := k.verification[kid]
+		switch pub := verifyKey.key.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Use: "sig",
+				Alg: verifyKey.method.Alg(),
+				Kid: kid,
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			set.Keys = append(set.Keys, JWK{
+				Kty: "EC",
+				Use: "sig",
+				Alg: verifyKey.method.Alg(),
+				Kid: kid,
+				Crv: pub.Curve.Params().Name,
+				X:   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+				Y:   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+			})
+		}
+	}
+
+	return set
+}
+
+// parsePrivateKeyPEM 解析PEM格式私钥（支持PKCS#1、PKCS#8、SEC 1）
+func parsePrivateKeyPEM(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("无效的PEM数据")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("不支持的私钥类型: %s", block.Type)
+	}
+}
+
+// parseVerificationKeyPEM 解析PEM格式公钥或证书，并根据密钥类型确定签名算法
+func parseVerificationKeyPEM(kid string, data []byte) (*jwtVerificationKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("无效的PEM数据")
+	}
+
+	var publicKey crypto.PublicKey
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			publicKey = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("不支持的公钥类型: %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return &jwtVerificationKey{kid: kid, method: jwt.SigningMethodRS256, key: key}, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New("仅支持P-256曲线的ECDSA公钥")
+		}
+		return &jwtVerificationKey{kid: kid, method: jwt.SigningMethodES256, key: key}, nil
+	default:
+		return nil, errors.New("不支持的公钥算法")
+	}
+}
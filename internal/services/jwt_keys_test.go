@@ -0,0 +1,155 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"member-link-lite/pkg/cache"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePEM 写入PEM文件
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+// newTestJWTService 使用指定密钥集合创建JWT服务
+func newTestJWTService(keys *jwtKeySet) *jwtServiceImpl {
+	return &jwtServiceImpl{
+		keys:            keys,
+		accessTokenTTL:  time.Hour,
+		refreshTokenTTL: 24 * time.Hour,
+		issuer:          "memberlink-lite",
+		cache:           cache.NewMemoryCache(),
+	}
+}
+
+func TestLoadJWTKeySet_RS256(t *testing.T) {
+	dir := t.TempDir()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyFile := writePEM(t, dir, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(privateKey))
+
+	keys, err := loadJWTKeySet(&jwtKeyConfig{Algorithm: "RS256", KeyID: "rsa-1", PrivateKeyFile: keyFile})
+	require.NoError(t, err)
+	service := newTestJWTService(keys)
+
+	token, err := service.GenerateAccessToken(123, "testuser")
+	require.NoError(t, err)
+
+	// 令牌头部携带kid和算法
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &JWTClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "rsa-1", parsed.Header["kid"])
+	assert.Equal(t, "RS256", parsed.Header["alg"])
+
+	claims, err := service.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(123), claims.UserID)
+
+	// 第三方可使用JWKS中的公钥验证
+	jwks := service.JWKS()
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+	assert.Equal(t, "rsa-1", jwks.Keys[0].Kid)
+	assert.Equal(t, "AQAB", jwks.Keys[0].E)
+}
+
+func TestLoadJWTKeySet_ES256Rotation(t *testing.T) {
+	dir := t.TempDir()
+
+	// 旧密钥
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	oldDER, err := x509.MarshalECPrivateKey(oldKey)
+	require.NoError(t, err)
+	oldKeyFile := writePEM(t, dir, "old.pem", "EC PRIVATE KEY", oldDER)
+	oldPubDER, err := x509.MarshalPKIXPublicKey(&oldKey.PublicKey)
+	require.NoError(t, err)
+	oldPubFile := writePEM(t, dir, "old.pub.pem", "PUBLIC KEY", oldPubDER)
+
+	oldKeys, err := loadJWTKeySet(&jwtKeyConfig{Algorithm: "ES256", KeyID: "ec-old", PrivateKeyFile: oldKeyFile})
+	require.NoError(t, err)
+	oldToken, err := newTestJWTService(oldKeys).GenerateAccessToken(123, "testuser")
+	require.NoError(t, err)
+
+	// 新密钥（PKCS#8格式），同时保留旧公钥用于验证
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newDER, err := x509.MarshalPKCS8PrivateKey(newKey)
+	require.NoError(t, err)
+	newKeyFile := writePEM(t, dir, "new.pem", "PRIVATE KEY", newDER)
+
+	newKeys, err := loadJWTKeySet(&jwtKeyConfig{
+		Algorithm:        "ES256",
+		KeyID:            "ec-new",
+		PrivateKeyFile:   newKeyFile,
+		VerificationKeys: map[string]string{"ec-old": oldPubFile},
+	})
+	require.NoError(t, err)
+	service := newTestJWTService(newKeys)
+
+	// 旧密钥签发的令牌在轮换期间仍然有效
+	claims, err := service.ValidateToken(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(123), claims.UserID)
+
+	newToken, err := service.GenerateAccessToken(456, "another")
+	require.NoError(t, err)
+	_, err = service.ValidateToken(newToken)
+	require.NoError(t, err)
+
+	// 移除旧公钥后旧令牌失效
+	_, err = newTestJWTService(&jwtKeySet{
+		method:       newKeys.method,
+		kid:          newKeys.kid,
+		signingKey:   newKeys.signingKey,
+		verification: map[string]*jwtVerificationKey{"ec-new": newKeys.verification["ec-new"]},
+	}).ValidateToken(oldToken)
+	assert.Error(t, err)
+
+	jwks := service.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "ec-new", jwks.Keys[0].Kid)
+	assert.Equal(t, "ec-old", jwks.Keys[1].Kid)
+	assert.Equal(t, "P-256", jwks.Keys[0].Crv)
+}
+
+func TestLoadJWTKeySet_RejectsAlgorithmConfusion(t *testing.T) {
+	dir := t.TempDir()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyFile := writePEM(t, dir, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(privateKey))
+
+	keys, err := loadJWTKeySet(&jwtKeyConfig{Algorithm: "RS256", KeyID: "rsa-1", PrivateKeyFile: keyFile})
+	require.NoError(t, err)
+	service := newTestJWTService(keys)
+
+	// 使用HS256伪造的令牌不能通过验证
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &JWTClaims{UserID: 1, Type: TokenTypeAccess})
+	forged.Header["kid"] = "rsa-1"
+	token, err := forged.SignedString([]byte("any-secret"))
+	require.NoError(t, err)
+
+	_, err = service.ValidateToken(token)
+	assert.Error(t, err)
+
+	// 私钥与算法不匹配时加载失败
+	_, err = loadJWTKeySet(&jwtKeyConfig{Algorithm: "ES256", KeyID: "rsa-1", PrivateKeyFile: keyFile})
+	assert.Error(t, err)
+}
@@ -1,15 +1,33 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"member-link-lite/config"
+	"member-link-lite/internal/database"
+	"member-link-lite/pkg/cache"
 	"member-link-lite/pkg/common"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// 令牌类型
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// 令牌状态缓存键前缀
+const (
+	revokedTokenKeyPrefix  = "jwt:revoked:"
+	revokedFamilyKeyPrefix = "jwt:revoked:family:"
+	usedTokenKeyPrefix     = "jwt:used:"
+)
+
 // JWTService JWT服务接口
 type JWTService interface {
 	// 生成访问令牌
@@ -20,85 +38,180 @@ type JWTService interface {
 	ValidateToken(tokenString string) (*JWTClaims, error)
 	// 解析令牌（不验证过期时间）
 	ParseToken(tokenString string) (*JWTClaims, error)
+	// 根据声明生成令牌（自动补全jti、签发时间和过期时间）
+	GenerateTokenWithClaims(claims *JWTClaims) (string, error)
+	// 吊销单个令牌
+	RevokeToken(ctx context.Context, claims *JWTClaims) error
+	// 吊销令牌族（令牌本身及同一登录会话中轮换产生的全部令牌）
+	RevokeTokenFamily(ctx context.Context, claims *JWTClaims) error
+	// 根据令牌族ID吊销令牌族
+	RevokeFamily(ctx context.Context, familyID string) error
+	// 检查令牌是否已被吊销
+	IsTokenRevoked(ctx context.Context, claims *JWTClaims) (bool, error)
+	// 将刷新令牌标记为已使用，返回是否为首次使用
+	ConsumeRefreshToken(ctx context.Context, claims *JWTClaims) (bool, error)
+	// 获取用于验证令牌的公钥集合（JWKS）
+	JWKS() *JWKSet
 }
 
 // JWTClaims JWT声明
 type JWTClaims struct {
-	UserID   uint64 `json:"user_id"`
-	Username string `json:"username"`
-	Type     string `json:"type"` // access 或 refresh
+	UserID    uint64   `json:"user_id"`
+	Username  string   `json:"username"`
+	TenantID  string   `json:"tenant_id"`            // 用户所属租户，令牌只能在该租户下使用
+	Type      string   `json:"type"`                 // access 或 refresh
+	FamilyID  string   `json:"fid,omitempty"`        // 令牌族ID，同一次登录及其后续刷新签发的令牌共享
+	Roles     []string `json:"roles,omitempty"`      // 用户在所属租户下的角色编码，仅访问令牌携带
+	ClientID  string   `json:"client_id,omitempty"`  // 第三方应用客户端ID，仅OAuth令牌携带
+	Scope     string   `json:"scope,omitempty"`      // 第三方应用获得的授权范围，仅OAuth令牌携带
+	LoginType string   `json:"login_type,omitempty"` // 登录方式，仅两步验证临时令牌携带
 	jwt.RegisteredClaims
 }
 
 // jwtServiceImpl JWT服务实现
 type jwtServiceImpl struct {
-	secretKey       []byte
+	keys            *jwtKeySet
 	accessTokenTTL  time.Duration
 	refreshTokenTTL time.Duration
 	issuer          string
+	cache           cache.Cache
 }
 
 // NewJWTService 创建JWT服务实例
 func NewJWTService() JWTService {
 	return &jwtServiceImpl{
-		secretKey:       []byte(config.GetString("jwt.secret")),
+		keys:            getJWTKeySet(),
 		accessTokenTTL:  time.Duration(config.GetInt("jwt.access_token_ttl")) * time.Hour,
 		refreshTokenTTL: time.Duration(config.GetInt("jwt.refresh_token_ttl")) * time.Hour,
 		issuer:          config.GetString("jwt.issuer"),
+		cache:           database.GetCache(),
 	}
 }
 
 // GenerateAccessToken 生成访问令牌
 func (s *jwtServiceImpl) GenerateAccessToken(userID uint64, username string) (string, error) {
-	now := time.Now()
-	claims := &JWTClaims{
+	return s.GenerateTokenWithClaims(&JWTClaims{
 		UserID:   userID,
 		Username: username,
-		Type:     "access",
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    s.issuer,
-			Subject:   fmt.Sprintf("%d", userID),
-			Audience:  []string{"member-system"},
-			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTokenTTL)),
-			NotBefore: jwt.NewNumericDate(now),
-			IssuedAt:  jwt.NewNumericDate(now),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secretKey)
+		Type:     TokenTypeAccess,
+	})
 }
 
 // GenerateRefreshToken 生成刷新令牌
 func (s *jwtServiceImpl) GenerateRefreshToken(userID uint64, username string) (string, error) {
-	now := time.Now()
-	claims := &JWTClaims{
+	return s.GenerateTokenWithClaims(&JWTClaims{
 		UserID:   userID,
 		Username: username,
-		Type:     "refresh",
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    s.issuer,
-			Subject:   fmt.Sprintf("%d", userID),
-			Audience:  []string{"member-system"},
-			ExpiresAt: jwt.NewNumericDate(now.Add(s.refreshTokenTTL)),
-			NotBefore: jwt.NewNumericDate(now),
-			IssuedAt:  jwt.NewNumericDate(now),
-		},
+		Type:     TokenTypeRefresh,
+	})
+}
+
+// GenerateTokenWithClaims 根据声明生成令牌
+func (s *jwtServiceImpl) GenerateTokenWithClaims(claims *JWTClaims) (string, error) {
+	now := time.Now()
+
+	if claims.ID == "" {
+		id, err := NewTokenID()
+		if err != nil {
+			return "", err
+		}
+		claims.ID = id
+	}
+	if claims.Issuer == "" {
+		claims.Issuer = s.issuer
+	}
+	if claims.Subject == "" {
+		claims.Subject = fmt.Sprintf("%d", claims.UserID)
+	}
+	if len(claims.Audience) == 0 {
+		claims.Audience = []string{"member-system"}
+	}
+	if claims.IssuedAt == nil {
+		claims.IssuedAt = jwt.NewNumericDate(now)
+	}
+	if claims.NotBefore == nil {
+		claims.NotBefore = claims.IssuedAt
+	}
+	if claims.ExpiresAt == nil {
+		ttl := s.accessTokenTTL
+		if claims.Type == TokenTypeRefresh || claims.Type == TokenTypeOAuthRefresh {
+			ttl = s.refreshTokenTTL
+		}
+		claims.ExpiresAt = jwt.NewNumericDate(claims.IssuedAt.Add(ttl))
+	}
+
+	return s.keys.sign(claims)
+}
+
+// RevokeToken 吊销单个令牌，吊销记录保留到令牌过期为止
+func (s *jwtServiceImpl) RevokeToken(ctx context.Context, claims *JWTClaims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return common.ErrInvalidToken
+	}
+
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		// 令牌已过期，无需记录
+		return nil
+	}
+
+	return s.cache.Set(ctx, revokedTokenKeyPrefix+claims.ID, "1", ttl)
+}
+
+// RevokeTokenFamily 吊销令牌族
+func (s *jwtServiceImpl) RevokeTokenFamily(ctx context.Context, claims *JWTClaims) error {
+	if err := s.RevokeToken(ctx, claims); err != nil {
+		return err
+	}
+
+	return s.RevokeFamily(ctx, claims.FamilyID)
+}
+
+// RevokeFamily 根据令牌族ID吊销令牌族
+func (s *jwtServiceImpl) RevokeFamily(ctx context.Context, familyID string) error {
+	if familyID == "" {
+		return nil
+	}
+
+	// 族内令牌均签发于此刻之前，吊销记录保留一个刷新令牌有效期即可覆盖全部令牌
+	return s.cache.Set(ctx, revokedFamilyKeyPrefix+familyID, "1", s.refreshTokenTTL)
+}
+
+// IsTokenRevoked 检查令牌是否已被吊销
+func (s *jwtServiceImpl) IsTokenRevoked(ctx context.Context, claims *JWTClaims) (bool, error) {
+	if claims.ID != "" {
+		revoked, err := s.cache.Exists(ctx, revokedTokenKeyPrefix+claims.ID)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	if claims.FamilyID != "" {
+		return s.cache.Exists(ctx, revokedFamilyKeyPrefix+claims.FamilyID)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secretKey)
+	return false, nil
+}
+
+// ConsumeRefreshToken 将刷新令牌标记为已使用
+// 每个刷新令牌只能使用一次，返回false表示令牌此前已被使用过
+func (s *jwtServiceImpl) ConsumeRefreshToken(ctx context.Context, claims *JWTClaims) (bool, error) {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return false, common.ErrInvalidToken
+	}
+
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return false, common.ErrTokenExpired
+	}
+
+	return s.cache.SetNX(ctx, usedTokenKeyPrefix+claims.ID, "1", ttl)
 }
 
 // ValidateToken 验证令牌
 func (s *jwtServiceImpl) ValidateToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// 验证签名方法
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return s.secretKey, nil
-	})
+	// 根据kid选择验证密钥并校验签名方法
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, s.keys.keyFunc)
 
 	if err != nil {
 		// 使用errors.Is检查特定的JWT错误
@@ -126,12 +239,7 @@ func (s *jwtServiceImpl) ValidateToken(tokenString string) (*JWTClaims, error) {
 
 // ParseToken 解析令牌（不验证过期时间）
 func (s *jwtServiceImpl) ParseToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return s.secretKey, nil
-	}, jwt.WithoutClaimsValidation())
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, s.keys.keyFunc, jwt.WithoutClaimsValidation())
 
 	if err != nil {
 		return nil, common.ErrTokenMalformed
@@ -144,6 +252,11 @@ func (s *jwtServiceImpl) ParseToken(tokenString string) (*JWTClaims, error) {
 	return nil, common.ErrInvalidToken
 }
 
+// JWKS 获取用于验证令牌的公钥集合
+func (s *jwtServiceImpl) JWKS() *JWKSet {
+	return s.keys.jwks()
+}
+
 // TokenResponse 令牌响应
 type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
@@ -152,14 +265,38 @@ type TokenResponse struct {
 	ExpiresIn    int64  `json:"expires_in"`
 }
 
-// GenerateTokenPair 生成令牌对
-func GenerateTokenPair(jwtService JWTService, userID uint64, username string) (*TokenResponse, error) {
-	accessToken, err := jwtService.GenerateAccessToken(userID, username)
+// GenerateTokenPair 生成令牌对（开启新的令牌族）
+func GenerateTokenPair(jwtService JWTService, userID uint64, username, tenantID string, roles ...string) (*TokenResponse, error) {
+	familyID, err := NewTokenID()
+	if err != nil {
+		return nil, fmt.Errorf("生成令牌族ID失败: %w", err)
+	}
+
+	return GenerateTokenPairInFamily(jwtService, userID, username, tenantID, familyID, roles...)
+}
+
+// GenerateTokenPairInFamily 在指定令牌族中生成令牌对（用于刷新令牌轮换）
+// 角色只写入访问令牌，刷新时重新加载，使授予的角色在下次刷新后生效；回收角色时注销用户的全部会话
+func GenerateTokenPairInFamily(jwtService JWTService, userID uint64, username, tenantID, familyID string, roles ...string) (*TokenResponse, error) {
+	accessToken, err := jwtService.GenerateTokenWithClaims(&JWTClaims{
+		UserID:   userID,
+		Username: username,
+		TenantID: tenantID,
+		Type:     TokenTypeAccess,
+		FamilyID: familyID,
+		Roles:    roles,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("生成访问令牌失败: %w", err)
 	}
 
-	refreshToken, err := jwtService.GenerateRefreshToken(userID, username)
+	refreshToken, err := jwtService.GenerateTokenWithClaims(&JWTClaims{
+		UserID:   userID,
+		Username: username,
+		TenantID: tenantID,
+		Type:     TokenTypeRefresh,
+		FamilyID: familyID,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("生成刷新令牌失败: %w", err)
 	}
@@ -171,3 +308,12 @@ func GenerateTokenPair(jwtService JWTService, userID uint64, username string) (*
 		ExpiresIn:    int64(time.Duration(config.GetInt("jwt.access_token_ttl")) * time.Hour / time.Second),
 	}, nil
 }
+
+// NewTokenID 生成随机令牌ID（用作jti等唯一标识）
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
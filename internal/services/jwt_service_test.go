@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"member-link-lite/config"
 	"testing"
 
@@ -82,7 +83,7 @@ func TestGenerateTokenPair(t *testing.T) {
 	userID := uint64(123)
 	username := "testuser"
 
-	tokenPair, err := GenerateTokenPair(jwtService, userID, username)
+	tokenPair, err := GenerateTokenPair(jwtService, userID, username, "default")
 	require.NoError(t, err)
 	assert.NotNil(t, tokenPair)
 	assert.NotEmpty(t, tokenPair.AccessToken)
@@ -99,3 +100,65 @@ func TestGenerateTokenPair(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "refresh", refreshClaims.Type)
 }
+
+func TestJWTService_RevokeTokenFamily(t *testing.T) {
+	config.Init()
+	jwtService := NewJWTService()
+	ctx := context.Background()
+
+	tokenPair, err := GenerateTokenPair(jwtService, 123, "testuser", "default")
+	require.NoError(t, err)
+
+	accessClaims, err := jwtService.ValidateToken(tokenPair.AccessToken)
+	require.NoError(t, err)
+	refreshClaims, err := jwtService.ValidateToken(tokenPair.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEmpty(t, accessClaims.ID)
+	assert.NotEqual(t, accessClaims.ID, refreshClaims.ID)
+	assert.Equal(t, accessClaims.FamilyID, refreshClaims.FamilyID)
+
+	revoked, err := jwtService.IsTokenRevoked(ctx, accessClaims)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	// 吊销访问令牌所在的令牌族，刷新令牌同时失效
+	require.NoError(t, jwtService.RevokeTokenFamily(ctx, accessClaims))
+
+	revoked, err = jwtService.IsTokenRevoked(ctx, accessClaims)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = jwtService.IsTokenRevoked(ctx, refreshClaims)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// 其他令牌族不受影响
+	otherPair, err := GenerateTokenPair(jwtService, 123, "testuser", "default")
+	require.NoError(t, err)
+	otherClaims, err := jwtService.ValidateToken(otherPair.AccessToken)
+	require.NoError(t, err)
+	revoked, err = jwtService.IsTokenRevoked(ctx, otherClaims)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestJWTService_ConsumeRefreshToken(t *testing.T) {
+	config.Init()
+	jwtService := NewJWTService()
+	ctx := context.Background()
+
+	refreshToken, err := jwtService.GenerateRefreshToken(123, "testuser")
+	require.NoError(t, err)
+	claims, err := jwtService.ValidateToken(refreshToken)
+	require.NoError(t, err)
+
+	// 首次使用成功
+	firstUse, err := jwtService.ConsumeRefreshToken(ctx, claims)
+	require.NoError(t, err)
+	assert.True(t, firstUse)
+
+	// 再次使用被识别为重复使用
+	firstUse, err = jwtService.ConsumeRefreshToken(ctx, claims)
+	require.NoError(t, err)
+	assert.False(t, firstUse)
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LedgerService 复式记账账本服务接口
+type LedgerService interface {
+	// 生成当前租户的试算平衡表，并核对会员钱包账户与会员余额
+	GetTrialBalance(ctx context.Context) (*TrialBalance, error)
+	// 分页获取分录及明细
+	ListJournalEntries(ctx context.Context, req *ListJournalEntriesRequest) (*common.PaginateResult, error)
+	// 为尚未建账的会员按当前余额写入期初分录，返回建账的会员数
+	PostOpeningBalances(ctx context.Context) (int, error)
+}
+
+// ListJournalEntriesRequest 分录查询请求
+type ListJournalEntriesRequest struct {
+	common.PageRequest
+	UserID  uint64 `json:"user_id" form:"user_id" example:"1"`                  // 会员ID
+	Type    string `json:"type" form:"type" example:"recharge"`                 // 业务类型
+	OrderNo string `json:"order_no" form:"order_no" example:"ORDER20240101001"` // 关联订单号
+}
+
+// TrialBalanceAccount 试算平衡表中的账户
+type TrialBalanceAccount struct {
+	models.LedgerAccount
+	Debit   int64 `json:"debit"`   // 借方发生额合计(分)
+	Credit  int64 `json:"credit"`  // 贷方发生额合计(分)
+	Balance int64 `json:"balance"` // 余额(分)，按账户的正常余额方向计算
+}
+
+// WalletMismatch 会员钱包账户余额与会员余额不一致的会员
+type WalletMismatch struct {
+	UserID        uint64 `json:"user_id"`        // 会员ID
+	MemberBalance int64  `json:"member_balance"` // 会员余额(分)
+	LedgerBalance int64  `json:"ledger_balance"` // 会员钱包账户余额(分)
+}
+
+// TrialBalance 试算平衡表
+type TrialBalance struct {
+	Accounts           []TrialBalanceAccount `json:"accounts"`             // 各账户发生额及余额
+	TotalDebit         int64                 `json:"total_debit"`          // 借方合计(分)
+	TotalCredit        int64                 `json:"total_credit"`         // 贷方合计(分)
+	Balanced           bool                  `json:"balanced"`             // 借贷是否平衡
+	WalletLedgerTotal  int64                 `json:"wallet_ledger_total"`  // 会员钱包账户余额合计(分)
+	MemberBalanceTotal int64                 `json:"member_balance_total"` // 会员余额合计(分)，包含已注销会员
+	WalletsMatched     bool                  `json:"wallets_matched"`      // 每个会员的钱包账户余额是否都与会员余额一致
+	MismatchCount      int64                 `json:"mismatch_count"`       // 不一致的会员数
+	Mismatches         []WalletMismatch      `json:"mismatches"`           // 不一致的会员，最多返回trialBalanceMismatchLimit条
+	GeneratedAt        time.Time             `json:"generated_at"`         // 生成时间
+}
+
+// trialBalanceMismatchLimit 试算平衡表最多返回的不一致会员数
+const trialBalanceMismatchLimit = 100
+
+// errUnbalancedJournalEntry 分录借贷不平衡
+var errUnbalancedJournalEntry = errors.New("分录借贷不平衡")
+
+// balanceCounterAccounts 余额变动类型对应的对方账户
+// 充值时平台收到资金，消费时钱包余额确认为消费收入；退款冲减应付退款；奖励及扣除计入营销费用
+var balanceCounterAccounts = map[string]string{
+	models.BalanceTypeRecharge: models.LedgerAccountPlatformCash,
+	models.BalanceTypeConsume:  models.LedgerAccountSalesRevenue,
+	models.BalanceTypeRefund:   models.LedgerAccountRefundsPayable,
+	models.BalanceTypeReward:   models.LedgerAccountPromotions,
+	models.BalanceTypeDeduct:   models.LedgerAccountPromotions,
+}
+
+// ledgerServiceImpl 复式记账账本服务实现
+type ledgerServiceImpl struct {
+	db *gorm.DB
+}
+
+// NewLedgerService 创建复式记账账本服务实例
+func NewLedgerService() LedgerService {
+	return &ledgerServiceImpl{
+		db: database.GetDB(),
+	}
+}
+
+// postWalletEntry 在事务中写入会员钱包与对方账户之间的分录
+// walletDelta为正时借记对方账户、贷记会员钱包（钱包余额增加），为负时借记会员钱包、贷记对方账户；为0时不记账
+func postWalletEntry(tx *gorm.DB, entry *models.JournalEntry, walletDelta int64, counter models.LedgerPosting) error {
+	if walletDelta == 0 {
+		return nil
+	}
+
+	wallet := models.LedgerPosting{Account: models.LedgerAccountMemberWallet, UserID: entry.UserID}
+	postings := []models.LedgerPosting{counter, wallet}
+	if walletDelta > 0 {
+		postings[0].Debit, postings[1].Credit = walletDelta, walletDelta
+	} else {
+		postings = []models.LedgerPosting{wallet, counter}
+		postings[0].Debit, postings[1].Credit = -walletDelta, -walletDelta
+	}
+	for i := range postings {
+		postings[i].TenantID = entry.TenantID
+	}
+
+	entry.Amount = postings[0].Debit
+	entry.Postings = postings
+	if !entry.IsBalanced() {
+		return errUnbalancedJournalEntry
+	}
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("写入分录失败: %w", err)
+	}
+	return nil
+}
+
+// ensureOpeningBalance 会员尚无钱包明细时，按变动前的余额写入期初分录
+// 上线账本前已有余额的会员在首次余额变动时自动建账，调用方需已锁定会员
+func ensureOpeningBalance(tx *gorm.DB, tenantID string, userID uint64, balanceBefore int64) error {
+	if balanceBefore == 0 {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Model(&models.LedgerPosting{}).
+		Where("account = ? AND user_id = ?", models.LedgerAccountMemberWallet, userID).
+		Limit(1).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查询钱包明细失败: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	entry := &models.JournalEntry{
+		Type:        models.JournalTypeOpening,
+		UserID:      userID,
+		Description: "期初余额建账",
+	}
+	entry.TenantID = tenantID
+	return postWalletEntry(tx, entry, balanceBefore, models.LedgerPosting{Account: models.LedgerAccountOpeningBalance})
+}
+
+// postBalanceRecord 在事务中为余额变动记录写入分录，会员尚未建账时先写入期初分录
+func postBalanceRecord(tx *gorm.DB, record *models.BalanceRecord, entryType, counterAccount string) error {
+	if err := ensureOpeningBalance(tx, record.TenantID, record.UserID, record.BalanceAfter-record.Amount); err != nil {
+		return err
+	}
+
+	entry := &models.JournalEntry{
+		Type:            entryType,
+		UserID:          record.UserID,
+		BalanceRecordID: record.ID,
+		OrderNo:         record.OrderNo,
+		Description:     record.Remark,
+	}
+	entry.TenantID = record.TenantID
+	return postWalletEntry(tx, entry, record.Amount, models.LedgerPosting{Account: counterAccount})
+}
+
+// GetTrialBalance 生成试算平衡表
+func (s *ledgerServiceImpl) GetTrialBalance(ctx context.Context) (*TrialBalance, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+	db := s.db.WithContext(ctx)
+
+	var totals []struct {
+		Account string
+		Debit   int64
+		Credit  int64
+	}
+	if err := db.Model(&models.LedgerPosting{}).
+		Select("account, COALESCE(SUM(debit), 0) AS debit, COALESCE(SUM(credit), 0) AS credit").
+		Scopes(models.ScopeByTenant(tenantID)).
+		Group("account").
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("汇总分录明细失败: %w", err)
+	}
+	totalsByAccount := make(map[string]int, len(totals))
+	for i, total := range totals {
+		totalsByAccount[total.Account] = i
+	}
+
+	report := &TrialBalance{GeneratedAt: time.Now()}
+	for _, account := range models.LedgerAccounts {
+		item := TrialBalanceAccount{LedgerAccount: account}
+		if i, ok := totalsByAccount[account.Code]; ok {
+			item.Debit, item.Credit = totals[i].Debit, totals[i].Credit
+		}
+		if account.IsDebitNormal() {
+			item.Balance = item.Debit - item.Credit
+		} else {
+			item.Balance = item.Credit - item.Debit
+		}
+		if account.Code == models.LedgerAccountMemberWallet {
+			report.WalletLedgerTotal = item.Balance
+		}
+		report.TotalDebit += item.Debit
+		report.TotalCredit += item.Credit
+		report.Accounts = append(report.Accounts, item)
+	}
+	report.Balanced = report.TotalDebit == report.TotalCredit
+
+	// 已注销的会员仍保留余额，核对时包含软删除的会员
+	if err := db.Unscoped().Model(&models.User{}).
+		Select("COALESCE(SUM(balance), 0)").
+		Where("tenant_id = ?", tenantID).
+		Scan(&report.MemberBalanceTotal).Error; err != nil {
+		return nil, fmt.Errorf("汇总会员余额失败: %w", err)
+	}
+
+	wallets := db.Model(&models.LedgerPosting{}).
+		Select("user_id, SUM(credit - debit) AS ledger_balance").
+		Where("tenant_id = ? AND account = ?", tenantID, models.LedgerAccountMemberWallet).
+		Group("user_id")
+	mismatches := db.Unscoped().Table("m_users AS u").
+		Joins("LEFT JOIN (?) AS w ON w.user_id = u.id", wallets).
+		Where("u.tenant_id = ? AND u.balance <> COALESCE(w.ledger_balance, 0)", tenantID)
+
+	if err := mismatches.Session(&gorm.Session{}).Count(&report.MismatchCount).Error; err != nil {
+		return nil, fmt.Errorf("核对会员钱包失败: %w", err)
+	}
+	report.Mismatches = []WalletMismatch{}
+	if report.MismatchCount > 0 {
+		if err := mismatches.Session(&gorm.Session{}).
+			Select("u.id AS user_id, u.balance AS member_balance, COALESCE(w.ledger_balance, 0) AS ledger_balance").
+			Order("u.id ASC").
+			Limit(trialBalanceMismatchLimit).
+			Scan(&report.Mismatches).Error; err != nil {
+			return nil, fmt.Errorf("核对会员钱包失败: %w", err)
+		}
+	}
+	report.WalletsMatched = report.MismatchCount == 0 && report.WalletLedgerTotal == report.MemberBalanceTotal
+
+	return report, nil
+}
+
+// ListJournalEntries 分页获取分录及明细
+func (s *ledgerServiceImpl) ListJournalEntries(ctx context.Context, req *ListJournalEntriesRequest) (*common.PaginateResult, error) {
+	if err := req.PageRequest.ValidateAndSetDefaults(); err != nil {
+		return nil, err
+	}
+
+	conditions := []func(*gorm.DB) *gorm.DB{
+		models.ScopeByTenant(database.GetTenantIDFromContext(ctx)),
+	}
+	if req.UserID > 0 {
+		conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+			return db.Where("id IN (?)", db.Session(&gorm.Session{NewDB: true}).Model(&models.LedgerPosting{}).
+				Select("entry_id").Where("user_id = ?", req.UserID))
+		})
+	}
+	if req.Type != "" {
+		conditions = append(conditions, models.ScopeByType(req.Type))
+	}
+	if req.OrderNo != "" {
+		conditions = append(conditions, models.ScopeByOrderNo(req.OrderNo))
+	}
+	conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Postings").Order("id DESC")
+	})
+
+	var entries []models.JournalEntry
+	result, err := common.PaginateQueryWithModel(s.db.WithContext(ctx), &req.PageRequest, &models.JournalEntry{}, &entries, conditions...)
+	if err != nil {
+		return nil, fmt.Errorf("查询分录失败: %w", err)
+	}
+	return result, nil
+}
+
+// PostOpeningBalances 为尚未建账的会员写入期初分录
+// 余额变动及账号合并时会自动建账，本方法用于上线账本后没有余额变动、尚无钱包明细的会员：
+// 按当前余额借记期初余额、贷记会员钱包；已建账的会员跳过，可重复执行
+func (s *ledgerServiceImpl) PostOpeningBalances(ctx context.Context) (int, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+	walletPostings := func(db *gorm.DB) *gorm.DB {
+		return db.Session(&gorm.Session{NewDB: true}).Model(&models.LedgerPosting{}).
+			Select("1").
+			Where("m_ledger_postings.user_id = m_users.id AND m_ledger_postings.account = ?", models.LedgerAccountMemberWallet)
+	}
+
+	var userIDs []uint64
+	if err := s.db.WithContext(ctx).Unscoped().Model(&models.User{}).
+		Where("tenant_id = ? AND balance <> 0", tenantID).
+		Where("NOT EXISTS (?)", walletPostings(s.db)).
+		Order("id ASC").
+		Pluck("id", &userIDs).Error; err != nil {
+		return 0, fmt.Errorf("查询未建账会员失败: %w", err)
+	}
+
+	posted := 0
+	for _, userID := range userIDs {
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// 锁定会员后重新检查，避免与并发的余额变动重复建账
+			var user models.User
+			if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("tenant_id = ? AND NOT EXISTS (?)", tenantID, walletPostings(tx)).
+				First(&user, userID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil
+				}
+				return err
+			}
+
+			entry := &models.JournalEntry{
+				Type:        models.JournalTypeOpening,
+				UserID:      user.ID,
+				Description: "期初余额建账",
+			}
+			entry.TenantID = tenantID
+			if err := postWalletEntry(tx, entry, user.Balance, models.LedgerPosting{Account: models.LedgerAccountOpeningBalance}); err != nil {
+				return err
+			}
+			if entry.ID > 0 {
+				posted++
+			}
+			return nil
+		})
+		if err != nil {
+			return posted, fmt.Errorf("期初建账失败: %w", err)
+		}
+	}
+
+	return posted, nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// trialBalanceAccount 获取试算平衡表中的账户
+func trialBalanceAccount(t *testing.T, report *TrialBalance, code string) TrialBalanceAccount {
+	for _, account := range report.Accounts {
+		if account.Code == code {
+			return account
+		}
+	}
+	t.Fatalf("试算平衡表缺少账户: %s", code)
+	return TrialBalanceAccount{}
+}
+
+func TestLedgerService_ChangeBalancePostings(t *testing.T) {
+	userService, users := setupMemberService(t, 2)
+	assets := NewAssetService(userService.db)
+	service := &ledgerServiceImpl{db: userService.db}
+	ctx := context.Background()
+
+	changes := []*ChangeBalanceRequest{
+		{UserID: users[0].ID, Amount: 10000, Type: models.BalanceTypeRecharge, OrderNo: "R001"},
+		{UserID: users[0].ID, Amount: -3000, Type: models.BalanceTypeConsume, OrderNo: "C001"},
+		{UserID: users[0].ID, Amount: 1000, Type: models.BalanceTypeRefund, OrderNo: "C001"},
+		{UserID: users[1].ID, Amount: 500, Type: models.BalanceTypeReward},
+		{UserID: users[1].ID, Amount: -200, Type: models.BalanceTypeDeduct},
+	}
+	for _, req := range changes {
+		_, err := assets.ChangeBalance(ctx, req)
+		require.NoError(t, err)
+	}
+
+	// 余额不足的变动不记账
+	_, err := assets.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: users[1].ID, Amount: -1000, Type: models.BalanceTypeConsume})
+	require.Error(t, err)
+
+	report, err := service.GetTrialBalance(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.Balanced)
+	assert.Equal(t, int64(14700), report.TotalDebit)
+	assert.Equal(t, report.TotalDebit, report.TotalCredit)
+	assert.Equal(t, int64(8300), report.WalletLedgerTotal)
+	assert.Equal(t, int64(8300), report.MemberBalanceTotal)
+	assert.True(t, report.WalletsMatched)
+	assert.Empty(t, report.Mismatches)
+
+	// 充值计入平台资金，消费确认为消费收入
+	assert.Equal(t, int64(10000), trialBalanceAccount(t, report, models.LedgerAccountPlatformCash).Balance)
+	assert.Equal(t, int64(3000), trialBalanceAccount(t, report, models.LedgerAccountSalesRevenue).Balance)
+	assert.Equal(t, int64(300), trialBalanceAccount(t, report, models.LedgerAccountPromotions).Balance)
+	assert.Equal(t, int64(-1000), trialBalanceAccount(t, report, models.LedgerAccountRefundsPayable).Balance)
+
+	// 分录与余额变动记录一一对应，每条分录借贷平衡
+	result, err := service.ListJournalEntries(ctx, &ListJournalEntriesRequest{PageRequest: *common.NewPageRequest(1, 10), UserID: users[0].ID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+	entries := *result.List.(*[]models.JournalEntry)
+	for _, entry := range entries {
+		assert.True(t, entry.IsBalanced())
+		assert.NotZero(t, entry.BalanceRecordID)
+	}
+	assert.Equal(t, models.BalanceTypeRefund, entries[0].Type)
+	assert.Equal(t, models.LedgerAccountRefundsPayable, entries[0].Postings[0].Account)
+	assert.Equal(t, int64(1000), entries[0].Postings[0].Debit)
+	assert.Equal(t, users[0].ID, entries[0].Postings[1].UserID)
+	assert.Equal(t, int64(1000), entries[0].Postings[1].Credit)
+
+	result, err = service.ListJournalEntries(ctx, &ListJournalEntriesRequest{PageRequest: *common.NewPageRequest(1, 10), OrderNo: "C001"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Total)
+
+	// 其他租户的账本为空
+	tenantReport, err := service.GetTrialBalance(context.WithValue(ctx, "tenant_id", "company1"))
+	require.NoError(t, err)
+	assert.Zero(t, tenantReport.TotalDebit)
+	assert.True(t, tenantReport.WalletsMatched)
+}
+
+func TestLedgerService_OpeningBalancesAndMerge(t *testing.T) {
+	userService, users := setupMemberService(t, 3)
+	assets := NewAssetService(userService.db)
+	service := &ledgerServiceImpl{db: userService.db}
+	ctx := context.Background()
+
+	// 上线账本前已有余额的会员
+	require.NoError(t, userService.db.Model(users[0]).Update("balance", 5000).Error)
+	require.NoError(t, userService.db.Model(users[1]).Update("balance", 2000).Error)
+
+	report, err := service.GetTrialBalance(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.Balanced)
+	assert.False(t, report.WalletsMatched)
+	assert.Equal(t, int64(2), report.MismatchCount)
+	assert.Equal(t, WalletMismatch{UserID: users[0].ID, MemberBalance: 5000, LedgerBalance: 0}, report.Mismatches[0])
+
+	// 首次余额变动时按变动前余额自动建账
+	_, err = assets.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: users[0].ID, Amount: -1000, Type: models.BalanceTypeConsume})
+	require.NoError(t, err)
+	_, err = assets.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: users[0].ID, Amount: 1000, Type: models.BalanceTypeRecharge})
+	require.NoError(t, err)
+	result, err := service.ListJournalEntries(ctx, &ListJournalEntriesRequest{PageRequest: *common.NewPageRequest(1, 10), UserID: users[0].ID, Type: models.JournalTypeOpening})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	assert.Equal(t, int64(5000), (*result.List.(*[]models.JournalEntry))[0].Amount)
+
+	// 没有余额变动的会员需补记期初分录
+	require.NoError(t, userService.db.Model(users[2]).Update("balance", 800).Error)
+	report, err = service.GetTrialBalance(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), report.MismatchCount)
+
+	posted, err := service.PostOpeningBalances(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, posted)
+
+	// 重复执行不会重复建账
+	posted, err = service.PostOpeningBalances(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, posted)
+
+	report, err = service.GetTrialBalance(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.WalletsMatched)
+	// 存量余额没有对应的平台资金，期初余额账户为借方余额
+	assert.Equal(t, int64(-7800), trialBalanceAccount(t, report, models.LedgerAccountOpeningBalance).Balance)
+
+	// 合并账号时余额在会员钱包之间转移
+	merge := &accountMergeServiceImpl{db: userService.db, jwtService: NewJWTService()}
+	_, err = merge.Merge(ctx, &MergeAccountsRequest{PrimaryUserID: users[0].ID, SecondaryUserID: users[1].ID}, models.AccountMergeReasonManual, 0)
+	require.NoError(t, err)
+
+	report, err = service.GetTrialBalance(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.Balanced)
+	assert.True(t, report.WalletsMatched)
+	assert.Equal(t, int64(7800), report.WalletLedgerTotal)
+
+	result, err = service.ListJournalEntries(ctx, &ListJournalEntriesRequest{PageRequest: *common.NewPageRequest(1, 10), Type: models.JournalTypeMerge})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	entry := (*result.List.(*[]models.JournalEntry))[0]
+	assert.Equal(t, int64(2000), entry.Amount)
+	assert.Equal(t, users[1].ID, entry.Postings[0].UserID)
+	assert.Equal(t, users[0].ID, entry.Postings[1].UserID)
+}
+
+func TestJournalEntry_IsBalanced(t *testing.T) {
+	wallet := func(debit, credit int64) models.LedgerPosting {
+		return models.LedgerPosting{Account: models.LedgerAccountMemberWallet, Debit: debit, Credit: credit}
+	}
+
+	assert.True(t, (&models.JournalEntry{Postings: []models.LedgerPosting{wallet(100, 0), wallet(0, 60), wallet(0, 40)}}).IsBalanced())
+	assert.False(t, (&models.JournalEntry{Postings: []models.LedgerPosting{wallet(100, 0), wallet(0, 50)}}).IsBalanced())
+	assert.False(t, (&models.JournalEntry{Postings: []models.LedgerPosting{wallet(100, 100), wallet(0, 0)}}).IsBalanced())
+	assert.False(t, (&models.JournalEntry{Postings: []models.LedgerPosting{wallet(-100, 0), wallet(0, -100)}}).IsBalanced())
+	assert.False(t, (&models.JournalEntry{Postings: []models.LedgerPosting{wallet(100, 0)}}).IsBalanced())
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"member-link-lite/config"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 登录失败计数缓存键前缀
+const (
+	loginFailUserKeyPrefix    = "login:fail:user:"
+	loginFailAccountKeyPrefix = "login:fail:account:"
+	loginFailIPKeyPrefix      = "login:fail:ip:"
+	loginLockAccountKeyPrefix = "login:lock:account:"
+)
+
+// LoginSecurityConfig 登录安全配置
+type LoginSecurityConfig struct {
+	MaxAccountFailures int64         // 单账号连续失败次数上限，达到后临时锁定
+	MaxIPFailures      int64         // 单IP失败次数上限，达到后拒绝登录
+	FailureWindow      time.Duration // 失败计数窗口
+	LockDuration       time.Duration // 临时锁定时长
+	DelayAfter         int64         // 失败多少次后开始延迟
+	DelayStep          time.Duration // 每次递增的延迟
+	MaxDelay           time.Duration // 最大延迟
+}
+
+// LoadLoginSecurityConfig 从配置文件加载登录安全配置
+func LoadLoginSecurityConfig() *LoginSecurityConfig {
+	return &LoginSecurityConfig{
+		MaxAccountFailures: int64(config.GetInt("security.login.max_account_failures")),
+		MaxIPFailures:      int64(config.GetInt("security.login.max_ip_failures")),
+		FailureWindow:      time.Duration(config.GetInt("security.login.failure_window")) * time.Minute,
+		LockDuration:       time.Duration(config.GetInt("security.login.lock_duration")) * time.Minute,
+		DelayAfter:         int64(config.GetInt("security.login.delay_after")),
+		DelayStep:          time.Duration(config.GetInt("security.login.delay_step")) * time.Millisecond,
+		MaxDelay:           time.Duration(config.GetInt("security.login.max_delay")) * time.Millisecond,
+	}
+}
+
+// loginProtector 登录防暴力破解
+// 按账号和IP分别统计失败次数，失败次数增加时递增延迟响应
+// 账号失败次数按用户ID统计，用户名、手机号和邮箱登录共用同一失败额度
+type loginProtector struct {
+	cache cache.Cache
+	cfg   *LoginSecurityConfig
+}
+
+// newLoginProtector 创建登录防护
+func newLoginProtector(c cache.Cache, cfg *LoginSecurityConfig) *loginProtector {
+	return &loginProtector{cache: c, cfg: cfg}
+}
+
+// CheckIP 检查IP是否因失败次数过多被限制
+func (p *loginProtector) CheckIP(ctx context.Context, ip string) error {
+	if ip == "" || p.cfg.MaxIPFailures <= 0 {
+		return nil
+	}
+
+	failures, err := p.failures(ctx, loginFailIPKeyPrefix+ip)
+	if err != nil {
+		return err
+	}
+	if failures >= p.cfg.MaxIPFailures {
+		return common.ErrTooManyAttempts
+	}
+
+	return nil
+}
+
+// Delay 根据账号已失败次数延迟响应
+func (p *loginProtector) Delay(ctx context.Context, key string) error {
+	if p.cfg.DelayStep <= 0 {
+		return nil
+	}
+
+	failures, err := p.failures(ctx, key)
+	if err != nil {
+		return err
+	}
+	if failures < p.cfg.DelayAfter {
+		return nil
+	}
+
+	delay := time.Duration(failures-p.cfg.DelayAfter+1) * p.cfg.DelayStep
+	if p.cfg.MaxDelay > 0 && delay > p.cfg.MaxDelay {
+		delay = p.cfg.MaxDelay
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RecordFailure 记录一次登录失败，返回是否达到账号锁定阈值
+func (p *loginProtector) RecordFailure(ctx context.Context, key, ip string) (bool, error) {
+	if ip != "" {
+		if _, err := p.cache.Incr(ctx, loginFailIPKeyPrefix+ip, p.cfg.FailureWindow); err != nil {
+			return false, fmt.Errorf("记录登录失败次数失败: %w", err)
+		}
+	}
+
+	failures, err := p.cache.Incr(ctx, key, p.cfg.FailureWindow)
+	if err != nil {
+		return false, fmt.Errorf("记录登录失败次数失败: %w", err)
+	}
+
+	return p.cfg.MaxAccountFailures > 0 && failures >= p.cfg.MaxAccountFailures, nil
+}
+
+// LockAccount 临时锁定不存在的账号，锁定时长与已存在的用户一致
+func (p *loginProtector) LockAccount(ctx context.Context, tenantID, account string) error {
+	if err := p.cache.Set(ctx, p.accountLockKey(tenantID, account), "1", p.cfg.LockDuration); err != nil {
+		return fmt.Errorf("记录账号锁定失败: %w", err)
+	}
+	return nil
+}
+
+// AccountLocked 检查不存在的账号是否处于锁定期
+// 锁定到期后与已存在的用户一样清除失败计数
+func (p *loginProtector) AccountLocked(ctx context.Context, tenantID, account string) (bool, error) {
+	locked, err := p.cache.Exists(ctx, p.accountLockKey(tenantID, account))
+	if err != nil {
+		return false, fmt.Errorf("读取账号锁定状态失败: %w", err)
+	}
+	if locked {
+		return true, nil
+	}
+
+	key := p.accountKey(tenantID, account)
+	failures, err := p.failures(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if p.cfg.MaxAccountFailures > 0 && failures >= p.cfg.MaxAccountFailures {
+		return false, p.Reset(ctx, key)
+	}
+	return false, nil
+}
+
+// Reset 清除账号的失败计数
+func (p *loginProtector) Reset(ctx context.Context, key string) error {
+	return p.cache.Delete(ctx, key)
+}
+
+// failures 获取失败次数
+func (p *loginProtector) failures(ctx context.Context, key string) (int64, error) {
+	value, err := p.cache.Get(ctx, key)
+	if err == cache.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("读取登录失败次数失败: %w", err)
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+	return n, nil
+}
+
+// userKey 生成用户失败计数键
+func (p *loginProtector) userKey(userID uint64) string {
+	return loginFailUserKeyPrefix + strconv.FormatUint(userID, 10)
+}
+
+// accountKey 生成不存在账号的失败计数键（账号不区分大小写）
+// 仅用于账号不存在时的延迟响应和锁定，已存在的用户按userKey统计
+func (p *loginProtector) accountKey(tenantID, account string) string {
+	return loginFailAccountKeyPrefix + tenantID + ":" + strings.ToLower(account)
+}
+
+// accountLockKey 生成不存在账号的锁定键
+func (p *loginProtector) accountLockKey(tenantID, account string) string {
+	return loginLockAccountKeyPrefix + tenantID + ":" + strings.ToLower(account)
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/utils"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 会员导入导出文件格式
+const (
+	MemberFileFormatCSV  = "csv"
+	MemberFileFormatXLSX = "xlsx"
+)
+
+const memberExportBatchSize = 500 // 导出会员时每批查询的数量
+
+// errUnsupportedMemberFileFormat 不支持的导入导出文件格式
+var errUnsupportedMemberFileFormat = common.NewCustomError(common.CodeBadRequest, "不支持的文件格式，仅支持csv、xlsx")
+
+// memberExportHeader 会员导出表头，导出的文件可直接作为导入文件使用（ID、注册时间、最后登录时间导入时忽略）
+var memberExportHeader = []string{"ID", "用户名", "手机号", "邮箱", "昵称", "状态", "余额(元)", "积分", "注册时间", "最后登录时间"}
+
+// memberExportRow 会员导出行
+func memberExportRow(user *models.User) []string {
+	lastTime := ""
+	if user.LastTime != nil {
+		lastTime = user.LastTime.Format("2006-01-02 15:04:05")
+	}
+	return []string{
+		strconv.FormatUint(user.ID, 10),
+		spreadsheetSafe(user.Username),
+		spreadsheetSafe(user.Phone),
+		spreadsheetSafe(user.Email),
+		spreadsheetSafe(user.Nickname),
+		strconv.Itoa(int(user.Status)),
+		fmt.Sprintf("%.2f", user.GetBalanceFloat()),
+		strconv.FormatInt(user.Points, 10),
+		user.CreatedAt.Format("2006-01-02 15:04:05"),
+		lastTime,
+	}
+}
+
+// spreadsheetFormulaPrefixes 电子表格软件会当作公式处理的起始字符
+const spreadsheetFormulaPrefixes = "=+-@\t\r"
+
+// spreadsheetSafe 以公式字符开头的文本前加单引号，防止会员填写的内容在电子表格中作为公式执行
+func spreadsheetSafe(value string) string {
+	if value != "" && strings.ContainsRune(spreadsheetFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// spreadsheetUnescape 去除spreadsheetSafe添加的单引号，使导出的文件可原样导入
+func spreadsheetUnescape(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(spreadsheetFormulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// MemberFileContentType 获取导出文件的Content-Type
+func MemberFileContentType(format string) string {
+	if format == MemberFileFormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// memberTableWriter 表格写入器
+type memberTableWriter interface {
+	WriteRow(values []string) error
+	Close() error
+}
+
+// csvTableWriter CSV表格写入器
+type csvTableWriter struct {
+	writer *csv.Writer
+}
+
+// WriteRow 写入一行
+func (c *csvTableWriter) WriteRow(values []string) error {
+	return c.writer.Write(values)
+}
+
+// Close 刷新缓冲区
+func (c *csvTableWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// newMemberTableWriter 按格式创建表格写入器
+func newMemberTableWriter(w io.Writer, format string) (memberTableWriter, error) {
+	switch format {
+	case MemberFileFormatCSV:
+		// 写入UTF-8 BOM，便于Excel正确识别中文
+		if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+			return nil, err
+		}
+		return &csvTableWriter{writer: csv.NewWriter(w)}, nil
+	case MemberFileFormatXLSX:
+		writer, err := utils.NewXLSXWriter(w, "会员")
+		if err != nil {
+			return nil, err
+		}
+		return writer, nil
+	default:
+		return nil, errUnsupportedMemberFileFormat
+	}
+}
+
+// ValidateMemberFileFormat 验证导入导出文件格式
+func ValidateMemberFileFormat(format string) error {
+	if format != MemberFileFormatCSV && format != MemberFileFormatXLSX {
+		return errUnsupportedMemberFileFormat
+	}
+	return nil
+}
+
+// writeMembers 分批查询会员并写入表格，避免一次性加载到内存
+func writeMembers(query *gorm.DB, w io.Writer, format string) error {
+	writer, err := newMemberTableWriter(w, format)
+	if err != nil {
+		return err
+	}
+	if err := writer.WriteRow(memberExportHeader); err != nil {
+		return err
+	}
+
+	var users []models.User
+	result := query.Order("m_users.id ASC").FindInBatches(&users, memberExportBatchSize, func(tx *gorm.DB, batch int) error {
+		for i := range users {
+			if err := writer.WriteRow(memberExportRow(&users[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return fmt.Errorf("导出会员失败: %w", result.Error)
+	}
+
+	return writer.Close()
+}
+
+// ExportMembers 按会员列表的筛选条件导出会员
+func (s *userServiceImpl) ExportMembers(ctx context.Context, req *ListMembersRequest, format string, w io.Writer) error {
+	if err := ValidateMemberFileFormat(format); err != nil {
+		return err
+	}
+
+	conditions, err := s.memberListConditions(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	return writeMembers(s.db.WithContext(ctx).Model(&models.User{}).Scopes(conditions...), w, format)
+}
@@ -0,0 +1,678 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"member-link-lite/config"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+	"member-link-lite/pkg/storage"
+	"member-link-lite/pkg/utils"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// MemberImportService 会员批量导入服务接口
+type MemberImportService interface {
+	// 上传导入文件并创建导入任务，导入在后台异步执行
+	CreateImport(ctx context.Context, filename string, file io.Reader, dryRun bool, operatorID uint64) (*models.MemberImport, error)
+	// 获取导入任务
+	GetImport(ctx context.Context, importID uint64) (*models.MemberImport, error)
+	// 分页获取导入任务
+	ListImports(ctx context.Context, req *common.PageRequest) (*common.PaginateResult, error)
+	// 打开导入错误报告，调用方负责关闭
+	OpenImportReport(ctx context.Context, importID uint64) (io.ReadCloser, *models.MemberImport, error)
+}
+
+// memberImportServiceImpl 会员批量导入服务实现
+type memberImportServiceImpl struct {
+	db          *gorm.DB
+	storage     storage.StorageAdapter
+	maxFileSize int64
+	maxRows     int
+	batchSize   int
+	runAsync    func(func()) // 执行异步任务，测试时可同步执行
+}
+
+// NewMemberImportService 创建会员批量导入服务实例
+func NewMemberImportService() MemberImportService {
+	return &memberImportServiceImpl{
+		db:          database.GetDB(),
+		storage:     storage.GetCurrentAdapter(),
+		maxFileSize: int64(config.GetInt("member_import.max_file_size")) * 1024 * 1024,
+		maxRows:     config.GetInt("member_import.max_rows"),
+		batchSize:   config.GetInt("member_import.batch_size"),
+		runAsync:    func(task func()) { go task() },
+	}
+}
+
+// memberImportColumns 导入文件表头与字段的对应关系，支持英文字段名及导出文件的中文表头
+var memberImportColumns = map[string]string{
+	"username": "username", "用户名": "username",
+	"phone": "phone", "手机号": "phone",
+	"email": "email", "邮箱": "email",
+	"nickname": "nickname", "昵称": "nickname",
+	"password": "password", "密码": "password",
+	"status": "status", "状态": "status",
+	"balance": "balance", "余额(元)": "balance", "余额": "balance",
+	"points": "points", "积分": "points",
+}
+
+// memberImportRequiredColumns 导入文件必须包含的列
+var memberImportRequiredColumns = []string{"username", "phone", "email"}
+
+// memberImportRow 校验通过的导入行
+type memberImportRow struct {
+	line    int
+	user    *models.User
+	balance int64
+	points  int64
+}
+
+// memberImportError 导入行错误
+type memberImportError struct {
+	line     int
+	username string
+	message  string
+}
+
+// CreateImport 上传导入文件并创建导入任务
+// dryRun为true时仅校验数据并生成错误报告，不写入会员
+func (s *memberImportServiceImpl) CreateImport(ctx context.Context, filename string, file io.Reader, dryRun bool, operatorID uint64) (*models.MemberImport, error) {
+	if s.storage == nil {
+		return nil, errors.New("存储未初始化")
+	}
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	if err := ValidateMemberFileFormat(format); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, s.maxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取导入文件失败: %w", err)
+	}
+	if int64(len(data)) > s.maxFileSize {
+		return nil, common.ErrFileTooBig
+	}
+	if len(data) == 0 {
+		return nil, common.NewCustomError(common.CodeBadRequest, "导入文件为空")
+	}
+
+	tenantID := database.GetTenantIDFromContext(ctx)
+	name, err := NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("imports/%s/%s.%s", tenantID, name, format)
+	if err := s.storage.Upload(ctx, path, bytes.NewReader(data), int64(len(data)), MemberFileContentType(format)); err != nil {
+		return nil, fmt.Errorf("上传导入文件失败: %w", err)
+	}
+
+	task := &models.MemberImport{
+		Filename:   filepath.Base(filename),
+		Format:     format,
+		DryRun:     dryRun,
+		State:      models.MemberImportStatePending,
+		SourcePath: path,
+		CreatedBy:  operatorID,
+	}
+	task.TenantID = tenantID
+	if err := s.db.WithContext(ctx).Create(task).Error; err != nil {
+		return nil, fmt.Errorf("创建导入任务失败: %w", err)
+	}
+
+	// 异步任务使用副本，避免与响应序列化并发读写
+	job := *task
+	s.runAsync(func() {
+		s.processImport(context.WithValue(context.Background(), "tenant_id", tenantID), &job)
+	})
+
+	return task, nil
+}
+
+// GetImport 获取导入任务
+func (s *memberImportServiceImpl) GetImport(ctx context.Context, importID uint64) (*models.MemberImport, error) {
+	var task models.MemberImport
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(database.GetTenantIDFromContext(ctx))).
+		First(&task, importID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.ErrImportNotFound
+		}
+		return nil, fmt.Errorf("查询导入任务失败: %w", err)
+	}
+	return &task, nil
+}
+
+// ListImports 分页获取导入任务
+func (s *memberImportServiceImpl) ListImports(ctx context.Context, req *common.PageRequest) (*common.PaginateResult, error) {
+	if err := req.ValidateAndSetDefaults(); err != nil {
+		return nil, err
+	}
+
+	var tasks []models.MemberImport
+	return common.PaginateQueryWithModel(
+		s.db.WithContext(ctx),
+		req,
+		&models.MemberImport{},
+		&tasks,
+		models.ScopeByTenant(database.GetTenantIDFromContext(ctx)),
+		func(db *gorm.DB) *gorm.DB { return db.Order("id DESC") },
+	)
+}
+
+// OpenImportReport 打开导入错误报告
+func (s *memberImportServiceImpl) OpenImportReport(ctx context.Context, importID uint64) (io.ReadCloser, *models.MemberImport, error) {
+	task, err := s.GetImport(ctx, importID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !task.HasReport() {
+		return nil, nil, common.ErrImportReportNotFound
+	}
+
+	reader, err := s.storage.Download(ctx, task.ReportPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取错误报告失败: %w", err)
+	}
+	return reader, task, nil
+}
+
+// processImport 执行导入任务
+func (s *memberImportServiceImpl) processImport(ctx context.Context, task *models.MemberImport) {
+	if err := s.db.WithContext(ctx).Model(task).Update("state", models.MemberImportStateProcessing).Error; err != nil {
+		logger.WithField("import_id", task.ID).Error("更新导入任务失败:", err)
+		return
+	}
+
+	updates := map[string]interface{}{"completed_at": time.Now()}
+	total, success, failures, err := s.runImport(ctx, task)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"import_id": task.ID,
+			"error":     err.Error(),
+		}).Error("会员导入失败")
+		updates["state"] = models.MemberImportStateFailed
+		updates["error"] = "导入失败"
+		var customErr *common.CustomError
+		if errors.As(err, &customErr) {
+			updates["error"] = customErr.Message
+		}
+	} else {
+		updates["state"] = models.MemberImportStateCompleted
+		updates["total_rows"] = total
+		updates["success_rows"] = success
+		updates["failed_rows"] = len(failures)
+		if len(failures) > 0 {
+			reportPath, err := s.writeReport(ctx, task, failures)
+			if err != nil {
+				logger.WithFields(logrus.Fields{
+					"import_id": task.ID,
+					"error":     err.Error(),
+				}).Error("生成导入错误报告失败")
+			}
+			updates["report_path"] = reportPath
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Model(task).Updates(updates).Error; err != nil {
+		logger.WithField("import_id", task.ID).Error("更新导入任务失败:", err)
+	}
+}
+
+// runImport 解析并校验导入文件，非试导入时分批写入会员，返回数据行数、成功行数及失败行
+func (s *memberImportServiceImpl) runImport(ctx context.Context, task *models.MemberImport) (int, int, []memberImportError, error) {
+	reader, err := s.storage.Download(ctx, task.SourcePath)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("读取导入文件失败: %w", err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("读取导入文件失败: %w", err)
+	}
+
+	records, err := parseMemberFile(data, task.Format)
+	if err != nil {
+		return 0, 0, nil, common.NewCustomError(common.CodeBadRequest, err.Error())
+	}
+	if len(records) == 0 {
+		return 0, 0, nil, common.NewCustomError(common.CodeBadRequest, "导入文件为空")
+	}
+
+	columns, err := memberImportHeader(records[0])
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	rows, failures := s.validateRows(task, columns, records[1:])
+	total := len(rows) + len(failures)
+	if s.maxRows > 0 && total > s.maxRows {
+		return 0, 0, nil, common.NewCustomError(common.CodeBadRequest, fmt.Sprintf("数据行数超过上限%d", s.maxRows))
+	}
+
+	rows, duplicates, err := s.checkExisting(ctx, rows)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	failures = append(failures, duplicates...)
+
+	if task.DryRun {
+		sortImportErrors(failures)
+		return total, len(rows), failures, nil
+	}
+
+	success := 0
+	batchSize := s.batchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		inserted, batchFailures := s.insertRows(ctx, task, rows[start:end])
+		success += inserted
+		failures = append(failures, batchFailures...)
+	}
+
+	sortImportErrors(failures)
+	return total, success, failures, nil
+}
+
+// parseMemberFile 解析CSV或XLSX文件
+func parseMemberFile(data []byte, format string) ([][]string, error) {
+	if format == MemberFileFormatXLSX {
+		return utils.ReadXLSX(bytes.NewReader(data), int64(len(data)))
+	}
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("无法解析CSV文件: %w", err)
+	}
+	return records, nil
+}
+
+// memberImportHeader 解析表头，返回字段对应的列序号
+func memberImportHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		key, ok := memberImportColumns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			continue
+		}
+		if _, exists := columns[key]; !exists {
+			columns[key] = i
+		}
+	}
+
+	var missing []string
+	for _, key := range memberImportRequiredColumns {
+		if _, ok := columns[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, common.NewCustomError(common.CodeBadRequest, "导入文件缺少必填列: "+strings.Join(missing, ", "))
+	}
+	return columns, nil
+}
+
+// validateRows 校验数据行，文件内重复的用户名、手机号、邮箱仅保留第一行
+func (s *memberImportServiceImpl) validateRows(task *models.MemberImport, columns map[string]int, records [][]string) ([]*memberImportRow, []memberImportError) {
+	policy := LoadPasswordPolicy(task.TenantID)
+	now := time.Now()
+
+	seen := map[string]map[string]int{"username": {}, "phone": {}, "email": {}}
+	rows := make([]*memberImportRow, 0, len(records))
+	var failures []memberImportError
+
+	for i, record := range records {
+		line := i + 2 // 第1行为表头
+		value := func(key string) string {
+			idx, ok := columns[key]
+			if !ok || idx >= len(record) {
+				return ""
+			}
+			return spreadsheetUnescape(strings.TrimSpace(record[idx]))
+		}
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		row, err := parseImportRow(value, policy, now)
+		if err == nil {
+			for _, key := range []string{"username", "phone", "email"} {
+				v := strings.ToLower(value(key))
+				if first, ok := seen[key][v]; ok {
+					err = fmt.Errorf("与第%d行的%s重复", first, importColumnName(key))
+					break
+				}
+			}
+		}
+		if err != nil {
+			failures = append(failures, memberImportError{line: line, username: value("username"), message: importErrorMessage(err)})
+			continue
+		}
+
+		for _, key := range []string{"username", "phone", "email"} {
+			seen[key][strings.ToLower(value(key))] = line
+		}
+		row.line = line
+		row.user.TenantID = task.TenantID
+		rows = append(rows, row)
+	}
+
+	return rows, failures
+}
+
+// parseImportRow 解析并校验单行数据
+func parseImportRow(value func(string) string, policy *PasswordPolicy, now time.Time) (*memberImportRow, error) {
+	user := &models.User{
+		Username: value("username"),
+		Phone:    value("phone"),
+		Email:    value("email"),
+		Nickname: value("nickname"),
+	}
+
+	if err := validateUsername(user.Username); err != nil {
+		return nil, err
+	}
+	if err := validatePhone(user.Phone); err != nil {
+		return nil, err
+	}
+	if err := validateEmail(user.Email); err != nil {
+		return nil, err
+	}
+	if user.Nickname == "" {
+		user.Nickname = user.Username
+	}
+	if utf8.RuneCountInString(user.Nickname) > 50 {
+		return nil, errors.New("昵称不能超过50个字符")
+	}
+
+	// 未提供密码的会员需通过找回密码或验证码登录；旧系统的密码哈希直接保存，登录后自动按当前配置重新加密
+	switch password := value("password"); {
+	case password == "":
+		user.Password = utils.UnusablePassword
+	case utils.IsPasswordHash(password):
+		user.Password = password
+		user.PasswordChangedAt = &now
+	default:
+		if err := policy.Validate(password); err != nil {
+			return nil, err
+		}
+		if err := user.HashPassword(password); err != nil {
+			return nil, fmt.Errorf("密码加密失败: %w", err)
+		}
+		user.PasswordChangedAt = &now
+	}
+
+	user.Status = models.UserStatusActive
+	if status := value("status"); status != "" {
+		s, err := strconv.ParseInt(status, 10, 8)
+		if err != nil || !isValidUserStatus(int8(s)) {
+			return nil, errors.New("状态无效，应为0-3")
+		}
+		user.Status = int8(s)
+	}
+
+	row := &memberImportRow{user: user}
+	if balance := value("balance"); balance != "" {
+		amount, err := parseYuan(balance)
+		if err != nil {
+			return nil, err
+		}
+		row.balance = amount
+	}
+	if points := value("points"); points != "" {
+		quantity, err := strconv.ParseInt(points, 10, 64)
+		if err != nil || quantity < 0 {
+			return nil, errors.New("积分必须为非负整数")
+		}
+		row.points = quantity
+	}
+	user.Balance = row.balance
+	user.Points = row.points
+
+	return row, nil
+}
+
+// parseYuan 将元为单位的金额转换为分，最多两位小数
+func parseYuan(value string) (int64, error) {
+	invalid := errors.New("余额必须为非负数且最多两位小数")
+
+	yuan, fen, hasFen := strings.Cut(value, ".")
+	if yuan == "" || (hasFen && (fen == "" || len(fen) > 2)) {
+		return 0, invalid
+	}
+	for len(fen) < 2 {
+		fen += "0"
+	}
+	amount, err := strconv.ParseInt(yuan+fen, 10, 64)
+	if err != nil || amount < 0 || strings.HasPrefix(yuan, "-") || strings.HasPrefix(yuan, "+") {
+		return 0, invalid
+	}
+	return amount, nil
+}
+
+// checkExisting 检查用户名、手机号、邮箱是否已被使用，返回可写入的行及重复的行
+// 唯一索引同样约束软删除及其他租户的会员，因此检查时不限定租户且包含已删除的会员
+func (s *memberImportServiceImpl) checkExisting(ctx context.Context, rows []*memberImportRow) ([]*memberImportRow, []memberImportError, error) {
+	existing := map[string]map[string]bool{"username": {}, "phone": {}, "email": {}}
+
+	const chunkSize = 500
+	for start := 0; start < len(rows); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		values := map[string][]string{}
+		for _, row := range rows[start:end] {
+			values["username"] = append(values["username"], row.user.Username)
+			values["phone"] = append(values["phone"], row.user.Phone)
+			values["email"] = append(values["email"], row.user.Email)
+		}
+
+		for column, list := range values {
+			var found []string
+			if err := s.db.WithContext(ctx).Unscoped().Model(&models.User{}).
+				Where(column+" IN ?", list).
+				Pluck(column, &found).Error; err != nil {
+				return nil, nil, fmt.Errorf("检查会员是否存在失败: %w", err)
+			}
+			for _, v := range found {
+				existing[column][strings.ToLower(v)] = true
+			}
+		}
+	}
+
+	valid := make([]*memberImportRow, 0, len(rows))
+	var failures []memberImportError
+	for _, row := range rows {
+		fields := map[string]string{"username": row.user.Username, "phone": row.user.Phone, "email": row.user.Email}
+		var conflict string
+		for _, key := range []string{"username", "phone", "email"} {
+			if existing[key][strings.ToLower(fields[key])] {
+				conflict = importColumnName(key) + "已存在"
+				break
+			}
+		}
+		if conflict != "" {
+			failures = append(failures, memberImportError{line: row.line, username: row.user.Username, message: conflict})
+			continue
+		}
+		valid = append(valid, row)
+	}
+
+	return valid, failures, nil
+}
+
+// insertRows 在一个事务中写入一批会员及期初余额、积分记录
+// 整批失败时逐行重试，定位失败的行
+func (s *memberImportServiceImpl) insertRows(ctx context.Context, task *models.MemberImport, rows []*memberImportRow) (int, []memberImportError) {
+	if err := s.insertBatch(ctx, task, rows); err == nil {
+		return len(rows), nil
+	} else if len(rows) == 1 {
+		logger.WithFields(logrus.Fields{
+			"import_id": task.ID,
+			"line":      rows[0].line,
+			"error":     err.Error(),
+		}).Warn("导入会员失败")
+		return 0, []memberImportError{{line: rows[0].line, username: rows[0].user.Username, message: "写入失败，用户名、手机号或邮箱可能已被使用"}}
+	}
+
+	success := 0
+	var failures []memberImportError
+	for _, row := range rows {
+		inserted, rowFailures := s.insertRows(ctx, task, []*memberImportRow{row})
+		success += inserted
+		failures = append(failures, rowFailures...)
+	}
+	return success, failures
+}
+
+// insertBatch 写入一批会员，余额、积分不为0时写入期初记录
+func (s *memberImportServiceImpl) insertBatch(ctx context.Context, task *models.MemberImport, rows []*memberImportRow) error {
+	orderNo := fmt.Sprintf("IMPORT%d", task.ID)
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		users := make([]*models.User, 0, len(rows))
+		for _, row := range rows {
+			// BeforeCreate会将状态0（待审核）设置为正常，创建后再更新待审核会员的状态
+			user := *row.user
+			users = append(users, &user)
+		}
+		if err := tx.Create(&users).Error; err != nil {
+			return err
+		}
+
+		var pendingIDs []uint64
+		var balanceRecords []*models.BalanceRecord
+		var pointsRecords []*models.PointsRecord
+		for i, row := range rows {
+			user := users[i]
+			if row.user.Status == models.UserStatusPending {
+				pendingIDs = append(pendingIDs, user.ID)
+			}
+			if row.balance > 0 {
+				record := &models.BalanceRecord{
+					UserID:       user.ID,
+					Amount:       row.balance,
+					Type:         models.BalanceTypeRecharge,
+					Remark:       "期初余额导入",
+					BalanceAfter: row.balance,
+					OrderNo:      orderNo,
+				}
+				record.TenantID = task.TenantID
+				balanceRecords = append(balanceRecords, record)
+			}
+			if row.points > 0 {
+				record := &models.PointsRecord{
+					UserID:      user.ID,
+					Quantity:    row.points,
+					Type:        models.PointsTypeObtain,
+					Remark:      "期初积分导入",
+					PointsAfter: row.points,
+					OrderNo:     orderNo,
+				}
+				record.TenantID = task.TenantID
+				pointsRecords = append(pointsRecords, record)
+			}
+		}
+
+		if len(pendingIDs) > 0 {
+			if err := tx.Model(&models.User{}).Where("id IN ?", pendingIDs).Update("status", models.UserStatusPending).Error; err != nil {
+				return err
+			}
+		}
+		if len(balanceRecords) > 0 {
+			if err := tx.Create(&balanceRecords).Error; err != nil {
+				return err
+			}
+			// 期初余额借记期初余额账户、贷记会员钱包
+			for _, record := range balanceRecords {
+				if err := postBalanceRecord(tx, record, models.JournalTypeOpening, models.LedgerAccountOpeningBalance); err != nil {
+					return err
+				}
+			}
+		}
+		if len(pointsRecords) > 0 {
+			if err := tx.Create(&pointsRecords).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeReport 生成CSV错误报告并上传到存储
+func (s *memberImportServiceImpl) writeReport(ctx context.Context, task *models.MemberImport, failures []memberImportError) (string, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\xEF\xBB\xBF")
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write([]string{"行号", "用户名", "错误原因"}); err != nil {
+		return "", err
+	}
+	for _, f := range failures {
+		if err := writer.Write([]string{strconv.Itoa(f.line), spreadsheetSafe(f.username), spreadsheetSafe(f.message)}); err != nil {
+			return "", err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", err
+	}
+
+	path := strings.TrimSuffix(task.SourcePath, filepath.Ext(task.SourcePath)) + "-report.csv"
+	if err := s.storage.Upload(ctx, path, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "text/csv"); err != nil {
+		return "", fmt.Errorf("上传错误报告失败: %w", err)
+	}
+	return path, nil
+}
+
+// sortImportErrors 按行号排序
+func sortImportErrors(failures []memberImportError) {
+	sort.Slice(failures, func(i, j int) bool { return failures[i].line < failures[j].line })
+}
+
+// importColumnName 字段的中文名称
+func importColumnName(key string) string {
+	switch key {
+	case "username":
+		return "用户名"
+	case "phone":
+		return "手机号"
+	case "email":
+		return "邮箱"
+	}
+	return key
+}
+
+// importErrorMessage 获取行错误提示，自定义错误仅返回错误信息
+func importErrorMessage(err error) string {
+	var customErr *common.CustomError
+	if errors.As(err, &customErr) {
+		return customErr.Message
+	}
+	return err.Error()
+}
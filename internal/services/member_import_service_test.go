@@ -0,0 +1,299 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/storage"
+	"member-link-lite/pkg/utils"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// setupMemberImportService 创建同步执行的导入服务
+func setupMemberImportService(t *testing.T, count int) (*memberImportServiceImpl, *userServiceImpl) {
+	userService, _ := setupMemberService(t, count)
+	service := &memberImportServiceImpl{
+		db:          userService.db,
+		storage:     storage.NewLocalAdapter(t.TempDir(), "http://localhost:8080/uploads/"),
+		maxFileSize: 20 * 1024 * 1024,
+		maxRows:     1000,
+		batchSize:   2,
+		runAsync:    func(task func()) { task() },
+	}
+	return service, userService
+}
+
+// readImportReport 读取导入错误报告
+func readImportReport(t *testing.T, service *memberImportServiceImpl, ctx context.Context, importID uint64) [][]string {
+	reader, _, err := service.OpenImportReport(ctx, importID)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF")))).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestMemberImportService_CSV(t *testing.T) {
+	service, _ := setupMemberImportService(t, 1)
+	ctx := context.Background()
+
+	legacyHash, err := bcrypt.GenerateFromPassword([]byte("legacy123"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	content := strings.Join([]string{
+		"username,phone,email,nickname,password,status,balance,points",
+		"alice,13900139001,alice@example.com,爱丽丝,,1,100.5,200",
+		"bob,13900139002,bob@example.com,,Password123,0,,",
+		"carol,13900139003,carol@example.com,,\"" + string(legacyHash) + "\",,0.01,0",
+		"badphone,12345,badphone@example.com,,,,,",
+		"alice2,13900139001,alice2@example.com,,,,,",
+		"member0,13900139009,new0@example.com,,,,,",
+		",,,,,,,",
+		"dave,13900139004,dave@example.com,,,,1.234,",
+		"erin,13900139005,erin@example.com,,,,,-1",
+		"frank,13900139006,frank@example.com,,,,,",
+		"grace,13900139007,grace@example.com,,,,,",
+	}, "\n")
+
+	task, err := service.CreateImport(ctx, "members.csv", strings.NewReader(content), false, 1)
+	require.NoError(t, err)
+	assert.Equal(t, MemberFileFormatCSV, task.Format)
+
+	task, err = service.GetImport(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.MemberImportStateCompleted, task.State)
+	assert.Equal(t, 10, task.TotalRows)
+	assert.Equal(t, 5, task.SuccessRows)
+	assert.Equal(t, 5, task.FailedRows)
+	assert.NotNil(t, task.CompletedAt)
+
+	// 期初余额、积分及记录
+	var alice models.User
+	require.NoError(t, service.db.Where("username = ?", "alice").First(&alice).Error)
+	assert.Equal(t, int64(10050), alice.Balance)
+	assert.Equal(t, int64(200), alice.Points)
+	assert.Equal(t, "爱丽丝", alice.Nickname)
+	assert.Equal(t, utils.UnusablePassword, alice.Password)
+	assert.False(t, alice.CheckPassword(""))
+
+	var balanceRecord models.BalanceRecord
+	require.NoError(t, service.db.Where("user_id = ?", alice.ID).First(&balanceRecord).Error)
+	assert.Equal(t, models.BalanceTypeRecharge, balanceRecord.Type)
+	assert.Equal(t, int64(10050), balanceRecord.BalanceAfter)
+	assert.Equal(t, fmt.Sprintf("IMPORT%d", task.ID), balanceRecord.OrderNo)
+
+	var pointsRecord models.PointsRecord
+	require.NoError(t, service.db.Where("user_id = ?", alice.ID).First(&pointsRecord).Error)
+	assert.Equal(t, models.PointsTypeObtain, pointsRecord.Type)
+	assert.Equal(t, int64(200), pointsRecord.PointsAfter)
+
+	// 待审核状态及明文密码
+	var bob models.User
+	require.NoError(t, service.db.Where("username = ?", "bob").First(&bob).Error)
+	assert.Equal(t, int8(models.UserStatusPending), bob.Status)
+	assert.Equal(t, "bob", bob.Nickname)
+	assert.True(t, bob.CheckPassword("Password123"))
+
+	// 旧系统密码哈希
+	var carol models.User
+	require.NoError(t, service.db.Where("username = ?", "carol").First(&carol).Error)
+	assert.True(t, carol.CheckPassword("legacy123"))
+	var count int64
+	service.db.Model(&models.PointsRecord{}).Where("user_id = ?", carol.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	// 错误报告
+	report := readImportReport(t, service, ctx, task.ID)
+	require.Len(t, report, 6)
+	assert.Equal(t, []string{"行号", "用户名", "错误原因"}, report[0])
+	assert.Equal(t, "5", report[1][0])
+	assert.Equal(t, "badphone", report[1][1])
+	assert.Equal(t, "6", report[2][0])
+	assert.Contains(t, report[2][2], "与第2行的手机号重复")
+	assert.Equal(t, "7", report[3][0])
+	assert.Equal(t, "用户名已存在", report[3][2])
+	assert.Equal(t, "9", report[4][0])
+	assert.Equal(t, "10", report[5][0])
+
+	// 期初余额已写入分录
+	trialBalance, err := (&ledgerServiceImpl{db: service.db}).GetTrialBalance(ctx)
+	require.NoError(t, err)
+	assert.True(t, trialBalance.Balanced)
+	assert.True(t, trialBalance.WalletsMatched)
+
+	// 其他租户不能访问
+	tenantCtx := context.WithValue(ctx, "tenant_id", "company1")
+	_, err = service.GetImport(tenantCtx, task.ID)
+	assert.Equal(t, common.ErrImportNotFound, err)
+}
+
+func TestMemberImportService_DryRun(t *testing.T) {
+	service, _ := setupMemberImportService(t, 0)
+	ctx := context.Background()
+
+	content := "用户名,手机号,邮箱\nalice,13900139001,alice@example.com\nbad,123,bad@example.com\n"
+	task, err := service.CreateImport(ctx, "members.csv", strings.NewReader(content), true, 1)
+	require.NoError(t, err)
+
+	task, err = service.GetImport(ctx, task.ID)
+	require.NoError(t, err)
+	assert.True(t, task.DryRun)
+	assert.Equal(t, models.MemberImportStateCompleted, task.State)
+	assert.Equal(t, 1, task.SuccessRows)
+	assert.Equal(t, 1, task.FailedRows)
+	assert.Len(t, readImportReport(t, service, ctx, task.ID), 2)
+
+	var count int64
+	service.db.Model(&models.User{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestMemberImportService_XLSX(t *testing.T) {
+	service, _ := setupMemberImportService(t, 0)
+	ctx := context.WithValue(context.Background(), "tenant_id", "company1")
+
+	var buf bytes.Buffer
+	writer, err := utils.NewXLSXWriter(&buf, "会员")
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteRow([]string{"username", "phone", "email", "points"}))
+	require.NoError(t, writer.WriteRow([]string{"alice", "13900139001", "alice@example.com", "50"}))
+	require.NoError(t, writer.WriteRow([]string{"bob", "13900139002", "bob@example.com", ""}))
+	require.NoError(t, writer.Close())
+
+	task, err := service.CreateImport(ctx, "members.XLSX", &buf, false, 1)
+	require.NoError(t, err)
+
+	task, err = service.GetImport(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.MemberImportStateCompleted, task.State)
+	assert.Equal(t, 2, task.SuccessRows)
+	assert.False(t, task.HasReport())
+
+	_, _, err = service.OpenImportReport(ctx, task.ID)
+	assert.Equal(t, common.ErrImportReportNotFound, err)
+
+	var alice models.User
+	require.NoError(t, service.db.Where("username = ?", "alice").First(&alice).Error)
+	assert.Equal(t, "company1", alice.TenantID)
+	assert.Equal(t, int64(50), alice.Points)
+}
+
+func TestMemberImportService_InvalidFile(t *testing.T) {
+	service, _ := setupMemberImportService(t, 0)
+	ctx := context.Background()
+
+	_, err := service.CreateImport(ctx, "members.txt", strings.NewReader("x"), false, 1)
+	assert.Equal(t, errUnsupportedMemberFileFormat, err)
+
+	_, err = service.CreateImport(ctx, "members.csv", strings.NewReader(""), false, 1)
+	assert.Error(t, err)
+
+	service.maxFileSize = 10
+	_, err = service.CreateImport(ctx, "members.csv", strings.NewReader("username,phone,email\n"), false, 1)
+	assert.Equal(t, common.ErrFileTooBig, err)
+	service.maxFileSize = 20 * 1024 * 1024
+
+	// 缺少必填列
+	task, err := service.CreateImport(ctx, "members.csv", strings.NewReader("username,phone\nalice,13900139001\n"), false, 1)
+	require.NoError(t, err)
+	task, err = service.GetImport(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.MemberImportStateFailed, task.State)
+	assert.Equal(t, "导入文件缺少必填列: email", task.Error)
+
+	// 超过行数上限
+	service.maxRows = 1
+	task, err = service.CreateImport(ctx, "members.csv", strings.NewReader("username,phone,email\na1,13900139001,a1@example.com\na2,13900139002,a2@example.com\n"), false, 1)
+	require.NoError(t, err)
+	task, err = service.GetImport(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.MemberImportStateFailed, task.State)
+
+	result, err := service.ListImports(ctx, common.NewPageRequest(1, 10))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Total)
+}
+
+func TestMemberImportService_XLSXTooLarge(t *testing.T) {
+	service, _ := setupMemberImportService(t, 0)
+	ctx := context.Background()
+
+	// 高压缩比的工作表，解压后超过上限
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("xl/workbook.xml")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(`<workbook></workbook>`))
+	require.NoError(t, err)
+	w, err = zw.Create("xl/worksheets/sheet1.xml")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(`<worksheet>`))
+	require.NoError(t, err)
+	_, err = w.Write(bytes.Repeat([]byte(" "), utils.MaxXLSXPartSize))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	task, err := service.CreateImport(ctx, "members.xlsx", &buf, false, 1)
+	require.NoError(t, err)
+	task, err = service.GetImport(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.MemberImportStateFailed, task.State)
+	assert.Contains(t, task.Error, utils.ErrXLSXTooLarge.Error())
+}
+
+func TestUserService_ExportMembers(t *testing.T) {
+	service, users := setupMemberService(t, 3)
+	ctx := context.Background()
+	require.NoError(t, service.db.Model(users[1]).Update("balance", 12345).Error)
+
+	// CSV
+	var buf bytes.Buffer
+	require.NoError(t, service.ExportMembers(ctx, &ListMembersRequest{Keyword: "member1"}, MemberFileFormatCSV, &buf))
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\xEF\xBB\xBF"))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, memberExportHeader, records[0])
+	assert.Equal(t, "member1", records[1][1])
+	assert.Equal(t, "123.45", records[1][6])
+
+	// XLSX
+	buf.Reset()
+	require.NoError(t, service.ExportMembers(ctx, &ListMembersRequest{}, MemberFileFormatXLSX, &buf))
+	rows, err := utils.ReadXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Equal(t, "member0", rows[1][1])
+	assert.Equal(t, "13800138000", rows[1][2])
+
+	// 导出文件可直接导入
+	columns, err := memberImportHeader(rows[0])
+	require.NoError(t, err)
+	assert.Equal(t, 6, columns["balance"])
+
+	assert.Equal(t, errUnsupportedMemberFileFormat, service.ExportMembers(ctx, &ListMembersRequest{}, "pdf", &buf))
+
+	// 以公式字符开头的内容加单引号，导入时还原
+	require.NoError(t, service.db.Model(users[0]).Update("nickname", "=HYPERLINK(\"http://evil\")").Error)
+	buf.Reset()
+	require.NoError(t, service.ExportMembers(ctx, &ListMembersRequest{Keyword: "member0"}, MemberFileFormatCSV, &buf))
+	records, err = csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\xEF\xBB\xBF"))).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "'=HYPERLINK(\"http://evil\")", records[1][4])
+	assert.Equal(t, "=HYPERLINK(\"http://evil\")", spreadsheetUnescape(records[1][4]))
+	for _, value := range []string{"+1", "-1", "@SUM(A1)", "\tcmd", "\rcmd"} {
+		assert.Equal(t, "'"+value, spreadsheetSafe(value))
+	}
+	assert.Equal(t, "member0", spreadsheetSafe("member0"))
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+	"member-link-lite/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ListMembersRequest 会员列表查询请求
+// @Description 会员列表查询参数，支持关键字搜索及按状态、租户、注册时间、最后登录时间筛选
+type ListMembersRequest struct {
+	common.PageRequest
+	Keyword        string `json:"keyword" form:"keyword" example:"138" description:"关键字，匹配用户名、手机号、邮箱或昵称（可选）"`
+	Status         *int8  `json:"status" form:"status" example:"1" description:"状态筛选：0-待审核，1-正常，2-禁用，3-锁定（可选）"`
+	RegisteredFrom string `json:"registered_from" form:"registered_from" example:"2024-01-01" description:"注册时间起（可选）"`
+	RegisteredTo   string `json:"registered_to" form:"registered_to" example:"2024-12-31" description:"注册时间止（可选）"`
+	LastLoginFrom  string `json:"last_login_from" form:"last_login_from" example:"2024-01-01" description:"最后登录时间起（可选）"`
+	LastLoginTo    string `json:"last_login_to" form:"last_login_to" example:"2024-12-31" description:"最后登录时间止（可选）"`
+}
+
+// CreateMemberRequest 创建会员请求
+// @Description 运营人员创建会员，密码需满足租户密码策略
+type CreateMemberRequest struct {
+	RegisterRequest
+	Status *int8 `json:"status" binding:"omitempty,oneof=0 1 2 3" example:"1" description:"初始状态，默认正常"`
+}
+
+// UpdateMemberStatusRequest 修改会员状态请求
+// @Description 修改会员状态，禁用或锁定后会员的全部登录会话失效
+type UpdateMemberStatusRequest struct {
+	Status *int8  `json:"status" binding:"required,oneof=0 1 2 3" example:"2" description:"状态：0-待审核，1-正常，2-禁用，3-锁定"`
+	Reason string `json:"reason" binding:"max=255" example:"违规操作" description:"原因（可选）"`
+}
+
+// MemberAssetSummary 会员资产汇总
+// @Description 会员当前余额、积分及已完成变动记录的累计金额
+type MemberAssetSummary struct {
+	Balance            int64   `json:"balance" example:"10000" description:"余额(分为单位)"`
+	BalanceFloat       float64 `json:"balance_float" example:"100.00" description:"余额(元为单位)"`
+	Points             int64   `json:"points" example:"500" description:"积分"`
+	TotalBalanceIn     int64   `json:"total_balance_in" example:"20000" description:"累计增加金额(分为单位)"`
+	TotalBalanceOut    int64   `json:"total_balance_out" example:"10000" description:"累计减少金额(分为单位)"`
+	TotalPointsIn      int64   `json:"total_points_in" example:"800" description:"累计获得积分"`
+	TotalPointsOut     int64   `json:"total_points_out" example:"300" description:"累计使用、过期及扣除积分"`
+	BalanceRecordCount int64   `json:"balance_record_count" example:"12" description:"余额变动次数"`
+	PointsRecordCount  int64   `json:"points_record_count" example:"30" description:"积分变动次数"`
+}
+
+// MemberDetail 会员详情
+// @Description 会员信息及资产汇总
+type MemberDetail struct {
+	User   *models.User        `json:"user"`
+	Assets *MemberAssetSummary `json:"assets"`
+}
+
+// MemberStatistics 会员统计
+// @Description 当前租户会员数量统计
+type MemberStatistics struct {
+	Total      int64 `json:"total" example:"1000" description:"会员总数"`
+	Pending    int64 `json:"pending" example:"5" description:"待审核"`
+	Active     int64 `json:"active" example:"950" description:"正常"`
+	Disabled   int64 `json:"disabled" example:"40" description:"禁用"`
+	Locked     int64 `json:"locked" example:"5" description:"锁定"`
+	NewToday   int64 `json:"new_today" example:"12" description:"今日注册"`
+	ActiveWeek int64 `json:"active_week" example:"300" description:"近7天登录"`
+}
+
+// ListMembers 分页查询会员
+func (s *userServiceImpl) ListMembers(ctx context.Context, req *ListMembersRequest) (*common.PaginateResult, error) {
+	if err := req.PageRequest.ValidateAndSetDefaults(); err != nil {
+		return nil, err
+	}
+
+	conditions, err := s.memberListConditions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	conditions = append(conditions, func(db *gorm.DB) *gorm.DB { return db.Order("id DESC") })
+
+	var users []models.User
+	result, err := common.PaginateQueryWithModel(
+		s.db.WithContext(ctx),
+		&req.PageRequest,
+		&models.User{},
+		&users,
+		conditions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询会员列表失败: %w", err)
+	}
+
+	return result, nil
+}
+
+// memberListConditions 构建会员列表的筛选条件，会员列表及导出共用
+func (s *userServiceImpl) memberListConditions(ctx context.Context, req *ListMembersRequest) ([]func(*gorm.DB) *gorm.DB, error) {
+	// 只能查询当前租户的会员
+	conditions := []func(*gorm.DB) *gorm.DB{
+		models.ScopeByTenant(database.GetTenantIDFromContext(ctx)),
+	}
+
+	if keyword := strings.TrimSpace(req.Keyword); keyword != "" {
+		like := "%" + keyword + "%"
+		conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+			return db.Where("username LIKE ? OR phone LIKE ? OR email LIKE ? OR nickname LIKE ?", like, like, like, like)
+		})
+	}
+
+	if req.Status != nil {
+		if !isValidUserStatus(*req.Status) {
+			return nil, common.ErrInvalidUserStatus
+		}
+		status := *req.Status
+		conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+			return db.Where("status = ?", status)
+		})
+	}
+
+	ranges := []struct {
+		column, from, to string
+	}{
+		{"created_at", req.RegisteredFrom, req.RegisteredTo},
+		{"last_time", req.LastLoginFrom, req.LastLoginTo},
+	}
+	for _, r := range ranges {
+		if r.from == "" && r.to == "" {
+			continue
+		}
+		start, end, err := utils.ParseTimeRange(r.from, r.to)
+		if err != nil {
+			return nil, common.NewCustomError(common.CodeBadRequest, err.Error())
+		}
+		conditions = append(conditions, scopeTimeRange(r.column, start, end))
+	}
+
+	return conditions, nil
+}
+
+// GetMemberDetail 获取会员详情及资产汇总
+func (s *userServiceImpl) GetMemberDetail(ctx context.Context, id uint64) (*MemberDetail, error) {
+	user, err := s.findMember(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	assets := &MemberAssetSummary{
+		Balance:      user.Balance,
+		BalanceFloat: user.GetBalanceFloat(),
+		Points:       user.Points,
+	}
+
+	var balance struct {
+		TotalIn, TotalOut, Records int64
+	}
+	if err := s.db.WithContext(ctx).
+		Model(&models.BalanceRecord{}).
+		Scopes(models.ScopeByUserID(user.ID), models.ScopeActive).
+		Select("COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0) AS total_in, " +
+			"COALESCE(SUM(CASE WHEN amount < 0 THEN -amount ELSE 0 END), 0) AS total_out, " +
+			"COUNT(*) AS records").
+		Scan(&balance).Error; err != nil {
+		return nil, fmt.Errorf("统计余额记录失败: %w", err)
+	}
+	assets.TotalBalanceIn, assets.TotalBalanceOut, assets.BalanceRecordCount = balance.TotalIn, balance.TotalOut, balance.Records
+
+	var points struct {
+		TotalIn, TotalOut, Records int64
+	}
+	if err := s.db.WithContext(ctx).
+		Model(&models.PointsRecord{}).
+		Scopes(models.ScopePointsByUserID(user.ID), models.ScopeActive).
+		Select("COALESCE(SUM(CASE WHEN quantity > 0 THEN quantity ELSE 0 END), 0) AS total_in, " +
+			"COALESCE(SUM(CASE WHEN quantity < 0 THEN -quantity ELSE 0 END), 0) AS total_out, " +
+			"COUNT(*) AS records").
+		Scan(&points).Error; err != nil {
+		return nil, fmt.Errorf("统计积分记录失败: %w", err)
+	}
+	assets.TotalPointsIn, assets.TotalPointsOut, assets.PointsRecordCount = points.TotalIn, points.TotalOut, points.Records
+
+	return &MemberDetail{User: user, Assets: assets}, nil
+}
+
+// CreateMember 创建会员
+// 与注册使用相同的校验及密码策略，可指定初始状态
+func (s *userServiceImpl) CreateMember(ctx context.Context, req *CreateMemberRequest, operatorID uint64) (*models.User, error) {
+	if req.Status != nil && !isValidUserStatus(*req.Status) {
+		return nil, common.ErrInvalidUserStatus
+	}
+
+	user, err := s.Register(ctx, &req.RegisterRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Status != nil && *req.Status != int8(models.UserStatusActive) {
+		if err := s.db.WithContext(ctx).Model(user).Update("status", *req.Status).Error; err != nil {
+			return nil, fmt.Errorf("设置会员状态失败: %w", err)
+		}
+		user.Status = *req.Status
+	}
+
+	logger.WithFields(logrus.Fields{
+		"user_id":     user.ID,
+		"operator_id": operatorID,
+	}).Info("运营人员创建会员")
+
+	return user, nil
+}
+
+// UpdateMember 修改会员信息
+// 与会员修改个人信息使用相同的校验，不限制会员状态
+func (s *userServiceImpl) UpdateMember(ctx context.Context, id uint64, req *UpdateProfileRequest) (*models.User, error) {
+	user, err := s.findMember(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.validateUpdateProfileRequest(ctx, id, req); err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.Nickname != "" {
+		updates["nickname"] = req.Nickname
+	}
+	if req.Email != "" {
+		updates["email"] = req.Email
+		// 邮箱变更后需重新验证
+		if !strings.EqualFold(user.Email, req.Email) {
+			updates["email_verified_at"] = nil
+		}
+	}
+	if req.Phone != "" {
+		updates["phone"] = req.Phone
+	}
+	if req.Avatar != "" {
+		updates["avatar"] = req.Avatar
+	}
+	if len(updates) == 0 {
+		return user, nil
+	}
+
+	if err := s.db.WithContext(ctx).Model(user).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新会员信息失败: %w", err)
+	}
+
+	return s.findMember(ctx, id)
+}
+
+// DeleteMember 删除会员（软删除）
+// 删除后会员的全部登录会话失效，余额、积分变动记录保留
+func (s *userServiceImpl) DeleteMember(ctx context.Context, id uint64, operatorID uint64) error {
+	if id == operatorID {
+		return common.ErrOperateSelf
+	}
+
+	user, err := s.findMember(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.WithContext(ctx).Delete(user).Error; err != nil {
+		return fmt.Errorf("删除会员失败: %w", err)
+	}
+
+	s.revokeMemberSessions(ctx, user.ID)
+
+	logger.WithFields(logrus.Fields{
+		"user_id":     user.ID,
+		"operator_id": operatorID,
+	}).Info("运营人员删除会员")
+
+	return nil
+}
+
+// UpdateMemberStatus 修改会员状态
+// 设置为锁定时不设置到期时间，需手动解锁；禁用或锁定后注销会员的全部登录会话
+func (s *userServiceImpl) UpdateMemberStatus(ctx context.Context, id uint64, req *UpdateMemberStatusRequest, operatorID uint64) error {
+	if req.Status == nil || !isValidUserStatus(*req.Status) {
+		return common.ErrInvalidUserStatus
+	}
+	if id == operatorID {
+		return common.ErrOperateSelf
+	}
+
+	user, err := s.findMember(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	status := *req.Status
+	if err := s.db.WithContext(ctx).Model(user).Updates(map[string]interface{}{
+		"status":       status,
+		"locked_until": nil,
+	}).Error; err != nil {
+		return fmt.Errorf("修改会员状态失败: %w", err)
+	}
+
+	if status == models.UserStatusDisabled || status == models.UserStatusLocked {
+		s.revokeMemberSessions(ctx, user.ID)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"user_id":     user.ID,
+		"from":        user.Status,
+		"to":          status,
+		"reason":      req.Reason,
+		"operator_id": operatorID,
+	}).Info("运营人员修改会员状态")
+
+	return nil
+}
+
+// GetMemberStatistics 获取当前租户会员统计
+func (s *userServiceImpl) GetMemberStatistics(ctx context.Context) (*MemberStatistics, error) {
+	tenantScope := models.ScopeByTenant(database.GetTenantIDFromContext(ctx))
+
+	var rows []struct {
+		Status int8
+		Count  int64
+	}
+	if err := s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Scopes(tenantScope).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计会员失败: %w", err)
+	}
+
+	stats := &MemberStatistics{}
+	for _, row := range rows {
+		stats.Total += row.Count
+		switch row.Status {
+		case models.UserStatusPending:
+			stats.Pending = row.Count
+		case models.UserStatusActive:
+			stats.Active = row.Count
+		case models.UserStatusDisabled:
+			stats.Disabled = row.Count
+		case models.UserStatusLocked:
+			stats.Locked = row.Count
+		}
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if err := s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Scopes(tenantScope).
+		Where("created_at >= ?", today).
+		Count(&stats.NewToday).Error; err != nil {
+		return nil, fmt.Errorf("统计会员失败: %w", err)
+	}
+	if err := s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Scopes(tenantScope).
+		Where("last_time >= ?", now.AddDate(0, 0, -7)).
+		Count(&stats.ActiveWeek).Error; err != nil {
+		return nil, fmt.Errorf("统计会员失败: %w", err)
+	}
+
+	return stats, nil
+}
+
+// findMember 查询当前租户下的会员（不限状态）
+func (s *userServiceImpl) findMember(ctx context.Context, id uint64) (*models.User, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(database.GetTenantIDFromContext(ctx))).
+		First(&user, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, common.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("查询会员失败: %w", err)
+	}
+	return &user, nil
+}
+
+// revokeMemberSessions 注销会员的全部登录会话，失败时仅记录日志
+func (s *userServiceImpl) revokeMemberSessions(ctx context.Context, userID uint64) {
+	sessions := &sessionServiceImpl{db: s.db, jwtService: s.jwtService}
+	if _, err := sessions.RevokeAllSessions(ctx, userID); err != nil {
+		logger.WithFields(logrus.Fields{
+			"user_id": userID,
+			"error":   err.Error(),
+		}).Warn("注销会员会话失败")
+	}
+}
+
+// isValidUserStatus 检查是否为有效的会员状态
+func isValidUserStatus(status int8) bool {
+	switch status {
+	case models.UserStatusPending, models.UserStatusActive, models.UserStatusDisabled, models.UserStatusLocked:
+		return true
+	}
+	return false
+}
+
+// scopeTimeRange 按时间字段范围查询
+func scopeTimeRange(column string, start, end *time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if start != nil {
+			db = db.Where(column+" >= ?", *start)
+		}
+		if end != nil {
+			db = db.Where(column+" <= ?", *end)
+		}
+		return db
+	}
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupMemberService 创建会员管理测试用服务及会员
+func setupMemberService(t *testing.T, count int) (*userServiceImpl, []*models.User) {
+	config.Init()
+	logger.Init()
+	db := setupTestDB(t)
+	service := &userServiceImpl{db: db, jwtService: NewJWTService(), cache: cache.NewMemoryCache()}
+
+	users := make([]*models.User, 0, count)
+	for i := 0; i < count; i++ {
+		user, err := service.Register(context.Background(), &RegisterRequest{
+			Username: fmt.Sprintf("member%d", i),
+			Password: "password123",
+			Phone:    fmt.Sprintf("1380013800%d", i),
+			Email:    fmt.Sprintf("member%d@example.com", i),
+			Nickname: fmt.Sprintf("会员%d", i),
+		})
+		require.NoError(t, err)
+		users = append(users, user)
+	}
+	return service, users
+}
+
+func TestUserService_ListMembers(t *testing.T) {
+	service, users := setupMemberService(t, 5)
+	ctx := context.Background()
+
+	// 其他租户的会员
+	other := &models.User{Username: "other", Phone: "13900139000", Email: "other@example.com", Password: "x"}
+	other.TenantID = "company1"
+	require.NoError(t, service.db.Create(other).Error)
+
+	disabled := int8(models.UserStatusDisabled)
+	require.NoError(t, service.db.Model(users[1]).Update("status", disabled).Error)
+	lastLogin := time.Now().AddDate(0, 0, -3)
+	require.NoError(t, service.db.Model(users[2]).Update("last_time", lastLogin).Error)
+
+	list := func(req *ListMembersRequest) []models.User {
+		result, err := service.ListMembers(ctx, req)
+		require.NoError(t, err)
+		return *result.List.(*[]models.User)
+	}
+
+	// 默认仅查询当前租户，按ID倒序分页
+	result, err := service.ListMembers(ctx, &ListMembersRequest{PageRequest: *common.NewPageRequest(1, 2)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), result.Total)
+	assert.Equal(t, 3, result.Pages)
+	page := *result.List.(*[]models.User)
+	require.Len(t, page, 2)
+	assert.Equal(t, users[4].ID, page[0].ID)
+
+	// 关键字匹配用户名、手机号、邮箱或昵称
+	assert.Len(t, list(&ListMembersRequest{Keyword: "member3"}), 1)
+	assert.Len(t, list(&ListMembersRequest{Keyword: "13800138002"}), 1)
+	assert.Len(t, list(&ListMembersRequest{Keyword: "会员"}), 5)
+
+	// 关键字与状态同时筛选
+	found := list(&ListMembersRequest{Keyword: "member", Status: &disabled})
+	require.Len(t, found, 1)
+	assert.Equal(t, users[1].ID, found[0].ID)
+
+	// 最后登录时间范围
+	found = list(&ListMembersRequest{LastLoginFrom: time.Now().AddDate(0, 0, -7).Format("2006-01-02")})
+	require.Len(t, found, 1)
+	assert.Equal(t, users[2].ID, found[0].ID)
+
+	// 注册时间范围
+	assert.Len(t, list(&ListMembersRequest{RegisteredTo: "2000-01-01"}), 0)
+	_, err = service.ListMembers(ctx, &ListMembersRequest{RegisteredFrom: "invalid"})
+	assert.Error(t, err)
+
+	// 只能查询当前租户的会员，默认租户也不能查询其他租户
+	for _, member := range list(&ListMembersRequest{}) {
+		assert.NotEqual(t, other.ID, member.ID)
+	}
+	tenantCtx := context.WithValue(ctx, "tenant_id", "company1")
+	result, err = service.ListMembers(tenantCtx, &ListMembersRequest{})
+	require.NoError(t, err)
+	tenantMembers := *result.List.(*[]models.User)
+	require.Len(t, tenantMembers, 1)
+	assert.Equal(t, other.ID, tenantMembers[0].ID)
+
+	invalid := int8(9)
+	_, err = service.ListMembers(ctx, &ListMembersRequest{Status: &invalid})
+	assert.Equal(t, common.ErrInvalidUserStatus, err)
+}
+
+func TestUserService_GetMemberDetail(t *testing.T) {
+	service, users := setupMemberService(t, 1)
+	ctx := context.Background()
+	assets := NewAssetService(service.db)
+	userID := users[0].ID
+
+	_, err := assets.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: userID, Amount: 10000, Type: models.BalanceTypeRecharge})
+	require.NoError(t, err)
+	_, err = assets.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: userID, Amount: -3000, Type: models.BalanceTypeConsume})
+	require.NoError(t, err)
+	_, err = assets.ChangePoints(ctx, &ChangePointsRequest{UserID: userID, Quantity: 500, Type: models.PointsTypeObtain})
+	require.NoError(t, err)
+	_, err = assets.ChangePoints(ctx, &ChangePointsRequest{UserID: userID, Quantity: -200, Type: models.PointsTypeUse})
+	require.NoError(t, err)
+
+	detail, err := service.GetMemberDetail(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, userID, detail.User.ID)
+	assert.Equal(t, int64(7000), detail.Assets.Balance)
+	assert.Equal(t, int64(10000), detail.Assets.TotalBalanceIn)
+	assert.Equal(t, int64(3000), detail.Assets.TotalBalanceOut)
+	assert.Equal(t, int64(2), detail.Assets.BalanceRecordCount)
+	assert.Equal(t, int64(300), detail.Assets.Points)
+	assert.Equal(t, int64(500), detail.Assets.TotalPointsIn)
+	assert.Equal(t, int64(200), detail.Assets.TotalPointsOut)
+	assert.Equal(t, int64(2), detail.Assets.PointsRecordCount)
+
+	// 禁用的会员同样可以查看
+	require.NoError(t, service.db.Model(users[0]).Update("status", models.UserStatusDisabled).Error)
+	_, err = service.GetMemberDetail(ctx, userID)
+	assert.NoError(t, err)
+
+	_, err = service.GetMemberDetail(ctx, 9999)
+	assert.Equal(t, common.ErrUserNotFound, err)
+	_, err = service.GetMemberDetail(context.WithValue(ctx, "tenant_id", "company1"), userID)
+	assert.Equal(t, common.ErrUserNotFound, err)
+}
+
+func TestUserService_CreateUpdateDeleteMember(t *testing.T) {
+	service, users := setupMemberService(t, 1)
+	ctx := context.Background()
+	operatorID := users[0].ID
+
+	pending := int8(models.UserStatusPending)
+	user, err := service.CreateMember(ctx, &CreateMemberRequest{
+		RegisterRequest: RegisterRequest{
+			Username: "created",
+			Password: "password123",
+			Phone:    "13700137000",
+			Email:    "created@example.com",
+		},
+		Status: &pending,
+	}, operatorID)
+	require.NoError(t, err)
+	assert.Equal(t, pending, user.Status)
+
+	// 与注册使用相同的校验
+	_, err = service.CreateMember(ctx, &CreateMemberRequest{RegisterRequest: RegisterRequest{
+		Username: "created2", Password: "password123", Phone: "13700137000", Email: "created2@example.com",
+	}}, operatorID)
+	assert.Equal(t, common.ErrPhoneExists, err)
+
+	// 未激活的会员同样可以修改，邮箱变更后需重新验证
+	updated, err := service.UpdateMember(ctx, user.ID, &UpdateProfileRequest{Nickname: "新昵称", Email: "changed@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "新昵称", updated.Nickname)
+	assert.Equal(t, "changed@example.com", updated.Email)
+	assert.Nil(t, updated.EmailVerifiedAt)
+	_, err = service.UpdateMember(ctx, user.ID, &UpdateProfileRequest{Phone: users[0].Phone})
+	assert.Equal(t, common.ErrPhoneExists, err)
+
+	// 不能删除自己
+	assert.Equal(t, common.ErrOperateSelf, service.DeleteMember(ctx, operatorID, operatorID))
+
+	require.NoError(t, service.DeleteMember(ctx, user.ID, operatorID))
+	_, err = service.GetMemberDetail(ctx, user.ID)
+	assert.Equal(t, common.ErrUserNotFound, err)
+
+	// 软删除后记录仍保留，用户名不能再注册
+	var count int64
+	service.db.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+	_, err = service.Register(ctx, &RegisterRequest{
+		Username: "created", Password: "password123", Phone: "13700137001", Email: "new@example.com",
+	})
+	assert.Equal(t, common.ErrUserExists, err)
+}
+
+func TestUserService_UpdateMemberStatus(t *testing.T) {
+	service, users := setupMemberService(t, 2)
+	ctx := context.Background()
+	operatorID, memberID := users[0].ID, users[1].ID
+
+	loginResp, err := service.Login(ctx, &LoginRequest{Account: users[1].Username, Password: "password123"})
+	require.NoError(t, err)
+
+	status := func(s int8) *UpdateMemberStatusRequest {
+		return &UpdateMemberStatusRequest{Status: &s, Reason: "测试"}
+	}
+
+	assert.Equal(t, common.ErrOperateSelf, service.UpdateMemberStatus(ctx, operatorID, status(models.UserStatusDisabled), operatorID))
+	assert.Equal(t, common.ErrInvalidUserStatus, service.UpdateMemberStatus(ctx, memberID, status(9), operatorID))
+
+	// 禁用后无法登录，已签发的令牌失效
+	require.NoError(t, service.UpdateMemberStatus(ctx, memberID, status(models.UserStatusDisabled), operatorID))
+	_, err = service.Login(ctx, &LoginRequest{Account: users[1].Username, Password: "password123"})
+	assert.Equal(t, common.ErrUserDisabled, err)
+	_, err = service.RefreshToken(ctx, loginResp.Tokens.RefreshToken)
+	assert.Equal(t, common.ErrTokenRevoked, err)
+
+	// 手动锁定不会自动解锁
+	require.NoError(t, service.UpdateMemberStatus(ctx, memberID, status(models.UserStatusLocked), operatorID))
+	_, err = service.Login(ctx, &LoginRequest{Account: users[1].Username, Password: "password123"})
+	assert.Equal(t, common.ErrUserLocked, err)
+
+	require.NoError(t, service.UpdateMemberStatus(ctx, memberID, status(models.UserStatusActive), operatorID))
+	_, err = service.Login(ctx, &LoginRequest{Account: users[1].Username, Password: "password123"})
+	assert.NoError(t, err)
+
+	stats, err := service.GetMemberStatistics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Total)
+	assert.Equal(t, int64(2), stats.Active)
+	assert.Equal(t, int64(2), stats.NewToday)
+}
@@ -0,0 +1,416 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"member-link-lite/config"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TokenTypeMFAPending 密码验证通过、等待两步验证的临时令牌类型
+const TokenTypeMFAPending = "mfa_pending"
+
+// 两步验证登录缓存键前缀
+const (
+	mfaAttemptKeyPrefix = "mfa:attempt:"
+	mfaUsedKeyPrefix    = "mfa:used:"
+)
+
+// 恢复码字符集（去除易混淆字符）
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// MFAService 两步验证服务接口
+type MFAService interface {
+	// 获取两步验证状态
+	GetStatus(ctx context.Context, userID uint64) (*MFAStatus, error)
+	// 生成密钥和otpauth地址，开始绑定
+	Setup(ctx context.Context, userID uint64) (*MFASetupResponse, error)
+	// 使用动态口令确认绑定并启用，返回恢复码
+	Enable(ctx context.Context, userID uint64, code string) (*RecoveryCodesResponse, error)
+	// 关闭两步验证
+	Disable(ctx context.Context, userID uint64, code string) error
+	// 重新生成恢复码，旧恢复码全部失效
+	RegenerateRecoveryCodes(ctx context.Context, userID uint64, code string) (*RecoveryCodesResponse, error)
+	// 校验动态口令或恢复码
+	Verify(ctx context.Context, userID uint64, code string) error
+	// 检查用户是否启用两步验证
+	IsEnabled(ctx context.Context, userID uint64) (bool, error)
+}
+
+// MFAConfig 两步验证配置
+type MFAConfig struct {
+	Issuer            string        // 发行方名称
+	PendingTokenTTL   time.Duration // 两步验证临时令牌有效期
+	MaxAttempts       int64         // 单次登录最多尝试次数
+	RecoveryCodeCount int           // 恢复码数量
+	Skew              int           // 允许的时间步偏差
+}
+
+// MFAStatus 两步验证状态
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
+
+// MFASetupResponse 两步验证绑定信息
+type MFASetupResponse struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`                        // 手动输入用的密钥
+	URI    string `json:"uri" example:"otpauth://totp/MemberLink:testuser?secret=JBSWY3DPEHPK3PXP"` // 用于生成二维码
+}
+
+// RecoveryCodesResponse 恢复码
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"` // 仅返回一次，请提示用户妥善保存
+}
+
+// MFACodeRequest 两步验证码请求
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"` // 动态口令或恢复码
+}
+
+// MFALoginRequest 两步验证登录请求
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required" example:"123456"` // 动态口令或恢复码
+}
+
+// mfaServiceImpl 两步验证服务实现
+type mfaServiceImpl struct {
+	db  *gorm.DB
+	cfg *MFAConfig
+}
+
+// LoadMFAConfig 从配置文件加载两步验证配置
+func LoadMFAConfig() *MFAConfig {
+	return &MFAConfig{
+		Issuer:            config.GetString("mfa.issuer"),
+		PendingTokenTTL:   time.Duration(config.GetInt("mfa.pending_token_ttl")) * time.Minute,
+		MaxAttempts:       int64(config.GetInt("mfa.max_attempts")),
+		RecoveryCodeCount: config.GetInt("mfa.recovery_code_count"),
+		Skew:              config.GetInt("mfa.skew"),
+	}
+}
+
+// NewMFAService 创建两步验证服务实例
+func NewMFAService() MFAService {
+	return &mfaServiceImpl{
+		db:  database.GetDB(),
+		cfg: LoadMFAConfig(),
+	}
+}
+
+// GetStatus 获取两步验证状态
+func (s *mfaServiceImpl) GetStatus(ctx context.Context, userID uint64) (*MFAStatus, error) {
+	mfa, err := s.find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MFAStatus{}
+	if mfa == nil || !mfa.IsEnabled() {
+		return status, nil
+	}
+
+	status.Enabled = true
+	status.EnabledAt = mfa.EnabledAt
+	if err := s.db.WithContext(ctx).
+		Model(&models.UserRecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&status.RecoveryCodesRemaining).Error; err != nil {
+		return nil, fmt.Errorf("查询恢复码失败: %w", err)
+	}
+
+	return status, nil
+}
+
+// Setup 生成密钥，开始绑定
+// 未确认前可重复调用，每次生成新的密钥
+func (s *mfaServiceImpl) Setup(ctx context.Context, userID uint64) (*MFASetupResponse, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeActive).
+		Where("id = ? AND tenant_id = ?", userID, database.GetTenantIDFromContext(ctx)).
+		First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, common.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	mfa, err := s.find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa != nil && mfa.IsEnabled() {
+		return nil, common.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("生成密钥失败: %w", err)
+	}
+
+	if mfa == nil {
+		mfa = &models.UserMFA{UserID: userID, Secret: secret}
+		mfa.TenantID = user.TenantID
+		if err := s.db.WithContext(ctx).Create(mfa).Error; err != nil {
+			return nil, fmt.Errorf("保存两步验证配置失败: %w", err)
+		}
+	} else {
+		if err := s.db.WithContext(ctx).
+			Model(mfa).
+			Updates(map[string]interface{}{"secret": secret, "last_used_step": 0}).Error; err != nil {
+			return nil, fmt.Errorf("保存两步验证配置失败: %w", err)
+		}
+	}
+
+	return &MFASetupResponse{
+		Secret: secret,
+		URI:    utils.TOTPURI(s.cfg.Issuer, user.Username, secret),
+	}, nil
+}
+
+// Enable 确认绑定并启用两步验证
+func (s *mfaServiceImpl) Enable(ctx context.Context, userID uint64, code string) (*RecoveryCodesResponse, error) {
+	mfa, err := s.find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, common.ErrMFASetupRequired
+	}
+	if mfa.IsEnabled() {
+		return nil, common.ErrMFAAlreadyEnabled
+	}
+
+	if err := s.verifyTOTP(ctx, mfa, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(mfa).Update("enabled_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("启用两步验证失败: %w", err)
+		}
+
+		codes, err = s.replaceRecoveryCodes(tx, mfa)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable 关闭两步验证
+func (s *mfaServiceImpl) Disable(ctx context.Context, userID uint64, code string) error {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserRecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("删除恢复码失败: %w", err)
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.UserMFA{}).Error; err != nil {
+			return fmt.Errorf("关闭两步验证失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+func (s *mfaServiceImpl) RegenerateRecoveryCodes(ctx context.Context, userID uint64, code string) (*RecoveryCodesResponse, error) {
+	mfa, err := s.find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil || !mfa.IsEnabled() {
+		return nil, common.ErrMFANotEnabled
+	}
+
+	// 重新生成恢复码需要使用动态口令，不接受恢复码
+	if err := s.verifyTOTP(ctx, mfa, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		codes, err = s.replaceRecoveryCodes(tx, mfa)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Verify 校验动态口令或恢复码
+func (s *mfaServiceImpl) Verify(ctx context.Context, userID uint64, code string) error {
+	mfa, err := s.find(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if mfa == nil || !mfa.IsEnabled() {
+		return common.ErrMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == utils.TOTPDigits {
+		return s.verifyTOTP(ctx, mfa, code)
+	}
+
+	return s.useRecoveryCode(ctx, userID, code)
+}
+
+// IsEnabled 检查用户是否启用两步验证
+func (s *mfaServiceImpl) IsEnabled(ctx context.Context, userID uint64) (bool, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).
+		Model(&models.UserMFA{}).
+		Where("user_id = ? AND enabled_at IS NOT NULL", userID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询两步验证状态失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// find 查询用户的两步验证配置，不存在时返回nil
+func (s *mfaServiceImpl) find(ctx context.Context, userID uint64) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&mfa).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询两步验证配置失败: %w", err)
+	}
+	return &mfa, nil
+}
+
+// verifyTOTP 校验动态口令，同一时间步的口令只能使用一次
+func (s *mfaServiceImpl) verifyTOTP(ctx context.Context, mfa *models.UserMFA, code string) error {
+	step, ok := utils.ValidateTOTPCode(mfa.Secret, code, time.Now(), s.cfg.Skew)
+	if !ok || step <= mfa.LastUsedStep {
+		return common.ErrInvalidMFACode
+	}
+
+	// 条件更新，并发请求中只有一个能使用该口令
+	result := s.db.WithContext(ctx).
+		Model(&models.UserMFA{}).
+		Where("id = ? AND last_used_step < ?", mfa.ID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return fmt.Errorf("更新两步验证状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return common.ErrInvalidMFACode
+	}
+
+	mfa.LastUsedStep = step
+	return nil
+}
+
+// useRecoveryCode 使用恢复码
+func (s *mfaServiceImpl) useRecoveryCode(ctx context.Context, userID uint64, code string) error {
+	code = normalizeRecoveryCode(code)
+	if code == "" {
+		return common.ErrInvalidMFACode
+	}
+
+	var records []models.UserRecoveryCode
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Find(&records).Error; err != nil {
+		return fmt.Errorf("查询恢复码失败: %w", err)
+	}
+
+	for _, record := range records {
+		if !utils.CheckPassword(code, record.CodeHash) {
+			continue
+		}
+
+		result := s.db.WithContext(ctx).
+			Model(&models.UserRecoveryCode{}).
+			Where("id = ? AND used_at IS NULL", record.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return fmt.Errorf("更新恢复码失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return common.ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	return common.ErrInvalidMFACode
+}
+
+// replaceRecoveryCodes 生成新的恢复码并替换旧恢复码
+func (s *mfaServiceImpl) replaceRecoveryCodes(tx *gorm.DB, mfa *models.UserMFA) ([]string, error) {
+	if err := tx.Where("user_id = ?", mfa.UserID).Delete(&models.UserRecoveryCode{}).Error; err != nil {
+		return nil, fmt.Errorf("删除恢复码失败: %w", err)
+	}
+
+	count := s.cfg.RecoveryCodeCount
+	if count <= 0 {
+		count = 10
+	}
+
+	codes := make([]string, 0, count)
+	records := make([]*models.UserRecoveryCode, 0, count)
+	for i := 0; i < count; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("生成恢复码失败: %w", err)
+		}
+
+		hash, err := utils.HashPassword(normalizeRecoveryCode(code), utils.DefaultPasswordConfig)
+		if err != nil {
+			return nil, fmt.Errorf("生成恢复码失败: %w", err)
+		}
+
+		record := &models.UserRecoveryCode{UserID: mfa.UserID, CodeHash: hash}
+		record.TenantID = mfa.TenantID
+		records = append(records, record)
+		codes = append(codes, code)
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("保存恢复码失败: %w", err)
+	}
+
+	return codes, nil
+}
+
+// generateRecoveryCode 生成恢复码，格式为 xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	code := make([]byte, 10)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = recoveryCodeAlphabet[n.Int64()]
+	}
+	return string(code[:5]) + "-" + string(code[5:]), nil
+}
+
+// normalizeRecoveryCode 规范化用户输入的恢复码（忽略大小写、空格和连字符）
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
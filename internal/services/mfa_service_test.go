@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// totpCodeAt 生成相对当前时间偏移offset个时间步的动态口令
+func totpCodeAt(t *testing.T, secret string, offset int64) string {
+	code, err := utils.GenerateTOTPCode(secret, utils.TOTPStep(time.Now())+offset)
+	require.NoError(t, err)
+	return code
+}
+
+func TestGenerateTOTPCode(t *testing.T) {
+	// RFC 6238 附录B测试向量（SHA1，截取后6位）
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := utils.GenerateTOTPCode(secret, tt.unix/utils.TOTPPeriod)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, code)
+	}
+
+	uri := utils.TOTPURI("MemberLink", "testuser", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/MemberLink:testuser?"))
+	assert.Contains(t, uri, "secret="+secret)
+}
+
+func TestMFAService_EnableAndLogin(t *testing.T) {
+	config.Init()
+	db := setupTestDB(t)
+	userService := &userServiceImpl{db: db, jwtService: NewJWTService(), cache: cache.NewMemoryCache()}
+	mfaService := userService.mfa()
+	ctx := context.Background()
+
+	user, err := userService.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+
+	// 未确认前不影响登录
+	setup, err := mfaService.Setup(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Contains(t, setup.URI, "secret="+setup.Secret)
+
+	resp, err := userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "password123"})
+	require.NoError(t, err)
+	assert.False(t, resp.MFARequired)
+	assert.NotNil(t, resp.Tokens)
+
+	// 使用动态口令确认启用
+	_, err = mfaService.Enable(ctx, user.ID, "000000")
+	assert.Equal(t, common.ErrInvalidMFACode, err)
+	// 记录确认启用时使用的口令，跨越时间步时仍用同一时间步验证重放
+	enableCode := totpCodeAt(t, setup.Secret, -1)
+	codes, err := mfaService.Enable(ctx, user.ID, enableCode)
+	require.NoError(t, err)
+	assert.Len(t, codes.RecoveryCodes, 10)
+
+	status, err := mfaService.GetStatus(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.Equal(t, int64(10), status.RecoveryCodesRemaining)
+
+	// 密码登录只返回临时令牌
+	resp, err = userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "password123"})
+	require.NoError(t, err)
+	assert.True(t, resp.MFARequired)
+	assert.Nil(t, resp.Tokens)
+	assert.Nil(t, resp.User)
+	require.NotEmpty(t, resp.MFAToken)
+
+	// 临时令牌不能作为访问令牌或刷新令牌使用
+	_, err = userService.RefreshToken(ctx, resp.MFAToken)
+	assert.Error(t, err)
+
+	// 已使用过的时间步不能重放
+	_, err = userService.VerifyMFALogin(ctx, &MFALoginRequest{MFAToken: resp.MFAToken, Code: enableCode})
+	assert.Equal(t, common.ErrInvalidMFACode, err)
+
+	loginResp, err := userService.VerifyMFALogin(ctx, &MFALoginRequest{MFAToken: resp.MFAToken, Code: totpCodeAt(t, setup.Secret, 0)})
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, loginResp.User.ID)
+	assert.NotEmpty(t, loginResp.Tokens.AccessToken)
+
+	// 临时令牌只能使用一次
+	_, err = userService.VerifyMFALogin(ctx, &MFALoginRequest{MFAToken: resp.MFAToken, Code: totpCodeAt(t, setup.Secret, 1)})
+	assert.Equal(t, common.ErrMFATokenInvalid, err)
+
+	// 恢复码可代替动态口令，且只能使用一次
+	resp, err = userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "password123"})
+	require.NoError(t, err)
+	_, err = userService.VerifyMFALogin(ctx, &MFALoginRequest{MFAToken: resp.MFAToken, Code: strings.ToUpper(codes.RecoveryCodes[0])})
+	require.NoError(t, err)
+
+	resp, err = userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "password123"})
+	require.NoError(t, err)
+	_, err = userService.VerifyMFALogin(ctx, &MFALoginRequest{MFAToken: resp.MFAToken, Code: codes.RecoveryCodes[0]})
+	assert.Equal(t, common.ErrInvalidMFACode, err)
+
+	status, err = mfaService.GetStatus(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), status.RecoveryCodesRemaining)
+
+	// 关闭后恢复为单步登录
+	require.NoError(t, mfaService.Disable(ctx, user.ID, codes.RecoveryCodes[1]))
+	resp, err = userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "password123"})
+	require.NoError(t, err)
+	assert.False(t, resp.MFARequired)
+}
+
+func TestMFAService_LoginAttemptLimit(t *testing.T) {
+	config.Init()
+	db := setupTestDB(t)
+	userService := &userServiceImpl{db: db, jwtService: NewJWTService(), cache: cache.NewMemoryCache()}
+	mfaService := userService.mfa()
+	ctx := context.Background()
+
+	user, err := userService.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+
+	setup, err := mfaService.Setup(ctx, user.ID)
+	require.NoError(t, err)
+	_, err = mfaService.Enable(ctx, user.ID, totpCodeAt(t, setup.Secret, -1))
+	require.NoError(t, err)
+
+	resp, err := userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "password123"})
+	require.NoError(t, err)
+
+	// 超过尝试次数后临时令牌作废
+	for i := 0; i < int(mfaService.cfg.MaxAttempts); i++ {
+		_, err = userService.VerifyMFALogin(ctx, &MFALoginRequest{MFAToken: resp.MFAToken, Code: "000000"})
+		assert.Equal(t, common.ErrInvalidMFACode, err)
+	}
+	_, err = userService.VerifyMFALogin(ctx, &MFALoginRequest{MFAToken: resp.MFAToken, Code: totpCodeAt(t, setup.Secret, 0)})
+	assert.Equal(t, common.ErrMFATokenInvalid, err)
+}
+
+func TestMFAService_SMSLoginRequiresMFA(t *testing.T) {
+	service, sender := setupTestSMSService(t)
+	userService := service.userService.(*userServiceImpl)
+	mfaService := userService.mfa()
+	ctx := context.Background()
+	phone := "13800138000"
+
+	user, err := userService.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    phone,
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+	setup, err := mfaService.Setup(ctx, user.ID)
+	require.NoError(t, err)
+	_, err = mfaService.Enable(ctx, user.ID, totpCodeAt(t, setup.Secret, -1))
+	require.NoError(t, err)
+
+	// 验证码登录同样只返回临时令牌
+	require.NoError(t, service.SendLoginCode(ctx, phone))
+	resp, err := service.LoginWithCode(ctx, &SMSLoginRequest{Phone: phone, Code: lastSMSCode(t, sender, phone)})
+	require.NoError(t, err)
+	assert.True(t, resp.MFARequired)
+	assert.Nil(t, resp.User)
+	assert.Nil(t, resp.Tokens)
+	require.NotEmpty(t, resp.MFAToken)
+
+	// 完成两步验证后按验证码登录记录会话
+	loginResp, err := userService.VerifyMFALogin(ctx, &MFALoginRequest{MFAToken: resp.MFAToken, Code: totpCodeAt(t, setup.Secret, 0)})
+	require.NoError(t, err)
+	assert.NotEmpty(t, loginResp.Tokens.AccessToken)
+
+	var count int64
+	userService.db.Model(&models.UserSession{}).Where("user_id = ? AND login_type = ?", user.ID, models.LoginTypeSMS).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
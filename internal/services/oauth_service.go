@@ -0,0 +1,934 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"member-link-lite/config"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// OAuth令牌类型
+// 与会员令牌区分，第三方应用的令牌不能访问会员接口
+const (
+	TokenTypeOAuthAccess  = "oauth_access"
+	TokenTypeOAuthRefresh = "oauth_refresh"
+)
+
+// OAuth授权类型
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// 授权码缓存键前缀
+const (
+	oauthCodeKeyPrefix     = "oauth:code:"
+	oauthCodeUsedKeyPrefix = "oauth:code:used:"
+)
+
+// oauthSupportedScopes 支持的授权范围
+var oauthSupportedScopes = []string{
+	models.OAuthScopeOpenID,
+	models.OAuthScopeProfile,
+	models.OAuthScopeEmail,
+	models.OAuthScopePhone,
+}
+
+// OAuthService OAuth2/OIDC授权服务接口
+type OAuthService interface {
+	// 登记第三方应用，密钥仅在此时返回
+	CreateClient(ctx context.Context, req *CreateOAuthClientRequest, createdBy uint64) (*OAuthClientCredentials, error)
+	// 获取第三方应用列表
+	ListClients(ctx context.Context) ([]*models.OAuthClient, error)
+	// 停用第三方应用
+	DisableClient(ctx context.Context, id uint64) error
+	// 校验授权请求，返回授权确认页所需信息
+	PrepareAuthorization(ctx context.Context, userID uint64, req *AuthorizeRequest) (*AuthorizePrompt, error)
+	// 会员确认或拒绝授权，返回携带授权码或错误信息的回调地址
+	Authorize(ctx context.Context, userID uint64, authTime time.Time, req *ConsentRequest) (*AuthorizeResult, error)
+	// 令牌端点，支持授权码和刷新令牌两种授权类型
+	Token(ctx context.Context, req *OAuthTokenRequest) (*OAuthTokenResponse, error)
+	// 根据访问令牌获取用户信息
+	UserInfo(ctx context.Context, accessToken string) (*UserInfoResponse, error)
+	// 获取OIDC发现文档，未启用OIDC时返回nil
+	Discovery() *OIDCDiscovery
+	// 获取ID令牌验证公钥集合，未启用OIDC时返回nil
+	JWKS() *JWKSet
+}
+
+// CreateOAuthClientRequest 登记第三方应用请求
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required,max=100" example:"合作方商城"`                                 // 应用名称
+	LogoURL      string   `json:"logo_url" binding:"omitempty,url,max=255" example:"https://example.com/logo.png"` // 应用图标
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,dive,url" example:"https://h5.example.com/callback"`
+	Scopes       []string `json:"scopes" example:"openid,profile"` // 允许申请的授权范围，默认openid（启用OIDC时）和profile
+	Public       bool     `json:"public" example:"true"`           // 公开客户端（纯前端应用）不发放密钥，只能依赖PKCE
+}
+
+// OAuthClientCredentials 第三方应用凭证
+type OAuthClientCredentials struct {
+	Client       *models.OAuthClient `json:"client"`
+	ClientID     string              `json:"client_id"`
+	ClientSecret string              `json:"client_secret,omitempty"` // 仅返回一次，公开客户端为空
+}
+
+// AuthorizeRequest 授权请求
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required" example:"code"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	Scope               string `form:"scope" json:"scope" binding:"required" example:"openid profile"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method" example:"S256"`
+}
+
+// ConsentRequest 授权确认请求
+type ConsentRequest struct {
+	AuthorizeRequest
+	Approve bool `json:"approve"` // 是否同意授权
+}
+
+// OAuthClientInfo 授权确认页展示的应用信息
+type OAuthClientInfo struct {
+	ClientID string `json:"client_id"`
+	Name     string `json:"name"`
+	LogoURL  string `json:"logo_url"`
+}
+
+// AuthorizePrompt 授权确认页信息
+type AuthorizePrompt struct {
+	Client          OAuthClientInfo `json:"client"`
+	Scopes          []string        `json:"scopes"`
+	ConsentRequired bool            `json:"consent_required"` // 已授权过相同范围时为false，可直接确认
+}
+
+// AuthorizeResult 授权结果
+type AuthorizeResult struct {
+	RedirectURI string `json:"redirect_uri"` // 前端跳转到该地址完成授权
+}
+
+// OAuthTokenRequest 令牌请求
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// OAuthTokenResponse 令牌响应
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// OIDCUserClaims 由会员信息映射的标准声明
+type OIDCUserClaims struct {
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+	UpdatedAt         int64  `json:"updated_at,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+	PhoneNumber       string `json:"phone_number,omitempty"`
+}
+
+// IDTokenClaims ID令牌声明
+type IDTokenClaims struct {
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time,omitempty"`
+	AtHash   string `json:"at_hash,omitempty"`
+	OIDCUserClaims
+	jwt.RegisteredClaims
+}
+
+// UserInfoResponse 用户信息响应
+type UserInfoResponse struct {
+	Subject string `json:"sub"`
+	OIDCUserClaims
+}
+
+// OIDCDiscovery OIDC发现文档
+type OIDCDiscovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// OAuthError 令牌端点错误（RFC 6749 第5.2节格式）
+type OAuthError struct {
+	Status      int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// Error 实现error接口
+func (e *OAuthError) Error() string {
+	if e.Description != "" {
+		return e.Code + ": " + e.Description
+	}
+	return e.Code
+}
+
+// newOAuthError 创建令牌端点错误
+func newOAuthError(status int, code, description string) *OAuthError {
+	return &OAuthError{Status: status, Code: code, Description: description}
+}
+
+// OAuthConfig OAuth2/OIDC配置
+type OAuthConfig struct {
+	Issuer               string        // 签发方，需为对外可访问的URL
+	AuthorizationPageURL string        // 前端授权确认页地址
+	CodeTTL              time.Duration // 授权码有效期
+	AccessTokenTTL       time.Duration // 访问令牌及ID令牌有效期
+}
+
+// LoadOAuthConfig 读取OAuth2/OIDC配置
+func LoadOAuthConfig() OAuthConfig {
+	return OAuthConfig{
+		Issuer:               strings.TrimRight(config.GetString("oauth.issuer"), "/"),
+		AuthorizationPageURL: config.GetString("oauth.authorization_page_url"),
+		CodeTTL:              time.Duration(config.GetInt("oauth.code_ttl")) * time.Second,
+		AccessTokenTTL:       time.Duration(config.GetInt("oauth.access_token_ttl")) * time.Minute,
+	}
+}
+
+// oauthAuthorizationCode 授权码关联的授权信息（保存在缓存中）
+type oauthAuthorizationCode struct {
+	ClientID      string `json:"client_id"`
+	RedirectURI   string `json:"redirect_uri"`
+	Scope         string `json:"scope"`
+	Nonce         string `json:"nonce,omitempty"`
+	CodeChallenge string `json:"code_challenge"`
+	UserID        uint64 `json:"user_id"`
+	TenantID      string `json:"tenant_id"`
+	FamilyID      string `json:"family_id"` // 预先分配的令牌族ID，授权码被重复使用时据此吊销已签发的令牌
+	AuthTime      int64  `json:"auth_time"`
+}
+
+// oauthServiceImpl OAuth2/OIDC授权服务实现
+type oauthServiceImpl struct {
+	db          *gorm.DB
+	jwtService  JWTService
+	idTokenKeys *jwtKeySet // ID令牌签名密钥，为nil时未启用OIDC
+	cache       cache.Cache
+	cfg         OAuthConfig
+}
+
+// NewOAuthService 创建OAuth2/OIDC授权服务实例
+func NewOAuthService() OAuthService {
+	return &oauthServiceImpl{
+		db:          database.GetDB(),
+		jwtService:  NewJWTService(),
+		idTokenKeys: getOIDCKeySet(),
+		cache:       database.GetCache(),
+		cfg:         LoadOAuthConfig(),
+	}
+}
+
+// supportedScopes 获取支持的授权范围，未启用OIDC时不支持openid
+func (s *oauthServiceImpl) supportedScopes() []string {
+	if s.idTokenKeys != nil {
+		return oauthSupportedScopes
+	}
+	return oauthSupportedScopes[1:]
+}
+
+// sessions 获取会话服务（复用当前数据库连接）
+func (s *oauthServiceImpl) sessions() *sessionServiceImpl {
+	return &sessionServiceImpl{db: s.db, jwtService: s.jwtService}
+}
+
+// CreateClient 登记第三方应用
+func (s *oauthServiceImpl) CreateClient(ctx context.Context, req *CreateOAuthClientRequest, createdBy uint64) (*OAuthClientCredentials, error) {
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{models.OAuthScopeProfile}
+		if s.idTokenKeys != nil {
+			scopes = []string{models.OAuthScopeOpenID, models.OAuthScopeProfile}
+		}
+	}
+	for _, scope := range scopes {
+		if !containsString(s.supportedScopes(), scope) {
+			return nil, common.NewCustomError(common.CodeBadRequest, "不支持的授权范围: "+scope)
+		}
+	}
+
+	for _, uri := range req.RedirectURIs {
+		parsed, err := url.Parse(uri)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || parsed.Fragment != "" {
+			return nil, common.NewCustomError(common.CodeBadRequest, "回调地址格式错误: "+uri)
+		}
+	}
+
+	clientID, err := randomHex(12)
+	if err != nil {
+		return nil, fmt.Errorf("生成客户端ID失败: %w", err)
+	}
+
+	client := &models.OAuthClient{
+		ClientID:     "mlc_" + clientID,
+		Name:         req.Name,
+		LogoURL:      req.LogoURL,
+		RedirectURIs: strings.Join(req.RedirectURIs, "\n"),
+		Scopes:       strings.Join(scopes, " "),
+		Public:       req.Public,
+		CreatedBy:    createdBy,
+	}
+	client.TenantID = database.GetTenantIDFromContext(ctx)
+
+	credentials := &OAuthClientCredentials{Client: client, ClientID: client.ClientID}
+	if !req.Public {
+		secret, err := randomHex(32)
+		if err != nil {
+			return nil, fmt.Errorf("生成客户端密钥失败: %w", err)
+		}
+		client.SecretHash = hashClientSecret(secret)
+		credentials.ClientSecret = secret
+	}
+
+	if err := s.db.WithContext(ctx).Create(client).Error; err != nil {
+		return nil, fmt.Errorf("登记应用失败: %w", err)
+	}
+
+	return credentials, nil
+}
+
+// ListClients 获取第三方应用列表
+func (s *oauthServiceImpl) ListClients(ctx context.Context) ([]*models.OAuthClient, error) {
+	var clients []*models.OAuthClient
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(database.GetTenantIDFromContext(ctx))).
+		Order("id DESC").
+		Find(&clients).Error; err != nil {
+		return nil, fmt.Errorf("查询应用失败: %w", err)
+	}
+	return clients, nil
+}
+
+// DisableClient 停用第三方应用
+func (s *oauthServiceImpl) DisableClient(ctx context.Context, id uint64) error {
+	result := s.db.WithContext(ctx).
+		Model(&models.OAuthClient{}).
+		Scopes(models.ScopeByTenant(database.GetTenantIDFromContext(ctx))).
+		Where("id = ?", id).
+		Update("status", models.StatusDisabled)
+	if result.Error != nil {
+		return fmt.Errorf("停用应用失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return common.ErrOAuthClientNotFound
+	}
+	return nil
+}
+
+// PrepareAuthorization 校验授权请求
+func (s *oauthServiceImpl) PrepareAuthorization(ctx context.Context, userID uint64, req *AuthorizeRequest) (*AuthorizePrompt, error) {
+	client, scopes, err := s.validateAuthorizeRequest(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	consented, err := s.hasConsent(ctx, userID, client.ClientID, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthorizePrompt{
+		Client: OAuthClientInfo{
+			ClientID: client.ClientID,
+			Name:     client.Name,
+			LogoURL:  client.LogoURL,
+		},
+		Scopes:          scopes,
+		ConsentRequired: !consented,
+	}, nil
+}
+
+// Authorize 会员确认或拒绝授权
+func (s *oauthServiceImpl) Authorize(ctx context.Context, userID uint64, authTime time.Time, req *ConsentRequest) (*AuthorizeResult, error) {
+	client, scopes, err := s.validateAuthorizeRequest(ctx, userID, &req.AuthorizeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.Approve {
+		return &AuthorizeResult{
+			RedirectURI: appendQuery(req.RedirectURI, map[string]string{
+				"error":             "access_denied",
+				"error_description": "用户拒绝授权",
+				"state":             req.State,
+			}),
+		}, nil
+	}
+
+	if err := s.saveConsent(ctx, userID, client, scopes); err != nil {
+		return nil, err
+	}
+
+	code, err := randomHex(32)
+	if err != nil {
+		return nil, fmt.Errorf("生成授权码失败: %w", err)
+	}
+	familyID, err := NewTokenID()
+	if err != nil {
+		return nil, fmt.Errorf("生成令牌族ID失败: %w", err)
+	}
+
+	payload, err := json.Marshal(&oauthAuthorizationCode{
+		ClientID:      client.ClientID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		UserID:        userID,
+		TenantID:      client.TenantID,
+		FamilyID:      familyID,
+		AuthTime:      authTime.Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成授权码失败: %w", err)
+	}
+	if err := s.cache.Set(ctx, oauthCodeKeyPrefix+hashClientSecret(code), string(payload), s.cfg.CodeTTL); err != nil {
+		return nil, fmt.Errorf("保存授权码失败: %w", err)
+	}
+
+	return &AuthorizeResult{
+		RedirectURI: appendQuery(req.RedirectURI, map[string]string{
+			"code":  code,
+			"state": req.State,
+		}),
+	}, nil
+}
+
+// Token 令牌端点
+func (s *oauthServiceImpl) Token(ctx context.Context, req *OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	// 令牌签发在应用所属租户下进行
+	ctx = context.WithValue(ctx, "tenant_id", client.TenantID)
+
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode:
+		return s.exchangeCode(ctx, client, req)
+	case GrantTypeRefreshToken:
+		return s.refreshToken(ctx, client, req)
+	case "":
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_request", "缺少grant_type")
+	default:
+		return nil, newOAuthError(http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+// exchangeCode 使用授权码换取令牌
+func (s *oauthServiceImpl) exchangeCode(ctx context.Context, client *models.OAuthClient, req *OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_request", "缺少code或code_verifier")
+	}
+
+	codeKey := oauthCodeKeyPrefix + hashClientSecret(req.Code)
+	value, err := s.cache.Get(ctx, codeKey)
+	if err != nil {
+		if err == cache.ErrNotFound {
+			return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "授权码无效或已过期")
+		}
+		return nil, fmt.Errorf("读取授权码失败: %w", err)
+	}
+
+	var code oauthAuthorizationCode
+	if err := json.Unmarshal([]byte(value), &code); err != nil {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "授权码无效或已过期")
+	}
+
+	// 授权码只能使用一次，重复使用时吊销由其签发的全部令牌
+	// 授权码数据保留到过期，以便识别重复使用
+	first, err := s.cache.SetNX(ctx, oauthCodeUsedKeyPrefix+hashClientSecret(req.Code), "1", s.cfg.CodeTTL)
+	if err != nil {
+		return nil, fmt.Errorf("记录授权码使用失败: %w", err)
+	}
+	if !first {
+		if err := s.jwtService.RevokeFamily(ctx, code.FamilyID); err != nil {
+			return nil, fmt.Errorf("吊销令牌族失败: %w", err)
+		}
+		if err := s.sessions().MarkSessionRevoked(ctx, code.FamilyID); err != nil {
+			return nil, fmt.Errorf("注销会话失败: %w", err)
+		}
+		logger.WithFields(logrus.Fields{
+			"event":     "oauth_code_reuse",
+			"client_id": client.ClientID,
+			"user_id":   code.UserID,
+		}).Warn("检测到授权码重复使用，已吊销相关令牌")
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "授权码已被使用")
+	}
+
+	if code.ClientID != client.ClientID || code.RedirectURI != req.RedirectURI {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "授权码与应用或回调地址不匹配")
+	}
+	if !verifyPKCE(req.CodeVerifier, code.CodeChallenge) {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "code_verifier校验失败")
+	}
+
+	user, err := s.activeUser(ctx, code.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.sessions().CreateSession(ctx, user.ID, code.FamilyID, models.LoginTypeOAuth); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, client, code.FamilyID, code.Scope, code.Nonce, code.AuthTime)
+}
+
+// refreshToken 使用刷新令牌换取新令牌
+// 与会员刷新令牌一致：每个刷新令牌只能使用一次，重复使用时吊销整个令牌族
+func (s *oauthServiceImpl) refreshToken(ctx context.Context, client *models.OAuthClient, req *OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_request", "缺少refresh_token")
+	}
+
+	invalidGrant := newOAuthError(http.StatusBadRequest, "invalid_grant", "刷新令牌无效或已过期")
+
+	claims, err := s.jwtService.ValidateToken(req.RefreshToken)
+	if err != nil || claims.Type != TokenTypeOAuthRefresh || claims.ClientID != client.ClientID {
+		return nil, invalidGrant
+	}
+
+	revoked, err := s.jwtService.IsTokenRevoked(ctx, claims)
+	if err != nil {
+		return nil, fmt.Errorf("检查令牌状态失败: %w", err)
+	}
+	if revoked {
+		return nil, invalidGrant
+	}
+
+	firstUse, err := s.jwtService.ConsumeRefreshToken(ctx, claims)
+	if err != nil {
+		return nil, invalidGrant
+	}
+	if !firstUse {
+		if err := s.jwtService.RevokeTokenFamily(ctx, claims); err != nil {
+			return nil, fmt.Errorf("吊销令牌族失败: %w", err)
+		}
+		if err := s.sessions().MarkSessionRevoked(ctx, claims.FamilyID); err != nil {
+			return nil, fmt.Errorf("注销会话失败: %w", err)
+		}
+		logger.WithFields(logrus.Fields{
+			"event":     "oauth_refresh_token_reuse",
+			"client_id": client.ClientID,
+			"user_id":   claims.UserID,
+			"family_id": claims.FamilyID,
+		}).Warn("检测到刷新令牌重复使用，已吊销令牌族")
+		return nil, invalidGrant
+	}
+
+	user, err := s.activeUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 会话已被注销（如会员在设备管理中移除）时拒绝刷新
+	if err := s.sessions().TouchSession(ctx, user.ID, claims.FamilyID); err != nil {
+		return nil, invalidGrant
+	}
+
+	return s.issueTokens(user, client, claims.FamilyID, claims.Scope, "", 0)
+}
+
+// issueTokens 签发访问令牌、刷新令牌及ID令牌
+func (s *oauthServiceImpl) issueTokens(user *models.User, client *models.OAuthClient, familyID, scope, nonce string, authTime int64) (*OAuthTokenResponse, error) {
+	now := time.Now()
+	audience := jwt.ClaimStrings{client.ClientID}
+
+	accessToken, err := s.jwtService.GenerateTokenWithClaims(&JWTClaims{
+		UserID:   user.ID,
+		Username: user.Username,
+		TenantID: user.TenantID,
+		Type:     TokenTypeOAuthAccess,
+		FamilyID: familyID,
+		ClientID: client.ClientID,
+		Scope:    scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.AccessTokenTTL)),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成访问令牌失败: %w", err)
+	}
+
+	refreshToken, err := s.jwtService.GenerateTokenWithClaims(&JWTClaims{
+		UserID:   user.ID,
+		Username: user.Username,
+		TenantID: user.TenantID,
+		Type:     TokenTypeOAuthRefresh,
+		FamilyID: familyID,
+		ClientID: client.ClientID,
+		Scope:    scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience: audience,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成刷新令牌失败: %w", err)
+	}
+
+	resp := &OAuthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.cfg.AccessTokenTTL / time.Second),
+		RefreshToken: refreshToken,
+		Scope:        scope,
+	}
+
+	scopes := strings.Fields(scope)
+	if containsString(scopes, models.OAuthScopeOpenID) {
+		// 已授权openid但随后停用OIDC时，不再使用其他密钥签发ID令牌
+		if s.idTokenKeys == nil {
+			return nil, newOAuthError(http.StatusBadRequest, "invalid_scope", "未启用OIDC，不能签发ID令牌")
+		}
+		jti, err := NewTokenID()
+		if err != nil {
+			return nil, fmt.Errorf("生成令牌ID失败: %w", err)
+		}
+		idToken, err := s.idTokenKeys.sign(&IDTokenClaims{
+			Nonce:          nonce,
+			AuthTime:       authTime,
+			AtHash:         accessTokenHash(accessToken),
+			OIDCUserClaims: mapUserClaims(user, scopes),
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        jti,
+				Issuer:    s.cfg.Issuer,
+				Subject:   strconv.FormatUint(user.ID, 10),
+				Audience:  audience,
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.AccessTokenTTL)),
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("生成ID令牌失败: %w", err)
+		}
+		resp.IDToken = idToken
+	}
+
+	return resp, nil
+}
+
+// UserInfo 根据访问令牌获取用户信息
+func (s *oauthServiceImpl) UserInfo(ctx context.Context, accessToken string) (*UserInfoResponse, error) {
+	invalidToken := newOAuthError(http.StatusUnauthorized, "invalid_token", "访问令牌无效或已过期")
+
+	claims, err := s.jwtService.ValidateToken(accessToken)
+	if err != nil || claims.Type != TokenTypeOAuthAccess {
+		return nil, invalidToken
+	}
+
+	revoked, err := s.jwtService.IsTokenRevoked(ctx, claims)
+	if err != nil || revoked {
+		return nil, invalidToken
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	if !containsString(scopes, models.OAuthScopeOpenID) {
+		return nil, newOAuthError(http.StatusForbidden, "insufficient_scope", "需要openid授权范围")
+	}
+
+	user, err := s.activeUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, invalidToken
+	}
+
+	return &UserInfoResponse{
+		Subject:        strconv.FormatUint(user.ID, 10),
+		OIDCUserClaims: mapUserClaims(user, scopes),
+	}, nil
+}
+
+// Discovery 获取OIDC发现文档
+func (s *oauthServiceImpl) Discovery() *OIDCDiscovery {
+	if s.idTokenKeys == nil {
+		return nil
+	}
+
+	authorizationEndpoint := s.cfg.AuthorizationPageURL
+	if authorizationEndpoint == "" {
+		authorizationEndpoint = s.cfg.Issuer + "/api/v1/oauth/authorize"
+	}
+
+	return &OIDCDiscovery{
+		Issuer:                            s.cfg.Issuer,
+		AuthorizationEndpoint:             authorizationEndpoint,
+		TokenEndpoint:                     s.cfg.Issuer + "/api/v1/oauth/token",
+		UserInfoEndpoint:                  s.cfg.Issuer + "/api/v1/userinfo",
+		JWKSURI:                           s.cfg.Issuer + "/.well-known/oidc-jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.idTokenKeys.method.Alg()},
+		ScopesSupported:                   oauthSupportedScopes,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "preferred_username", "picture", "updated_at",
+			"email", "email_verified", "phone_number",
+		},
+	}
+}
+
+// JWKS 获取ID令牌验证公钥集合
+func (s *oauthServiceImpl) JWKS() *JWKSet {
+	if s.idTokenKeys == nil {
+		return nil
+	}
+	return s.idTokenKeys.jwks()
+}
+
+// validateAuthorizeRequest 校验授权请求，返回应用及规范化后的授权范围
+func (s *oauthServiceImpl) validateAuthorizeRequest(ctx context.Context, userID uint64, req *AuthorizeRequest) (*models.OAuthClient, []string, error) {
+	var client models.OAuthClient
+	if err := s.db.WithContext(ctx).Where("client_id = ?", req.ClientID).First(&client).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, common.ErrInvalidOAuthClient
+		}
+		return nil, nil, fmt.Errorf("查询应用失败: %w", err)
+	}
+	if !client.IsActive() {
+		return nil, nil, common.ErrInvalidOAuthClient
+	}
+
+	// 应用只能由所属租户的会员授权
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("id", "tenant_id").First(&user, userID).Error; err != nil {
+		return nil, nil, common.ErrUserNotFound
+	}
+	if user.TenantID != client.TenantID {
+		return nil, nil, common.ErrInvalidOAuthClient
+	}
+
+	if !client.AllowsRedirectURI(req.RedirectURI) {
+		return nil, nil, common.ErrInvalidRedirectURI
+	}
+	if req.ResponseType != "code" {
+		return nil, nil, common.ErrUnsupportedResponseType
+	}
+
+	// 所有应用都必须使用PKCE，只支持S256
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) < 43 || len(req.CodeChallenge) > 128 {
+		return nil, nil, common.ErrPKCERequired
+	}
+
+	allowed := client.ScopeList()
+	var scopes []string
+	for _, scope := range strings.Fields(req.Scope) {
+		if !containsString(allowed, scope) || !containsString(s.supportedScopes(), scope) {
+			return nil, nil, common.ErrInvalidOAuthScope
+		}
+		if !containsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, nil, common.ErrInvalidOAuthScope
+	}
+
+	return &client, scopes, nil
+}
+
+// hasConsent 检查会员是否已授权全部申请的范围
+func (s *oauthServiceImpl) hasConsent(ctx context.Context, userID uint64, clientID string, scopes []string) (bool, error) {
+	var consent models.OAuthConsent
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND client_id = ?", userID, clientID).
+		First(&consent).Error
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("查询授权记录失败: %w", err)
+	}
+
+	granted := strings.Fields(consent.Scopes)
+	for _, scope := range scopes {
+		if !containsString(granted, scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// saveConsent 保存授权记录，与已授权的范围合并
+func (s *oauthServiceImpl) saveConsent(ctx context.Context, userID uint64, client *models.OAuthClient, scopes []string) error {
+	var consent models.OAuthConsent
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND client_id = ?", userID, client.ClientID).
+		First(&consent).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("查询授权记录失败: %w", err)
+	}
+
+	granted := strings.Fields(consent.Scopes)
+	for _, scope := range scopes {
+		if !containsString(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	if consent.ID == 0 {
+		consent = models.OAuthConsent{UserID: userID, ClientID: client.ClientID, Scopes: strings.Join(granted, " ")}
+		consent.TenantID = client.TenantID
+		if err := s.db.WithContext(ctx).Create(&consent).Error; err != nil {
+			return fmt.Errorf("保存授权记录失败: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.db.WithContext(ctx).Model(&consent).Update("scopes", strings.Join(granted, " ")).Error; err != nil {
+		return fmt.Errorf("保存授权记录失败: %w", err)
+	}
+	return nil
+}
+
+// authenticateClient 验证令牌端点的应用身份
+// 机密客户端需提供密钥，公开客户端只需client_id（依赖PKCE保护授权码）
+func (s *oauthServiceImpl) authenticateClient(ctx context.Context, clientID, clientSecret string) (*models.OAuthClient, error) {
+	invalidClient := newOAuthError(http.StatusUnauthorized, "invalid_client", "应用身份验证失败")
+
+	if clientID == "" {
+		return nil, invalidClient
+	}
+
+	var client models.OAuthClient
+	if err := s.db.WithContext(ctx).Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, invalidClient
+		}
+		return nil, fmt.Errorf("查询应用失败: %w", err)
+	}
+	if !client.IsActive() {
+		return nil, invalidClient
+	}
+
+	if !client.Public {
+		if clientSecret == "" || !hmac.Equal([]byte(hashClientSecret(clientSecret)), []byte(client.SecretHash)) {
+			return nil, invalidClient
+		}
+	}
+
+	return &client, nil
+}
+
+// activeUser 获取有效会员
+func (s *oauthServiceImpl) activeUser(ctx context.Context, userID uint64) (*models.User, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "用户不存在")
+		}
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	if !user.IsActive() {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "用户已被禁用")
+	}
+	return &user, nil
+}
+
+// mapUserClaims 按授权范围将会员信息映射为标准声明
+func mapUserClaims(user *models.User, scopes []string) OIDCUserClaims {
+	var claims OIDCUserClaims
+
+	if containsString(scopes, models.OAuthScopeProfile) {
+		claims.Name = user.Nickname
+		claims.PreferredUsername = user.Username
+		claims.Picture = user.Avatar
+		claims.UpdatedAt = user.UpdatedAt.Unix()
+	}
+	if containsString(scopes, models.OAuthScopeEmail) && user.Email != "" {
+		verified := user.IsEmailVerified()
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+	if containsString(scopes, models.OAuthScopePhone) {
+		claims.PhoneNumber = user.Phone
+	}
+
+	return claims
+}
+
+// verifyPKCE 校验PKCE（S256）
+func verifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return hmac.Equal([]byte(expected), []byte(challenge))
+}
+
+// accessTokenHash 计算ID令牌的at_hash（访问令牌SHA256摘要左半部分）
+func accessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+// hashClientSecret 计算密钥摘要
+func hashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// appendQuery 向回调地址追加查询参数，忽略空值
+func appendQuery(rawURL string, params map[string]string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	query := parsed.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authorizeCode 按前端授权确认页的方式完成授权，返回授权码
+func authorizeCode(t *testing.T, service *oauthServiceImpl, userID uint64, req *AuthorizeRequest) string {
+	result, err := service.Authorize(context.Background(), userID, time.Now(), &ConsentRequest{AuthorizeRequest: *req, Approve: true})
+	require.NoError(t, err)
+
+	redirect, err := url.Parse(result.RedirectURI)
+	require.NoError(t, err)
+	assert.Equal(t, req.State, redirect.Query().Get("state"))
+	require.NotEmpty(t, redirect.Query().Get("code"))
+	return redirect.Query().Get("code")
+}
+
+// newTestOIDCKeys 生成ES256的ID令牌签名密钥
+func newTestOIDCKeys(t *testing.T) *jwtKeySet {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	require.NoError(t, err)
+	keyFile := writePEM(t, t.TempDir(), "oidc.pem", "EC PRIVATE KEY", der)
+
+	keys, err := loadOIDCKeySet(&jwtKeyConfig{Algorithm: "ES256", KeyID: "oidc-1", PrivateKeyFile: keyFile})
+	require.NoError(t, err)
+	return keys
+}
+
+func TestLoadOIDCKeySet(t *testing.T) {
+	// 未配置私钥时不启用OIDC
+	keys, err := loadOIDCKeySet(&jwtKeyConfig{Algorithm: "ES256"})
+	require.NoError(t, err)
+	assert.Nil(t, keys)
+
+	// 不允许使用HS256签发ID令牌
+	_, err = loadOIDCKeySet(&jwtKeyConfig{Algorithm: "HS256", Secret: "secret", KeyID: "oidc-1", PrivateKeyFile: "oidc.pem"})
+	assert.Error(t, err)
+	_, err = loadOIDCKeySet(&jwtKeyConfig{KeyID: "oidc-1", PrivateKeyFile: "oidc.pem"})
+	assert.Error(t, err)
+
+	keys = newTestOIDCKeys(t)
+	jwks := keys.jwks()
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "ES256", jwks.Keys[0].Alg)
+	assert.Equal(t, "oidc-1", jwks.Keys[0].Kid)
+}
+
+func TestOAuthService_OIDCDisabled(t *testing.T) {
+	config.Init()
+	logger.Init()
+	db := setupTestDB(t)
+	service := &oauthServiceImpl{db: db, jwtService: NewJWTService(), cache: cache.NewMemoryCache(), cfg: LoadOAuthConfig()}
+	ctx := context.Background()
+
+	assert.Nil(t, service.Discovery())
+	assert.Nil(t, service.JWKS())
+
+	// 未启用OIDC时不能申请openid范围
+	_, err := service.CreateClient(ctx, &CreateOAuthClientRequest{
+		Name:         "合作方商城",
+		RedirectURIs: []string{"https://h5.example.com/callback"},
+		Scopes:       []string{models.OAuthScopeOpenID},
+	}, 1)
+	assert.Error(t, err)
+
+	credentials, err := service.CreateClient(ctx, &CreateOAuthClientRequest{
+		Name:         "合作方商城",
+		RedirectURIs: []string{"https://h5.example.com/callback"},
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.OAuthScopeProfile, credentials.Client.Scopes)
+}
+
+func TestOAuthService_AuthorizationCodeFlow(t *testing.T) {
+	config.Init()
+	logger.Init()
+	db := setupTestDB(t)
+	jwtService := NewJWTService()
+	idTokenKeys := newTestOIDCKeys(t)
+	service := &oauthServiceImpl{db: db, jwtService: jwtService, idTokenKeys: idTokenKeys, cache: cache.NewMemoryCache(), cfg: LoadOAuthConfig()}
+	ctx := context.Background()
+
+	user := &models.User{Username: "oauthuser", Password: "x", Nickname: "小明", Email: "oauth@example.com", Phone: "13800138000"}
+	require.NoError(t, db.Create(user).Error)
+
+	credentials, err := service.CreateClient(ctx, &CreateOAuthClientRequest{
+		Name:         "合作方商城",
+		RedirectURIs: []string{"https://h5.example.com/callback"},
+		Scopes:       []string{models.OAuthScopeOpenID, models.OAuthScopeProfile, models.OAuthScopeEmail},
+	}, 1)
+	require.NoError(t, err)
+	require.NotEmpty(t, credentials.ClientSecret)
+
+	verifier := strings.Repeat("v", 50)
+	sum := sha256.Sum256([]byte(verifier))
+	req := &AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            credentials.ClientID,
+		RedirectURI:         "https://h5.example.com/callback",
+		Scope:               "openid profile",
+		State:               "xyz",
+		Nonce:               "n-0S6_WzA2Mj",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+		CodeChallengeMethod: "S256",
+	}
+
+	// 参数校验
+	bad := *req
+	bad.RedirectURI = "https://evil.example.com/callback"
+	_, err = service.PrepareAuthorization(ctx, user.ID, &bad)
+	assert.Equal(t, common.ErrInvalidRedirectURI, err)
+	bad = *req
+	bad.CodeChallenge = ""
+	_, err = service.PrepareAuthorization(ctx, user.ID, &bad)
+	assert.Equal(t, common.ErrPKCERequired, err)
+	bad = *req
+	bad.Scope = "openid phone"
+	_, err = service.PrepareAuthorization(ctx, user.ID, &bad)
+	assert.Equal(t, common.ErrInvalidOAuthScope, err)
+
+	prompt, err := service.PrepareAuthorization(ctx, user.ID, req)
+	require.NoError(t, err)
+	assert.True(t, prompt.ConsentRequired)
+	assert.Equal(t, "合作方商城", prompt.Client.Name)
+
+	// 拒绝授权
+	denied, err := service.Authorize(ctx, user.ID, time.Now(), &ConsentRequest{AuthorizeRequest: *req})
+	require.NoError(t, err)
+	assert.Contains(t, denied.RedirectURI, "error=access_denied")
+
+	code := authorizeCode(t, service, user.ID, req)
+
+	// 已授权的范围无需再次确认
+	prompt, err = service.PrepareAuthorization(ctx, user.ID, req)
+	require.NoError(t, err)
+	assert.False(t, prompt.ConsentRequired)
+
+	tokenReq := &OAuthTokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		Code:         code,
+		RedirectURI:  req.RedirectURI,
+		CodeVerifier: verifier,
+		ClientID:     credentials.ClientID,
+		ClientSecret: credentials.ClientSecret,
+	}
+
+	// 密钥错误
+	wrongSecret := *tokenReq
+	wrongSecret.ClientSecret = "wrong"
+	_, err = service.Token(ctx, &wrongSecret)
+	assert.Equal(t, "invalid_client", err.(*OAuthError).Code)
+
+	tokens, err := service.Token(ctx, tokenReq)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, "openid profile", tokens.Scope)
+	require.NotEmpty(t, tokens.IDToken)
+
+	// ID令牌声明
+	idClaims := &IDTokenClaims{}
+	_, err = jwt.ParseWithClaims(tokens.IDToken, idClaims, idTokenKeys.keyFunc)
+	require.NoError(t, err)
+	_, err = jwt.ParseWithClaims(tokens.IDToken, &IDTokenClaims{}, jwtService.(*jwtServiceImpl).keys.keyFunc)
+	assert.Error(t, err, "ID令牌不应使用会员令牌密钥签名")
+	assert.Equal(t, "ES256", service.Discovery().IDTokenSigningAlgValuesSupported[0])
+	assert.Equal(t, strconv.FormatUint(user.ID, 10), idClaims.Subject)
+	assert.Equal(t, jwt.ClaimStrings{credentials.ClientID}, idClaims.Audience)
+	assert.Equal(t, req.Nonce, idClaims.Nonce)
+	assert.Equal(t, "小明", idClaims.Name)
+	assert.Empty(t, idClaims.Email, "未授权email范围不应包含邮箱")
+	assert.Equal(t, accessTokenHash(tokens.AccessToken), idClaims.AtHash)
+
+	// 用户信息
+	info, err := service.UserInfo(ctx, tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "oauthuser", info.PreferredUsername)
+
+	// OAuth访问令牌不能当作刷新令牌使用
+	_, err = service.Token(ctx, &OAuthTokenRequest{
+		GrantType:    GrantTypeRefreshToken,
+		RefreshToken: tokens.AccessToken,
+		ClientID:     credentials.ClientID,
+		ClientSecret: credentials.ClientSecret,
+	})
+	assert.Equal(t, "invalid_grant", err.(*OAuthError).Code)
+
+	// 刷新令牌轮换
+	refreshReq := &OAuthTokenRequest{
+		GrantType:    GrantTypeRefreshToken,
+		RefreshToken: tokens.RefreshToken,
+		ClientID:     credentials.ClientID,
+		ClientSecret: credentials.ClientSecret,
+	}
+	refreshed, err := service.Token(ctx, refreshReq)
+	require.NoError(t, err)
+	assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+
+	// 重复使用旧刷新令牌时吊销整个令牌族
+	_, err = service.Token(ctx, refreshReq)
+	assert.Equal(t, "invalid_grant", err.(*OAuthError).Code)
+	_, err = service.UserInfo(ctx, refreshed.AccessToken)
+	assert.Equal(t, "invalid_token", err.(*OAuthError).Code)
+
+	// 授权码只能使用一次
+	_, err = service.Token(ctx, tokenReq)
+	assert.Equal(t, "invalid_grant", err.(*OAuthError).Code)
+}
+
+func TestOAuthService_CodeReuseRevokesTokens(t *testing.T) {
+	config.Init()
+	logger.Init()
+	db := setupTestDB(t)
+	service := &oauthServiceImpl{db: db, jwtService: NewJWTService(), idTokenKeys: newTestOIDCKeys(t), cache: cache.NewMemoryCache(), cfg: LoadOAuthConfig()}
+	ctx := context.Background()
+
+	user := &models.User{Username: "oauthuser2", Password: "x"}
+	require.NoError(t, db.Create(user).Error)
+
+	// 公开客户端无需密钥
+	credentials, err := service.CreateClient(ctx, &CreateOAuthClientRequest{
+		Name:         "合作方H5",
+		RedirectURIs: []string{"https://h5.example.com/callback"},
+		Public:       true,
+	}, 1)
+	require.NoError(t, err)
+	assert.Empty(t, credentials.ClientSecret)
+
+	verifier := strings.Repeat("a", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	req := &AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            credentials.ClientID,
+		RedirectURI:         "https://h5.example.com/callback",
+		Scope:               "openid",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+		CodeChallengeMethod: "S256",
+	}
+	code := authorizeCode(t, service, user.ID, req)
+
+	tokenReq := &OAuthTokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		Code:         code,
+		RedirectURI:  req.RedirectURI,
+		CodeVerifier: strings.Repeat("b", 43),
+		ClientID:     credentials.ClientID,
+	}
+
+	// PKCE校验失败，授权码同时作废
+	_, err = service.Token(ctx, tokenReq)
+	assert.Equal(t, "invalid_grant", err.(*OAuthError).Code)
+
+	code = authorizeCode(t, service, user.ID, req)
+	tokenReq.Code = code
+	tokenReq.CodeVerifier = verifier
+	tokens, err := service.Token(ctx, tokenReq)
+	require.NoError(t, err)
+
+	var session models.UserSession
+	require.NoError(t, db.Where("user_id = ?", user.ID).First(&session).Error)
+	assert.Equal(t, models.LoginTypeOAuth, session.LoginType)
+
+	// 授权码被重复使用时吊销已签发的令牌
+	_, err = service.Token(ctx, tokenReq)
+	assert.Equal(t, "invalid_grant", err.(*OAuthError).Code)
+	_, err = service.UserInfo(ctx, tokens.AccessToken)
+	assert.Equal(t, "invalid_token", err.(*OAuthError).Code)
+}
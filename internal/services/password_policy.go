@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordPolicy 租户密码策略
+type PasswordPolicy struct {
+	utils.PasswordPolicy
+	MaxAge       time.Duration // 密码有效期，0为永不过期
+	HistoryCount int           // 禁止重复使用最近N次设置的密码，0为不限制
+}
+
+// LoadPasswordPolicy 从配置文件加载租户密码策略
+// 优先读取 password_policy.tenants.<租户>.<配置项>，未配置时读取 password_policy.<配置项>
+func LoadPasswordPolicy(tenantID string) *PasswordPolicy {
+	key := func(name string) string {
+		if tenantID != "" {
+			tenantKey := fmt.Sprintf("password_policy.tenants.%s.%s", tenantID, name)
+			if config.GetString(tenantKey) != "" {
+				return tenantKey
+			}
+		}
+		return "password_policy." + name
+	}
+
+	var classes []string
+	for _, class := range strings.Split(config.GetString(key("required_classes")), ",") {
+		if class = strings.TrimSpace(class); class != "" {
+			classes = append(classes, class)
+		}
+	}
+
+	return &PasswordPolicy{
+		PasswordPolicy: utils.PasswordPolicy{
+			MinLength:       config.GetInt(key("min_length")),
+			MaxLength:       config.GetInt(key("max_length")),
+			RequiredClasses: classes,
+		},
+		MaxAge:       time.Duration(config.GetInt(key("max_age_days"))) * 24 * time.Hour,
+		HistoryCount: config.GetInt(key("history_count")),
+	}
+}
+
+// Validate 验证密码是否符合长度及字符类型要求
+func (p *PasswordPolicy) Validate(password string) error {
+	if err := p.PasswordPolicy.Validate(password); err != nil {
+		return common.NewCustomError(common.CodeBadRequest, err.Error())
+	}
+	return nil
+}
+
+// IsExpired 检查用户密码是否已过期，从未修改过密码时以注册时间计算
+func (p *PasswordPolicy) IsExpired(user *models.User, now time.Time) bool {
+	if p.MaxAge <= 0 {
+		return false
+	}
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	return now.After(changedAt.Add(p.MaxAge))
+}
+
+// CheckReuse 检查新密码是否与当前密码或最近使用过的密码相同
+func (p *PasswordPolicy) CheckReuse(ctx context.Context, db *gorm.DB, user *models.User, password string) error {
+	if p.HistoryCount <= 0 {
+		return nil
+	}
+
+	if user.CheckPassword(password) {
+		return common.ErrPasswordReused
+	}
+
+	var histories []models.PasswordHistory
+	if err := db.WithContext(ctx).
+		Where("user_id = ?", user.ID).
+		Order("id DESC").
+		Limit(p.HistoryCount).
+		Find(&histories).Error; err != nil {
+		return fmt.Errorf("查询历史密码失败: %w", err)
+	}
+
+	for _, history := range histories {
+		if utils.CheckPassword(password, history.Password) {
+			return common.ErrPasswordReused
+		}
+	}
+
+	return nil
+}
+
+// Record 保存新设置的密码哈希，并清理超出保留数量的历史密码
+func (p *PasswordPolicy) Record(tx *gorm.DB, user *models.User, passwordHash string) error {
+	if p.HistoryCount <= 0 {
+		return nil
+	}
+
+	history := &models.PasswordHistory{UserID: user.ID, Password: passwordHash}
+	history.TenantID = user.TenantID
+	if err := tx.Create(history).Error; err != nil {
+		return fmt.Errorf("保存历史密码失败: %w", err)
+	}
+
+	// 保留最近N条，更早的记录不再参与检查
+	var keepIDs []uint64
+	if err := tx.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", user.ID).
+		Order("id DESC").
+		Limit(p.HistoryCount).
+		Pluck("id", &keepIDs).Error; err != nil {
+		return fmt.Errorf("查询历史密码失败: %w", err)
+	}
+	if err := tx.Unscoped().
+		Where("user_id = ? AND id NOT IN ?", user.ID, keepIDs).
+		Delete(&models.PasswordHistory{}).Error; err != nil {
+		return fmt.Errorf("清理历史密码失败: %w", err)
+	}
+
+	return nil
+}
+
+// passwordUpdates 设置新密码时需要更新的字段
+func passwordUpdates(passwordHash string, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"password":            passwordHash,
+		"password_changed_at": now,
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setPasswordPolicyConfig 临时修改密码策略配置，测试结束后恢复
+func setPasswordPolicyConfig(t *testing.T, values map[string]interface{}) {
+	for key, value := range values {
+		original := viper.Get(key)
+		viper.Set(key, value)
+		t.Cleanup(func() { viper.Set(key, original) })
+	}
+}
+
+func TestLoadPasswordPolicy(t *testing.T) {
+	config.Init()
+	setPasswordPolicyConfig(t, map[string]interface{}{
+		"password_policy.tenants.company1.min_length":       10,
+		"password_policy.tenants.company1.required_classes": "lower,upper,digit,special",
+		"password_policy.tenants.company1.max_age_days":     90,
+	})
+
+	// 默认策略
+	policy := LoadPasswordPolicy("default")
+	assert.Equal(t, 6, policy.MinLength)
+	assert.Equal(t, 64, policy.MaxLength)
+	assert.Equal(t, time.Duration(0), policy.MaxAge)
+	assert.NoError(t, policy.Validate("password123"))
+	assert.Error(t, policy.Validate("password"))
+
+	// 租户覆盖的配置项生效，未覆盖的沿用默认配置
+	policy = LoadPasswordPolicy("company1")
+	assert.Equal(t, 10, policy.MinLength)
+	assert.Equal(t, 64, policy.MaxLength)
+	assert.Equal(t, 90*24*time.Hour, policy.MaxAge)
+
+	tests := []struct {
+		password string
+		valid    bool
+	}{
+		{"Pass12!", false},      // 长度不足
+		{"password123!", false}, // 缺少大写字母
+		{"PASSWORD123!", false}, // 缺少小写字母
+		{"Password!!!!", false}, // 缺少数字
+		{"Password1234", false}, // 缺少特殊字符
+		{"Password123!", true},
+	}
+	for _, tt := range tests {
+		err := policy.Validate(tt.password)
+		if tt.valid {
+			assert.NoError(t, err, tt.password)
+		} else {
+			require.Error(t, err, tt.password)
+			customErr, ok := err.(*common.CustomError)
+			require.True(t, ok)
+			assert.Equal(t, common.CodeBadRequest, customErr.Code)
+		}
+	}
+}
+
+func TestUserService_ChangePasswordHistory(t *testing.T) {
+	config.Init()
+	setPasswordPolicyConfig(t, map[string]interface{}{"password_policy.history_count": 2})
+	db := setupTestDB(t)
+	service := &userServiceImpl{db: db, jwtService: NewJWTService(), cache: cache.NewMemoryCache()}
+	ctx := context.Background()
+
+	user, err := service.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password1",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, user.PasswordChangedAt)
+
+	change := func(oldPassword, newPassword string) error {
+		return service.ChangePassword(ctx, user.ID, &ChangePasswordRequest{OldPassword: oldPassword, NewPassword: newPassword})
+	}
+
+	// 不能使用当前密码
+	assert.Equal(t, common.ErrPasswordReused, change("password1", "password1"))
+
+	require.NoError(t, change("password1", "password2"))
+	// 最近两次设置的密码均不能使用
+	assert.Equal(t, common.ErrPasswordReused, change("password2", "password1"))
+
+	require.NoError(t, change("password2", "password3"))
+	// 仅保留最近两次，更早的密码可以再次使用
+	var count int64
+	db.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).Count(&count)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, common.ErrPasswordReused, change("password3", "password2"))
+	assert.NoError(t, change("password3", "password1"))
+}
+
+func TestUserService_LoginPasswordExpired(t *testing.T) {
+	config.Init()
+	setPasswordPolicyConfig(t, map[string]interface{}{"password_policy.max_age_days": 30})
+	service, userService, mailer := setupTestEmailService(t)
+	ctx := context.Background()
+
+	user, err := userService.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+
+	_, err = userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "password123"})
+	require.NoError(t, err)
+
+	// 超过有效期后提示密码已过期
+	expired := time.Now().AddDate(0, 0, -31)
+	require.NoError(t, service.db.Model(&models.User{}).Where("id = ?", user.ID).
+		Update("password_changed_at", expired).Error)
+	_, err = userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "password123"})
+	assert.Equal(t, common.ErrPasswordExpired, err)
+
+	// 密码错误时仍提示密码错误，不暴露密码是否过期
+	_, err = userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "wrongpassword1"})
+	assert.Equal(t, common.ErrInvalidPassword, err)
+
+	// 通过找回密码设置新密码后可以登录
+	require.NoError(t, service.ForgotPassword(ctx, "test@example.com"))
+	token := lastMailToken(t, mailer, "test@example.com")
+	require.NoError(t, service.ResetPassword(ctx, &ResetPasswordRequest{Token: token, NewPassword: "newpassword123"}))
+	_, err = userService.Login(ctx, &LoginRequest{Account: "testuser", Password: "newpassword123"})
+	assert.NoError(t, err)
+}
+
+func TestEmailService_ResetPasswordHistory(t *testing.T) {
+	config.Init()
+	setPasswordPolicyConfig(t, map[string]interface{}{"password_policy.history_count": 3})
+	service, userService, mailer := setupTestEmailService(t)
+	ctx := context.Background()
+
+	_, err := userService.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, service.ForgotPassword(ctx, "test@example.com"))
+	token := lastMailToken(t, mailer, "test@example.com")
+
+	// 重置为最近使用过的密码被拒绝，链接仍可使用
+	err = service.ResetPassword(ctx, &ResetPasswordRequest{Token: token, NewPassword: "password123"})
+	assert.Equal(t, common.ErrPasswordReused, err)
+	assert.NoError(t, service.ResetPassword(ctx, &ResetPasswordRequest{Token: token, NewPassword: "newpassword123"}))
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"member-link-lite/config"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PointsTransferService 积分转账服务接口
+type PointsTransferService interface {
+	// 会员向同一租户的其他会员转账积分，返回转账记录及转出会员的积分记录；幂等键重复时返回首次的转账结果
+	Transfer(ctx context.Context, fromUserID uint64, req *TransferPointsRequest) (*PointsTransferResult, error)
+}
+
+// TransferPointsRequest 积分转账请求
+// @Description 会员向同一租户的其他会员转账积分，收款会员通过会员ID或手机号指定
+type TransferPointsRequest struct {
+	ToUserID uint64 `json:"to_user_id" example:"2" description:"收款会员ID，与to_phone二选一"`
+	ToPhone  string `json:"to_phone" example:"13800138000" description:"收款会员手机号，与to_user_id二选一"`
+	Amount   int64  `json:"amount" binding:"required,min=1,max=100000000" example:"100" description:"转账积分，即收款会员到账的积分，单笔最多1亿，手续费由转出会员额外支付"`
+	Remark   string `json:"remark" binding:"max=255" example:"转给好友" description:"备注说明（可选）"`
+	// 幂等键，同一租户内唯一，重试时使用相同的幂等键及参数可避免重复转账
+	IdempotencyKey string `json:"idempotency_key" binding:"omitempty,max=64" example:"TRANSFER20240101001" description:"幂等键（可选），相同幂等键及参数的重复请求返回首次的转账结果，参数不同则拒绝"`
+}
+
+// PointsTransferResult 积分转账结果
+type PointsTransferResult struct {
+	Transfer  *models.PointsTransfer `json:"transfer"`             // 转账记录
+	Record    *models.PointsRecord   `json:"record"`               // 转出会员的转账积分记录，扣减数量为到账积分
+	FeeRecord *models.PointsRecord   `json:"fee_record,omitempty"` // 转出会员的手续费积分记录，未收取手续费时为空
+}
+
+// maxTransferAmount 单笔转账的最大积分
+const maxTransferAmount = 100000000
+
+// pointsTransferServiceImpl 积分转账服务实现
+type pointsTransferServiceImpl struct {
+	db           *gorm.DB
+	dailyLimit   int64         // 每日最多转出积分，0为不限制
+	dailyCount   int64         // 每日最多转账次数，0为不限制
+	minPointsAge time.Duration // 积分获得后需持有的时长，0为不限制
+	feeRate      float64       // 手续费比例，0为不收取
+}
+
+// NewPointsTransferService 创建积分转账服务实例
+func NewPointsTransferService() PointsTransferService {
+	return &pointsTransferServiceImpl{
+		db:           database.GetDB(),
+		dailyLimit:   int64(config.GetInt("points_transfer.daily_limit")),
+		dailyCount:   int64(config.GetInt("points_transfer.daily_count")),
+		minPointsAge: time.Duration(config.GetInt("points_transfer.min_points_age_hours")) * time.Hour,
+		feeRate:      config.GetFloat64("points_transfer.fee_rate"),
+	}
+}
+
+// Transfer 积分转账
+// 扣减转出会员积分、增加收款会员积分并写入转账记录、成对的积分记录及手续费记录在同一事务中完成
+// 提供幂等键时，相同幂等键及参数的重复请求直接返回首次的转账结果，参数不同则返回ErrIdempotencyConflict
+func (s *pointsTransferServiceImpl) Transfer(ctx context.Context, fromUserID uint64, req *TransferPointsRequest) (*PointsTransferResult, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("转账积分必须大于0")
+	}
+	if req.Amount > maxTransferAmount {
+		return nil, common.ErrTransferAmountTooLarge
+	}
+	if len(req.IdempotencyKey) > 64 {
+		return nil, fmt.Errorf("幂等键长度不能超过64个字符")
+	}
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	requestHash := idempotencyHash(fromUserID, req.ToUserID, req.ToPhone, req.Amount, req.Remark)
+	if req.IdempotencyKey != "" {
+		if result, err := s.findTransferByKey(ctx, tenantID, req.IdempotencyKey, requestHash); err != nil || result != nil {
+			return result, err
+		}
+	}
+
+	toUserID, err := s.resolveRecipient(ctx, tenantID, req)
+	if err != nil {
+		return nil, err
+	}
+	if toUserID == fromUserID {
+		return nil, common.ErrTransferToSelf
+	}
+
+	fee := s.calculateFee(req.Amount)
+	if fee > math.MaxInt64-req.Amount {
+		return nil, common.ErrTransferAmountTooLarge
+	}
+	total := req.Amount + fee
+	now := time.Now()
+
+	result := &PointsTransferResult{}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 按ID顺序锁定两个会员，避免相互转账时死锁
+		// SQLite驱动忽略行锁子句，由数据库级写锁保证事务串行
+		users := make(map[uint64]*models.User, 2)
+		ids := []uint64{fromUserID, toUserID}
+		if ids[0] > ids[1] {
+			ids[0], ids[1] = ids[1], ids[0]
+		}
+		for _, id := range ids {
+			var user models.User
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Scopes(models.ScopeByTenant(tenantID)).
+				First(&user, id).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					if id == toUserID {
+						return common.ErrTransferRecipientNotFound
+					}
+					return common.ErrUserNotFound
+				}
+				return fmt.Errorf("查询用户失败: %w", err)
+			}
+			users[id] = &user
+		}
+		sender, recipient := users[fromUserID], users[toUserID]
+
+		// 仅激活的会员可以转出及收款，临时锁定到期后可正常转账
+		if !sender.IsActive() && !sender.IsLockExpired(now) {
+			return common.ErrTransferSenderInactive
+		}
+		if !recipient.IsActive() && !recipient.IsLockExpired(now) {
+			return common.ErrTransferRecipientInactive
+		}
+
+		if err := s.checkDailyLimit(tx, tenantID, fromUserID, req.Amount, now); err != nil {
+			return err
+		}
+
+		if sender.Points < total {
+			return common.ErrInsufficientPoints
+		}
+		if s.minPointsAge > 0 {
+			// 持有时长不足的积分不可转出：扣除转账后的积分不能少于最近获得的积分
+			var recent int64
+			if err := tx.Model(&models.PointsRecord{}).
+				Scopes(models.ScopeByTenant(tenantID), models.ScopePointsByUserID(fromUserID), models.ScopeActive).
+				Where("quantity > 0 AND created_at > ?", now.Add(-s.minPointsAge)).
+				Select("COALESCE(SUM(quantity), 0)").
+				Scan(&recent).Error; err != nil {
+				return fmt.Errorf("统计近期获得积分失败: %w", err)
+			}
+			if sender.Points-recent < total {
+				return common.ErrTransferPointsTooNew
+			}
+		}
+
+		transfer := &models.PointsTransfer{
+			FromUserID:     fromUserID,
+			ToUserID:       toUserID,
+			Amount:         req.Amount,
+			Fee:            fee,
+			Remark:         req.Remark,
+			IdempotencyKey: optionalString(req.IdempotencyKey),
+			RequestHash:    requestHash,
+		}
+		transfer.TenantID = tenantID
+		if err := tx.Create(transfer).Error; err != nil {
+			return fmt.Errorf("创建转账记录失败: %w", err)
+		}
+
+		sender, err := applyUserAssetDelta(tx, tenantID, fromUserID, "points", -total, common.ErrInsufficientPoints)
+		if err != nil {
+			return err
+		}
+		recipient, err = applyUserAssetDelta(tx, tenantID, toUserID, "points", req.Amount, common.ErrTransferRecipientNotFound)
+		if err != nil {
+			return err
+		}
+
+		outRemark, inRemark := req.Remark, req.Remark
+		if outRemark == "" {
+			outRemark = fmt.Sprintf("转账给会员%d", toUserID)
+			inRemark = fmt.Sprintf("会员%d转入", fromUserID)
+		}
+		out := &models.PointsRecord{
+			UserID:      fromUserID,
+			Quantity:    -req.Amount,
+			Type:        models.PointsTypeTransfer,
+			Remark:      outRemark,
+			PointsAfter: sender.Points + fee,
+			TransferID:  transfer.ID,
+		}
+		in := &models.PointsRecord{
+			UserID:      toUserID,
+			Quantity:    req.Amount,
+			Type:        models.PointsTypeTransfer,
+			Remark:      inRemark,
+			PointsAfter: recipient.Points,
+			TransferID:  transfer.ID,
+		}
+		out.TenantID, in.TenantID = tenantID, tenantID
+		records := []*models.PointsRecord{out, in}
+
+		// 手续费单独记一条转出会员的手续费记录，与转账记录区分统计
+		if fee > 0 {
+			result.FeeRecord = &models.PointsRecord{
+				UserID:      fromUserID,
+				Quantity:    -fee,
+				Type:        models.PointsTypeTransferFee,
+				Remark:      fmt.Sprintf("转账手续费，到账积分%d", req.Amount),
+				PointsAfter: sender.Points,
+				TransferID:  transfer.ID,
+			}
+			result.FeeRecord.TenantID = tenantID
+			records = append(records, result.FeeRecord)
+		}
+		if err := tx.Create(records).Error; err != nil {
+			return fmt.Errorf("创建积分变动记录失败: %w", err)
+		}
+
+		result.Transfer, result.Record = transfer, out
+		return nil
+	})
+	if err != nil {
+		// 并发的重复请求已先提交时，唯一索引拒绝本次写入，返回已提交的转账结果
+		if req.IdempotencyKey != "" {
+			if existing, findErr := s.findTransferByKey(ctx, tenantID, req.IdempotencyKey, requestHash); existing != nil || errors.Is(findErr, common.ErrIdempotencyConflict) {
+				return existing, findErr
+			}
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// findTransferByKey 按幂等键查询当前租户的转账记录及转出会员的积分记录
+// 记录不存在时返回nil；参数摘要不一致说明幂等键被不同的请求使用，返回ErrIdempotencyConflict
+func (s *pointsTransferServiceImpl) findTransferByKey(ctx context.Context, tenantID, key, requestHash string) (*PointsTransferResult, error) {
+	var transfer models.PointsTransfer
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(tenantID)).
+		Where("idempotency_key = ?", key).
+		First(&transfer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询转账记录失败: %w", err)
+	}
+	if transfer.RequestHash != requestHash {
+		return nil, common.ErrIdempotencyConflict
+	}
+
+	var records []models.PointsRecord
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(tenantID), models.ScopePointsByUserID(transfer.FromUserID)).
+		Where("transfer_id = ?", transfer.ID).
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询积分变动记录失败: %w", err)
+	}
+
+	result := &PointsTransferResult{Transfer: &transfer}
+	for i := range records {
+		if records[i].Type == models.PointsTypeTransferFee {
+			result.FeeRecord = &records[i]
+		} else {
+			result.Record = &records[i]
+		}
+	}
+	return result, nil
+}
+
+// resolveRecipient 获取收款会员ID，未指定会员ID时按手机号查询当前租户的会员
+func (s *pointsTransferServiceImpl) resolveRecipient(ctx context.Context, tenantID string, req *TransferPointsRequest) (uint64, error) {
+	if req.ToUserID > 0 {
+		return req.ToUserID, nil
+	}
+	if req.ToPhone == "" {
+		return 0, common.ErrTransferRecipientRequired
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(tenantID)).
+		Where("phone = ?", req.ToPhone).
+		First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, common.ErrTransferRecipientNotFound
+		}
+		return 0, fmt.Errorf("查询收款会员失败: %w", err)
+	}
+	return user.ID, nil
+}
+
+// checkDailyLimit 检查转出会员当日的转账次数及转出积分是否超过限额
+func (s *pointsTransferServiceImpl) checkDailyLimit(tx *gorm.DB, tenantID string, fromUserID uint64, amount int64, now time.Time) error {
+	if s.dailyLimit <= 0 && s.dailyCount <= 0 {
+		return nil
+	}
+
+	var today struct {
+		Count  int64
+		Amount int64
+	}
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if err := tx.Model(&models.PointsTransfer{}).
+		Scopes(models.ScopeByTenant(tenantID)).
+		Where("from_user_id = ? AND created_at >= ?", fromUserID, startOfDay).
+		Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Scan(&today).Error; err != nil {
+		return fmt.Errorf("统计当日转账失败: %w", err)
+	}
+
+	if s.dailyCount > 0 && today.Count >= s.dailyCount {
+		return common.ErrTransferDailyLimit
+	}
+	if s.dailyLimit > 0 && today.Amount+amount > s.dailyLimit {
+		return common.ErrTransferDailyLimit
+	}
+	return nil
+}
+
+// calculateFee 按手续费比例计算手续费，向上取整；超出int64范围时返回math.MaxInt64
+func (s *pointsTransferServiceImpl) calculateFee(amount int64) int64 {
+	if s.feeRate <= 0 {
+		return 0
+	}
+	// 减去极小值，避免浮点误差导致整数结果被多取整
+	fee := math.Ceil(float64(amount)*s.feeRate - 1e-9)
+	if fee >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(fee)
+}
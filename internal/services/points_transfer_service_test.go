@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"math"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupPointsTransferService 创建积分转账服务，会员0持有2天前获得的积分
+func setupPointsTransferService(t *testing.T, count int, points int64) (*pointsTransferServiceImpl, []*models.User) {
+	userService, users := setupMemberService(t, count)
+	assets := NewAssetService(userService.db)
+	_, err := assets.ChangePoints(context.Background(), &ChangePointsRequest{UserID: users[0].ID, Quantity: points, Type: models.PointsTypeObtain})
+	require.NoError(t, err)
+	require.NoError(t, userService.db.Model(&models.PointsRecord{}).
+		Where("user_id = ?", users[0].ID).
+		Update("created_at", time.Now().Add(-48*time.Hour)).Error)
+
+	service := &pointsTransferServiceImpl{
+		db:           userService.db,
+		dailyLimit:   1000,
+		dailyCount:   3,
+		minPointsAge: 24 * time.Hour,
+		feeRate:      0.05,
+	}
+	return service, users
+}
+
+// userPoints 查询会员当前积分
+func userPoints(t *testing.T, service *pointsTransferServiceImpl, userID uint64) int64 {
+	var user models.User
+	require.NoError(t, service.db.First(&user, userID).Error)
+	return user.Points
+}
+
+func TestPointsTransferService_Transfer(t *testing.T) {
+	service, users := setupPointsTransferService(t, 3, 2000)
+	ctx := context.Background()
+
+	result, err := service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[1].ID, Amount: 100, Remark: "生日礼物"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), result.Transfer.Amount)
+	assert.Equal(t, int64(5), result.Transfer.Fee)
+	assert.Equal(t, int64(-100), result.Record.Quantity)
+	assert.Equal(t, int64(1900), result.Record.PointsAfter)
+	require.NotNil(t, result.FeeRecord)
+	assert.Equal(t, int64(-5), result.FeeRecord.Quantity)
+	assert.Equal(t, int64(1895), result.FeeRecord.PointsAfter)
+	assert.Equal(t, int64(1895), userPoints(t, service, users[0].ID))
+	assert.Equal(t, int64(100), userPoints(t, service, users[1].ID))
+
+	// 转出、转入及手续费记录通过转账ID关联
+	var records []models.PointsRecord
+	require.NoError(t, service.db.Where("transfer_id = ?", result.Transfer.ID).Order("id ASC").Find(&records).Error)
+	require.Len(t, records, 3)
+	assert.Equal(t, models.PointsTypeTransfer, records[0].Type)
+	assert.Equal(t, users[0].ID, records[0].UserID)
+	assert.True(t, records[0].IsExpense())
+	assert.Equal(t, users[1].ID, records[1].UserID)
+	assert.Equal(t, int64(100), records[1].Quantity)
+	assert.Equal(t, "转入", records[1].GetTypeDescription())
+	assert.Equal(t, models.PointsTypeTransferFee, records[2].Type)
+	assert.Equal(t, users[0].ID, records[2].UserID)
+	assert.True(t, records[2].IsExpense())
+
+	// 按手机号指定收款会员，手续费向上取整
+	result, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToPhone: users[2].Phone, Amount: 30})
+	require.NoError(t, err)
+	assert.Equal(t, users[2].ID, result.Transfer.ToUserID)
+	assert.Equal(t, int64(2), result.Transfer.Fee)
+
+	// 当日转出积分超过限额
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[1].ID, Amount: 900})
+	assert.Equal(t, common.ErrTransferDailyLimit, err)
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[1].ID, Amount: 800})
+	require.NoError(t, err)
+
+	// 当日转账次数超过限额
+	service.dailyLimit = 0
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[1].ID, Amount: 10})
+	assert.Equal(t, common.ErrTransferDailyLimit, err)
+
+	// 收到的积分持有时长不足，不能转出
+	service.dailyCount = 0
+	_, err = service.Transfer(ctx, users[1].ID, &TransferPointsRequest{ToUserID: users[2].ID, Amount: 50})
+	assert.Equal(t, common.ErrTransferPointsTooNew, err)
+	service.minPointsAge = 0
+	_, err = service.Transfer(ctx, users[1].ID, &TransferPointsRequest{ToUserID: users[2].ID, Amount: 50})
+	require.NoError(t, err)
+}
+
+func TestPointsTransferService_Rejected(t *testing.T) {
+	service, users := setupPointsTransferService(t, 3, 500)
+	ctx := context.Background()
+
+	_, err := service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[0].ID, Amount: 10})
+	assert.Equal(t, common.ErrTransferToSelf, err)
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{Amount: 10})
+	assert.Equal(t, common.ErrTransferRecipientRequired, err)
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: 9999, Amount: 10})
+	assert.Equal(t, common.ErrTransferRecipientNotFound, err)
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToPhone: "13900000000", Amount: 10})
+	assert.Equal(t, common.ErrTransferRecipientNotFound, err)
+
+	// 积分不足（含手续费）
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[1].ID, Amount: 480})
+	assert.Equal(t, common.ErrInsufficientPoints, err)
+
+	// 待审核、禁用及锁定中的会员不能收款
+	require.NoError(t, service.db.Model(users[1]).Update("status", models.UserStatusPending).Error)
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[1].ID, Amount: 10})
+	assert.Equal(t, common.ErrTransferRecipientInactive, err)
+	require.NoError(t, service.db.Model(users[1]).Update("status", models.UserStatusDisabled).Error)
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[1].ID, Amount: 10})
+	assert.Equal(t, common.ErrTransferRecipientInactive, err)
+
+	lockedUntil := time.Now().Add(time.Hour)
+	require.NoError(t, service.db.Model(users[2]).Updates(map[string]interface{}{
+		"status":       models.UserStatusLocked,
+		"locked_until": lockedUntil,
+	}).Error)
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[2].ID, Amount: 10})
+	assert.Equal(t, common.ErrTransferRecipientInactive, err)
+
+	// 临时锁定到期后可正常收款
+	require.NoError(t, service.db.Model(users[2]).Update("locked_until", time.Now().Add(-time.Minute)).Error)
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[2].ID, Amount: 10})
+	require.NoError(t, err)
+
+	// 不能转账给其他租户的会员
+	other := &models.User{Username: "other", Phone: "13900139000", Email: "other@example.com", Password: "x"}
+	other.TenantID = "company1"
+	require.NoError(t, service.db.Create(other).Error)
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: other.ID, Amount: 10})
+	assert.Equal(t, common.ErrTransferRecipientNotFound, err)
+
+	// 被禁用的会员不能转出
+	require.NoError(t, service.db.Model(users[0]).Update("status", models.UserStatusDisabled).Error)
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[2].ID, Amount: 10})
+	assert.Equal(t, common.ErrTransferSenderInactive, err)
+
+	// 失败的转账不扣减积分
+	assert.Equal(t, int64(500-10-1), userPoints(t, service, users[0].ID))
+	var transfers int64
+	service.db.Model(&models.PointsTransfer{}).Count(&transfers)
+	assert.Equal(t, int64(1), transfers)
+}
+
+func TestPointsTransferService_Idempotency(t *testing.T) {
+	service, users := setupPointsTransferService(t, 2, 1000)
+	ctx := context.Background()
+
+	req := &TransferPointsRequest{ToUserID: users[1].ID, Amount: 100, IdempotencyKey: "transfer-001"}
+	first, err := service.Transfer(ctx, users[0].ID, req)
+	require.NoError(t, err)
+
+	// 重复请求返回首次的转账结果，不重复扣减积分
+	second, err := service.Transfer(ctx, users[0].ID, req)
+	require.NoError(t, err)
+	assert.Equal(t, first.Transfer.ID, second.Transfer.ID)
+	assert.Equal(t, first.Record.ID, second.Record.ID)
+	require.NotNil(t, second.FeeRecord)
+	assert.Equal(t, first.FeeRecord.ID, second.FeeRecord.ID)
+	assert.Equal(t, int64(895), userPoints(t, service, users[0].ID))
+	assert.Equal(t, int64(100), userPoints(t, service, users[1].ID))
+
+	// 参数不同的请求使用相同的幂等键被拒绝
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[1].ID, Amount: 200, IdempotencyKey: "transfer-001"})
+	assert.Equal(t, common.ErrIdempotencyConflict, err)
+
+	var transfers int64
+	service.db.Model(&models.PointsTransfer{}).Count(&transfers)
+	assert.Equal(t, int64(1), transfers)
+}
+
+func TestPointsTransferService_AmountLimit(t *testing.T) {
+	service, users := setupPointsTransferService(t, 2, 1000)
+	service.dailyLimit, service.dailyCount = 0, 0
+	ctx := context.Background()
+
+	// 未限制每日转账时，单笔转账积分仍有上限
+	_, err := service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[1].ID, Amount: math.MaxInt64})
+	assert.Equal(t, common.ErrTransferAmountTooLarge, err)
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[1].ID, Amount: maxTransferAmount + 1})
+	assert.Equal(t, common.ErrTransferAmountTooLarge, err)
+
+	// 手续费溢出时拒绝转账，不会使扣减数量变为负数
+	service.feeRate = 1e20
+	_, err = service.Transfer(ctx, users[0].ID, &TransferPointsRequest{ToUserID: users[1].ID, Amount: maxTransferAmount})
+	assert.Equal(t, common.ErrTransferAmountTooLarge, err)
+
+	assert.Equal(t, int64(1000), userPoints(t, service, users[0].ID))
+	assert.Equal(t, int64(0), userPoints(t, service, users[1].ID))
+}
@@ -0,0 +1,559 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"member-link-lite/config"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+	storage2 "member-link-lite/pkg/storage"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// exportStaleAfter 生成中的导出任务超过该时长仍未完成时视为失败（如生成过程中服务重启）
+const exportStaleAfter = time.Hour
+
+// deletionBatchSize 每批查询的到期注销账号数量
+const deletionBatchSize = 100
+
+// PrivacyService 个人数据导出及账号注销服务接口
+type PrivacyService interface {
+	// 申请导出个人数据，导出文件异步生成
+	RequestExport(ctx context.Context, userID uint64) (*models.DataExport, error)
+	// 获取导出任务
+	GetExport(ctx context.Context, userID, exportID uint64) (*models.DataExport, error)
+	// 打开导出文件
+	OpenExport(ctx context.Context, userID, exportID uint64) (io.ReadCloser, *models.DataExport, error)
+	// 申请注销账号，冷静期结束后执行
+	RequestDeletion(ctx context.Context, userID uint64) (*AccountDeletionInfo, error)
+	// 撤销注销申请
+	CancelDeletion(ctx context.Context, userID uint64) error
+	// 执行冷静期已结束的注销申请
+	ProcessDueDeletions(ctx context.Context) (int, error)
+	// 清理过期的导出文件
+	CleanupExpiredExports(ctx context.Context) (int, error)
+}
+
+// AccountDeletionInfo 账号注销申请信息
+// @Description 账号注销申请信息，冷静期内可撤销
+type AccountDeletionInfo struct {
+	DeletionAt time.Time `json:"deletion_at" example:"2024-01-16T00:00:00Z" description:"注销生效时间"`
+	GraceDays  int       `json:"grace_days" example:"15" description:"冷静期天数"`
+}
+
+// exportProfile 导出文件中的个人资料
+type exportProfile struct {
+	User       *models.User           `json:"user"`
+	Identities []*models.UserIdentity `json:"identities"`
+	ExportedAt time.Time              `json:"exported_at"`
+}
+
+// exportFile 导出文件中的文件信息
+type exportFile struct {
+	ID          uint64    `json:"id"`
+	Filename    string    `json:"filename"`
+	Size        int64     `json:"size"`
+	MimeType    string    `json:"mime_type"`
+	Category    string    `json:"category"`
+	CreatedAt   time.Time `json:"created_at"`
+	DownloadURL string    `json:"download_url"`
+}
+
+// privacyServiceImpl 个人数据导出及账号注销服务实现
+type privacyServiceImpl struct {
+	db          *gorm.DB
+	storage     storage2.StorageAdapter
+	jwtService  JWTService
+	gracePeriod time.Duration
+	exportTTL   time.Duration
+	runAsync    func(func()) // 执行异步任务，测试时可同步执行
+}
+
+// NewPrivacyService 创建个人数据导出及账号注销服务实例
+func NewPrivacyService() PrivacyService {
+	return &privacyServiceImpl{
+		db:          database.GetDB(),
+		storage:     storage2.GetCurrentAdapter(),
+		jwtService:  NewJWTService(),
+		gracePeriod: time.Duration(config.GetInt("privacy.deletion_grace_days")) * 24 * time.Hour,
+		exportTTL:   time.Duration(config.GetInt("privacy.export_ttl_hours")) * time.Hour,
+		runAsync:    func(task func()) { go task() },
+	}
+}
+
+// StartPrivacyJobs 启动后台任务，定期执行到期的账号注销并清理过期的导出文件
+func StartPrivacyJobs() {
+	interval := time.Duration(config.GetInt("privacy.job_interval")) * time.Second
+	if interval <= 0 {
+		return
+	}
+
+	service := NewPrivacyService()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ctx := context.Background()
+			if _, err := service.ProcessDueDeletions(ctx); err != nil {
+				logger.Error("执行账号注销失败:", err)
+			}
+			if _, err := service.CleanupExpiredExports(ctx); err != nil {
+				logger.Error("清理过期导出文件失败:", err)
+			}
+		}
+	}()
+}
+
+// RequestExport 申请导出个人数据
+// 已有生成中的导出任务时直接返回该任务
+func (s *privacyServiceImpl) RequestExport(ctx context.Context, userID uint64) (*models.DataExport, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	var pending models.DataExport
+	err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(tenantID)).
+		Where("user_id = ? AND state = ?", userID, models.DataExportStatePending).
+		First(&pending).Error
+	if err == nil {
+		return &pending, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询导出任务失败: %w", err)
+	}
+
+	export := &models.DataExport{UserID: userID, State: models.DataExportStatePending}
+	export.TenantID = tenantID
+	if err := s.db.WithContext(ctx).Create(export).Error; err != nil {
+		return nil, fmt.Errorf("创建导出任务失败: %w", err)
+	}
+
+	// 异步任务使用副本，避免与响应序列化并发读写
+	task := *export
+	s.runAsync(func() {
+		s.buildExport(context.WithValue(context.Background(), "tenant_id", tenantID), &task)
+	})
+
+	return export, nil
+}
+
+// GetExport 获取导出任务
+func (s *privacyServiceImpl) GetExport(ctx context.Context, userID, exportID uint64) (*models.DataExport, error) {
+	var export models.DataExport
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(database.GetTenantIDFromContext(ctx))).
+		Where("user_id = ?", userID).
+		First(&export, exportID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, common.ErrExportNotFound
+		}
+		return nil, fmt.Errorf("查询导出任务失败: %w", err)
+	}
+	return &export, nil
+}
+
+// OpenExport 打开导出文件，调用方负责关闭
+func (s *privacyServiceImpl) OpenExport(ctx context.Context, userID, exportID uint64) (io.ReadCloser, *models.DataExport, error) {
+	export, err := s.GetExport(ctx, userID, exportID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !export.IsDownloadable(time.Now()) {
+		return nil, nil, common.ErrExportNotReady
+	}
+
+	reader, err := s.storage.Download(ctx, export.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取导出文件失败: %w", err)
+	}
+	return reader, export, nil
+}
+
+// buildExport 生成导出文件并更新导出任务
+func (s *privacyServiceImpl) buildExport(ctx context.Context, export *models.DataExport) {
+	now := time.Now()
+	updates := map[string]interface{}{}
+
+	path, size, err := s.writeExport(ctx, export.UserID)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"export_id": export.ID,
+			"user_id":   export.UserID,
+			"error":     err.Error(),
+		}).Error("生成个人数据导出文件失败")
+		updates["state"] = models.DataExportStateFailed
+		updates["error"] = "生成导出文件失败"
+	} else {
+		expiresAt := now.Add(s.exportTTL)
+		updates["state"] = models.DataExportStateCompleted
+		updates["path"] = path
+		updates["size"] = size
+		updates["completed_at"] = now
+		updates["expires_at"] = expiresAt
+	}
+
+	if err := s.db.WithContext(ctx).Model(export).Updates(updates).Error; err != nil {
+		logger.WithField("export_id", export.ID).Error("更新导出任务失败:", err)
+	}
+}
+
+// writeExport 打包个人资料、余额及积分流水、文件信息，上传到存储并返回存储路径和大小
+func (s *privacyServiceImpl) writeExport(ctx context.Context, userID uint64) (string, int64, error) {
+	if s.storage == nil {
+		return "", 0, errors.New("存储未初始化")
+	}
+
+	tenantID := database.GetTenantIDFromContext(ctx)
+	db := s.db.WithContext(ctx)
+
+	var user models.User
+	if err := db.Scopes(models.ScopeByTenant(tenantID)).First(&user, userID).Error; err != nil {
+		return "", 0, fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	var identities []*models.UserIdentity
+	if err := db.Where("user_id = ?", userID).Order("id ASC").Find(&identities).Error; err != nil {
+		return "", 0, fmt.Errorf("查询绑定账号失败: %w", err)
+	}
+
+	var balanceRecords []*models.BalanceRecord
+	if err := db.Where("user_id = ?", userID).Order("id ASC").Find(&balanceRecords).Error; err != nil {
+		return "", 0, fmt.Errorf("查询余额记录失败: %w", err)
+	}
+
+	var pointsRecords []*models.PointsRecord
+	if err := db.Where("user_id = ?", userID).Order("id ASC").Find(&pointsRecords).Error; err != nil {
+		return "", 0, fmt.Errorf("查询积分记录失败: %w", err)
+	}
+
+	var files []*models.File
+	if err := db.Where("user_id = ? AND status <> ?", userID, models.StatusDeleted).Order("id ASC").Find(&files).Error; err != nil {
+		return "", 0, fmt.Errorf("查询文件失败: %w", err)
+	}
+
+	// 文件下载链接与导出文件同时过期
+	fileInfos := make([]*exportFile, 0, len(files))
+	for _, file := range files {
+		downloadURL, err := s.storage.GetSignedURL(ctx, file.Path, s.exportTTL)
+		if err != nil {
+			return "", 0, fmt.Errorf("生成文件下载链接失败: %w", err)
+		}
+		fileInfos = append(fileInfos, &exportFile{
+			ID:          file.ID,
+			Filename:    file.Filename,
+			Size:        file.Size,
+			MimeType:    file.MimeType,
+			Category:    file.Category,
+			CreatedAt:   file.CreatedAt,
+			DownloadURL: downloadURL,
+		})
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	entries := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", &exportProfile{User: &user, Identities: identities, ExportedAt: time.Now()}},
+		{"balance_records.json", balanceRecords},
+		{"points_records.json", pointsRecords},
+		{"files.json", fileInfos},
+	}
+	for _, entry := range entries {
+		w, err := zw.Create(entry.name)
+		if err != nil {
+			return "", 0, err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entry.data); err != nil {
+			return "", 0, fmt.Errorf("写入%s失败: %w", entry.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", 0, err
+	}
+
+	// 文件名使用随机值，避免公开访问的存储被猜测路径
+	name, err := NewTokenID()
+	if err != nil {
+		return "", 0, err
+	}
+	path := fmt.Sprintf("exports/%s/%d/%s.zip", tenantID, userID, name)
+	size := int64(buf.Len())
+	if err := s.storage.Upload(ctx, path, bytes.NewReader(buf.Bytes()), size, "application/zip"); err != nil {
+		return "", 0, fmt.Errorf("上传导出文件失败: %w", err)
+	}
+
+	return path, size, nil
+}
+
+// RequestDeletion 申请注销账号
+func (s *privacyServiceImpl) RequestDeletion(ctx context.Context, userID uint64) (*AccountDeletionInfo, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(database.GetTenantIDFromContext(ctx))).
+		First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, common.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	if user.DeletionAt != nil {
+		return nil, common.ErrDeletionAlreadyPending
+	}
+	// 余额属于会员资金，须先使用或退款；冻结中的金额须等待扣款或释放
+	if user.Balance != 0 || user.FrozenBalance != 0 {
+		return nil, common.ErrDeletionBalanceRemains
+	}
+
+	deletionAt := time.Now().Add(s.gracePeriod)
+	if err := s.db.WithContext(ctx).Model(&user).Update("deletion_at", deletionAt).Error; err != nil {
+		return nil, fmt.Errorf("申请注销失败: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"deletion_at": deletionAt,
+	}).Info("会员申请注销账号")
+
+	return &AccountDeletionInfo{
+		DeletionAt: deletionAt,
+		GraceDays:  int(s.gracePeriod / (24 * time.Hour)),
+	}, nil
+}
+
+// CancelDeletion 撤销注销申请
+func (s *privacyServiceImpl) CancelDeletion(ctx context.Context, userID uint64) error {
+	result := s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Scopes(models.ScopeByTenant(database.GetTenantIDFromContext(ctx))).
+		Where("id = ? AND deletion_at IS NOT NULL", userID).
+		Update("deletion_at", nil)
+	if result.Error != nil {
+		return fmt.Errorf("撤销注销失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return common.ErrDeletionNotScheduled
+	}
+	return nil
+}
+
+// ProcessDueDeletions 执行冷静期已结束的注销申请（所有租户），返回已注销的账号数
+// 按ID分批处理，单个账号注销失败时记录日志并继续处理后续批次，避免失败的账号阻塞其他账号
+func (s *privacyServiceImpl) ProcessDueDeletions(ctx context.Context) (int, error) {
+	now := time.Now()
+	deleted := 0
+	lastID := uint64(0)
+	for {
+		var users []*models.User
+		if err := s.db.WithContext(ctx).
+			Where("deletion_at IS NOT NULL AND deletion_at <= ? AND id > ?", now, lastID).
+			Order("id ASC").
+			Limit(deletionBatchSize).
+			Find(&users).Error; err != nil {
+			return deleted, fmt.Errorf("查询待注销账号失败: %w", err)
+		}
+
+		for _, user := range users {
+			lastID = user.ID
+			if err := s.deleteAccount(context.WithValue(ctx, "tenant_id", user.TenantID), user); err != nil {
+				logger.WithFields(logrus.Fields{
+					"user_id": user.ID,
+					"error":   err.Error(),
+				}).Error("注销账号失败")
+				continue
+			}
+			deleted++
+		}
+
+		if len(users) < deletionBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// deleteAccount 注销账号
+// 清除个人信息并删除账号，删除第三方账号、两步验证、角色、第三方应用授权及导出文件，通过存储适配器删除上传的文件；
+// 剩余积分清零并记录扣除流水，余额及积分流水保留用于审计。
+// 冷静期内余额未清零或仍有冻结金额时不注销，并撤销注销申请，避免每次执行都重试
+func (s *privacyServiceImpl) deleteAccount(ctx context.Context, user *models.User) error {
+	var files []*models.File
+	if err := s.db.WithContext(ctx).Where("user_id = ?", user.ID).Find(&files).Error; err != nil {
+		return fmt.Errorf("查询文件失败: %w", err)
+	}
+	var exports []*models.DataExport
+	if err := s.db.WithContext(ctx).Where("user_id = ?", user.ID).Find(&exports).Error; err != nil {
+		return fmt.Errorf("查询导出任务失败: %w", err)
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, user.ID).Error; err != nil {
+			return fmt.Errorf("查询用户失败: %w", err)
+		}
+		if current.Balance != 0 || current.FrozenBalance != 0 {
+			return common.ErrDeletionBalanceRemains
+		}
+
+		// 剩余积分随账号作废
+		if current.Points != 0 {
+			record := &models.PointsRecord{
+				BaseModel:   models.BaseModel{TenantID: current.TenantID},
+				UserID:      current.ID,
+				Quantity:    -current.Points,
+				Type:        models.PointsTypeDeduct,
+				Remark:      "账号注销，积分清零",
+				PointsAfter: 0,
+			}
+			if err := tx.Create(record).Error; err != nil {
+				return fmt.Errorf("创建积分记录失败: %w", err)
+			}
+		}
+
+		// 用户名改为占位值，手机号、邮箱置空，释放原手机号、邮箱、用户名
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"username":          fmt.Sprintf("deleted_%d", user.ID),
+			"password":          "",
+			"nickname":          "已注销用户",
+			"avatar":            "",
+			"phone":             nil,
+			"email":             nil,
+			"email_verified_at": nil,
+			"points":            0,
+			"last_ip":           "",
+			"last_time":         nil,
+			"locked_until":      nil,
+			"deletion_at":       nil,
+			"status":            models.UserStatusDisabled,
+		}).Error; err != nil {
+			return fmt.Errorf("清除个人信息失败: %w", err)
+		}
+		if err := tx.Delete(user).Error; err != nil {
+			return fmt.Errorf("删除账号失败: %w", err)
+		}
+
+		for _, model := range []interface{}{
+			&models.UserIdentity{},
+			&models.UserMFA{},
+			&models.UserRecoveryCode{},
+			&models.UserRole{},
+			&models.OAuthConsent{},
+			&models.DataExport{},
+			&models.PasswordHistory{},
+			&models.UserTag{},
+		} {
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return fmt.Errorf("删除账号数据失败: %w", err)
+			}
+		}
+
+		if err := tx.Model(&models.File{}).Where("user_id = ?", user.ID).Update("status", models.StatusDeleted).Error; err != nil {
+			return fmt.Errorf("删除文件记录失败: %w", err)
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.File{}).Error; err != nil {
+			return fmt.Errorf("删除文件记录失败: %w", err)
+		}
+		return nil
+	})
+	if err == common.ErrDeletionBalanceRemains {
+		if cancelErr := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", user.ID).Update("deletion_at", nil).Error; cancelErr != nil {
+			return fmt.Errorf("撤销注销失败: %w", cancelErr)
+		}
+		logger.WithField("user_id", user.ID).Warn("账户余额未清零，已撤销注销申请")
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	sessions := &sessionServiceImpl{db: s.db, jwtService: s.jwtService}
+	if _, err := sessions.RevokeAllSessions(ctx, user.ID); err != nil {
+		logger.WithField("user_id", user.ID).Warn("注销会话失败:", err)
+	}
+
+	paths := make([]string, 0, len(files)+len(exports))
+	for _, file := range files {
+		// 去重上传的文件可能被其他记录引用，仍被引用时保留存储文件
+		// 无法确认时保留存储文件，避免误删其他记录引用的文件
+		var refs int64
+		if err := s.db.WithContext(ctx).Model(&models.File{}).Where("path = ? AND id <> ?", file.Path, file.ID).Count(&refs).Error; err != nil {
+			logger.WithFields(logrus.Fields{
+				"user_id": user.ID,
+				"path":    file.Path,
+				"error":   err.Error(),
+			}).Warn("查询文件引用失败，保留存储文件")
+			continue
+		}
+		if refs == 0 {
+			paths = append(paths, file.Path)
+		}
+	}
+	for _, export := range exports {
+		if export.Path != "" {
+			paths = append(paths, export.Path)
+		}
+	}
+	s.deleteStoredFiles(ctx, paths)
+
+	logger.WithField("user_id", user.ID).Info("账号已注销")
+	return nil
+}
+
+// CleanupExpiredExports 删除过期的导出文件，并将长时间未完成的导出任务标记为失败
+func (s *privacyServiceImpl) CleanupExpiredExports(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	if err := s.db.WithContext(ctx).
+		Model(&models.DataExport{}).
+		Where("state = ? AND created_at <= ?", models.DataExportStatePending, now.Add(-exportStaleAfter)).
+		Updates(map[string]interface{}{
+			"state": models.DataExportStateFailed,
+			"error": "导出超时",
+		}).Error; err != nil {
+		return 0, fmt.Errorf("更新导出任务失败: %w", err)
+	}
+
+	var exports []*models.DataExport
+	if err := s.db.WithContext(ctx).
+		Where("state = ? AND expires_at <= ?", models.DataExportStateCompleted, now).
+		Find(&exports).Error; err != nil {
+		return 0, fmt.Errorf("查询过期导出任务失败: %w", err)
+	}
+
+	for _, export := range exports {
+		s.deleteStoredFiles(ctx, []string{export.Path})
+		if err := s.db.WithContext(ctx).Model(export).Updates(map[string]interface{}{
+			"state": models.DataExportStateExpired,
+			"path":  "",
+		}).Error; err != nil {
+			return 0, fmt.Errorf("更新导出任务失败: %w", err)
+		}
+	}
+
+	return len(exports), nil
+}
+
+// deleteStoredFiles 通过存储适配器删除文件，失败时记录日志
+func (s *privacyServiceImpl) deleteStoredFiles(ctx context.Context, paths []string) {
+	if s.storage == nil {
+		return
+	}
+	for _, path := range paths {
+		if err := s.storage.Delete(ctx, path); err != nil {
+			logger.WithField("path", path).Warn("删除存储文件失败:", err)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+	"member-link-lite/pkg/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupPrivacyService(t *testing.T) (*privacyServiceImpl, *gorm.DB, storage.StorageAdapter) {
+	config.Init()
+	logger.Init()
+	db := setupTestDB(t)
+	adapter := storage.NewLocalAdapter(t.TempDir(), "http://localhost:8080/uploads/")
+
+	service := &privacyServiceImpl{
+		db:          db,
+		storage:     adapter,
+		jwtService:  NewJWTService(),
+		gracePeriod: 15 * 24 * time.Hour,
+		exportTTL:   72 * time.Hour,
+		runAsync:    func(task func()) { task() },
+	}
+	return service, db, adapter
+}
+
+// createPrivacyTestUser 创建带流水、文件及第三方账号的测试用户
+func createPrivacyTestUser(t *testing.T, db *gorm.DB, adapter storage.StorageAdapter) (*models.User, *models.File) {
+	user := &models.User{Username: "privacy", Password: "x", Nickname: "小明", Email: "privacy@example.com", Phone: "13800138030", Balance: 1000, Points: 20}
+	require.NoError(t, db.Create(user).Error)
+
+	require.NoError(t, db.Create(&models.BalanceRecord{UserID: user.ID, Amount: 1000, Type: models.BalanceTypeRecharge, BalanceAfter: 1000}).Error)
+	require.NoError(t, db.Create(&models.PointsRecord{UserID: user.ID, Quantity: 20, Type: models.PointsTypeObtain, PointsAfter: 20}).Error)
+	require.NoError(t, db.Create(&models.UserIdentity{UserID: user.ID, Provider: models.IdentityProviderWeChatMini, ProviderUserID: "privacy_openid"}).Error)
+
+	path := "default/avatar/privacy.png"
+	require.NoError(t, adapter.Upload(context.Background(), path, strings.NewReader("png"), 3, "image/png"))
+	file := &models.File{UserID: user.ID, Filename: "privacy.png", Path: path, URL: "http://localhost:8080/uploads/" + path, Size: 3, Category: models.FileCategoryAvatar}
+	require.NoError(t, db.Create(file).Error)
+
+	return user, file
+}
+
+func TestPrivacyService_Export(t *testing.T) {
+	service, db, adapter := setupPrivacyService(t)
+	ctx := context.Background()
+	user, _ := createPrivacyTestUser(t, db, adapter)
+
+	export, err := service.RequestExport(ctx, user.ID)
+	require.NoError(t, err)
+
+	export, err = service.GetExport(ctx, user.ID, export.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DataExportStateCompleted, export.State)
+	require.NotNil(t, export.ExpiresAt)
+
+	reader, _, err := service.OpenExport(ctx, user.ID, export.ID)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, export.Size, int64(len(content)))
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	entries := make(map[string][]byte)
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		entries[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	require.Contains(t, entries, "profile.json")
+	require.Contains(t, entries, "balance_records.json")
+	require.Contains(t, entries, "points_records.json")
+	require.Contains(t, entries, "files.json")
+
+	var profile struct {
+		User       map[string]interface{} `json:"user"`
+		Identities []models.UserIdentity  `json:"identities"`
+	}
+	require.NoError(t, json.Unmarshal(entries["profile.json"], &profile))
+	assert.Equal(t, "13800138030", profile.User["phone"])
+	assert.NotContains(t, profile.User, "password")
+	assert.Len(t, profile.Identities, 1)
+
+	var files []exportFile
+	require.NoError(t, json.Unmarshal(entries["files.json"], &files))
+	require.Len(t, files, 1)
+	assert.Contains(t, files[0].DownloadURL, "privacy.png")
+
+	t.Run("其他用户不能下载", func(t *testing.T) {
+		_, _, err := service.OpenExport(ctx, user.ID+1, export.ID)
+		assert.Equal(t, common.ErrExportNotFound, err)
+	})
+
+	t.Run("过期后清理导出文件", func(t *testing.T) {
+		require.NoError(t, db.Model(export).Update("expires_at", time.Now().Add(-time.Minute)).Error)
+		_, _, err := service.OpenExport(ctx, user.ID, export.ID)
+		assert.Equal(t, common.ErrExportNotReady, err)
+
+		cleaned, err := service.CleanupExpiredExports(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, cleaned)
+
+		exists, _ := adapter.Exists(ctx, export.Path)
+		assert.False(t, exists)
+		expired, err := service.GetExport(ctx, user.ID, export.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DataExportStateExpired, expired.State)
+	})
+}
+
+func TestPrivacyService_Deletion(t *testing.T) {
+	service, db, adapter := setupPrivacyService(t)
+	ctx := context.Background()
+	user, file := createPrivacyTestUser(t, db, adapter)
+
+	t.Run("余额未清零不能申请注销", func(t *testing.T) {
+		_, err := service.RequestDeletion(ctx, user.ID)
+		assert.Equal(t, common.ErrDeletionBalanceRemains, err)
+
+		require.NoError(t, db.Model(user).Updates(map[string]interface{}{"balance": 0}).Error)
+	})
+
+	t.Run("申请及撤销注销", func(t *testing.T) {
+		info, err := service.RequestDeletion(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 15, info.GraceDays)
+
+		_, err = service.RequestDeletion(ctx, user.ID)
+		assert.Equal(t, common.ErrDeletionAlreadyPending, err)
+
+		require.NoError(t, service.CancelDeletion(ctx, user.ID))
+		assert.Equal(t, common.ErrDeletionNotScheduled, service.CancelDeletion(ctx, user.ID))
+	})
+
+	t.Run("冷静期内不执行注销", func(t *testing.T) {
+		_, err := service.RequestDeletion(ctx, user.ID)
+		require.NoError(t, err)
+
+		deleted, err := service.ProcessDueDeletions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, deleted)
+	})
+
+	t.Run("冷静期内存入余额时撤销注销", func(t *testing.T) {
+		require.NoError(t, db.Model(user).Updates(map[string]interface{}{
+			"deletion_at":    time.Now().Add(-time.Minute),
+			"balance":        500,
+			"frozen_balance": 500,
+		}).Error)
+
+		deleted, err := service.ProcessDueDeletions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, deleted)
+
+		var current models.User
+		require.NoError(t, db.First(&current, user.ID).Error)
+		assert.Nil(t, current.DeletionAt)
+		assert.Equal(t, "13800138030", current.Phone)
+
+		require.NoError(t, db.Model(user).Updates(map[string]interface{}{"balance": 0, "frozen_balance": 0}).Error)
+	})
+
+	t.Run("冷静期结束后注销", func(t *testing.T) {
+		require.NoError(t, db.Model(user).Update("deletion_at", time.Now().Add(-time.Minute)).Error)
+
+		deleted, err := service.ProcessDueDeletions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+
+		// 账号已删除，个人信息已清除
+		var count int64
+		db.Model(&models.User{}).Where("id = ?", user.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+
+		var anonymised models.User
+		require.NoError(t, db.Unscoped().First(&anonymised, user.ID).Error)
+		assert.Empty(t, anonymised.Phone)
+		assert.Empty(t, anonymised.Email)
+		assert.Zero(t, anonymised.Points)
+		assert.NotEqual(t, "小明", anonymised.Nickname)
+		assert.Empty(t, anonymised.Password)
+		assert.Nil(t, anonymised.DeletionAt)
+
+		// 余额及积分流水保留，剩余积分清零
+		db.Model(&models.BalanceRecord{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+		var cleared models.PointsRecord
+		require.NoError(t, db.Where("user_id = ? AND type = ?", user.ID, models.PointsTypeDeduct).First(&cleared).Error)
+		assert.Equal(t, int64(-20), cleared.Quantity)
+		assert.Equal(t, int64(0), cleared.PointsAfter)
+
+		// 第三方账号及文件已删除
+		db.Unscoped().Model(&models.UserIdentity{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+		db.Model(&models.File{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+		exists, _ := adapter.Exists(ctx, file.Path)
+		assert.False(t, exists)
+
+		// 原手机号可重新注册
+		reused := &models.User{Username: "privacy2", Password: "x", Email: "privacy@example.com", Phone: "13800138030"}
+		assert.NoError(t, db.Create(reused).Error)
+	})
+}
+
+func TestPrivacyService_ProcessDueDeletionsSkipsFailures(t *testing.T) {
+	service, db, _ := setupPrivacyService(t)
+	ctx := context.Background()
+
+	// 前一整批账号注销时持续失败
+	require.NoError(t, db.Exec(`CREATE TRIGGER stuck_deletion BEFORE UPDATE ON m_users
+		WHEN OLD.username LIKE 'stuck%' BEGIN SELECT RAISE(ABORT, 'stuck'); END`).Error)
+	due := time.Now().Add(-time.Minute)
+	for i := 0; i < deletionBatchSize; i++ {
+		require.NoError(t, db.Create(&models.User{
+			Username: fmt.Sprintf("stuck%03d", i), Password: "x",
+			Phone: fmt.Sprintf("1380013%04d", i), Email: fmt.Sprintf("stuck%03d@example.com", i),
+			DeletionAt: &due,
+		}).Error)
+	}
+	user := &models.User{Username: "due", Password: "x", Phone: "13900139000", Email: "due@example.com", DeletionAt: &due}
+	require.NoError(t, db.Create(user).Error)
+
+	// 失败的账号不阻塞后续批次
+	deleted, err := service.ProcessDueDeletions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	var count int64
+	require.NoError(t, db.Model(&models.User{}).Where("id = ?", user.ID).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+	require.NoError(t, db.Model(&models.User{}).Where("username LIKE ?", "stuck%").Count(&count).Error)
+	assert.Equal(t, int64(deletionBatchSize), count)
+}
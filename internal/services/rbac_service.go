@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"member-link-lite/config"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RBACService 角色权限服务接口
+type RBACService interface {
+	// 初始化内置权限和角色，并为配置的管理员账号授予管理员角色
+	EnsureDefaults(ctx context.Context) error
+	// 获取角色列表（包含权限）
+	ListRoles(ctx context.Context) ([]*models.Role, error)
+	// 获取用户的角色编码
+	GetUserRoles(ctx context.Context, userID uint64) ([]string, error)
+	// 为用户授予角色
+	GrantRole(ctx context.Context, userID uint64, roleCode string, grantedBy uint64) error
+	// 回收用户角色，并注销该用户的全部会话使旧令牌中的角色失效
+	RevokeRole(ctx context.Context, userID uint64, roleCode string) error
+	// 检查角色集合是否拥有指定权限
+	HasPermission(ctx context.Context, roles []string, permission string) (bool, error)
+}
+
+// GrantRoleRequest 授予角色请求
+type GrantRoleRequest struct {
+	Role string `json:"role" binding:"required" example:"operator"` // 角色编码
+}
+
+// defaultPermissions 内置权限
+var defaultPermissions = []models.Permission{
+	{Code: models.PermissionBalanceWrite, Name: "调整余额", Description: "调整会员余额"},
+	{Code: models.PermissionPointsWrite, Name: "调整积分", Description: "调整会员积分、维护积分规则"},
+	{Code: models.PermissionLevelWrite, Name: "管理等级", Description: "维护等级及调整会员等级"},
+	{Code: models.PermissionMemberRead, Name: "查看会员", Description: "查看会员列表和详情"},
+	{Code: models.PermissionMemberWrite, Name: "管理会员", Description: "创建、修改、禁用会员"},
+	{Code: models.PermissionRoleManage, Name: "管理角色", Description: "分配和回收角色"},
+	{Code: models.PermissionClientManage, Name: "管理接入客户端", Description: "创建和停用服务端接入客户端"},
+	{Code: models.PermissionLedgerRead, Name: "查看账本", Description: "查看资金账本分录及试算平衡表"},
+}
+
+// defaultRoles 内置角色及其权限
+var defaultRoles = []struct {
+	role        models.Role
+	permissions []string
+}{
+	{
+		role: models.Role{Code: models.RoleAdmin, Name: "管理员", Description: "拥有全部权限"},
+		permissions: []string{
+			models.PermissionBalanceWrite,
+			models.PermissionPointsWrite,
+			models.PermissionLevelWrite,
+			models.PermissionMemberRead,
+			models.PermissionMemberWrite,
+			models.PermissionRoleManage,
+			models.PermissionClientManage,
+			models.PermissionLedgerRead,
+		},
+	},
+	{
+		role: models.Role{Code: models.RoleOperator, Name: "运营人员", Description: "管理会员资产、等级和会员信息"},
+		permissions: []string{
+			models.PermissionBalanceWrite,
+			models.PermissionPointsWrite,
+			models.PermissionLevelWrite,
+			models.PermissionMemberRead,
+			models.PermissionMemberWrite,
+		},
+	},
+}
+
+// rolePermissionCacheTTL 角色权限缓存时长
+const rolePermissionCacheTTL = time.Minute
+
+// rolePermissionCache 角色权限缓存（进程内），每次鉴权无需查询数据库
+var rolePermissionCache = struct {
+	sync.RWMutex
+	permissions map[string]map[string]bool
+	loadedAt    time.Time
+}{}
+
+// rbacServiceImpl 角色权限服务实现
+type rbacServiceImpl struct {
+	db         *gorm.DB
+	jwtService JWTService
+}
+
+// NewRBACService 创建角色权限服务实例
+func NewRBACService() RBACService {
+	return &rbacServiceImpl{
+		db:         database.GetDB(),
+		jwtService: NewJWTService(),
+	}
+}
+
+// EnsureDefaults 初始化内置权限和角色
+func (s *rbacServiceImpl) EnsureDefaults(ctx context.Context) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range defaultPermissions {
+			permission := defaultPermissions[i]
+			if err := tx.Where("code = ?", permission.Code).FirstOrCreate(&permission).Error; err != nil {
+				return fmt.Errorf("初始化权限失败: %w", err)
+			}
+		}
+
+		for _, def := range defaultRoles {
+			role := def.role
+			if err := tx.Where("code = ?", role.Code).FirstOrCreate(&role).Error; err != nil {
+				return fmt.Errorf("初始化角色失败: %w", err)
+			}
+
+			var permissions []models.Permission
+			if err := tx.Where("code IN ?", def.permissions).Find(&permissions).Error; err != nil {
+				return fmt.Errorf("查询权限失败: %w", err)
+			}
+			// 只追加缺失的权限，保留人工调整过的角色权限
+			if err := tx.Model(&role).Association("Permissions").Append(permissions); err != nil {
+				return fmt.Errorf("初始化角色权限失败: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateRolePermissionCache()
+
+	// 为配置的管理员账号授予管理员角色（账号需已注册）
+	for _, username := range strings.Split(config.GetString("rbac.admin_usernames"), ",") {
+		username = strings.TrimSpace(username)
+		if username == "" {
+			continue
+		}
+
+		var user models.User
+		if err := s.db.WithContext(ctx).
+			Where("username = ? AND tenant_id = ?", username, database.GetTenantIDFromContext(ctx)).
+			First(&user).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				continue
+			}
+			return fmt.Errorf("查询管理员账号失败: %w", err)
+		}
+
+		if err := s.GrantRole(ctx, user.ID, models.RoleAdmin, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListRoles 获取角色列表
+func (s *rbacServiceImpl) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	var roles []*models.Role
+	if err := s.db.WithContext(ctx).
+		Preload("Permissions").
+		Order("id ASC").
+		Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("查询角色失败: %w", err)
+	}
+	return roles, nil
+}
+
+// GetUserRoles 获取用户的角色编码
+func (s *rbacServiceImpl) GetUserRoles(ctx context.Context, userID uint64) ([]string, error) {
+	var codes []string
+	if err := s.db.WithContext(ctx).
+		Model(&models.UserRole{}).
+		Joins("JOIN m_roles ON m_roles.id = m_user_roles.role_id AND m_roles.deleted_at IS NULL").
+		Where("m_user_roles.user_id = ? AND m_user_roles.tenant_id = ?", userID, database.GetTenantIDFromContext(ctx)).
+		Order("m_roles.code ASC").
+		Pluck("m_roles.code", &codes).Error; err != nil {
+		return nil, fmt.Errorf("查询用户角色失败: %w", err)
+	}
+	return codes, nil
+}
+
+// GrantRole 为用户授予角色
+func (s *rbacServiceImpl) GrantRole(ctx context.Context, userID uint64, roleCode string, grantedBy uint64) error {
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	role, err := s.findRole(ctx, roleCode)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ? AND tenant_id = ?", userID, tenantID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+	if count == 0 {
+		return common.ErrUserNotFound
+	}
+
+	userRole := &models.UserRole{UserID: userID, RoleID: role.ID, GrantedBy: grantedBy}
+	userRole.TenantID = tenantID
+	if err := s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(userRole).Error; err != nil {
+		return fmt.Errorf("授予角色失败: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeRole 回收用户角色
+func (s *rbacServiceImpl) RevokeRole(ctx context.Context, userID uint64, roleCode string) error {
+	role, err := s.findRole(ctx, roleCode)
+	if err != nil {
+		return err
+	}
+
+	result := s.db.WithContext(ctx).
+		Unscoped().
+		Where("user_id = ? AND role_id = ? AND tenant_id = ?", userID, role.ID, database.GetTenantIDFromContext(ctx)).
+		Delete(&models.UserRole{})
+	if result.Error != nil {
+		return fmt.Errorf("回收角色失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	// 已签发的访问令牌仍携带旧角色，注销全部会话使其立即失效
+	sessions := &sessionServiceImpl{db: s.db, jwtService: s.jwtService}
+	if _, err := sessions.RevokeAllSessions(ctx, userID); err != nil {
+		return fmt.Errorf("注销会话失败: %w", err)
+	}
+
+	return nil
+}
+
+// HasPermission 检查角色集合是否拥有指定权限
+func (s *rbacServiceImpl) HasPermission(ctx context.Context, roles []string, permission string) (bool, error) {
+	if len(roles) == 0 {
+		return false, nil
+	}
+
+	permissions, err := s.rolePermissions(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, role := range roles {
+		if permissions[role][permission] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// findRole 根据编码查找角色
+func (s *rbacServiceImpl) findRole(ctx context.Context, code string) (*models.Role, error) {
+	var role models.Role
+	if err := s.db.WithContext(ctx).Where("code = ?", code).First(&role).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, common.ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("查询角色失败: %w", err)
+	}
+	return &role, nil
+}
+
+// rolePermissions 获取角色到权限的映射，优先使用进程内缓存
+func (s *rbacServiceImpl) rolePermissions(ctx context.Context) (map[string]map[string]bool, error) {
+	rolePermissionCache.RLock()
+	if rolePermissionCache.permissions != nil && time.Since(rolePermissionCache.loadedAt) < rolePermissionCacheTTL {
+		permissions := rolePermissionCache.permissions
+		rolePermissionCache.RUnlock()
+		return permissions, nil
+	}
+	rolePermissionCache.RUnlock()
+
+	roles, err := s.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make(map[string]map[string]bool, len(roles))
+	for _, role := range roles {
+		set := make(map[string]bool, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			set[permission.Code] = true
+		}
+		permissions[role.Code] = set
+	}
+
+	rolePermissionCache.Lock()
+	rolePermissionCache.permissions = permissions
+	rolePermissionCache.loadedAt = time.Now()
+	rolePermissionCache.Unlock()
+
+	return permissions, nil
+}
+
+// invalidateRolePermissionCache 清除角色权限缓存
+func invalidateRolePermissionCache() {
+	rolePermissionCache.Lock()
+	rolePermissionCache.permissions = nil
+	rolePermissionCache.Unlock()
+}
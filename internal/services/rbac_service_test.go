@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRBACService_GrantAndRevoke(t *testing.T) {
+	config.Init()
+	db := setupTestDB(t)
+	userService := &userServiceImpl{db: db, jwtService: NewJWTService(), cache: cache.NewMemoryCache()}
+	rbacService := userService.rbac()
+	ctx := context.Background()
+
+	require.NoError(t, rbacService.EnsureDefaults(ctx))
+	// 重复初始化不产生重复数据
+	require.NoError(t, rbacService.EnsureDefaults(ctx))
+
+	roles, err := rbacService.ListRoles(ctx)
+	require.NoError(t, err)
+	assert.Len(t, roles, 2)
+
+	user, err := userService.Register(ctx, &RegisterRequest{
+		Username: "operator",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "operator@example.com",
+	})
+	require.NoError(t, err)
+
+	// 未分配角色的用户为普通会员，令牌中携带所属租户而不携带角色
+	resp, err := userService.Login(ctx, &LoginRequest{Account: "operator", Password: "password123"})
+	require.NoError(t, err)
+	claims, err := userService.jwtService.ValidateToken(resp.Tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.TenantID, claims.TenantID)
+	assert.Empty(t, claims.Roles)
+
+	allowed, err := rbacService.HasPermission(ctx, claims.Roles, models.PermissionBalanceWrite)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	// 授予运营人员角色
+	assert.Equal(t, common.ErrRoleNotFound, rbacService.GrantRole(ctx, user.ID, "unknown", 0))
+	assert.Equal(t, common.ErrUserNotFound, rbacService.GrantRole(ctx, 9999, models.RoleOperator, 0))
+	require.NoError(t, rbacService.GrantRole(ctx, user.ID, models.RoleOperator, 0))
+	require.NoError(t, rbacService.GrantRole(ctx, user.ID, models.RoleOperator, 0))
+
+	codes, err := rbacService.GetUserRoles(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.RoleOperator}, codes)
+
+	// 其他租户下没有该角色
+	otherRoles, err := rbacService.GetUserRoles(context.WithValue(ctx, "tenant_id", "other"), user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, otherRoles)
+
+	// 刷新令牌后角色写入新的访问令牌
+	tokens, err := userService.RefreshToken(ctx, resp.Tokens.RefreshToken)
+	require.NoError(t, err)
+	claims, err = userService.jwtService.ValidateToken(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.RoleOperator}, claims.Roles)
+
+	allowed, err = rbacService.HasPermission(ctx, claims.Roles, models.PermissionBalanceWrite)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	// 运营人员不能管理角色
+	allowed, err = rbacService.HasPermission(ctx, claims.Roles, models.PermissionRoleManage)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	// 回收角色后注销全部会话，携带旧角色的访问令牌和刷新令牌均失效
+	require.NoError(t, rbacService.RevokeRole(ctx, user.ID, models.RoleOperator))
+	codes, err = rbacService.GetUserRoles(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, codes)
+
+	revoked, err := userService.jwtService.IsTokenRevoked(ctx, claims)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	_, err = userService.RefreshToken(ctx, tokens.RefreshToken)
+	assert.Error(t, err)
+}
+
+func TestRBACService_AdminUsernames(t *testing.T) {
+	config.Init()
+	viper.Set("rbac.admin_usernames", "admin, missing")
+	defer viper.Set("rbac.admin_usernames", "")
+
+	db := setupTestDB(t)
+	userService := &userServiceImpl{db: db, jwtService: NewJWTService(), cache: cache.NewMemoryCache()}
+	rbacService := userService.rbac()
+	ctx := context.Background()
+
+	user, err := userService.Register(ctx, &RegisterRequest{
+		Username: "admin",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "admin@example.com",
+	})
+	require.NoError(t, err)
+
+	// 未注册的用户名被忽略
+	require.NoError(t, rbacService.EnsureDefaults(ctx))
+
+	codes, err := rbacService.GetUserRoles(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.RoleAdmin}, codes)
+
+	allowed, err := rbacService.HasPermission(ctx, codes, models.PermissionRoleManage)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	segmentMaxDepth      = 5  // 规则树最大嵌套层数
+	segmentMaxConditions = 50 // 规则树最多条件数
+)
+
+// 分群字段类型
+const (
+	segmentKindString    = "string"    // 文本字段
+	segmentKindNumber    = "number"    // 数值字段
+	segmentKindTime      = "time"      // 时间字段
+	segmentKindDays      = "days"      // 距今天数
+	segmentKindBool      = "bool"      // 是否
+	segmentKindTag       = "tag"       // 会员标签
+	segmentKindAggregate = "aggregate" // 余额、积分变动统计
+)
+
+// segmentField 分群可用字段
+type segmentField struct {
+	kind   string
+	column string   // 会员表字段或表达式；统计类字段为统计表达式
+	table  string   // 统计类字段的变动记录表
+	types  []string // 统计类字段允许筛选的变动类型
+}
+
+// segmentFields 分群规则可使用的字段
+var segmentFields = map[string]segmentField{
+	"username":        {kind: segmentKindString, column: "m_users.username"},
+	"nickname":        {kind: segmentKindString, column: "m_users.nickname"},
+	"phone":           {kind: segmentKindString, column: "m_users.phone"},
+	"email":           {kind: segmentKindString, column: "m_users.email"},
+	"status":          {kind: segmentKindNumber, column: "m_users.status"},
+	"balance":         {kind: segmentKindNumber, column: "m_users.balance"},
+	"points":          {kind: segmentKindNumber, column: "m_users.points"},
+	"created_at":      {kind: segmentKindTime, column: "m_users.created_at"},
+	"last_login_at":   {kind: segmentKindTime, column: "m_users.last_time"},
+	"registered_days": {kind: segmentKindDays, column: "m_users.created_at"},
+	"inactive_days":   {kind: segmentKindDays, column: "COALESCE(m_users.last_time, m_users.created_at)"}, // 从未登录时按注册时间计算
+	"email_verified":  {kind: segmentKindBool, column: "m_users.email_verified_at"},
+	"tag":             {kind: segmentKindTag},
+	"balance_sum":     {kind: segmentKindAggregate, table: "m_balance_records", column: "COALESCE(SUM(amount), 0)", types: balanceRecordTypes},
+	"balance_count":   {kind: segmentKindAggregate, table: "m_balance_records", column: "COUNT(*)", types: balanceRecordTypes},
+	"points_sum":      {kind: segmentKindAggregate, table: "m_points_records", column: "COALESCE(SUM(quantity), 0)", types: pointsRecordTypes},
+	"points_count":    {kind: segmentKindAggregate, table: "m_points_records", column: "COUNT(*)", types: pointsRecordTypes},
+}
+
+// segmentOperators 各字段类型支持的比较方式
+var segmentOperators = map[string][]string{
+	segmentKindString:    {"eq", "ne", "contains", "prefix", "in"},
+	segmentKindNumber:    {"eq", "ne", "gt", "gte", "lt", "lte", "between", "in"},
+	segmentKindTime:      {"gt", "gte", "lt", "lte", "between"},
+	segmentKindDays:      {"gt", "gte", "lt", "lte", "between"},
+	segmentKindBool:      {"eq"},
+	segmentKindTag:       {"has", "not_has"},
+	segmentKindAggregate: {"eq", "ne", "gt", "gte", "lt", "lte", "between"},
+}
+
+// segmentComparisons 比较方式对应的SQL运算符
+var segmentComparisons = map[string]string{
+	"eq":  "=",
+	"ne":  "<>",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// segmentDaysComparisons 距今天数转换为时间比较时的运算符（天数越大时间越早）
+var segmentDaysComparisons = map[string]string{
+	"gt":  "<",
+	"gte": "<=",
+	"lt":  ">",
+	"lte": ">=",
+}
+
+var (
+	balanceRecordTypes = []string{
+		models.BalanceTypeRecharge, models.BalanceTypeConsume, models.BalanceTypeRefund,
+		models.BalanceTypeReward, models.BalanceTypeDeduct,
+	}
+	pointsRecordTypes = []string{
+		models.PointsTypeObtain, models.PointsTypeUse, models.PointsTypeExpire,
+		models.PointsTypeReward, models.PointsTypeDeduct, models.PointsTypeTransfer,
+		models.PointsTypeTransferFee,
+	}
+)
+
+// CompileSegmentRule 将分群规则编译为会员查询条件
+// 统计类字段仅统计状态正常的变动记录，now用于计算距今天数及统计天数
+func CompileSegmentRule(rule *models.SegmentRule, now time.Time) (func(db *gorm.DB) *gorm.DB, error) {
+	if rule == nil {
+		return nil, segmentRuleError("规则不能为空")
+	}
+
+	c := &segmentCompiler{now: now}
+	sql, args, err := c.compile(rule, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(sql, args...)
+	}, nil
+}
+
+// ValidateSegmentRule 验证分群规则
+func ValidateSegmentRule(rule *models.SegmentRule) error {
+	_, err := CompileSegmentRule(rule, time.Now())
+	return err
+}
+
+// segmentCompiler 分群规则编译器
+type segmentCompiler struct {
+	now        time.Time
+	conditions int
+}
+
+// compile 编译规则节点
+func (c *segmentCompiler) compile(rule *models.SegmentRule, depth int) (string, []interface{}, error) {
+	if depth > segmentMaxDepth {
+		return "", nil, segmentRuleError("规则嵌套不能超过%d层", segmentMaxDepth)
+	}
+
+	if !rule.IsGroup() {
+		c.conditions++
+		if c.conditions > segmentMaxConditions {
+			return "", nil, segmentRuleError("条件不能超过%d个", segmentMaxConditions)
+		}
+		return c.compileCondition(rule)
+	}
+
+	logic := strings.ToLower(rule.Logic)
+	if logic == "" {
+		logic = "and"
+	}
+	if logic != "and" && logic != "or" {
+		return "", nil, segmentRuleError("不支持的逻辑运算: %s", rule.Logic)
+	}
+	if len(rule.Rules) == 0 {
+		return "", nil, segmentRuleError("条件组不能为空")
+	}
+
+	parts := make([]string, 0, len(rule.Rules))
+	var args []interface{}
+	for i := range rule.Rules {
+		sql, childArgs, err := c.compile(&rule.Rules[i], depth+1)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, "("+sql+")")
+		args = append(args, childArgs...)
+	}
+
+	return strings.Join(parts, " "+strings.ToUpper(logic)+" "), args, nil
+}
+
+// compileCondition 编译单个条件
+func (c *segmentCompiler) compileCondition(rule *models.SegmentRule) (string, []interface{}, error) {
+	field, ok := segmentFields[rule.Field]
+	if !ok {
+		return "", nil, segmentRuleError("不支持的字段: %s", rule.Field)
+	}
+	if !containsString(segmentOperators[field.kind], rule.Operator) {
+		return "", nil, segmentRuleError("字段%s不支持比较方式: %s", rule.Field, rule.Operator)
+	}
+	if field.kind != segmentKindAggregate && (len(rule.Types) > 0 || rule.Days != 0) {
+		return "", nil, segmentRuleError("仅统计类字段可以指定types和days")
+	}
+
+	switch field.kind {
+	case segmentKindString:
+		return c.compileString(rule, field.column)
+	case segmentKindNumber:
+		return c.compileNumber(rule, field.column, nil)
+	case segmentKindTime:
+		return c.compileTime(rule, field.column)
+	case segmentKindDays:
+		return c.compileDays(rule, field.column)
+	case segmentKindBool:
+		var value bool
+		if err := json.Unmarshal(rule.Value, &value); err != nil {
+			return "", nil, segmentRuleError("字段%s的值必须为true或false", rule.Field)
+		}
+		if value {
+			return field.column + " IS NOT NULL", nil, nil
+		}
+		return field.column + " IS NULL", nil, nil
+	case segmentKindTag:
+		return c.compileTag(rule)
+	default:
+		return c.compileAggregate(rule, field)
+	}
+}
+
+// compileString 编译文本条件
+func (c *segmentCompiler) compileString(rule *models.SegmentRule, column string) (string, []interface{}, error) {
+	if rule.Operator == "in" {
+		var values []string
+		if err := json.Unmarshal(rule.Value, &values); err != nil || len(values) == 0 {
+			return "", nil, segmentRuleError("字段%s的值必须为非空文本数组", rule.Field)
+		}
+		return column + " IN ?", []interface{}{values}, nil
+	}
+
+	var value string
+	if err := json.Unmarshal(rule.Value, &value); err != nil {
+		return "", nil, segmentRuleError("字段%s的值必须为文本", rule.Field)
+	}
+
+	switch rule.Operator {
+	case "contains":
+		return column + " LIKE ?", []interface{}{"%" + value + "%"}, nil
+	case "prefix":
+		return column + " LIKE ?", []interface{}{value + "%"}, nil
+	}
+	return column + " " + segmentComparisons[rule.Operator] + " ?", []interface{}{value}, nil
+}
+
+// compileNumber 编译数值条件，prefixArgs为表达式本身需要的参数
+func (c *segmentCompiler) compileNumber(rule *models.SegmentRule, expr string, prefixArgs []interface{}) (string, []interface{}, error) {
+	args := append([]interface{}{}, prefixArgs...)
+
+	switch rule.Operator {
+	case "between":
+		var values []int64
+		if err := json.Unmarshal(rule.Value, &values); err != nil || len(values) != 2 || values[0] > values[1] {
+			return "", nil, segmentRuleError("字段%s的值必须为[最小值, 最大值]", rule.Field)
+		}
+		return expr + " BETWEEN ? AND ?", append(args, values[0], values[1]), nil
+	case "in":
+		var values []int64
+		if err := json.Unmarshal(rule.Value, &values); err != nil || len(values) == 0 {
+			return "", nil, segmentRuleError("字段%s的值必须为非空整数数组", rule.Field)
+		}
+		return expr + " IN ?", append(args, values), nil
+	}
+
+	var value int64
+	if err := json.Unmarshal(rule.Value, &value); err != nil {
+		return "", nil, segmentRuleError("字段%s的值必须为整数", rule.Field)
+	}
+	return expr + " " + segmentComparisons[rule.Operator] + " ?", append(args, value), nil
+}
+
+// compileTime 编译时间条件
+func (c *segmentCompiler) compileTime(rule *models.SegmentRule, column string) (string, []interface{}, error) {
+	if rule.Operator == "between" {
+		var values []string
+		if err := json.Unmarshal(rule.Value, &values); err != nil || len(values) != 2 {
+			return "", nil, segmentRuleError("字段%s的值必须为[开始时间, 结束时间]", rule.Field)
+		}
+		start, err := parseSegmentTime(rule.Field, values[0])
+		if err != nil {
+			return "", nil, err
+		}
+		end, err := parseSegmentTime(rule.Field, values[1])
+		if err != nil {
+			return "", nil, err
+		}
+		return column + " BETWEEN ? AND ?", []interface{}{start, end}, nil
+	}
+
+	var value string
+	if err := json.Unmarshal(rule.Value, &value); err != nil {
+		return "", nil, segmentRuleError("字段%s的值必须为时间文本", rule.Field)
+	}
+	t, err := parseSegmentTime(rule.Field, value)
+	if err != nil {
+		return "", nil, err
+	}
+	return column + " " + segmentComparisons[rule.Operator] + " ?", []interface{}{t}, nil
+}
+
+// compileDays 编译距今天数条件，转换为时间比较以便使用索引
+func (c *segmentCompiler) compileDays(rule *models.SegmentRule, column string) (string, []interface{}, error) {
+	daysAgo := func(days int64) time.Time {
+		return c.now.Add(-time.Duration(days) * 24 * time.Hour)
+	}
+
+	if rule.Operator == "between" {
+		var values []int64
+		if err := json.Unmarshal(rule.Value, &values); err != nil || len(values) != 2 || values[0] < 0 || values[0] > values[1] {
+			return "", nil, segmentRuleError("字段%s的值必须为[最小天数, 最大天数]", rule.Field)
+		}
+		return column + " BETWEEN ? AND ?", []interface{}{daysAgo(values[1]), daysAgo(values[0])}, nil
+	}
+
+	var value int64
+	if err := json.Unmarshal(rule.Value, &value); err != nil || value < 0 {
+		return "", nil, segmentRuleError("字段%s的值必须为非负整数", rule.Field)
+	}
+	return column + " " + segmentDaysComparisons[rule.Operator] + " ?", []interface{}{daysAgo(value)}, nil
+}
+
+// compileTag 编译标签条件，值为标签ID或标签ID数组，拥有其中任一标签即匹配
+func (c *segmentCompiler) compileTag(rule *models.SegmentRule) (string, []interface{}, error) {
+	var ids []uint64
+	var id uint64
+	if err := json.Unmarshal(rule.Value, &id); err == nil {
+		ids = []uint64{id}
+	} else if err := json.Unmarshal(rule.Value, &ids); err != nil || len(ids) == 0 {
+		return "", nil, segmentRuleError("字段tag的值必须为标签ID或标签ID数组")
+	}
+
+	sql := "m_users.id IN (SELECT user_id FROM m_user_tags WHERE tag_id IN ? AND deleted_at IS NULL)"
+	if rule.Operator == "not_has" {
+		sql = "m_users.id NOT IN (SELECT user_id FROM m_user_tags WHERE tag_id IN ? AND deleted_at IS NULL)"
+	}
+	return sql, []interface{}{ids}, nil
+}
+
+// compileAggregate 编译统计条件，使用关联子查询统计每个会员的变动记录
+func (c *segmentCompiler) compileAggregate(rule *models.SegmentRule, field segmentField) (string, []interface{}, error) {
+	if rule.Days < 0 {
+		return "", nil, segmentRuleError("字段%s的统计天数不能为负数", rule.Field)
+	}
+	for _, t := range rule.Types {
+		if !containsString(field.types, t) {
+			return "", nil, segmentRuleError("字段%s不支持的变动类型: %s", rule.Field, t)
+		}
+	}
+
+	sub := fmt.Sprintf("SELECT %s FROM %s r WHERE r.user_id = m_users.id AND r.status = %d AND r.deleted_at IS NULL",
+		field.column, field.table, models.StatusActive)
+	var args []interface{}
+	if len(rule.Types) > 0 {
+		sub += " AND r.type IN ?"
+		args = append(args, rule.Types)
+	}
+	if rule.Days > 0 {
+		sub += " AND r.created_at >= ?"
+		args = append(args, c.now.AddDate(0, 0, -rule.Days))
+	}
+
+	return c.compileNumber(rule, "("+sub+")", args)
+}
+
+// parseSegmentTime 解析规则中的时间，支持日期及RFC3339格式
+func parseSegmentTime(field, value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, segmentRuleError("字段%s的时间格式错误: %s", field, value)
+}
+
+// segmentRuleError 创建分群规则错误
+func segmentRuleError(format string, args ...interface{}) error {
+	return common.NewCustomError(common.CodeBadRequest, "分群规则无效: "+fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,488 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SegmentService 会员标签及分群服务接口
+type SegmentService interface {
+	// 会员标签
+	ListTags(ctx context.Context) ([]models.MemberTag, error)
+	CreateTag(ctx context.Context, req *TagRequest) (*models.MemberTag, error)
+	UpdateTag(ctx context.Context, tagID uint64, req *TagRequest) (*models.MemberTag, error)
+	DeleteTag(ctx context.Context, tagID uint64) error
+	TagMembers(ctx context.Context, tagID uint64, req *TagMembersRequest, operatorID uint64) (int64, error)
+	UntagMembers(ctx context.Context, tagID uint64, req *TagMembersRequest) (int64, error)
+	GetMemberTags(ctx context.Context, userID uint64) ([]models.MemberTag, error)
+
+	// 会员分群
+	ListSegments(ctx context.Context, req *common.PageRequest) (*common.PaginateResult, error)
+	GetSegment(ctx context.Context, segmentID uint64) (*models.Segment, error)
+	CreateSegment(ctx context.Context, req *SegmentRequest, operatorID uint64) (*models.Segment, error)
+	UpdateSegment(ctx context.Context, segmentID uint64, req *SegmentRequest) (*models.Segment, error)
+	DeleteSegment(ctx context.Context, segmentID uint64) error
+	PreviewRule(ctx context.Context, rule *models.SegmentRule) (*SegmentPreview, error)
+	PreviewSegment(ctx context.Context, segmentID uint64) (*SegmentPreview, error)
+	ListSegmentMembers(ctx context.Context, segmentID uint64, req *common.PageRequest) (*common.PaginateResult, error)
+	ExportSegmentMembers(ctx context.Context, segmentID uint64, format string, w io.Writer) error
+}
+
+// segmentServiceImpl 会员标签及分群服务实现
+type segmentServiceImpl struct {
+	db *gorm.DB
+}
+
+// NewSegmentService 创建会员标签及分群服务
+func NewSegmentService() SegmentService {
+	return &segmentServiceImpl{
+		db: database.GetDB(),
+	}
+}
+
+// TagRequest 创建或修改标签请求
+// @Description 会员标签信息
+type TagRequest struct {
+	Name        string `json:"name" binding:"required,max=50" example:"高价值" description:"标签名称"`
+	Color       string `json:"color" binding:"max=20" example:"#FF6600" description:"显示颜色（可选）"`
+	Description string `json:"description" binding:"max=255" example:"近一年消费超过1万元" description:"标签描述（可选）"`
+}
+
+// TagMembersRequest 批量打标签或移除标签请求
+// @Description 会员ID列表
+type TagMembersRequest struct {
+	UserIDs []uint64 `json:"user_ids" binding:"required,min=1,max=1000" example:"1,2,3" description:"会员ID列表，最多1000个"`
+}
+
+// SegmentRequest 创建或修改分群请求
+// @Description 分群信息及规则树
+type SegmentRequest struct {
+	Name        string              `json:"name" binding:"required,max=50" example:"高积分沉睡会员" description:"分群名称"`
+	Description string              `json:"description" binding:"max=255" example:"积分超过5000且30天未登录" description:"分群描述（可选）"`
+	Rules       *models.SegmentRule `json:"rules" binding:"required" description:"分群规则树"`
+}
+
+// SegmentPreview 分群预览结果
+// @Description 当前符合分群规则的会员数量
+type SegmentPreview struct {
+	Count int64 `json:"count" example:"128" description:"会员数量"`
+}
+
+// ListTags 获取当前租户的标签及各标签会员数量
+func (s *segmentServiceImpl) ListTags(ctx context.Context) ([]models.MemberTag, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	var tags []models.MemberTag
+	if err := s.db.WithContext(ctx).Scopes(models.ScopeByTenant(tenantID)).Order("id ASC").Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("查询标签失败: %w", err)
+	}
+	if len(tags) == 0 {
+		return tags, nil
+	}
+
+	tagIDs := make([]uint64, 0, len(tags))
+	for _, tag := range tags {
+		tagIDs = append(tagIDs, tag.ID)
+	}
+
+	var counts []struct {
+		TagID uint64
+		Count int64
+	}
+	if err := s.db.WithContext(ctx).Model(&models.UserTag{}).
+		Select("m_user_tags.tag_id, COUNT(*) AS count").
+		Joins("JOIN m_users ON m_users.id = m_user_tags.user_id AND m_users.deleted_at IS NULL").
+		Where("m_user_tags.tag_id IN ?", tagIDs).
+		Group("m_user_tags.tag_id").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("统计标签会员数量失败: %w", err)
+	}
+
+	countMap := make(map[uint64]int64, len(counts))
+	for _, c := range counts {
+		countMap[c.TagID] = c.Count
+	}
+	for i := range tags {
+		tags[i].MemberCount = countMap[tags[i].ID]
+	}
+
+	return tags, nil
+}
+
+// CreateTag 创建标签
+func (s *segmentServiceImpl) CreateTag(ctx context.Context, req *TagRequest) (*models.MemberTag, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+	name := strings.TrimSpace(req.Name)
+
+	if err := s.checkTagName(ctx, tenantID, name, 0); err != nil {
+		return nil, err
+	}
+
+	tag := &models.MemberTag{
+		Name:        name,
+		Color:       req.Color,
+		Description: req.Description,
+	}
+	tag.TenantID = tenantID
+
+	if err := s.db.WithContext(ctx).Create(tag).Error; err != nil {
+		return nil, fmt.Errorf("创建标签失败: %w", err)
+	}
+
+	return tag, nil
+}
+
+// UpdateTag 修改标签
+func (s *segmentServiceImpl) UpdateTag(ctx context.Context, tagID uint64, req *TagRequest) (*models.MemberTag, error) {
+	tag, err := s.findTag(ctx, tagID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if err := s.checkTagName(ctx, tag.TenantID, name, tag.ID); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"name":        name,
+		"color":       req.Color,
+		"description": req.Description,
+	}
+	if err := s.db.WithContext(ctx).Model(tag).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("修改标签失败: %w", err)
+	}
+
+	return s.findTag(ctx, tagID)
+}
+
+// DeleteTag 删除标签及会员的标签关联
+// 使用该标签的分群规则将不再匹配任何会员
+func (s *segmentServiceImpl) DeleteTag(ctx context.Context, tagID uint64) error {
+	tag, err := s.findTag(ctx, tagID)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("tag_id = ?", tag.ID).Delete(&models.UserTag{}).Error; err != nil {
+			return fmt.Errorf("删除标签关联失败: %w", err)
+		}
+		if err := tx.Delete(tag).Error; err != nil {
+			return fmt.Errorf("删除标签失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// TagMembers 给会员打标签，已有该标签的会员跳过，返回新增数量
+func (s *segmentServiceImpl) TagMembers(ctx context.Context, tagID uint64, req *TagMembersRequest, operatorID uint64) (int64, error) {
+	tag, err := s.findTag(ctx, tagID)
+	if err != nil {
+		return 0, err
+	}
+
+	userIDs, err := s.tenantUserIDs(ctx, tag.TenantID, req.UserIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	var tagged []uint64
+	if err := s.db.WithContext(ctx).Model(&models.UserTag{}).
+		Where("tag_id = ? AND user_id IN ?", tag.ID, userIDs).
+		Pluck("user_id", &tagged).Error; err != nil {
+		return 0, fmt.Errorf("查询会员标签失败: %w", err)
+	}
+	taggedSet := make(map[uint64]bool, len(tagged))
+	for _, id := range tagged {
+		taggedSet[id] = true
+	}
+
+	userTags := make([]models.UserTag, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if taggedSet[userID] {
+			continue
+		}
+		userTag := models.UserTag{UserID: userID, TagID: tag.ID, TaggedBy: operatorID}
+		userTag.TenantID = tag.TenantID
+		userTags = append(userTags, userTag)
+	}
+	if len(userTags) == 0 {
+		return 0, nil
+	}
+
+	if err := s.db.WithContext(ctx).CreateInBatches(userTags, 100).Error; err != nil {
+		return 0, fmt.Errorf("添加会员标签失败: %w", err)
+	}
+
+	return int64(len(userTags)), nil
+}
+
+// UntagMembers 移除会员标签，返回移除数量
+func (s *segmentServiceImpl) UntagMembers(ctx context.Context, tagID uint64, req *TagMembersRequest) (int64, error) {
+	tag, err := s.findTag(ctx, tagID)
+	if err != nil {
+		return 0, err
+	}
+
+	result := s.db.WithContext(ctx).Unscoped().
+		Where("tag_id = ? AND user_id IN ?", tag.ID, req.UserIDs).
+		Delete(&models.UserTag{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("移除会员标签失败: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
+
+// GetMemberTags 获取会员的标签
+func (s *segmentServiceImpl) GetMemberTags(ctx context.Context, userID uint64) ([]models.MemberTag, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	if _, err := s.tenantUserIDs(ctx, tenantID, []uint64{userID}); err != nil {
+		return nil, err
+	}
+
+	var tags []models.MemberTag
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeByTenant(tenantID)).
+		Where("id IN (?)", s.db.Model(&models.UserTag{}).Select("tag_id").Where("user_id = ?", userID)).
+		Order("id ASC").
+		Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("查询会员标签失败: %w", err)
+	}
+
+	return tags, nil
+}
+
+// ListSegments 分页获取分群
+func (s *segmentServiceImpl) ListSegments(ctx context.Context, req *common.PageRequest) (*common.PaginateResult, error) {
+	if err := req.ValidateAndSetDefaults(); err != nil {
+		return nil, err
+	}
+
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	var segments []models.Segment
+	return common.PaginateQueryWithModel(
+		s.db.WithContext(ctx),
+		req,
+		&models.Segment{},
+		&segments,
+		models.ScopeByTenant(tenantID),
+		func(db *gorm.DB) *gorm.DB { return db.Order("id DESC") },
+	)
+}
+
+// GetSegment 获取分群
+func (s *segmentServiceImpl) GetSegment(ctx context.Context, segmentID uint64) (*models.Segment, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	var segment models.Segment
+	if err := s.db.WithContext(ctx).Scopes(models.ScopeByTenant(tenantID)).First(&segment, segmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.ErrSegmentNotFound
+		}
+		return nil, fmt.Errorf("查询分群失败: %w", err)
+	}
+
+	return &segment, nil
+}
+
+// CreateSegment 创建分群
+func (s *segmentServiceImpl) CreateSegment(ctx context.Context, req *SegmentRequest, operatorID uint64) (*models.Segment, error) {
+	if err := ValidateSegmentRule(req.Rules); err != nil {
+		return nil, err
+	}
+
+	segment := &models.Segment{
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Rule:        req.Rules,
+		CreatedBy:   operatorID,
+	}
+	segment.TenantID = database.GetTenantIDFromContext(ctx)
+
+	if err := s.db.WithContext(ctx).Create(segment).Error; err != nil {
+		return nil, fmt.Errorf("创建分群失败: %w", err)
+	}
+
+	return segment, nil
+}
+
+// UpdateSegment 修改分群
+func (s *segmentServiceImpl) UpdateSegment(ctx context.Context, segmentID uint64, req *SegmentRequest) (*models.Segment, error) {
+	segment, err := s.GetSegment(ctx, segmentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateSegmentRule(req.Rules); err != nil {
+		return nil, err
+	}
+
+	segment.Name = strings.TrimSpace(req.Name)
+	segment.Description = req.Description
+	segment.Rule = req.Rules
+	if err := s.db.WithContext(ctx).Select("name", "description", "rules").Save(segment).Error; err != nil {
+		return nil, fmt.Errorf("修改分群失败: %w", err)
+	}
+
+	return s.GetSegment(ctx, segmentID)
+}
+
+// DeleteSegment 删除分群
+func (s *segmentServiceImpl) DeleteSegment(ctx context.Context, segmentID uint64) error {
+	segment, err := s.GetSegment(ctx, segmentID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.WithContext(ctx).Delete(segment).Error; err != nil {
+		return fmt.Errorf("删除分群失败: %w", err)
+	}
+	return nil
+}
+
+// PreviewRule 预览规则匹配的会员数量，用于保存分群前调整规则
+func (s *segmentServiceImpl) PreviewRule(ctx context.Context, rule *models.SegmentRule) (*SegmentPreview, error) {
+	query, err := s.memberQuery(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("统计分群会员失败: %w", err)
+	}
+
+	return &SegmentPreview{Count: count}, nil
+}
+
+// PreviewSegment 预览分群当前的会员数量
+func (s *segmentServiceImpl) PreviewSegment(ctx context.Context, segmentID uint64) (*SegmentPreview, error) {
+	segment, err := s.GetSegment(ctx, segmentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.PreviewRule(ctx, segment.Rule)
+}
+
+// ListSegmentMembers 分页获取分群会员
+func (s *segmentServiceImpl) ListSegmentMembers(ctx context.Context, segmentID uint64, req *common.PageRequest) (*common.PaginateResult, error) {
+	if err := req.ValidateAndSetDefaults(); err != nil {
+		return nil, err
+	}
+
+	segment, err := s.GetSegment(ctx, segmentID)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := CompileSegmentRule(segment.Rule, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	return common.PaginateQueryWithModel(
+		s.db.WithContext(ctx),
+		req,
+		&models.User{},
+		&users,
+		models.ScopeByTenant(segment.TenantID),
+		scope,
+		func(db *gorm.DB) *gorm.DB { return db.Order("m_users.id DESC") },
+	)
+}
+
+// ExportSegmentMembers 导出分群会员，支持CSV及XLSX格式
+func (s *segmentServiceImpl) ExportSegmentMembers(ctx context.Context, segmentID uint64, format string, w io.Writer) error {
+	if err := ValidateMemberFileFormat(format); err != nil {
+		return err
+	}
+
+	segment, err := s.GetSegment(ctx, segmentID)
+	if err != nil {
+		return err
+	}
+	query, err := s.memberQuery(ctx, segment.Rule)
+	if err != nil {
+		return err
+	}
+
+	return writeMembers(query, w, format)
+}
+
+// memberQuery 构建分群规则对应的会员查询
+func (s *segmentServiceImpl) memberQuery(ctx context.Context, rule *models.SegmentRule) (*gorm.DB, error) {
+	scope, err := CompileSegmentRule(rule, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	tenantID := database.GetTenantIDFromContext(ctx)
+	return s.db.WithContext(ctx).Model(&models.User{}).Scopes(models.ScopeByTenant(tenantID), scope), nil
+}
+
+// findTag 查询当前租户的标签
+func (s *segmentServiceImpl) findTag(ctx context.Context, tagID uint64) (*models.MemberTag, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	var tag models.MemberTag
+	if err := s.db.WithContext(ctx).Scopes(models.ScopeByTenant(tenantID)).First(&tag, tagID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.ErrTagNotFound
+		}
+		return nil, fmt.Errorf("查询标签失败: %w", err)
+	}
+
+	return &tag, nil
+}
+
+// checkTagName 检查标签名称在租户内是否重复
+func (s *segmentServiceImpl) checkTagName(ctx context.Context, tenantID, name string, excludeID uint64) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.MemberTag{}).
+		Scopes(models.ScopeByTenant(tenantID)).
+		Where("name = ? AND id <> ?", name, excludeID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("检查标签名称失败: %w", err)
+	}
+	if count > 0 {
+		return common.ErrTagExists
+	}
+	return nil
+}
+
+// tenantUserIDs 去重并检查会员均属于指定租户
+func (s *segmentServiceImpl) tenantUserIDs(ctx context.Context, tenantID string, userIDs []uint64) ([]uint64, error) {
+	seen := make(map[uint64]bool, len(userIDs))
+	unique := make([]uint64, 0, len(userIDs))
+	for _, id := range userIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.User{}).
+		Scopes(models.ScopeByTenant(tenantID)).
+		Where("id IN ?", unique).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询会员失败: %w", err)
+	}
+	if count != int64(len(unique)) {
+		return nil, common.ErrUserNotFound
+	}
+
+	return unique, nil
+}
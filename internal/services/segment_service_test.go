@@ -0,0 +1,238 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// segmentCondition 创建分群条件
+func segmentCondition(field, operator string, value interface{}) models.SegmentRule {
+	data, _ := json.Marshal(value)
+	return models.SegmentRule{Field: field, Operator: operator, Value: data}
+}
+
+func TestCompileSegmentRule_Invalid(t *testing.T) {
+	nested := models.SegmentRule{Rules: []models.SegmentRule{segmentCondition("points", "gt", 1)}}
+	for i := 0; i < segmentMaxDepth; i++ {
+		nested = models.SegmentRule{Rules: []models.SegmentRule{nested}}
+	}
+
+	tooMany := models.SegmentRule{}
+	for i := 0; i <= segmentMaxConditions; i++ {
+		tooMany.Rules = append(tooMany.Rules, segmentCondition("points", "gt", i))
+	}
+
+	withTypes := segmentCondition("points", "gt", 1)
+	withTypes.Types = []string{models.PointsTypeObtain}
+	wrongType := segmentCondition("points_sum", "gt", 1)
+	wrongType.Types = []string{models.BalanceTypeRecharge}
+
+	cases := map[string]*models.SegmentRule{
+		"空规则":       nil,
+		"空条件组":      {Logic: "and"},
+		"不支持的逻辑":    {Logic: "xor", Rules: []models.SegmentRule{segmentCondition("points", "gt", 1)}},
+		"不支持的字段":    {Rules: []models.SegmentRule{segmentCondition("password", "eq", "x")}},
+		"不支持的比较方式":  {Rules: []models.SegmentRule{segmentCondition("username", "gt", "x")}},
+		"值类型错误":     {Rules: []models.SegmentRule{segmentCondition("points", "gt", "many")}},
+		"区间顺序错误":    {Rules: []models.SegmentRule{segmentCondition("balance", "between", []int{10, 1})}},
+		"时间格式错误":    {Rules: []models.SegmentRule{segmentCondition("created_at", "gt", "yesterday")}},
+		"负数天数":      {Rules: []models.SegmentRule{segmentCondition("inactive_days", "gte", -1)}},
+		"非统计字段限定类型": {Rules: []models.SegmentRule{withTypes}},
+		"变动类型错误":    {Rules: []models.SegmentRule{wrongType}},
+		"嵌套过深":      &nested,
+		"条件过多":      &tooMany,
+	}
+	for name, rule := range cases {
+		err := ValidateSegmentRule(rule)
+		require.Error(t, err, name)
+		customErr, ok := err.(*common.CustomError)
+		require.True(t, ok, name)
+		assert.Equal(t, common.CodeBadRequest, customErr.Code, name)
+	}
+}
+
+func TestSegmentService_Tags(t *testing.T) {
+	userService, users := setupMemberService(t, 3)
+	service := &segmentServiceImpl{db: userService.db}
+	ctx := context.Background()
+
+	tag, err := service.CreateTag(ctx, &TagRequest{Name: "高价值", Color: "#FF6600"})
+	require.NoError(t, err)
+	_, err = service.CreateTag(ctx, &TagRequest{Name: "高价值"})
+	assert.Equal(t, common.ErrTagExists, err)
+
+	// 其他租户可以使用相同的标签名称，但不能给本租户会员打标签
+	tenantCtx := context.WithValue(ctx, "tenant_id", "company1")
+	otherTag, err := service.CreateTag(tenantCtx, &TagRequest{Name: "高价值"})
+	require.NoError(t, err)
+	_, err = service.TagMembers(tenantCtx, otherTag.ID, &TagMembersRequest{UserIDs: []uint64{users[0].ID}}, 0)
+	assert.Equal(t, common.ErrUserNotFound, err)
+	_, err = service.TagMembers(ctx, otherTag.ID, &TagMembersRequest{UserIDs: []uint64{users[0].ID}}, 0)
+	assert.Equal(t, common.ErrTagNotFound, err)
+
+	// 重复的会员ID及已有标签的会员跳过
+	count, err := service.TagMembers(ctx, tag.ID, &TagMembersRequest{UserIDs: []uint64{users[0].ID, users[1].ID, users[0].ID}}, users[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	count, err = service.TagMembers(ctx, tag.ID, &TagMembersRequest{UserIDs: []uint64{users[1].ID, users[2].ID}}, users[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	tags, err := service.ListTags(ctx)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, int64(3), tags[0].MemberCount)
+
+	// 移除后可以重新添加
+	count, err = service.UntagMembers(ctx, tag.ID, &TagMembersRequest{UserIDs: []uint64{users[1].ID}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	memberTags, err := service.GetMemberTags(ctx, users[1].ID)
+	require.NoError(t, err)
+	assert.Empty(t, memberTags)
+	count, err = service.TagMembers(ctx, tag.ID, &TagMembersRequest{UserIDs: []uint64{users[1].ID}}, users[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	memberTags, err = service.GetMemberTags(ctx, users[0].ID)
+	require.NoError(t, err)
+	require.Len(t, memberTags, 1)
+	assert.Equal(t, "高价值", memberTags[0].Name)
+
+	// 删除标签同时移除会员的标签
+	require.NoError(t, service.DeleteTag(ctx, tag.ID))
+	memberTags, err = service.GetMemberTags(ctx, users[0].ID)
+	require.NoError(t, err)
+	assert.Empty(t, memberTags)
+	var remaining int64
+	service.db.Unscoped().Model(&models.UserTag{}).Where("tag_id = ?", tag.ID).Count(&remaining)
+	assert.Zero(t, remaining)
+}
+
+func TestSegmentService_Segments(t *testing.T) {
+	userService, users := setupMemberService(t, 4)
+	service := &segmentServiceImpl{db: userService.db}
+	assets := NewAssetService(userService.db)
+	ctx := context.Background()
+
+	// member0：积分6000，40天未登录；member1：积分6000，昨天登录；member2：积分100，从未登录且40天前注册；member3：积分0
+	for _, user := range users[:2] {
+		_, err := assets.ChangePoints(ctx, &ChangePointsRequest{UserID: user.ID, Quantity: 6000, Type: models.PointsTypeObtain})
+		require.NoError(t, err)
+	}
+	_, err := assets.ChangePoints(ctx, &ChangePointsRequest{UserID: users[2].ID, Quantity: 100, Type: models.PointsTypeObtain})
+	require.NoError(t, err)
+	_, err = assets.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: users[1].ID, Amount: 20000, Type: models.BalanceTypeRecharge})
+	require.NoError(t, err)
+	_, err = assets.ChangeBalance(ctx, &ChangeBalanceRequest{UserID: users[1].ID, Amount: -5000, Type: models.BalanceTypeConsume})
+	require.NoError(t, err)
+
+	now := time.Now()
+	require.NoError(t, service.db.Model(users[0]).Update("last_time", now.AddDate(0, 0, -40)).Error)
+	require.NoError(t, service.db.Model(users[1]).Update("last_time", now.AddDate(0, 0, -1)).Error)
+	require.NoError(t, service.db.Model(users[2]).Update("created_at", now.AddDate(0, 0, -40)).Error)
+
+	preview := func(rule models.SegmentRule) int64 {
+		result, err := service.PreviewRule(ctx, &rule)
+		require.NoError(t, err)
+		return result.Count
+	}
+
+	// 积分超过5000且30天未登录
+	dormant := models.SegmentRule{Logic: "and", Rules: []models.SegmentRule{
+		segmentCondition("points", "gt", 5000),
+		segmentCondition("inactive_days", "gte", 30),
+	}}
+	assert.Equal(t, int64(1), preview(dormant))
+
+	// 从未登录的会员按注册时间计算未登录天数
+	assert.Equal(t, int64(2), preview(models.SegmentRule{Rules: []models.SegmentRule{segmentCondition("inactive_days", "gte", 30)}}))
+	assert.Equal(t, int64(2), preview(models.SegmentRule{Rules: []models.SegmentRule{segmentCondition("inactive_days", "between", []int{0, 7})}}))
+
+	// 条件组嵌套
+	assert.Equal(t, int64(2), preview(models.SegmentRule{Logic: "or", Rules: []models.SegmentRule{
+		dormant,
+		{Rules: []models.SegmentRule{segmentCondition("username", "in", []string{"member3"})}},
+	}}))
+
+	// 统计类字段
+	assert.Equal(t, int64(1), preview(models.SegmentRule{Rules: []models.SegmentRule{segmentCondition("balance_sum", "eq", 15000)}}))
+	assert.Equal(t, int64(3), preview(models.SegmentRule{Rules: []models.SegmentRule{segmentCondition("points_count", "gte", 1)}}))
+	assert.Equal(t, int64(3), preview(models.SegmentRule{Rules: []models.SegmentRule{segmentCondition("balance_count", "lt", 2)}}))
+	consume := segmentCondition("balance_count", "gte", 1)
+	consume.Types = []string{models.BalanceTypeConsume}
+	consume.Days = 30
+	assert.Equal(t, int64(1), preview(models.SegmentRule{Rules: []models.SegmentRule{consume}}))
+
+	// 标签条件
+	tag, err := service.CreateTag(ctx, &TagRequest{Name: "VIP"})
+	require.NoError(t, err)
+	_, err = service.TagMembers(ctx, tag.ID, &TagMembersRequest{UserIDs: []uint64{users[2].ID, users[3].ID}}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), preview(models.SegmentRule{Rules: []models.SegmentRule{segmentCondition("tag", "has", tag.ID)}}))
+	assert.Equal(t, int64(2), preview(models.SegmentRule{Rules: []models.SegmentRule{segmentCondition("tag", "not_has", []uint64{tag.ID})}}))
+
+	// 保存分群
+	segment, err := service.CreateSegment(ctx, &SegmentRequest{Name: "高积分沉睡会员", Rules: &dormant}, users[3].ID)
+	require.NoError(t, err)
+	saved, err := service.GetSegment(ctx, segment.ID)
+	require.NoError(t, err)
+	require.NotNil(t, saved.Rule)
+	assert.Len(t, saved.Rule.Rules, 2)
+
+	_, err = service.CreateSegment(ctx, &SegmentRequest{Name: "无效", Rules: &models.SegmentRule{}}, 0)
+	assert.Error(t, err)
+
+	// 分群成员随会员数据实时变化
+	result, err := service.PreviewSegment(ctx, segment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Count)
+	require.NoError(t, service.db.Model(users[1]).Update("last_time", now.AddDate(0, 0, -31)).Error)
+	result, err = service.PreviewSegment(ctx, segment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Count)
+
+	page, err := service.ListSegmentMembers(ctx, segment.ID, common.NewPageRequest(1, 1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+	list := *page.List.(*[]models.User)
+	require.Len(t, list, 1)
+	assert.Equal(t, users[1].ID, list[0].ID)
+
+	// 导出CSV
+	var buf bytes.Buffer
+	require.NoError(t, service.ExportSegmentMembers(ctx, segment.ID, MemberFileFormatCSV, &buf))
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\xEF\xBB\xBF"))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "member0", records[1][1])
+	assert.Equal(t, "150.00", records[2][6])
+
+	// 修改规则
+	updated, err := service.UpdateSegment(ctx, segment.ID, &SegmentRequest{
+		Name:  "全部高积分会员",
+		Rules: &models.SegmentRule{Rules: []models.SegmentRule{segmentCondition("points", "gt", 5000)}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "全部高积分会员", updated.Name)
+	assert.Len(t, updated.Rule.Rules, 1)
+
+	// 其他租户不能访问
+	tenantCtx := context.WithValue(ctx, "tenant_id", "company1")
+	_, err = service.PreviewSegment(tenantCtx, segment.ID)
+	assert.Equal(t, common.ErrSegmentNotFound, err)
+
+	require.NoError(t, service.DeleteSegment(ctx, segment.ID))
+	_, err = service.GetSegment(ctx, segment.ID)
+	assert.Equal(t, common.ErrSegmentNotFound, err)
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SessionService 登录会话服务接口
+type SessionService interface {
+	// 创建会话
+	CreateSession(ctx context.Context, userID uint64, familyID, loginType string) (*models.UserSession, error)
+	// 刷新会话活跃信息，会话已注销时返回错误
+	TouchSession(ctx context.Context, userID uint64, familyID string) error
+	// 获取用户的有效会话列表
+	ListSessions(ctx context.Context, userID uint64, currentFamilyID string) ([]*SessionInfo, error)
+	// 注销指定会话
+	RevokeSession(ctx context.Context, userID, sessionID uint64) error
+	// 注销除当前会话外的其他会话
+	RevokeOtherSessions(ctx context.Context, userID uint64, currentFamilyID string) (int64, error)
+	// 注销用户的全部会话
+	RevokeAllSessions(ctx context.Context, userID uint64) (int64, error)
+	// 根据令牌族ID标记会话已注销
+	MarkSessionRevoked(ctx context.Context, familyID string) error
+}
+
+// SessionInfo 会话信息
+type SessionInfo struct {
+	*models.UserSession
+	Current bool `json:"current"` // 是否为当前请求所在的会话
+}
+
+// sessionServiceImpl 登录会话服务实现
+type sessionServiceImpl struct {
+	db         *gorm.DB
+	jwtService JWTService
+}
+
+// NewSessionService 创建登录会话服务实例
+func NewSessionService() SessionService {
+	return &sessionServiceImpl{
+		db:         database.GetDB(),
+		jwtService: NewJWTService(),
+	}
+}
+
+// CreateSession 创建会话
+func (s *sessionServiceImpl) CreateSession(ctx context.Context, userID uint64, familyID, loginType string) (*models.UserSession, error) {
+	client := GetClientInfoFromContext(ctx)
+
+	session := &models.UserSession{
+		UserID:     userID,
+		FamilyID:   familyID,
+		LoginType:  loginType,
+		Device:     client.Device,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		LastSeenAt: time.Now(),
+	}
+	session.TenantID = database.GetTenantIDFromContext(ctx)
+
+	if err := s.db.WithContext(ctx).Create(session).Error; err != nil {
+		return nil, fmt.Errorf("创建会话失败: %w", err)
+	}
+
+	return session, nil
+}
+
+// TouchSession 刷新会话活跃信息
+func (s *sessionServiceImpl) TouchSession(ctx context.Context, userID uint64, familyID string) error {
+	var session models.UserSession
+	err := s.db.WithContext(ctx).Where("family_id = ?", familyID).First(&session).Error
+	if err == gorm.ErrRecordNotFound {
+		// 会话功能上线前签发的令牌没有会话记录，补建一条
+		_, err = s.CreateSession(ctx, userID, familyID, "")
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("查询会话失败: %w", err)
+	}
+
+	if session.UserID != userID || session.IsRevoked() {
+		return common.ErrTokenRevoked
+	}
+
+	client := GetClientInfoFromContext(ctx)
+	updates := map[string]interface{}{
+		"last_seen_at": time.Now(),
+	}
+	if client.IP != "" {
+		updates["ip"] = client.IP
+	}
+	if client.UserAgent != "" {
+		updates["user_agent"] = client.UserAgent
+		updates["device"] = client.Device
+	}
+
+	return s.db.WithContext(ctx).Model(&session).Updates(updates).Error
+}
+
+// ListSessions 获取用户的有效会话列表
+func (s *sessionServiceImpl) ListSessions(ctx context.Context, userID uint64, currentFamilyID string) ([]*SessionInfo, error) {
+	var sessions []*models.UserSession
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeActiveSessions).
+		Where("user_id = ?", userID).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("查询会话失败: %w", err)
+	}
+
+	result := make([]*SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, &SessionInfo{
+			UserSession: session,
+			Current:     session.FamilyID == currentFamilyID,
+		})
+	}
+
+	return result, nil
+}
+
+// RevokeSession 注销指定会话
+func (s *sessionServiceImpl) RevokeSession(ctx context.Context, userID, sessionID uint64) error {
+	var session models.UserSession
+	err := s.db.WithContext(ctx).
+		Scopes(models.ScopeActiveSessions).
+		Where("id = ? AND user_id = ?", sessionID, userID).
+		First(&session).Error
+	if err == gorm.ErrRecordNotFound {
+		return common.ErrSessionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("查询会话失败: %w", err)
+	}
+
+	return s.revoke(ctx, &session)
+}
+
+// RevokeOtherSessions 注销除当前会话外的其他会话
+func (s *sessionServiceImpl) RevokeOtherSessions(ctx context.Context, userID uint64, currentFamilyID string) (int64, error) {
+	var sessions []*models.UserSession
+	if err := s.db.WithContext(ctx).
+		Scopes(models.ScopeActiveSessions).
+		Where("user_id = ? AND family_id <> ?", userID, currentFamilyID).
+		Find(&sessions).Error; err != nil {
+		return 0, fmt.Errorf("查询会话失败: %w", err)
+	}
+
+	for _, session := range sessions {
+		if err := s.revoke(ctx, session); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(sessions)), nil
+}
+
+// RevokeAllSessions 注销用户的全部会话
+func (s *sessionServiceImpl) RevokeAllSessions(ctx context.Context, userID uint64) (int64, error) {
+	return s.RevokeOtherSessions(ctx, userID, "")
+}
+
+// MarkSessionRevoked 根据令牌族ID标记会话已注销
+func (s *sessionServiceImpl) MarkSessionRevoked(ctx context.Context, familyID string) error {
+	if familyID == "" {
+		return nil
+	}
+
+	return s.db.WithContext(ctx).
+		Model(&models.UserSession{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Updates(map[string]interface{}{
+			"status":     models.SessionStatusRevoked,
+			"revoked_at": time.Now(),
+		}).Error
+}
+
+// revoke 吊销会话对应的令牌族并标记会话已注销
+func (s *sessionServiceImpl) revoke(ctx context.Context, session *models.UserSession) error {
+	if err := s.jwtService.RevokeFamily(ctx, session.FamilyID); err != nil {
+		return fmt.Errorf("吊销令牌族失败: %w", err)
+	}
+
+	return s.MarkSessionRevoked(ctx, session.FamilyID)
+}
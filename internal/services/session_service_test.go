@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionService_Lifecycle(t *testing.T) {
+	config.Init()
+	db := setupTestDB(t)
+	userService := &userServiceImpl{db: db, jwtService: NewJWTService()}
+	sessionService := userService.sessions()
+
+	_, err := userService.Register(context.Background(), &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+
+	// 两台设备分别登录
+	phoneCtx := WithClientInfo(context.Background(), &ClientInfo{IP: "10.0.0.1", UserAgent: "Mozilla/5.0 (iPhone)", Device: "iPhone"})
+	phoneLogin, err := userService.Login(phoneCtx, &LoginRequest{Username: "testuser", Password: "password123"})
+	require.NoError(t, err)
+
+	pcCtx := WithClientInfo(context.Background(), &ClientInfo{IP: "10.0.0.2", UserAgent: "Mozilla/5.0 (Windows NT 10.0)", Device: "Windows"})
+	pcLogin, err := userService.Login(pcCtx, &LoginRequest{Username: "testuser", Password: "password123"})
+	require.NoError(t, err)
+
+	phoneClaims, err := userService.jwtService.ValidateToken(phoneLogin.Tokens.AccessToken)
+	require.NoError(t, err)
+	userID := phoneClaims.UserID
+
+	sessions, err := sessionService.ListSessions(phoneCtx, userID, phoneClaims.FamilyID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	var current *SessionInfo
+	for _, session := range sessions {
+		if session.Current {
+			current = session
+		}
+	}
+	require.NotNil(t, current)
+	assert.Equal(t, "iPhone", current.Device)
+	assert.Equal(t, "10.0.0.1", current.IP)
+	assert.Equal(t, models.LoginTypePassword, current.LoginType)
+
+	// 刷新令牌沿用原会话并更新活跃信息
+	newIPCtx := WithClientInfo(context.Background(), &ClientInfo{IP: "10.0.0.3", UserAgent: "Mozilla/5.0 (iPhone)", Device: "iPhone"})
+	_, err = userService.RefreshToken(newIPCtx, phoneLogin.Tokens.RefreshToken)
+	require.NoError(t, err)
+
+	var session models.UserSession
+	require.NoError(t, db.First(&session, current.ID).Error)
+	assert.Equal(t, "10.0.0.3", session.IP)
+
+	// 注销其他设备后，电脑端的刷新令牌不能再使用
+	count, err := sessionService.RevokeOtherSessions(phoneCtx, userID, phoneClaims.FamilyID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	_, err = userService.RefreshToken(pcCtx, pcLogin.Tokens.RefreshToken)
+	assert.Equal(t, common.ErrTokenRevoked, err)
+
+	sessions, err = sessionService.ListSessions(phoneCtx, userID, phoneClaims.FamilyID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.True(t, sessions[0].Current)
+
+	// 不能注销其他用户的会话
+	err = sessionService.RevokeSession(phoneCtx, userID+1, current.ID)
+	assert.Equal(t, common.ErrSessionNotFound, err)
+
+	// 注销当前会话后访问令牌失效
+	require.NoError(t, sessionService.RevokeSession(phoneCtx, userID, current.ID))
+	revoked, err := userService.jwtService.IsTokenRevoked(phoneCtx, phoneClaims)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
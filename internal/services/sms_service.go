@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"member-link-lite/config"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/sms"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 验证码场景
+const (
+	SMSSceneLogin = "login" // 登录/注册
+)
+
+// 验证码缓存键前缀
+const (
+	smsCodeKeyPrefix     = "sms:code:"
+	smsAttemptKeyPrefix  = "sms:attempt:"
+	smsIntervalKeyPrefix = "sms:interval:"
+	smsPhoneDayKeyPrefix = "sms:limit:phone:"
+	smsIPHourKeyPrefix   = "sms:limit:ip:"
+)
+
+// SMSService 短信验证码服务接口
+type SMSService interface {
+	// 发送登录验证码
+	SendLoginCode(ctx context.Context, phone string) error
+	// 验证码登录，手机号未注册时自动创建用户
+	LoginWithCode(ctx context.Context, req *SMSLoginRequest) (*SMSLoginResponse, error)
+}
+
+// SMSConfig 短信验证码配置
+type SMSConfig struct {
+	CodeLength        int           // 验证码位数
+	CodeTTL           time.Duration // 验证码有效期
+	SendInterval      time.Duration // 同一手机号发送间隔
+	PhoneDailyLimit   int64         // 同一手机号每日发送上限
+	IPHourlyLimit     int64         // 同一IP每小时发送上限
+	MaxVerifyAttempts int64         // 单个验证码最多校验次数
+	LoginTemplate     string        // 登录验证码短信模板
+	Secret            string        // 验证码哈希密钥
+}
+
+// SendSMSCodeRequest 发送验证码请求
+type SendSMSCodeRequest struct {
+	Phone string `json:"phone" binding:"required" example:"13800138000"`
+}
+
+// SMSLoginRequest 验证码登录请求
+type SMSLoginRequest struct {
+	Phone string `json:"phone" binding:"required" example:"13800138000"`
+	Code  string `json:"code" binding:"required" example:"123456"`
+}
+
+// SMSLoginResponse 验证码登录响应
+// 用户启用两步验证时只返回MFARequired和MFAToken，需调用两步验证接口完成登录
+type SMSLoginResponse struct {
+	User        *models.User   `json:"user,omitempty"`
+	Tokens      *TokenResponse `json:"tokens,omitempty"`
+	IsNewUser   bool           `json:"is_new_user"`
+	MFARequired bool           `json:"mfa_required,omitempty"`
+	MFAToken    string         `json:"mfa_token,omitempty"`
+}
+
+// smsServiceImpl 短信验证码服务实现
+type smsServiceImpl struct {
+	db          *gorm.DB
+	userService UserService
+	sender      sms.SMSSender
+	cache       cache.Cache
+	cfg         *SMSConfig
+}
+
+// LoadSMSConfig 从配置文件加载短信验证码配置
+func LoadSMSConfig() *SMSConfig {
+	return &SMSConfig{
+		CodeLength:        config.GetInt("sms.code_length"),
+		CodeTTL:           time.Duration(config.GetInt("sms.code_ttl")) * time.Second,
+		SendInterval:      time.Duration(config.GetInt("sms.send_interval")) * time.Second,
+		PhoneDailyLimit:   int64(config.GetInt("sms.phone_daily_limit")),
+		IPHourlyLimit:     int64(config.GetInt("sms.ip_hourly_limit")),
+		MaxVerifyAttempts: int64(config.GetInt("sms.max_verify_attempts")),
+		LoginTemplate:     config.GetString("sms.login_template"),
+		Secret:            config.GetString("jwt.secret"),
+	}
+}
+
+// NewSMSService 创建短信验证码服务实例
+func NewSMSService() SMSService {
+	return &smsServiceImpl{
+		db:          database.GetDB(),
+		userService: NewUserService(),
+		sender:      sms.GetSender(),
+		cache:       database.GetCache(),
+		cfg:         LoadSMSConfig(),
+	}
+}
+
+// SendLoginCode 发送登录验证码
+func (s *smsServiceImpl) SendLoginCode(ctx context.Context, phone string) error {
+	if err := validatePhone(phone); err != nil {
+		return common.ErrInvalidPhone
+	}
+
+	tenantID := database.GetTenantIDFromContext(ctx)
+	target := tenantID + ":" + phone
+
+	// 同一手机号发送间隔
+	ok, err := s.cache.SetNX(ctx, smsIntervalKeyPrefix+target, "1", s.cfg.SendInterval)
+	if err != nil {
+		return fmt.Errorf("检查发送间隔失败: %w", err)
+	}
+	if !ok {
+		return common.ErrSMSSendTooFrequent
+	}
+
+	// 同一手机号每日上限
+	if s.cfg.PhoneDailyLimit > 0 {
+		key := smsPhoneDayKeyPrefix + target + ":" + time.Now().Format("20060102")
+		count, err := s.cache.Incr(ctx, key, 24*time.Hour)
+		if err != nil {
+			return fmt.Errorf("检查发送次数失败: %w", err)
+		}
+		if count > s.cfg.PhoneDailyLimit {
+			return common.ErrSMSSendLimit
+		}
+	}
+
+	// 同一IP每小时上限
+	if ip := GetClientInfoFromContext(ctx).IP; ip != "" && s.cfg.IPHourlyLimit > 0 {
+		key := smsIPHourKeyPrefix + ip + ":" + time.Now().Format("2006010215")
+		count, err := s.cache.Incr(ctx, key, time.Hour)
+		if err != nil {
+			return fmt.Errorf("检查发送次数失败: %w", err)
+		}
+		if count > s.cfg.IPHourlyLimit {
+			return common.ErrSMSSendLimit
+		}
+	}
+
+	code, err := generateNumericCode(s.cfg.CodeLength)
+	if err != nil {
+		return fmt.Errorf("生成验证码失败: %w", err)
+	}
+
+	// 仅保存验证码哈希，重新发送会使旧验证码失效
+	codeKey := smsCodeKeyPrefix + SMSSceneLogin + ":" + target
+	if err := s.cache.Set(ctx, codeKey, s.hashCode(SMSSceneLogin, target, code), s.cfg.CodeTTL); err != nil {
+		return fmt.Errorf("保存验证码失败: %w", err)
+	}
+	if err := s.cache.Delete(ctx, smsAttemptKeyPrefix+SMSSceneLogin+":"+target); err != nil {
+		return fmt.Errorf("保存验证码失败: %w", err)
+	}
+
+	content := fmt.Sprintf(s.cfg.LoginTemplate, code, int(s.cfg.CodeTTL/time.Minute))
+	if err := s.sender.Send(ctx, phone, content); err != nil {
+		s.cache.Delete(ctx, codeKey)
+		return common.ErrSMSSendFailed
+	}
+
+	return nil
+}
+
+// LoginWithCode 验证码登录
+func (s *smsServiceImpl) LoginWithCode(ctx context.Context, req *SMSLoginRequest) (*SMSLoginResponse, error) {
+	if err := validatePhone(req.Phone); err != nil {
+		return nil, common.ErrInvalidPhone
+	}
+
+	if err := s.verifyCode(ctx, SMSSceneLogin, req.Phone, req.Code); err != nil {
+		return nil, err
+	}
+
+	// 查找用户，不存在时自动注册
+	isNewUser := false
+	user, err := s.userService.GetByPhone(ctx, req.Phone)
+	if err == common.ErrUserNotFound {
+		// 手机号被禁用或锁定的用户占用时不重复注册
+		var existing models.User
+		err := s.db.WithContext(ctx).
+			Where("phone = ? AND tenant_id = ?", req.Phone, database.GetTenantIDFromContext(ctx)).
+			First(&existing).Error
+		if err == nil {
+			if existing.IsLocked() {
+				return nil, common.ErrUserLocked
+			}
+			return nil, common.ErrUserDisabled
+		}
+		if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+
+		user, err = s.createUserByPhone(ctx, req.Phone)
+		if err != nil {
+			return nil, err
+		}
+		isNewUser = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	// 与密码登录相同，启用两步验证时仅返回临时令牌
+	loginResp, err := s.userService.CompleteLogin(ctx, user, models.LoginTypeSMS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SMSLoginResponse{
+		User:        loginResp.User,
+		Tokens:      loginResp.Tokens,
+		IsNewUser:   isNewUser,
+		MFARequired: loginResp.MFARequired,
+		MFAToken:    loginResp.MFAToken,
+	}, nil
+}
+
+// verifyCode 校验验证码，校验成功后验证码立即失效
+func (s *smsServiceImpl) verifyCode(ctx context.Context, scene, phone, code string) error {
+	target := database.GetTenantIDFromContext(ctx) + ":" + phone
+	codeKey := smsCodeKeyPrefix + scene + ":" + target
+	attemptKey := smsAttemptKeyPrefix + scene + ":" + target
+
+	stored, err := s.cache.Get(ctx, codeKey)
+	if err == cache.ErrNotFound {
+		return common.ErrInvalidSMSCode
+	}
+	if err != nil {
+		return fmt.Errorf("读取验证码失败: %w", err)
+	}
+
+	// 校验次数过多时作废验证码，防止穷举
+	attempts, err := s.cache.Incr(ctx, attemptKey, s.cfg.CodeTTL)
+	if err != nil {
+		return fmt.Errorf("记录校验次数失败: %w", err)
+	}
+	if s.cfg.MaxVerifyAttempts > 0 && attempts > s.cfg.MaxVerifyAttempts {
+		s.cache.Delete(ctx, codeKey, attemptKey)
+		return common.ErrInvalidSMSCode
+	}
+
+	if !hmac.Equal([]byte(stored), []byte(s.hashCode(scene, target, code))) {
+		return common.ErrInvalidSMSCode
+	}
+
+	if err := s.cache.Delete(ctx, codeKey, attemptKey); err != nil {
+		return fmt.Errorf("删除验证码失败: %w", err)
+	}
+	return nil
+}
+
+// createUserByPhone 使用手机号创建用户
+func (s *smsServiceImpl) createUserByPhone(ctx context.Context, phone string) (*models.User, error) {
+	suffix, err := NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+	username := "u_" + suffix[:12]
+
+	// 验证码注册的用户没有密码，生成随机密码，用户可通过找回密码设置
+	password, err := NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	// 不生成占位邮箱，邮箱留空（存储为NULL），用户可在资料中补充
+	user := &models.User{
+		Username: username,
+		Phone:    phone,
+		Nickname: "用户" + phone[len(phone)-4:],
+	}
+	user.TenantID = database.GetTenantIDFromContext(ctx)
+	if err := user.HashPassword(password); err != nil {
+		return nil, fmt.Errorf("密码加密失败: %w", err)
+	}
+
+	if err := s.db.WithContext(ctx).Create(user).Error; err != nil {
+		return nil, fmt.Errorf("创建用户失败: %w", err)
+	}
+
+	return user, nil
+}
+
+// hashCode 计算验证码哈希
+func (s *smsServiceImpl) hashCode(scene, target, code string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.Secret))
+	mac.Write([]byte(scene + ":" + target + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateNumericCode 生成数字验证码
+func generateNumericCode(length int) (string, error) {
+	if length <= 0 {
+		length = 6
+	}
+
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		code[i] = byte('0' + n.Int64())
+	}
+	return string(code), nil
+}
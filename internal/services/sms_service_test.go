@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/sms"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestSMSService 创建使用控制台发送器的短信服务
+func setupTestSMSService(t *testing.T) (*smsServiceImpl, *sms.ConsoleSender) {
+	config.Init()
+	db := setupTestDB(t)
+	memCache := cache.NewMemoryCache()
+	sender := sms.NewConsoleSender(false)
+
+	service := &smsServiceImpl{
+		db:          db,
+		userService: &userServiceImpl{db: db, jwtService: NewJWTService(), cache: memCache},
+		sender:      sender,
+		cache:       memCache,
+		cfg: &SMSConfig{
+			CodeLength:        6,
+			CodeTTL:           5 * time.Minute,
+			SendInterval:      time.Minute,
+			PhoneDailyLimit:   10,
+			IPHourlyLimit:     2,
+			MaxVerifyAttempts: 3,
+			LoginTemplate:     "验证码%s，%d分钟内有效",
+			Secret:            "test-secret",
+		},
+	}
+	return service, sender
+}
+
+// lastSMSCode 从最后一条短信中提取验证码
+func lastSMSCode(t *testing.T, sender *sms.ConsoleSender, phone string) string {
+	msg, ok := sender.LastMessage(phone)
+	require.True(t, ok)
+	code := regexp.MustCompile(`\d{6}`).FindString(msg.Content)
+	require.NotEmpty(t, code)
+	return code
+}
+
+func TestSMSService_LoginWithCode(t *testing.T) {
+	service, sender := setupTestSMSService(t)
+	ctx := context.Background()
+	phone := "13800138000"
+
+	require.NoError(t, service.SendLoginCode(ctx, phone))
+	code := lastSMSCode(t, sender, phone)
+
+	// 首次登录自动创建用户
+	resp, err := service.LoginWithCode(ctx, &SMSLoginRequest{Phone: phone, Code: code})
+	require.NoError(t, err)
+	assert.True(t, resp.IsNewUser)
+	assert.Equal(t, phone, resp.User.Phone)
+	assert.NotEmpty(t, resp.Tokens.AccessToken)
+
+	// 验证码仅能使用一次
+	_, err = service.LoginWithCode(ctx, &SMSLoginRequest{Phone: phone, Code: code})
+	assert.Equal(t, common.ErrInvalidSMSCode, err)
+
+	// 再次登录返回已有用户
+	service.cache.Delete(ctx, smsIntervalKeyPrefix+"default:"+phone)
+	require.NoError(t, service.SendLoginCode(ctx, phone))
+	resp2, err := service.LoginWithCode(ctx, &SMSLoginRequest{Phone: phone, Code: lastSMSCode(t, sender, phone)})
+	require.NoError(t, err)
+	assert.False(t, resp2.IsNewUser)
+	assert.Equal(t, resp.User.ID, resp2.User.ID)
+
+	// 每次验证码登录创建独立会话
+	var count int64
+	service.db.Model(&models.UserSession{}).Where("user_id = ? AND login_type = ?", resp.User.ID, models.LoginTypeSMS).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	// 自动注册不生成占位邮箱，多个用户的空邮箱不冲突
+	otherPhone := "13900139000"
+	require.NoError(t, service.SendLoginCode(ctx, otherPhone))
+	resp3, err := service.LoginWithCode(ctx, &SMSLoginRequest{Phone: otherPhone, Code: lastSMSCode(t, sender, otherPhone)})
+	require.NoError(t, err)
+	assert.True(t, resp3.IsNewUser)
+	for _, id := range []uint64{resp.User.ID, resp3.User.ID} {
+		var stored models.User
+		require.NoError(t, service.db.First(&stored, id).Error)
+		assert.Empty(t, stored.Email)
+	}
+}
+
+func TestSMSService_VerifyAttempts(t *testing.T) {
+	service, sender := setupTestSMSService(t)
+	ctx := context.Background()
+	phone := "13800138000"
+
+	require.NoError(t, service.SendLoginCode(ctx, phone))
+	code := lastSMSCode(t, sender, phone)
+
+	// 缓存中只保存验证码哈希
+	stored, err := service.cache.Get(ctx, smsCodeKeyPrefix+SMSSceneLogin+":default:"+phone)
+	require.NoError(t, err)
+	assert.NotContains(t, stored, code)
+
+	// 超过校验次数后正确验证码也失效
+	for i := 0; i < 3; i++ {
+		_, err = service.LoginWithCode(ctx, &SMSLoginRequest{Phone: phone, Code: "000000x"})
+		assert.Equal(t, common.ErrInvalidSMSCode, err)
+	}
+	_, err = service.LoginWithCode(ctx, &SMSLoginRequest{Phone: phone, Code: code})
+	assert.Equal(t, common.ErrInvalidSMSCode, err)
+}
+
+func TestSMSService_SendLimits(t *testing.T) {
+	service, _ := setupTestSMSService(t)
+	ctx := WithClientInfo(context.Background(), &ClientInfo{IP: "10.0.0.1"})
+
+	// 手机号格式校验
+	assert.Equal(t, common.ErrInvalidPhone, service.SendLoginCode(ctx, "12345"))
+
+	// 发送间隔内不能重复发送
+	require.NoError(t, service.SendLoginCode(ctx, "13800138000"))
+	assert.Equal(t, common.ErrSMSSendTooFrequent, service.SendLoginCode(ctx, "13800138000"))
+
+	// 同一IP每小时上限
+	require.NoError(t, service.SendLoginCode(ctx, "13800138001"))
+	assert.Equal(t, common.ErrSMSSendLimit, service.SendLoginCode(ctx, "13800138002"))
+}
@@ -0,0 +1,546 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"member-link-lite/config"
+	"member-link-lite/internal/database"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// socialStateKeyPrefix 第三方授权state缓存键前缀
+const socialStateKeyPrefix = "social:state:"
+
+// SocialLoginService 第三方账号登录服务接口
+type SocialLoginService interface {
+	// 获取已启用的登录方式
+	Providers(ctx context.Context) []string
+	// 生成第三方授权地址，userID为0时用于登录，否则用于绑定
+	AuthURL(ctx context.Context, provider string, userID uint64) (*SocialAuthURLResponse, error)
+	// 使用授权码登录，账号不存在时自动注册
+	Login(ctx context.Context, provider string, req *SocialLoginRequest) (*SocialLoginResponse, error)
+	// 使用已换取的第三方身份登录，账号不存在时自动注册
+	LoginWithIdentity(ctx context.Context, identity *ExternalIdentity) (*SocialLoginResponse, error)
+	// 获取已绑定的第三方账号
+	ListIdentities(ctx context.Context, userID uint64) ([]*models.UserIdentity, error)
+	// 绑定第三方账号
+	Bind(ctx context.Context, userID uint64, provider string, req *SocialLoginRequest) (*models.UserIdentity, error)
+	// 解绑第三方账号
+	Unbind(ctx context.Context, userID uint64, provider string) error
+}
+
+// SocialLoginRequest 第三方登录/绑定请求
+type SocialLoginRequest struct {
+	Code  string `json:"code" binding:"required" example:"061Xyz"` // 第三方回调返回的授权码
+	State string `json:"state" example:"a1b2c3"`                   // 网页授权回调返回的state，小程序登录无需提供
+}
+
+// SocialAuthURLResponse 第三方授权地址
+type SocialAuthURLResponse struct {
+	URL   string `json:"url"`   // 第三方授权页地址
+	State string `json:"state"` // 防CSRF状态值，回调时原样提交
+}
+
+// SocialLoginResponse 第三方登录响应
+// 用户启用两步验证时只返回MFARequired和MFAToken，需调用两步验证接口完成登录
+type SocialLoginResponse struct {
+	User        *models.User      `json:"user,omitempty"`
+	Tokens      *TokenResponse    `json:"tokens,omitempty"`
+	IsNewUser   bool              `json:"is_new_user"`
+	Identity    *ExternalIdentity `json:"identity,omitempty"`
+	MFARequired bool              `json:"mfa_required,omitempty"`
+	MFAToken    string            `json:"mfa_token,omitempty"`
+}
+
+// socialState 授权state关联的信息
+type socialState struct {
+	Provider string `json:"provider"`
+	UserID   uint64 `json:"user_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+// socialLoginServiceImpl 第三方账号登录服务实现
+type socialLoginServiceImpl struct {
+	db              *gorm.DB
+	userService     UserService
+	cache           cache.Cache
+	stateTTL        time.Duration
+	providerFactory func(tenantID, name string) (SocialProvider, error)
+	mergeService    AccountMergeService
+}
+
+// NewSocialLoginService 创建第三方账号登录服务实例
+func NewSocialLoginService() SocialLoginService {
+	return &socialLoginServiceImpl{
+		db:              database.GetDB(),
+		userService:     NewUserService(),
+		cache:           database.GetCache(),
+		stateTTL:        time.Duration(config.GetInt("social.state_ttl")) * time.Second,
+		providerFactory: NewSocialProvider,
+		mergeService:    NewAccountMergeService(),
+	}
+}
+
+// Providers 获取已启用的登录方式
+func (s *socialLoginServiceImpl) Providers(ctx context.Context) []string {
+	return EnabledSocialProviders(database.GetTenantIDFromContext(ctx))
+}
+
+// AuthURL 生成第三方授权地址
+func (s *socialLoginServiceImpl) AuthURL(ctx context.Context, provider string, userID uint64) (*SocialAuthURLResponse, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	p, err := s.providerFactory(tenantID, provider)
+	if err != nil {
+		return nil, err
+	}
+	redirectProvider, ok := p.(RedirectSocialProvider)
+	if !ok {
+		return nil, common.NewCustomError(common.CodeBadRequest, "该登录方式无需跳转授权")
+	}
+
+	state, err := randomHex(16)
+	if err != nil {
+		return nil, fmt.Errorf("生成state失败: %w", err)
+	}
+	payload, err := json.Marshal(&socialState{Provider: provider, UserID: userID, TenantID: tenantID})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cache.Set(ctx, socialStateKeyPrefix+state, string(payload), s.stateTTL); err != nil {
+		return nil, fmt.Errorf("保存state失败: %w", err)
+	}
+
+	return &SocialAuthURLResponse{
+		URL:   redirectProvider.AuthCodeURL(state),
+		State: state,
+	}, nil
+}
+
+// Login 使用授权码登录
+func (s *socialLoginServiceImpl) Login(ctx context.Context, provider string, req *SocialLoginRequest) (*SocialLoginResponse, error) {
+	identity, err := s.exchange(ctx, provider, req, 0)
+	if err != nil {
+		return nil, err
+	}
+	return s.LoginWithIdentity(ctx, identity)
+}
+
+// LoginWithIdentity 使用第三方身份登录
+// 查找顺序：已绑定的身份 -> 手机号相同的账号 -> UnionID相同的其他身份，均未找到时自动注册
+// 手机号对应的账号与第三方账号已绑定的账号不同时，将后者合并到手机号对应的账号
+func (s *socialLoginServiceImpl) LoginWithIdentity(ctx context.Context, identity *ExternalIdentity) (*SocialLoginResponse, error) {
+	if identity == nil || identity.ProviderUserID == "" {
+		return nil, common.ErrSocialAuthFailed
+	}
+
+	user, bound, err := s.findUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	isNewUser := false
+	if user == nil {
+		user, err = s.createUser(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+		isNewUser = true
+	} else {
+		if user.IsLocked() {
+			return nil, common.ErrUserLocked
+		}
+		if !user.IsActive() {
+			return nil, common.ErrUserDisabled
+		}
+		if err := s.linkIdentity(ctx, user, bound, identity); err != nil {
+			return nil, err
+		}
+	}
+
+	loginType := models.LoginTypeSocial
+	if identity.Provider == models.IdentityProviderWeChatMini {
+		loginType = models.LoginTypeWeChat
+	}
+
+	// 与密码登录相同，启用两步验证时仅返回临时令牌
+	loginResp, err := s.userService.CompleteLogin(ctx, user, loginType)
+	if err != nil {
+		return nil, err
+	}
+	if loginResp.MFARequired {
+		return &SocialLoginResponse{
+			MFARequired: true,
+			MFAToken:    loginResp.MFAToken,
+		}, nil
+	}
+
+	return &SocialLoginResponse{
+		User:      loginResp.User,
+		Tokens:    loginResp.Tokens,
+		IsNewUser: isNewUser,
+		Identity:  identity,
+	}, nil
+}
+
+// ListIdentities 获取已绑定的第三方账号
+func (s *socialLoginServiceImpl) ListIdentities(ctx context.Context, userID uint64) ([]*models.UserIdentity, error) {
+	var identities []*models.UserIdentity
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("id ASC").
+		Find(&identities).Error; err != nil {
+		return nil, fmt.Errorf("查询绑定账号失败: %w", err)
+	}
+	return identities, nil
+}
+
+// Bind 绑定第三方账号
+func (s *socialLoginServiceImpl) Bind(ctx context.Context, userID uint64, provider string, req *SocialLoginRequest) (*models.UserIdentity, error) {
+	identity, err := s.exchange(ctx, provider, req, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.UserIdentity
+	err = s.db.WithContext(ctx).
+		Where("provider = ? AND provider_user_id = ? AND tenant_id = ?", identity.Provider, identity.ProviderUserID, user.TenantID).
+		First(&existing).Error
+	if err == nil {
+		if existing.UserID == userID {
+			return &existing, nil
+		}
+		return nil, common.ErrIdentityAlreadyBound
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询绑定账号失败: %w", err)
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).
+		Model(&models.UserIdentity{}).
+		Where("user_id = ? AND provider = ?", userID, identity.Provider).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询绑定账号失败: %w", err)
+	}
+	if count > 0 {
+		return nil, common.ErrProviderAlreadyBound
+	}
+
+	record := newUserIdentity(user, identity)
+	if err := s.db.WithContext(ctx).Create(record).Error; err != nil {
+		return nil, fmt.Errorf("绑定账号失败: %w", err)
+	}
+
+	return record, nil
+}
+
+// Unbind 解绑第三方账号
+// 解绑后账号须仍可登录：保留至少一个第三方账号，或已有真实手机号/已验证邮箱
+func (s *socialLoginServiceImpl) Unbind(ctx context.Context, userID uint64, provider string) error {
+	var identity models.UserIdentity
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND provider = ?", userID, provider).
+		First(&identity).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return common.ErrIdentityNotFound
+		}
+		return fmt.Errorf("查询绑定账号失败: %w", err)
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).
+		Model(&models.UserIdentity{}).
+		Where("user_id = ?", userID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查询绑定账号失败: %w", err)
+	}
+	if count <= 1 {
+		user, err := s.userService.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if validatePhone(user.Phone) != nil && !user.IsEmailVerified() {
+			return common.ErrLastLoginMethod
+		}
+	}
+
+	// 物理删除，以便该第三方账号之后重新绑定
+	if err := s.db.WithContext(ctx).Unscoped().Delete(&identity).Error; err != nil {
+		return fmt.Errorf("解绑账号失败: %w", err)
+	}
+	return nil
+}
+
+// exchange 校验state并使用授权码换取第三方身份
+func (s *socialLoginServiceImpl) exchange(ctx context.Context, provider string, req *SocialLoginRequest, userID uint64) (*ExternalIdentity, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	p, err := s.providerFactory(tenantID, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	// 网页跳转授权需校验state，state仅能使用一次且须与发起方一致
+	if _, ok := p.(RedirectSocialProvider); ok {
+		if err := s.consumeState(ctx, req.State, &socialState{Provider: provider, UserID: userID, TenantID: tenantID}); err != nil {
+			return nil, err
+		}
+	}
+
+	identity, err := p.Exchange(ctx, req.Code)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"provider": provider,
+			"error":    err.Error(),
+		}).Warn("第三方授权失败")
+		return nil, common.ErrSocialAuthFailed
+	}
+	if identity.ProviderUserID == "" {
+		return nil, common.ErrSocialAuthFailed
+	}
+	identity.Provider = p.Name()
+
+	return identity, nil
+}
+
+// consumeState 校验并作废state
+func (s *socialLoginServiceImpl) consumeState(ctx context.Context, state string, expected *socialState) error {
+	if state == "" {
+		return common.ErrInvalidSocialState
+	}
+
+	key := socialStateKeyPrefix + state
+	value, err := s.cache.Get(ctx, key)
+	if err == cache.ErrNotFound {
+		return common.ErrInvalidSocialState
+	}
+	if err != nil {
+		return fmt.Errorf("读取state失败: %w", err)
+	}
+	if err := s.cache.Delete(ctx, key); err != nil {
+		return fmt.Errorf("删除state失败: %w", err)
+	}
+
+	var stored socialState
+	if err := json.Unmarshal([]byte(value), &stored); err != nil || stored != *expected {
+		return common.ErrInvalidSocialState
+	}
+	return nil
+}
+
+// findUser 查找第三方身份对应的账号，返回账号及已绑定的身份记录（未绑定时为nil）
+func (s *socialLoginServiceImpl) findUser(ctx context.Context, identity *ExternalIdentity) (*models.User, *models.UserIdentity, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+	db := s.db.WithContext(ctx)
+
+	var bound *models.UserIdentity
+	var record models.UserIdentity
+	err := db.Where("provider = ? AND provider_user_id = ? AND tenant_id = ?", identity.Provider, identity.ProviderUserID, tenantID).
+		First(&record).Error
+	if err == nil {
+		bound = &record
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, nil, fmt.Errorf("查询绑定账号失败: %w", err)
+	}
+
+	// 提供方返回手机号时优先使用手机号对应的账号，避免同一手机号出现多个账号
+	if identity.Phone != "" {
+		var user models.User
+		err := db.Where("phone = ? AND tenant_id = ?", identity.Phone, tenantID).First(&user).Error
+		if err == nil {
+			if bound != nil && bound.UserID != user.ID {
+				bound, err = s.mergeBoundAccount(ctx, &user, bound)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			return &user, bound, nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return nil, nil, fmt.Errorf("查询用户失败: %w", err)
+		}
+	}
+
+	userID := uint64(0)
+	if bound != nil {
+		userID = bound.UserID
+	} else if identity.UnionID != "" {
+		// 同一开放平台下的其他应用已绑定时，关联到同一账号
+		var sibling models.UserIdentity
+		err := db.Where("union_id = ? AND tenant_id = ?", identity.UnionID, tenantID).First(&sibling).Error
+		if err == nil {
+			userID = sibling.UserID
+		} else if err != gorm.ErrRecordNotFound {
+			return nil, nil, fmt.Errorf("查询绑定账号失败: %w", err)
+		}
+	}
+	if userID == 0 {
+		return nil, nil, nil
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	return &user, bound, nil
+}
+
+// mergeBoundAccount 第三方账号已绑定其他账号、手机号又属于当前账号时，将第三方账号所属账号合并到手机号对应的账号
+// 返回合并后的身份记录；无法合并时保持原绑定不变，返回nil
+func (s *socialLoginServiceImpl) mergeBoundAccount(ctx context.Context, user *models.User, bound *models.UserIdentity) (*models.UserIdentity, error) {
+	_, err := s.mergeService.Merge(ctx, &MergeAccountsRequest{
+		PrimaryUserID:   user.ID,
+		SecondaryUserID: bound.UserID,
+		Remark:          fmt.Sprintf("%s登录授权的手机号已属于其他账号", bound.Provider),
+	}, models.AccountMergeReasonWeChatPhone, 0)
+	if err != nil {
+		if err != common.ErrMergeIdentityConflict && err != common.ErrMergeAccountInactive {
+			return nil, err
+		}
+		logger.WithFields(logrus.Fields{
+			"provider":      bound.Provider,
+			"phone_user_id": user.ID,
+			"bound_user_id": bound.UserID,
+			"error":         err.Error(),
+		}).Warn("第三方账号与手机号分属不同账号，无法合并")
+		return nil, nil
+	}
+
+	var record models.UserIdentity
+	if err := s.db.WithContext(ctx).First(&record, bound.ID).Error; err != nil {
+		return nil, fmt.Errorf("查询绑定账号失败: %w", err)
+	}
+	return &record, nil
+}
+
+// linkIdentity 更新已绑定身份的资料，未绑定时绑定到账号
+func (s *socialLoginServiceImpl) linkIdentity(ctx context.Context, user *models.User, bound *models.UserIdentity, identity *ExternalIdentity) error {
+	now := time.Now()
+
+	if bound != nil {
+		updates := map[string]interface{}{"last_login_at": now}
+		if identity.Nickname != "" {
+			updates["nickname"] = identity.Nickname
+		}
+		if identity.Avatar != "" {
+			updates["avatar"] = identity.Avatar
+		}
+		if identity.UnionID != "" {
+			updates["union_id"] = identity.UnionID
+		}
+		if err := s.db.WithContext(ctx).Model(bound).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新绑定账号失败: %w", err)
+		}
+	} else {
+		// 账号已绑定同一登录方式的其他第三方账号，或该第三方账号已绑定其他账号时不修改绑定
+		var count int64
+		if err := s.db.WithContext(ctx).
+			Model(&models.UserIdentity{}).
+			Where("provider = ? AND (user_id = ? OR (provider_user_id = ? AND tenant_id = ?))", identity.Provider, user.ID, identity.ProviderUserID, user.TenantID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("查询绑定账号失败: %w", err)
+		}
+		if count == 0 {
+			record := newUserIdentity(user, identity)
+			record.LastLoginAt = &now
+			if err := s.db.WithContext(ctx).Create(record).Error; err != nil {
+				return fmt.Errorf("绑定账号失败: %w", err)
+			}
+		}
+	}
+
+	// 账号尚未绑定真实手机号时补充提供方返回的手机号
+	if identity.Phone != "" && identity.Phone != user.Phone && validatePhone(user.Phone) != nil {
+		if err := s.db.WithContext(ctx).Model(user).Update("phone", identity.Phone).Error; err != nil {
+			return fmt.Errorf("更新手机号失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// createUser 使用第三方身份创建账号
+func (s *socialLoginServiceImpl) createUser(ctx context.Context, identity *ExternalIdentity) (*models.User, error) {
+	suffix, err := NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+	prefix := "s_"
+	if strings.HasPrefix(identity.Provider, "wechat") {
+		prefix = "wx_"
+	}
+	username := prefix + suffix[:12]
+
+	// 第三方登录注册的用户没有密码，生成随机密码，用户可通过找回密码设置
+	password, err := NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	nickname := identity.Nickname
+	if nickname == "" {
+		nickname = "用户" + suffix[:6]
+	}
+
+	user := &models.User{
+		Username: username,
+		Phone:    identity.Phone, // 提供方未返回手机号时为NULL，邮箱同样留空
+		Nickname: nickname,
+		Avatar:   identity.Avatar,
+	}
+	user.TenantID = database.GetTenantIDFromContext(ctx)
+	if err := user.HashPassword(password); err != nil {
+		return nil, fmt.Errorf("密码加密失败: %w", err)
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return fmt.Errorf("创建用户失败: %w", err)
+		}
+
+		now := time.Now()
+		record := newUserIdentity(user, identity)
+		record.LastLoginAt = &now
+		if err := tx.Create(record).Error; err != nil {
+			return fmt.Errorf("绑定账号失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// newUserIdentity 构造身份绑定记录
+func newUserIdentity(user *models.User, identity *ExternalIdentity) *models.UserIdentity {
+	record := &models.UserIdentity{
+		UserID:         user.ID,
+		Provider:       identity.Provider,
+		ProviderUserID: identity.ProviderUserID,
+		UnionID:        identity.UnionID,
+		Nickname:       identity.Nickname,
+		Avatar:         identity.Avatar,
+	}
+	record.TenantID = user.TenantID
+	return record
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fakeSocialProvider 测试用第三方登录提供方，授权码即用户标识
+type fakeSocialProvider struct {
+	name     string
+	unionIDs map[string]string
+}
+
+func (p *fakeSocialProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeSocialProvider) Exchange(ctx context.Context, code string) (*ExternalIdentity, error) {
+	if code == "bad" {
+		return nil, errors.New("invalid code")
+	}
+	return &ExternalIdentity{ProviderUserID: code, UnionID: p.unionIDs[code], Nickname: "第三方用户"}, nil
+}
+
+// fakeRedirectSocialProvider 测试用网页跳转授权提供方
+type fakeRedirectSocialProvider struct {
+	fakeSocialProvider
+}
+
+func (p *fakeRedirectSocialProvider) AuthCodeURL(state string) string {
+	return "https://auth.example.com/authorize?state=" + state
+}
+
+func setupSocialLoginService(t *testing.T) (*socialLoginServiceImpl, *gorm.DB) {
+	config.Init()
+	logger.Init()
+	db := setupTestDB(t)
+
+	unionIDs := map[string]string{"mini_a": "union_1", "web_a": "union_1"}
+	providers := map[string]SocialProvider{
+		models.IdentityProviderWeChatMini: &fakeSocialProvider{name: models.IdentityProviderWeChatMini, unionIDs: unionIDs},
+		models.IdentityProviderWeChatWeb:  &fakeRedirectSocialProvider{fakeSocialProvider{name: models.IdentityProviderWeChatWeb, unionIDs: unionIDs}},
+	}
+
+	service := &socialLoginServiceImpl{
+		db:          db,
+		userService: &userServiceImpl{db: db, jwtService: NewJWTService(), cache: cache.NewMemoryCache()},
+		cache:       cache.NewMemoryCache(),
+		stateTTL:    time.Minute,
+		providerFactory: func(tenantID, name string) (SocialProvider, error) {
+			if p, ok := providers[name]; ok {
+				return p, nil
+			}
+			return nil, common.ErrSocialProviderNotFound
+		},
+		mergeService: &accountMergeServiceImpl{db: db, jwtService: NewJWTService()},
+	}
+	return service, db
+}
+
+func TestSocialLoginService_Login(t *testing.T) {
+	service, db := setupSocialLoginService(t)
+	ctx := context.Background()
+
+	t.Run("首次登录自动注册", func(t *testing.T) {
+		resp, err := service.Login(ctx, models.IdentityProviderWeChatMini, &SocialLoginRequest{Code: "mini_a"})
+		require.NoError(t, err)
+		assert.True(t, resp.IsNewUser)
+		assert.NotEmpty(t, resp.Tokens.AccessToken)
+		assert.Contains(t, resp.User.Username, "wx_")
+
+		var identity models.UserIdentity
+		require.NoError(t, db.Where("provider_user_id = ?", "mini_a").First(&identity).Error)
+		assert.Equal(t, resp.User.ID, identity.UserID)
+		assert.Equal(t, "union_1", identity.UnionID)
+	})
+
+	t.Run("自动注册不生成占位手机号和邮箱", func(t *testing.T) {
+		resp, err := service.Login(ctx, models.IdentityProviderWeChatMini, &SocialLoginRequest{Code: "mini_c"})
+		require.NoError(t, err)
+		assert.Empty(t, resp.User.Phone)
+		assert.Empty(t, resp.User.Email)
+
+		var nullCount int64
+		db.Model(&models.User{}).Where("phone IS NULL AND email IS NULL").Count(&nullCount)
+		assert.Equal(t, int64(2), nullCount)
+	})
+
+	t.Run("不同租户可分别绑定同一第三方账号", func(t *testing.T) {
+		tenantCtx := context.WithValue(ctx, "tenant_id", "company1")
+		resp, err := service.Login(tenantCtx, models.IdentityProviderWeChatMini, &SocialLoginRequest{Code: "mini_a"})
+		require.NoError(t, err)
+		assert.True(t, resp.IsNewUser)
+		assert.Equal(t, "company1", resp.User.TenantID)
+
+		var count int64
+		db.Model(&models.UserIdentity{}).Where("provider_user_id = ?", "mini_a").Count(&count)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("再次登录返回同一账号", func(t *testing.T) {
+		first, err := service.Login(ctx, models.IdentityProviderWeChatMini, &SocialLoginRequest{Code: "mini_b"})
+		require.NoError(t, err)
+		second, err := service.Login(ctx, models.IdentityProviderWeChatMini, &SocialLoginRequest{Code: "mini_b"})
+		require.NoError(t, err)
+		assert.False(t, second.IsNewUser)
+		assert.Equal(t, first.User.ID, second.User.ID)
+	})
+
+	t.Run("网页授权须使用有效state", func(t *testing.T) {
+		_, err := service.Login(ctx, models.IdentityProviderWeChatWeb, &SocialLoginRequest{Code: "web_a"})
+		assert.Equal(t, common.ErrInvalidSocialState, err)
+
+		_, err = service.Login(ctx, models.IdentityProviderWeChatWeb, &SocialLoginRequest{Code: "web_a", State: "forged"})
+		assert.Equal(t, common.ErrInvalidSocialState, err)
+	})
+
+	t.Run("UnionID相同登录到同一账号", func(t *testing.T) {
+		authURL, err := service.AuthURL(ctx, models.IdentityProviderWeChatWeb, 0)
+		require.NoError(t, err)
+		parsed, err := url.Parse(authURL.URL)
+		require.NoError(t, err)
+		assert.Equal(t, authURL.State, parsed.Query().Get("state"))
+
+		resp, err := service.Login(ctx, models.IdentityProviderWeChatWeb, &SocialLoginRequest{Code: "web_a", State: authURL.State})
+		require.NoError(t, err)
+		assert.False(t, resp.IsNewUser)
+
+		var identity models.UserIdentity
+		require.NoError(t, db.Where("provider_user_id = ?", "mini_a").First(&identity).Error)
+		assert.Equal(t, identity.UserID, resp.User.ID)
+
+		// state仅能使用一次
+		_, err = service.Login(ctx, models.IdentityProviderWeChatWeb, &SocialLoginRequest{Code: "web_a", State: authURL.State})
+		assert.Equal(t, common.ErrInvalidSocialState, err)
+	})
+
+	t.Run("授权失败", func(t *testing.T) {
+		_, err := service.Login(ctx, models.IdentityProviderWeChatMini, &SocialLoginRequest{Code: "bad"})
+		assert.Equal(t, common.ErrSocialAuthFailed, err)
+
+		_, err = service.Login(ctx, "unknown", &SocialLoginRequest{Code: "x"})
+		assert.Equal(t, common.ErrSocialProviderNotFound, err)
+	})
+}
+
+func TestSocialLoginService_BindAndUnbind(t *testing.T) {
+	service, db := setupSocialLoginService(t)
+	ctx := context.Background()
+
+	user := &models.User{Username: "binduser", Password: "x", Email: "bind@example.com", Phone: "13800138001"}
+	require.NoError(t, db.Create(user).Error)
+
+	t.Run("绑定第三方账号", func(t *testing.T) {
+		identity, err := service.Bind(ctx, user.ID, models.IdentityProviderWeChatMini, &SocialLoginRequest{Code: "bind_a"})
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, identity.UserID)
+
+		identities, err := service.ListIdentities(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Len(t, identities, 1)
+	})
+
+	t.Run("同一登录方式只能绑定一个账号", func(t *testing.T) {
+		_, err := service.Bind(ctx, user.ID, models.IdentityProviderWeChatMini, &SocialLoginRequest{Code: "bind_b"})
+		assert.Equal(t, common.ErrProviderAlreadyBound, err)
+	})
+
+	t.Run("第三方账号已绑定其他用户", func(t *testing.T) {
+		other := &models.User{Username: "otheruser", Password: "x", Email: "other@example.com", Phone: "13800138002"}
+		require.NoError(t, db.Create(other).Error)
+
+		_, err := service.Bind(ctx, other.ID, models.IdentityProviderWeChatMini, &SocialLoginRequest{Code: "bind_a"})
+		assert.Equal(t, common.ErrIdentityAlreadyBound, err)
+	})
+
+	t.Run("绑定须使用本人发起的state", func(t *testing.T) {
+		loginState, err := service.AuthURL(ctx, models.IdentityProviderWeChatWeb, 0)
+		require.NoError(t, err)
+		_, err = service.Bind(ctx, user.ID, models.IdentityProviderWeChatWeb, &SocialLoginRequest{Code: "web_bind", State: loginState.State})
+		assert.Equal(t, common.ErrInvalidSocialState, err)
+
+		bindState, err := service.AuthURL(ctx, models.IdentityProviderWeChatWeb, user.ID)
+		require.NoError(t, err)
+		_, err = service.Bind(ctx, user.ID, models.IdentityProviderWeChatWeb, &SocialLoginRequest{Code: "web_bind", State: bindState.State})
+		assert.NoError(t, err)
+	})
+
+	t.Run("解绑第三方账号", func(t *testing.T) {
+		require.NoError(t, service.Unbind(ctx, user.ID, models.IdentityProviderWeChatWeb))
+		assert.Equal(t, common.ErrIdentityNotFound, service.Unbind(ctx, user.ID, models.IdentityProviderWeChatWeb))
+
+		// 已绑定手机号，可解绑最后一个第三方账号
+		require.NoError(t, service.Unbind(ctx, user.ID, models.IdentityProviderWeChatMini))
+	})
+
+	t.Run("唯一的登录方式不能解绑", func(t *testing.T) {
+		resp, err := service.Login(ctx, models.IdentityProviderWeChatMini, &SocialLoginRequest{Code: "only_login"})
+		require.NoError(t, err)
+		require.True(t, resp.IsNewUser)
+
+		err = service.Unbind(ctx, resp.User.ID, models.IdentityProviderWeChatMini)
+		assert.Equal(t, common.ErrLastLoginMethod, err)
+	})
+}
+
+func TestOAuth2Provider_Exchange(t *testing.T) {
+	config.Init()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/token":
+			require.NoError(t, r.ParseForm())
+			if r.PostForm.Get("code") != "good" || r.PostForm.Get("client_secret") != "secret" {
+				w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token":"at_1","token_type":"bearer"}`))
+		case "/user":
+			if r.Header.Get("Authorization") != "Bearer at_1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"id":12345678901234567,"login":"octocat","avatar_url":"https://example.com/a.png"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	for key, value := range map[string]interface{}{
+		"type":           "oauth2",
+		"enabled":        true,
+		"auth_url":       server.URL + "/authorize",
+		"token_url":      server.URL + "/token",
+		"userinfo_url":   server.URL + "/user",
+		"client_id":      "client",
+		"client_secret":  "secret",
+		"redirect_uri":   "https://h5.example.com/callback",
+		"id_field":       "id",
+		"nickname_field": "login",
+		"avatar_field":   "avatar_url",
+	} {
+		viper.Set("social.providers.testhub."+key, value)
+	}
+	defer viper.Set("social.providers.testhub", nil)
+
+	provider, err := NewSocialProvider("default", "testhub")
+	require.NoError(t, err)
+
+	redirectProvider, ok := provider.(RedirectSocialProvider)
+	require.True(t, ok)
+	authURL, err := url.Parse(redirectProvider.AuthCodeURL("state_1"))
+	require.NoError(t, err)
+	assert.Equal(t, "client", authURL.Query().Get("client_id"))
+	assert.Equal(t, "state_1", authURL.Query().Get("state"))
+
+	identity, err := provider.Exchange(context.Background(), "good")
+	require.NoError(t, err)
+	assert.Equal(t, "12345678901234567", identity.ProviderUserID)
+	assert.Equal(t, "octocat", identity.Nickname)
+	assert.Equal(t, "https://example.com/a.png", identity.Avatar)
+
+	_, err = provider.Exchange(context.Background(), "bad")
+	assert.Error(t, err)
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"member-link-lite/config"
+	"member-link-lite/internal/models"
+	"member-link-lite/pkg/common"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SocialProvider 第三方登录提供方
+// 负责使用授权码换取第三方身份，账号的查找、创建与绑定由SocialLoginService统一处理
+type SocialProvider interface {
+	// 登录方式标识
+	Name() string
+	// 使用授权码换取第三方身份
+	Exchange(ctx context.Context, code string) (*ExternalIdentity, error)
+}
+
+// RedirectSocialProvider 通过网页跳转授权的第三方登录提供方
+// 此类提供方需要先跳转到第三方授权页，回调时校验state防止CSRF
+type RedirectSocialProvider interface {
+	SocialProvider
+	// 构造第三方授权页地址
+	AuthCodeURL(state string) string
+}
+
+// ExternalIdentity 第三方身份
+type ExternalIdentity struct {
+	Provider       string `json:"provider"`           // 登录方式
+	ProviderUserID string `json:"provider_user_id"`   // 第三方用户标识
+	UnionID        string `json:"union_id,omitempty"` // 跨应用统一标识
+	Nickname       string `json:"nickname"`           // 昵称
+	Avatar         string `json:"avatar"`             // 头像
+	Phone          string `json:"phone,omitempty"`    // 手机号（部分提供方可获取）
+}
+
+// socialProviderConfig 第三方登录配置
+// 优先读取 social.tenants.<租户>.<登录方式>.<配置项>，未配置时读取 social.providers.<登录方式>.<配置项>
+type socialProviderConfig struct {
+	tenantID string
+	name     string
+}
+
+// get 获取配置项
+func (c socialProviderConfig) get(key string) string {
+	if value := config.GetString(fmt.Sprintf("social.tenants.%s.%s.%s", c.tenantID, c.name, key)); value != "" {
+		return value
+	}
+	return config.GetString(fmt.Sprintf("social.providers.%s.%s", c.name, key))
+}
+
+// enabled 检查登录方式是否启用
+func (c socialProviderConfig) enabled() bool {
+	tenantKey := fmt.Sprintf("social.tenants.%s.%s.enabled", c.tenantID, c.name)
+	if config.GetString(tenantKey) != "" {
+		return config.GetBool(tenantKey)
+	}
+	return config.GetBool(fmt.Sprintf("social.providers.%s.enabled", c.name))
+}
+
+// providerType 获取提供方类型，未配置时与登录方式名称相同
+func (c socialProviderConfig) providerType() string {
+	if providerType := c.get("type"); providerType != "" {
+		return providerType
+	}
+	return c.name
+}
+
+// NewSocialProvider 根据租户配置创建第三方登录提供方
+func NewSocialProvider(tenantID, name string) (SocialProvider, error) {
+	// 微信小程序沿用wechat配置
+	if name == models.IdentityProviderWeChatMini {
+		wechatService := NewWeChatAuthService()
+		if !wechatService.isEnabled(tenantID) {
+			return nil, common.ErrSocialProviderNotFound
+		}
+		return &weChatMiniProvider{service: wechatService, tenantID: tenantID}, nil
+	}
+
+	cfg := socialProviderConfig{tenantID: tenantID, name: name}
+	if !cfg.enabled() {
+		return nil, common.ErrSocialProviderNotFound
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	switch cfg.providerType() {
+	case models.IdentityProviderWeChatWeb:
+		return &weChatWebProvider{name: name, cfg: cfg, client: client}, nil
+	case models.IdentityProviderAlipay:
+		return newAlipayProvider(name, cfg, client)
+	case "oauth2":
+		return newOAuth2Provider(name, cfg, client)
+	default:
+		return nil, common.ErrSocialProviderNotFound
+	}
+}
+
+// EnabledSocialProviders 获取租户已启用的登录方式
+func EnabledSocialProviders(tenantID string) []string {
+	var names []string
+	if NewWeChatAuthService().isEnabled(tenantID) {
+		names = append(names, models.IdentityProviderWeChatMini)
+	}
+
+	configured := make(map[string]bool)
+	for name := range config.GetStringMap("social.providers") {
+		configured[name] = true
+	}
+	for name := range config.GetStringMap("social.tenants." + tenantID) {
+		configured[name] = true
+	}
+
+	var others []string
+	for name := range configured {
+		if (socialProviderConfig{tenantID: tenantID, name: name}).enabled() {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+
+	return append(names, others...)
+}
+
+// weChatMiniProvider 微信小程序登录
+type weChatMiniProvider struct {
+	service  *WeChatAuthService
+	tenantID string
+}
+
+// Name 登录方式标识
+func (p *weChatMiniProvider) Name() string {
+	return models.IdentityProviderWeChatMini
+}
+
+// Exchange 使用wx.login的code换取身份
+func (p *weChatMiniProvider) Exchange(ctx context.Context, code string) (*ExternalIdentity, error) {
+	info, err := p.service.HandleMiniProgramLogin(ctx, code, p.tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return info.Identity(), nil
+}
+
+// weChatWebProvider 微信公众号网页授权登录
+type weChatWebProvider struct {
+	name   string
+	cfg    socialProviderConfig
+	client *http.Client
+}
+
+// Name 登录方式标识
+func (p *weChatWebProvider) Name() string {
+	return p.name
+}
+
+// AuthCodeURL 构造微信网页授权地址
+func (p *weChatWebProvider) AuthCodeURL(state string) string {
+	scope := p.cfg.get("scope")
+	if scope == "" {
+		scope = "snsapi_userinfo"
+	}
+
+	params := url.Values{}
+	params.Set("appid", p.cfg.get("app_id"))
+	params.Set("redirect_uri", p.cfg.get("redirect_uri"))
+	params.Set("response_type", "code")
+	params.Set("scope", scope)
+	params.Set("state", state)
+
+	// 微信要求参数按appid、redirect_uri、response_type、scope、state顺序排列（Encode按键名排序恰好满足），并以#wechat_redirect结尾
+	return "https://open.weixin.qq.com/connect/oauth2/authorize?" + params.Encode() + "#wechat_redirect"
+}
+
+// Exchange 使用网页授权code换取身份
+func (p *weChatWebProvider) Exchange(ctx context.Context, code string) (*ExternalIdentity, error) {
+	params := url.Values{}
+	params.Set("appid", p.cfg.get("app_id"))
+	params.Set("secret", p.cfg.get("app_secret"))
+	params.Set("code", code)
+	params.Set("grant_type", "authorization_code")
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		OpenID      string `json:"openid"`
+		UnionID     string `json:"unionid"`
+		Scope       string `json:"scope"`
+		ErrCode     int    `json:"errcode"`
+		ErrMsg      string `json:"errmsg"`
+	}
+	if err := getJSON(ctx, p.client, "https://api.weixin.qq.com/sns/oauth2/access_token?"+params.Encode(), &tokenResp); err != nil {
+		return nil, err
+	}
+	if tokenResp.ErrCode != 0 {
+		return nil, fmt.Errorf("微信API错误: %d - %s", tokenResp.ErrCode, tokenResp.ErrMsg)
+	}
+
+	identity := &ExternalIdentity{
+		Provider:       p.name,
+		ProviderUserID: tokenResp.OpenID,
+		UnionID:        tokenResp.UnionID,
+	}
+
+	// 静默授权（snsapi_base）无法获取昵称头像
+	if !strings.Contains(tokenResp.Scope, "snsapi_userinfo") {
+		return identity, nil
+	}
+
+	params = url.Values{}
+	params.Set("access_token", tokenResp.AccessToken)
+	params.Set("openid", tokenResp.OpenID)
+	params.Set("lang", "zh_CN")
+
+	var userResp struct {
+		Nickname   string `json:"nickname"`
+		HeadImgURL string `json:"headimgurl"`
+		UnionID    string `json:"unionid"`
+		ErrCode    int    `json:"errcode"`
+		ErrMsg     string `json:"errmsg"`
+	}
+	if err := getJSON(ctx, p.client, "https://api.weixin.qq.com/sns/userinfo?"+params.Encode(), &userResp); err != nil {
+		return nil, err
+	}
+	if userResp.ErrCode != 0 {
+		return nil, fmt.Errorf("微信API错误: %d - %s", userResp.ErrCode, userResp.ErrMsg)
+	}
+
+	identity.Nickname = userResp.Nickname
+	identity.Avatar = userResp.HeadImgURL
+	if identity.UnionID == "" {
+		identity.UnionID = userResp.UnionID
+	}
+
+	return identity, nil
+}
+
+// getJSON 发送GET请求并解析JSON响应
+func getJSON(ctx context.Context, client *http.Client, rawURL string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(client, req, out)
+}
+
+// doJSON 发送请求并解析JSON响应
+func doJSON(client *http.Client, req *http.Request, out interface{}) error {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("第三方接口错误: HTTP %d", resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(body)))
+	decoder.UseNumber()
+	return decoder.Decode(out)
+}
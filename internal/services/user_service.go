@@ -3,14 +3,20 @@ package services
 import (
 	"context"
 	"fmt"
+	"io"
+	"member-link-lite/config"
 	"member-link-lite/internal/database"
 	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
 	"member-link-lite/pkg/common"
-	"member-link-lite/pkg/utils"
+	"member-link-lite/pkg/logger"
 	"mime/multipart"
 	"regexp"
+	"strings"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
@@ -26,8 +32,6 @@ type UserService interface {
 	GetByPhone(ctx context.Context, phone string) (*models.User, error)
 	// 根据邮箱查找用户
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
-	// 根据微信OpenID查找用户
-	GetByWeChatOpenID(ctx context.Context, openID string) (*models.User, error)
 	// 根据ID查找用户
 	GetByID(ctx context.Context, id uint64) (*models.User, error)
 	// 检查用户名是否存在
@@ -36,10 +40,19 @@ type UserService interface {
 	IsPhoneExists(ctx context.Context, phone string) (bool, error)
 	// 检查邮箱是否存在
 	IsEmailExists(ctx context.Context, email string) (bool, error)
-	// 检查微信OpenID是否存在
-	IsWeChatOpenIDExists(ctx context.Context, openID string) (bool, error)
 	// 刷新令牌
 	RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, error)
+	// 登出（吊销当前令牌族）
+	Logout(ctx context.Context, claims *JWTClaims) error
+	// 两步验证登录：使用登录返回的临时令牌和动态口令换取正式令牌
+	VerifyMFALogin(ctx context.Context, req *MFALoginRequest) (*LoginResponse, error)
+	// 身份验证通过后完成登录：启用两步验证时仅返回临时令牌，否则签发令牌
+	// 所有登录方式（密码、短信验证码、第三方账号）均须通过该方法签发令牌
+	CompleteLogin(ctx context.Context, user *models.User, loginType string) (*LoginResponse, error)
+	// 为用户签发新的令牌对并记录登录会话
+	IssueTokens(ctx context.Context, user *models.User, loginType string) (*TokenResponse, error)
+	// 解除已到期的临时锁定，返回解锁的用户数
+	UnlockExpiredUsers(ctx context.Context) (int64, error)
 	// 更新最后登录信息
 	UpdateLastLogin(ctx context.Context, userID uint64, ip string) error
 	// 更新用户信息
@@ -48,51 +61,79 @@ type UserService interface {
 	ChangePassword(ctx context.Context, userID uint64, req *ChangePasswordRequest) error
 	// 上传头像
 	UploadAvatar(ctx context.Context, userID uint64, file *multipart.FileHeader) (string, error)
+
+	// 会员管理（运营人员）
+	// 分页查询会员
+	ListMembers(ctx context.Context, req *ListMembersRequest) (*common.PaginateResult, error)
+	// 按会员列表的筛选条件导出会员（CSV或XLSX）
+	ExportMembers(ctx context.Context, req *ListMembersRequest, format string, w io.Writer) error
+	// 获取会员详情及资产汇总
+	GetMemberDetail(ctx context.Context, id uint64) (*MemberDetail, error)
+	// 创建会员
+	CreateMember(ctx context.Context, req *CreateMemberRequest, operatorID uint64) (*models.User, error)
+	// 修改会员信息
+	UpdateMember(ctx context.Context, id uint64, req *UpdateProfileRequest) (*models.User, error)
+	// 删除会员（软删除）
+	DeleteMember(ctx context.Context, id uint64, operatorID uint64) error
+	// 修改会员状态
+	UpdateMemberStatus(ctx context.Context, id uint64, req *UpdateMemberStatusRequest, operatorID uint64) error
+	// 获取会员统计
+	GetMemberStatistics(ctx context.Context) (*MemberStatistics, error)
 }
 
 // RegisterRequest 注册请求
 type RegisterRequest struct {
-	Username      string `json:"username" binding:"required,min=3,max=20" example:"testuser"`
-	Password      string `json:"password" binding:"required,min=6,max=20" example:"password123"`
-	Phone         string `json:"phone" binding:"required" example:"13800138000"`
-	Email         string `json:"email" binding:"required,email" example:"test@example.com"`
-	Nickname      string `json:"nickname" binding:"max=20" example:"测试用户"`
-	WeChatOpenID  string `json:"wechat_openid" example:"wx_openid_123"`
-	WeChatUnionID string `json:"wechat_unionid" example:"wx_unionid_123"`
+	Username string `json:"username" binding:"required,min=3,max=20" example:"testuser"`
+	Password string `json:"password" binding:"required,max=128" example:"password123"` // 长度及字符要求由租户密码策略决定
+	Phone    string `json:"phone" binding:"required" example:"13800138000"`
+	Email    string `json:"email" binding:"required,email" example:"test@example.com"`
+	Nickname string `json:"nickname" binding:"max=20" example:"测试用户"`
 }
 
 // LoginRequest 登录请求
 type LoginRequest struct {
-	Username string `json:"username" binding:"required" example:"testuser"`
+	Account  string `json:"account" example:"13800138000"` // 账号：用户名、手机号或邮箱
+	Username string `json:"username" example:"testuser"`   // 兼容旧版客户端，Account为空时使用
 	Password string `json:"password" binding:"required" example:"password123"`
 }
 
+// LoginAccount 获取登录账号
+func (r *LoginRequest) LoginAccount() string {
+	if account := strings.TrimSpace(r.Account); account != "" {
+		return account
+	}
+	return strings.TrimSpace(r.Username)
+}
+
 // LoginResponse 登录响应
+// 用户启用两步验证时只返回MFARequired和MFAToken，不返回用户信息及令牌，需调用两步验证接口完成登录
 type LoginResponse struct {
-	User   *models.User   `json:"user"`
-	Tokens *TokenResponse `json:"tokens"`
+	User        *models.User   `json:"user,omitempty"`
+	Tokens      *TokenResponse `json:"tokens,omitempty"`
+	MFARequired bool           `json:"mfa_required,omitempty"`
+	MFAToken    string         `json:"mfa_token,omitempty"`
 }
 
 // UpdateProfileRequest 更新用户信息请求
 type UpdateProfileRequest struct {
-	Nickname      string `json:"nickname" binding:"max=20" example:"新昵称"`
-	Email         string `json:"email" binding:"omitempty,email" example:"newemail@example.com"`
-	Phone         string `json:"phone" binding:"omitempty" example:"13800138001"`
-	Avatar        string `json:"avatar" binding:"omitempty" example:"http://example.com/avatar.jpg"`
-	WeChatOpenID  string `json:"wechat_openid" example:"wx_openid_123"`
-	WeChatUnionID string `json:"wechat_unionid" example:"wx_unionid_123"`
+	Nickname string `json:"nickname" binding:"max=20" example:"新昵称"`
+	Email    string `json:"email" binding:"omitempty,email" example:"newemail@example.com"`
+	Phone    string `json:"phone" binding:"omitempty" example:"13800138001"`
+	Avatar   string `json:"avatar" binding:"omitempty" example:"http://example.com/avatar.jpg"`
 }
 
 // ChangePasswordRequest 修改密码请求
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required" example:"oldpassword123"`
-	NewPassword string `json:"new_password" binding:"required,min=6,max=20" example:"newpassword123"`
+	NewPassword string `json:"new_password" binding:"required,max=128" example:"newpassword123"` // 长度及字符要求由租户密码策略决定
 }
 
 // userServiceImpl 用户服务实现
 type userServiceImpl struct {
-	db         *gorm.DB
-	jwtService JWTService
+	db            *gorm.DB
+	jwtService    JWTService
+	cache         cache.Cache          // 为空时使用全局缓存
+	loginSecurity *LoginSecurityConfig // 为空时从配置文件加载
 }
 
 // NewUserService 创建用户服务实例
@@ -103,15 +144,37 @@ func NewUserService() UserService {
 	}
 }
 
+// StartAccountUnlockJobs 启动后台任务，定期解除已到期的临时锁定
+// 锁定到期的账号即使不再登录也会恢复为正常状态，避免其他业务仍按锁定处理
+func StartAccountUnlockJobs() {
+	interval := time.Duration(config.GetInt("security.login.unlock_interval")) * time.Second
+	if interval <= 0 {
+		return
+	}
+
+	service := NewUserService()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := service.UnlockExpiredUsers(context.Background()); err != nil {
+				logger.Error("解除到期锁定失败:", err)
+			}
+		}
+	}()
+}
+
 // Register 用户注册
 func (s *userServiceImpl) Register(ctx context.Context, req *RegisterRequest) (*models.User, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+	policy := LoadPasswordPolicy(tenantID)
+
 	// 验证请求参数
-	if err := s.validateRegisterRequest(req); err != nil {
+	if err := s.validateRegisterRequest(req, policy); err != nil {
 		return nil, err
 	}
 
-	tenantID := database.GetTenantIDFromContext(ctx)
-
 	// 检查用户名是否已存在（限定租户）
 	exists, err := s.IsUsernameExists(ctx, req.Username)
 	if err != nil {
@@ -139,25 +202,12 @@ func (s *userServiceImpl) Register(ctx context.Context, req *RegisterRequest) (*
 		return nil, common.ErrEmailExists
 	}
 
-	// 检查微信OpenID是否已存在（如果提供）
-	if req.WeChatOpenID != "" {
-		exists, err = s.IsWeChatOpenIDExists(ctx, req.WeChatOpenID)
-		if err != nil {
-			return nil, err
-		}
-		if exists {
-			return nil, common.ErrUserExists
-		}
-	}
-
 	// 创建用户
 	user := &models.User{
-		Username:      req.Username,
-		Phone:         req.Phone,
-		Email:         req.Email,
-		Nickname:      req.Nickname,
-		WeChatOpenID:  req.WeChatOpenID,
-		WeChatUnionID: req.WeChatUnionID,
+		Username: req.Username,
+		Phone:    req.Phone,
+		Email:    req.Email,
+		Nickname: req.Nickname,
 	}
 	// 设置租户ID
 	user.TenantID = tenantID
@@ -171,10 +221,17 @@ func (s *userServiceImpl) Register(ctx context.Context, req *RegisterRequest) (*
 	if err := user.HashPassword(req.Password); err != nil {
 		return nil, fmt.Errorf("密码加密失败: %w", err)
 	}
+	now := time.Now()
+	user.PasswordChangedAt = &now
 
-	// 保存到数据库
-	if err := s.db.WithContext(ctx).Create(user).Error; err != nil {
-		return nil, fmt.Errorf("创建用户失败: %w", err)
+	// 保存到数据库，同时记录历史密码
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return fmt.Errorf("创建用户失败: %w", err)
+		}
+		return policy.Record(tx, user, user.Password)
+	}); err != nil {
+		return nil, err
 	}
 
 	return user, nil
@@ -182,35 +239,112 @@ func (s *userServiceImpl) Register(ctx context.Context, req *RegisterRequest) (*
 
 // Login 用户登录
 func (s *userServiceImpl) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
-	// 查找用户
-	user, err := s.GetByUsername(ctx, req.Username)
+	account := req.LoginAccount()
+	if account == "" {
+		return nil, common.ErrAccountRequired
+	}
+
+	tenantID := database.GetTenantIDFromContext(ctx)
+	clientIP := GetClientInfoFromContext(ctx).IP
+	guard := s.loginProtector()
+
+	// 检查IP是否因失败次数过多被限制
+	if err := guard.CheckIP(ctx, clientIP); err != nil {
+		return nil, err
+	}
+
+	// 查找用户（包含已锁定和禁用的用户，以便返回准确的状态）
+	user, err := s.findLoginUser(ctx, account)
 	if err != nil {
-		return nil, common.ErrInvalidPassword // 不暴露用户是否存在的信息
+		if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+		// 账号不存在同样延迟响应、计入失败次数并在达到阈值后锁定，不暴露用户是否存在的信息
+		key := guard.accountKey(tenantID, account)
+		if err := guard.Delay(ctx, key); err != nil {
+			return nil, err
+		}
+		locked, err := guard.AccountLocked(ctx, tenantID, account)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			return nil, common.ErrUserLocked
+		}
+		reachLimit, err := guard.RecordFailure(ctx, key, clientIP)
+		if err != nil {
+			return nil, err
+		}
+		if reachLimit {
+			if err := guard.LockAccount(ctx, tenantID, account); err != nil {
+				return nil, err
+			}
+			return nil, common.ErrUserLocked
+		}
+		return nil, common.ErrInvalidPassword
+	}
+
+	// 失败次数按用户统计，使用用户名、手机号或邮箱登录共用同一失败额度
+	failureKey := guard.userKey(user.ID)
+
+	// 账号连续失败后递增延迟响应
+	if err := guard.Delay(ctx, failureKey); err != nil {
+		return nil, err
+	}
+
+	// 临时锁定到期后自动解锁
+	if user.IsLockExpired(time.Now()) {
+		if err := s.unlockUser(ctx, user); err != nil {
+			return nil, err
+		}
+		if err := guard.Reset(ctx, failureKey); err != nil {
+			return nil, err
+		}
 	}
 
 	// 检查用户状态
 	if !user.IsActive() {
-		if user.Status == models.UserStatusDisabled {
+		switch user.Status {
+		case models.UserStatusDisabled:
 			return nil, common.ErrUserDisabled
+		case models.UserStatusLocked:
+			return nil, common.ErrUserLocked
 		}
 		return nil, common.ErrInvalidPassword
 	}
 
 	// 验证密码
 	if !user.CheckPassword(req.Password) {
+		reachLimit, err := guard.RecordFailure(ctx, failureKey, clientIP)
+		if err != nil {
+			return nil, err
+		}
+		if reachLimit {
+			if err := s.lockUser(ctx, user, guard.cfg.LockDuration); err != nil {
+				return nil, err
+			}
+			return nil, common.ErrUserLocked
+		}
 		return nil, common.ErrInvalidPassword
 	}
 
-	// 生成令牌
-	tokens, err := GenerateTokenPair(s.jwtService, user.ID, user.Username)
-	if err != nil {
-		return nil, fmt.Errorf("生成令牌失败: %w", err)
+	// 登录成功，清除失败计数
+	if err := guard.Reset(ctx, failureKey); err != nil {
+		return nil, err
 	}
 
-	return &LoginResponse{
-		User:   user,
-		Tokens: tokens,
-	}, nil
+	// 旧系统导入的哈希或加密参数已调整时，使用当前配置重新加密
+	if user.NeedsRehash() {
+		s.rehashPassword(ctx, user, req.Password)
+	}
+
+	// 密码超过有效期时不签发令牌，需通过找回密码设置新密码
+	if LoadPasswordPolicy(tenantID).IsExpired(user, time.Now()) {
+		return nil, common.ErrPasswordExpired
+	}
+
+	// 启用两步验证的用户返回临时令牌，否则签发正式令牌
+	return s.CompleteLogin(ctx, user, models.LoginTypePassword)
 }
 
 // RefreshToken 刷新令牌
@@ -222,10 +356,40 @@ func (s *userServiceImpl) RefreshToken(ctx context.Context, refreshToken string)
 	}
 
 	// 检查令牌类型
-	if claims.Type != "refresh" {
+	if claims.Type != TokenTypeRefresh {
 		return nil, common.ErrInvalidToken
 	}
 
+	// 检查令牌是否已被吊销
+	revoked, err := s.jwtService.IsTokenRevoked(ctx, claims)
+	if err != nil {
+		return nil, fmt.Errorf("检查令牌状态失败: %w", err)
+	}
+	if revoked {
+		return nil, common.ErrTokenRevoked
+	}
+
+	// 刷新令牌只能使用一次，重复使用说明令牌可能已泄露，吊销整个令牌族
+	firstUse, err := s.jwtService.ConsumeRefreshToken(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if !firstUse {
+		if err := s.jwtService.RevokeTokenFamily(ctx, claims); err != nil {
+			return nil, fmt.Errorf("吊销令牌族失败: %w", err)
+		}
+		if err := s.sessions().MarkSessionRevoked(ctx, claims.FamilyID); err != nil {
+			return nil, fmt.Errorf("注销会话失败: %w", err)
+		}
+		logger.WithFields(logrus.Fields{
+			"event":     "refresh_token_reuse",
+			"user_id":   claims.UserID,
+			"family_id": claims.FamilyID,
+			"jti":       claims.ID,
+		}).Warn("检测到刷新令牌重复使用，已吊销令牌族")
+		return nil, common.ErrTokenReused
+	}
+
 	// 验证用户是否仍然有效
 	user, err := s.GetByID(ctx, claims.UserID)
 	if err != nil {
@@ -236,8 +400,25 @@ func (s *userServiceImpl) RefreshToken(ctx context.Context, refreshToken string)
 		return nil, common.ErrUserDisabled
 	}
 
-	// 生成新的令牌对
-	tokens, err := GenerateTokenPair(s.jwtService, user.ID, user.Username)
+	// 在同一令牌族中轮换生成新的令牌对
+	familyID := claims.FamilyID
+	if familyID == "" {
+		if familyID, err = NewTokenID(); err != nil {
+			return nil, fmt.Errorf("生成令牌族ID失败: %w", err)
+		}
+	}
+
+	// 更新会话活跃信息（会话已被注销时拒绝刷新）
+	if err := s.sessions().TouchSession(ctx, user.ID, familyID); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.userRoles(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := GenerateTokenPairInFamily(s.jwtService, user.ID, user.Username, user.TenantID, familyID, roles...)
 	if err != nil {
 		return nil, fmt.Errorf("生成令牌失败: %w", err)
 	}
@@ -245,6 +426,328 @@ func (s *userServiceImpl) RefreshToken(ctx context.Context, refreshToken string)
 	return tokens, nil
 }
 
+// VerifyMFALogin 两步验证登录
+func (s *userServiceImpl) VerifyMFALogin(ctx context.Context, req *MFALoginRequest) (*LoginResponse, error) {
+	claims, err := s.jwtService.ValidateToken(req.MFAToken)
+	if err != nil || claims.Type != TokenTypeMFAPending {
+		return nil, common.ErrMFATokenInvalid
+	}
+
+	c := s.getCache()
+	usedKey := mfaUsedKeyPrefix + claims.ID
+
+	// 临时令牌只能成功使用一次
+	used, err := c.Exists(ctx, usedKey)
+	if err != nil {
+		return nil, fmt.Errorf("检查令牌状态失败: %w", err)
+	}
+	if used {
+		return nil, common.ErrMFATokenInvalid
+	}
+
+	// 限制单个临时令牌的尝试次数，防止穷举动态口令
+	ttl := time.Until(claims.ExpiresAt.Time)
+	mfaService := s.mfa()
+	attempts, err := c.Incr(ctx, mfaAttemptKeyPrefix+claims.ID, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("记录尝试次数失败: %w", err)
+	}
+	if mfaService.cfg.MaxAttempts > 0 && attempts > mfaService.cfg.MaxAttempts {
+		return nil, common.ErrMFATokenInvalid
+	}
+
+	user, err := s.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, common.ErrMFATokenInvalid
+	}
+
+	if err := mfaService.Verify(ctx, user.ID, req.Code); err != nil {
+		return nil, err
+	}
+
+	first, err := c.SetNX(ctx, usedKey, "1", ttl)
+	if err != nil {
+		return nil, fmt.Errorf("记录令牌使用失败: %w", err)
+	}
+	if !first {
+		return nil, common.ErrMFATokenInvalid
+	}
+
+	loginType := claims.LoginType
+	if loginType == "" {
+		loginType = models.LoginTypePassword
+	}
+	tokens, err := s.IssueTokens(ctx, user, loginType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		User:   user,
+		Tokens: tokens,
+	}, nil
+}
+
+// Logout 登出
+// 吊销当前访问令牌以及同一令牌族中的刷新令牌
+func (s *userServiceImpl) Logout(ctx context.Context, claims *JWTClaims) error {
+	if claims == nil {
+		return common.ErrInvalidToken
+	}
+
+	if err := s.jwtService.RevokeTokenFamily(ctx, claims); err != nil {
+		return fmt.Errorf("吊销令牌失败: %w", err)
+	}
+
+	if err := s.sessions().MarkSessionRevoked(ctx, claims.FamilyID); err != nil {
+		return fmt.Errorf("注销会话失败: %w", err)
+	}
+
+	return nil
+}
+
+// CompleteLogin 身份验证通过后完成登录
+// 启用两步验证的用户只返回临时令牌，验证动态口令后再按原登录方式签发正式令牌
+func (s *userServiceImpl) CompleteLogin(ctx context.Context, user *models.User, loginType string) (*LoginResponse, error) {
+	mfaService := s.mfa()
+	mfaEnabled, err := mfaService.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		mfaToken, err := s.jwtService.GenerateTokenWithClaims(&JWTClaims{
+			UserID:    user.ID,
+			Username:  user.Username,
+			TenantID:  user.TenantID,
+			Type:      TokenTypeMFAPending,
+			LoginType: loginType,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaService.cfg.PendingTokenTTL)),
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("生成令牌失败: %w", err)
+		}
+
+		return &LoginResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		}, nil
+	}
+
+	// 生成令牌并记录会话
+	tokens, err := s.IssueTokens(ctx, user, loginType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		User:   user,
+		Tokens: tokens,
+	}, nil
+}
+
+// IssueTokens 签发令牌并记录登录会话
+func (s *userServiceImpl) IssueTokens(ctx context.Context, user *models.User, loginType string) (*TokenResponse, error) {
+	familyID, err := NewTokenID()
+	if err != nil {
+		return nil, fmt.Errorf("生成令牌族ID失败: %w", err)
+	}
+
+	roles, err := s.userRoles(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := GenerateTokenPairInFamily(s.jwtService, user.ID, user.Username, user.TenantID, familyID, roles...)
+	if err != nil {
+		return nil, fmt.Errorf("生成令牌失败: %w", err)
+	}
+
+	if _, err := s.sessions().CreateSession(ctx, user.ID, familyID, loginType); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// userRoles 加载用户在所属租户下的角色编码，写入访问令牌
+func (s *userServiceImpl) userRoles(ctx context.Context, user *models.User) ([]string, error) {
+	return s.rbac().GetUserRoles(context.WithValue(ctx, "tenant_id", user.TenantID), user.ID)
+}
+
+// findLoginUser 根据登录账号查找用户
+// 账号符合手机号格式时按手机号查找，符合邮箱格式时按邮箱查找，否则按用户名查找
+// 纯数字用户名可能与手机号格式重合，按手机号未找到时再按用户名查找
+func (s *userServiceImpl) findLoginUser(ctx context.Context, account string) (*models.User, error) {
+	tenantID := database.GetTenantIDFromContext(ctx)
+
+	column := "username"
+	if validatePhone(account) == nil {
+		column = "phone"
+	} else if validateEmail(account) == nil {
+		column = "email"
+	}
+
+	var user models.User
+	err := s.db.WithContext(ctx).
+		Where(column+" = ? AND tenant_id = ?", account, tenantID).
+		First(&user).Error
+	if err == gorm.ErrRecordNotFound && column == "phone" {
+		err = s.db.WithContext(ctx).
+			Where("username = ? AND tenant_id = ?", account, tenantID).
+			First(&user).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// lockUser 临时锁定用户
+func (s *userServiceImpl) lockUser(ctx context.Context, user *models.User, duration time.Duration) error {
+	lockedUntil := time.Now().Add(duration)
+	if err := s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ? AND status = ?", user.ID, models.UserStatusActive).
+		Updates(map[string]interface{}{
+			"status":       models.UserStatusLocked,
+			"locked_until": lockedUntil,
+		}).Error; err != nil {
+		return fmt.Errorf("锁定用户失败: %w", err)
+	}
+
+	user.Status = models.UserStatusLocked
+	user.LockedUntil = &lockedUntil
+
+	logger.WithFields(logrus.Fields{
+		"event":        "account_locked",
+		"user_id":      user.ID,
+		"tenant_id":    user.TenantID,
+		"locked_until": lockedUntil,
+		"ip":           GetClientInfoFromContext(ctx).IP,
+	}).Warn("登录失败次数过多，账号已被临时锁定")
+
+	return nil
+}
+
+// unlockUser 解除用户临时锁定
+func (s *userServiceImpl) unlockUser(ctx context.Context, user *models.User) error {
+	if err := s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ? AND status = ?", user.ID, models.UserStatusLocked).
+		Updates(map[string]interface{}{
+			"status":       models.UserStatusActive,
+			"locked_until": nil,
+		}).Error; err != nil {
+		return fmt.Errorf("解锁用户失败: %w", err)
+	}
+
+	user.Status = models.UserStatusActive
+	user.LockedUntil = nil
+	return nil
+}
+
+// UnlockExpiredUsers 解除已到期的临时锁定并清除失败计数
+// 不区分租户，由后台任务定期执行
+func (s *userServiceImpl) UnlockExpiredUsers(ctx context.Context) (int64, error) {
+	now := time.Now()
+	var userIDs []uint64
+	if err := s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("status = ? AND locked_until IS NOT NULL AND locked_until <= ?", models.UserStatusLocked, now).
+		Pluck("id", &userIDs).Error; err != nil {
+		return 0, fmt.Errorf("查询到期锁定用户失败: %w", err)
+	}
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+
+	result := s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id IN ? AND status = ? AND locked_until <= ?", userIDs, models.UserStatusLocked, now).
+		Updates(map[string]interface{}{
+			"status":       models.UserStatusActive,
+			"locked_until": nil,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("解锁用户失败: %w", result.Error)
+	}
+
+	guard := s.loginProtector()
+	for _, userID := range userIDs {
+		if err := guard.Reset(ctx, guard.userKey(userID)); err != nil {
+			return result.RowsAffected, err
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"event": "account_unlocked",
+		"count": result.RowsAffected,
+	}).Info("已解除到期的临时锁定")
+
+	return result.RowsAffected, nil
+}
+
+// rehashPassword 使用当前加密配置重新加密密码
+// 仅在哈希未被并发修改时更新，失败时不影响本次登录
+func (s *userServiceImpl) rehashPassword(ctx context.Context, user *models.User, password string) {
+	oldHash := user.Password
+	if err := user.HashPassword(password); err != nil {
+		user.Password = oldHash
+		logger.WithFields(logrus.Fields{
+			"user_id": user.ID,
+			"error":   err.Error(),
+		}).Warn("重新加密密码失败")
+		return
+	}
+
+	if err := s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ? AND password = ?", user.ID, oldHash).
+		Update("password", user.Password).Error; err != nil {
+		user.Password = oldHash
+		logger.WithFields(logrus.Fields{
+			"user_id": user.ID,
+			"error":   err.Error(),
+		}).Warn("更新密码哈希失败")
+	}
+}
+
+// loginProtector 获取登录防护
+func (s *userServiceImpl) loginProtector() *loginProtector {
+	c := s.getCache()
+	cfg := s.loginSecurity
+	if cfg == nil {
+		cfg = LoadLoginSecurityConfig()
+	}
+	return newLoginProtector(c, cfg)
+}
+
+// getCache 获取缓存，未指定时使用全局缓存
+func (s *userServiceImpl) getCache() cache.Cache {
+	if s.cache != nil {
+		return s.cache
+	}
+	return database.GetCache()
+}
+
+// mfa 获取两步验证服务
+func (s *userServiceImpl) mfa() *mfaServiceImpl {
+	return &mfaServiceImpl{db: s.db, cfg: LoadMFAConfig()}
+}
+
+// sessions 获取登录会话服务
+func (s *userServiceImpl) sessions() SessionService {
+	return &sessionServiceImpl{db: s.db, jwtService: s.jwtService}
+}
+
+// rbac 获取角色权限服务（复用当前数据库连接）
+func (s *userServiceImpl) rbac() *rbacServiceImpl {
+	return &rbacServiceImpl{db: s.db, jwtService: s.jwtService}
+}
+
 // UpdateLastLogin 更新最后登录信息
 func (s *userServiceImpl) UpdateLastLogin(ctx context.Context, userID uint64, ip string) error {
 	return s.db.WithContext(ctx).
@@ -274,6 +777,15 @@ func (s *userServiceImpl) UpdateProfile(ctx context.Context, userID uint64, req
 
 	if req.Email != "" {
 		updates["email"] = req.Email
+
+		// 邮箱变更后需重新验证
+		user, err := s.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(user.Email, req.Email) {
+			updates["email_verified_at"] = nil
+		}
 	}
 
 	if req.Phone != "" {
@@ -284,14 +796,6 @@ func (s *userServiceImpl) UpdateProfile(ctx context.Context, userID uint64, req
 		updates["avatar"] = req.Avatar
 	}
 
-	if req.WeChatOpenID != "" {
-		updates["wechat_openid"] = req.WeChatOpenID
-	}
-
-	if req.WeChatUnionID != "" {
-		updates["wechat_unionid"] = req.WeChatUnionID
-	}
-
 	// 如果没有要更新的字段，直接返回
 	if len(updates) == 0 {
 		return nil
@@ -316,8 +820,11 @@ func (s *userServiceImpl) UpdateProfile(ctx context.Context, userID uint64, req
 
 // ChangePassword 修改密码
 func (s *userServiceImpl) ChangePassword(ctx context.Context, userID uint64, req *ChangePasswordRequest) error {
-	// 验证新密码强度
-	if err := utils.ValidatePassword(req.NewPassword); err != nil {
+	tenantID := database.GetTenantIDFromContext(ctx)
+	policy := LoadPasswordPolicy(tenantID)
+
+	// 验证新密码是否符合租户密码策略
+	if err := policy.Validate(req.NewPassword); err != nil {
 		return err
 	}
 
@@ -332,29 +839,33 @@ func (s *userServiceImpl) ChangePassword(ctx context.Context, userID uint64, req
 		return common.ErrInvalidPassword
 	}
 
+	// 不能使用最近使用过的密码
+	if err := policy.CheckReuse(ctx, s.db, user, req.NewPassword); err != nil {
+		return err
+	}
+
 	// 加密新密码
 	newUser := &models.User{}
 	if err := newUser.HashPassword(req.NewPassword); err != nil {
 		return fmt.Errorf("密码加密失败: %w", err)
 	}
 
-	tenantID := database.GetTenantIDFromContext(ctx)
-
-	// 更新密码（限定租户）
-	result := s.db.WithContext(ctx).
-		Model(&models.User{}).
-		Where("id = ? AND status = ? AND tenant_id = ?", userID, models.StatusActive, tenantID).
-		Update("password", newUser.Password)
+	// 更新密码（限定租户）并记录历史密码
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND status = ? AND tenant_id = ?", userID, models.StatusActive, tenantID).
+			Updates(passwordUpdates(newUser.Password, time.Now()))
 
-	if result.Error != nil {
-		return fmt.Errorf("修改密码失败: %w", result.Error)
-	}
+		if result.Error != nil {
+			return fmt.Errorf("修改密码失败: %w", result.Error)
+		}
 
-	if result.RowsAffected == 0 {
-		return common.ErrUserNotFound
-	}
+		if result.RowsAffected == 0 {
+			return common.ErrUserNotFound
+		}
 
-	return nil
+		return policy.Record(tx, user, newUser.Password)
+	})
 }
 
 // UploadAvatar 上传头像
@@ -432,7 +943,7 @@ func (s *userServiceImpl) validateUpdateProfileRequest(ctx context.Context, user
 
 		// 检查邮箱是否被其他用户使用（限定租户）
 		var count int64
-		err := s.db.WithContext(ctx).
+		err := s.db.WithContext(ctx).Unscoped().
 			Model(&models.User{}).
 			Where("email = ? AND id != ? AND tenant_id = ?", req.Email, userID, database.GetTenantIDFromContext(ctx)).
 			Count(&count).Error
@@ -452,7 +963,7 @@ func (s *userServiceImpl) validateUpdateProfileRequest(ctx context.Context, user
 
 		// 检查手机号是否被其他用户使用（限定租户）
 		var count int64
-		err := s.db.WithContext(ctx).
+		err := s.db.WithContext(ctx).Unscoped().
 			Model(&models.User{}).
 			Where("phone = ? AND id != ? AND tenant_id = ?", req.Phone, userID, database.GetTenantIDFromContext(ctx)).
 			Count(&count).Error
@@ -542,7 +1053,8 @@ func (s *userServiceImpl) GetByID(ctx context.Context, id uint64) (*models.User,
 // IsUsernameExists 检查用户名是否存在
 func (s *userServiceImpl) IsUsernameExists(ctx context.Context, username string) (bool, error) {
 	var count int64
-	err := s.db.WithContext(ctx).
+	// 包含已删除的会员，唯一索引同样约束软删除的记录
+	err := s.db.WithContext(ctx).Unscoped().
 		Model(&models.User{}).
 		Where("username = ? AND tenant_id = ?", username, database.GetTenantIDFromContext(ctx)).
 		Count(&count).Error
@@ -553,7 +1065,8 @@ func (s *userServiceImpl) IsUsernameExists(ctx context.Context, username string)
 // IsPhoneExists 检查手机号是否存在
 func (s *userServiceImpl) IsPhoneExists(ctx context.Context, phone string) (bool, error) {
 	var count int64
-	err := s.db.WithContext(ctx).
+	// 包含已删除的会员，唯一索引同样约束软删除的记录
+	err := s.db.WithContext(ctx).Unscoped().
 		Model(&models.User{}).
 		Where("phone = ? AND tenant_id = ?", phone, database.GetTenantIDFromContext(ctx)).
 		Count(&count).Error
@@ -564,7 +1077,8 @@ func (s *userServiceImpl) IsPhoneExists(ctx context.Context, phone string) (bool
 // IsEmailExists 检查邮箱是否存在
 func (s *userServiceImpl) IsEmailExists(ctx context.Context, email string) (bool, error) {
 	var count int64
-	err := s.db.WithContext(ctx).
+	// 包含已删除的会员，唯一索引同样约束软删除的记录
+	err := s.db.WithContext(ctx).Unscoped().
 		Model(&models.User{}).
 		Where("email = ? AND tenant_id = ?", email, database.GetTenantIDFromContext(ctx)).
 		Count(&count).Error
@@ -573,14 +1087,14 @@ func (s *userServiceImpl) IsEmailExists(ctx context.Context, email string) (bool
 }
 
 // validateRegisterRequest 验证注册请求
-func (s *userServiceImpl) validateRegisterRequest(req *RegisterRequest) error {
+func (s *userServiceImpl) validateRegisterRequest(req *RegisterRequest, policy *PasswordPolicy) error {
 	// 验证用户名格式
 	if err := validateUsername(req.Username); err != nil {
 		return err
 	}
 
-	// 验证密码强度
-	if err := utils.ValidatePassword(req.Password); err != nil {
+	// 验证密码是否符合租户密码策略
+	if err := policy.Validate(req.Password); err != nil {
 		return err
 	}
 
@@ -644,31 +1158,3 @@ func validateEmail(email string) error {
 
 	return nil
 }
-
-// GetByWeChatOpenID 根据微信OpenID查找用户
-func (s *userServiceImpl) GetByWeChatOpenID(ctx context.Context, openID string) (*models.User, error) {
-	var user models.User
-	err := s.db.WithContext(ctx).
-		Where("wechat_openid = ? AND tenant_id = ?", openID, database.GetTenantIDFromContext(ctx)).
-		First(&user).Error
-
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, common.ErrUserNotFound
-		}
-		return nil, fmt.Errorf("查询用户失败: %w", err)
-	}
-
-	return &user, nil
-}
-
-// IsWeChatOpenIDExists 检查微信OpenID是否存在
-func (s *userServiceImpl) IsWeChatOpenIDExists(ctx context.Context, openID string) (bool, error) {
-	var count int64
-	err := s.db.WithContext(ctx).
-		Model(&models.User{}).
-		Where("wechat_openid = ? AND tenant_id = ?", openID, database.GetTenantIDFromContext(ctx)).
-		Count(&count).Error
-
-	return count > 0, err
-}
@@ -2,12 +2,22 @@ package services
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
 	"member-link-lite/config"
+	"member-link-lite/internal/database"
 	"member-link-lite/internal/models"
+	"member-link-lite/pkg/cache"
+	"member-link-lite/pkg/common"
+	"member-link-lite/pkg/logger"
+	"member-link-lite/pkg/utils"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -18,8 +28,15 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	require.NoError(t, err)
 
 	// 自动迁移
-	err = db.AutoMigrate(&models.User{})
+	err = db.AutoMigrate(&models.User{}, &models.UserSession{}, &models.UserMFA{}, &models.UserRecoveryCode{},
+		&models.Role{}, &models.Permission{}, &models.UserRole{}, &models.APIClient{},
+		&models.OAuthClient{}, &models.OAuthConsent{}, &models.UserIdentity{},
+		&models.BalanceRecord{}, &models.PointsRecord{}, &models.File{}, &models.AccountMerge{},
+		&models.DataExport{}, &models.PasswordHistory{}, &models.MemberTag{}, &models.UserTag{}, &models.Segment{}, &models.MemberImport{},
+		&models.JournalEntry{}, &models.LedgerPosting{}, &models.PointsTransfer{})
 	require.NoError(t, err)
+	require.NoError(t, database.CreateIdempotencyIndexes(db))
+	require.NoError(t, database.CreateIdentityIndexes(db))
 
 	return db
 }
@@ -339,6 +356,80 @@ func TestUserService_RefreshToken(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestUserService_RefreshTokenReuse(t *testing.T) {
+	config.Init()
+	logger.Init()
+	db := setupTestDB(t)
+	service := &userServiceImpl{db: db, jwtService: NewJWTService()}
+	ctx := context.Background()
+
+	registerReq := &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	}
+	_, err := service.Register(ctx, registerReq)
+	require.NoError(t, err)
+
+	loginResp, err := service.Login(ctx, &LoginRequest{Username: "testuser", Password: "password123"})
+	require.NoError(t, err)
+
+	// 刷新令牌轮换，新令牌与旧令牌属于同一令牌族
+	rotated, err := service.RefreshToken(ctx, loginResp.Tokens.RefreshToken)
+	require.NoError(t, err)
+	oldClaims, err := service.jwtService.ValidateToken(loginResp.Tokens.RefreshToken)
+	require.NoError(t, err)
+	newClaims, err := service.jwtService.ValidateToken(rotated.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, oldClaims.FamilyID, newClaims.FamilyID)
+
+	// 旧刷新令牌被再次使用，整个令牌族被吊销
+	_, err = service.RefreshToken(ctx, loginResp.Tokens.RefreshToken)
+	assert.Equal(t, common.ErrTokenReused, err)
+
+	_, err = service.RefreshToken(ctx, rotated.RefreshToken)
+	assert.Equal(t, common.ErrTokenRevoked, err)
+
+	accessClaims, err := service.jwtService.ValidateToken(rotated.AccessToken)
+	require.NoError(t, err)
+	revoked, err := service.jwtService.IsTokenRevoked(ctx, accessClaims)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestUserService_Logout(t *testing.T) {
+	config.Init()
+	db := setupTestDB(t)
+	service := &userServiceImpl{db: db, jwtService: NewJWTService()}
+	ctx := context.Background()
+
+	registerReq := &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	}
+	_, err := service.Register(ctx, registerReq)
+	require.NoError(t, err)
+
+	loginResp, err := service.Login(ctx, &LoginRequest{Username: "testuser", Password: "password123"})
+	require.NoError(t, err)
+
+	claims, err := service.jwtService.ValidateToken(loginResp.Tokens.AccessToken)
+	require.NoError(t, err)
+
+	// 登出后访问令牌被吊销
+	require.NoError(t, service.Logout(ctx, claims))
+	revoked, err := service.jwtService.IsTokenRevoked(ctx, claims)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// 配对的刷新令牌也不能再使用
+	_, err = service.RefreshToken(ctx, loginResp.Tokens.RefreshToken)
+	assert.Equal(t, common.ErrTokenRevoked, err)
+}
+
 func TestUserService_UpdateProfile(t *testing.T) {
 	config.Init()
 	db := setupTestDB(t)
@@ -595,3 +686,294 @@ func TestValidateEmail(t *testing.T) {
 		})
 	}
 }
+
+func TestUserService_LoginByAccount(t *testing.T) {
+	config.Init()
+	db := setupTestDB(t)
+	service := &userServiceImpl{db: db, jwtService: NewJWTService(), cache: cache.NewMemoryCache()}
+	ctx := context.Background()
+
+	registeredUser, err := service.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+
+	// 用户名、手机号、邮箱均可作为登录账号
+	for _, account := range []string{"testuser", "13800138000", "test@example.com"} {
+		resp, err := service.Login(ctx, &LoginRequest{Account: account, Password: "password123"})
+		require.NoError(t, err, account)
+		assert.Equal(t, registeredUser.ID, resp.User.ID)
+	}
+
+	// 兼容仅传username的旧版请求
+	_, err = service.Login(ctx, &LoginRequest{Username: "testuser", Password: "password123"})
+	assert.NoError(t, err)
+
+	// 账号不存在与密码错误返回相同错误
+	_, err = service.Login(ctx, &LoginRequest{Account: "13900139000", Password: "password123"})
+	assert.Equal(t, common.ErrInvalidPassword, err)
+	_, err = service.Login(ctx, &LoginRequest{Account: "other@example.com", Password: "password123"})
+	assert.Equal(t, common.ErrInvalidPassword, err)
+	_, err = service.Login(ctx, &LoginRequest{Account: "test@example.com", Password: "wrongpassword"})
+	assert.Equal(t, common.ErrInvalidPassword, err)
+
+	// 未提供账号
+	_, err = service.Login(ctx, &LoginRequest{Password: "password123"})
+	assert.Equal(t, common.ErrAccountRequired, err)
+}
+
+func TestUserService_LoginRehash(t *testing.T) {
+	config.Init()
+	db := setupTestDB(t)
+	service := &userServiceImpl{db: db, jwtService: NewJWTService(), cache: cache.NewMemoryCache()}
+	ctx := context.Background()
+
+	outdated, err := utils.HashPassword("password123", &utils.PasswordConfig{
+		Time: 1, Memory: 16 * 1024, Threads: 2, KeyLen: 32, SaltLen: 16,
+	})
+	require.NoError(t, err)
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	md5Sum := md5.Sum([]byte("password123" + "s4lt"))
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"argon2id_outdated", outdated},
+		{"bcrypt", string(bcryptHash)},
+		{"bcrypt_2y", "$2y$" + string(bcryptHash[4:])},
+		{"md5_salt", "$md5$s4lt$" + hex.EncodeToString(md5Sum[:])},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := service.Register(ctx, &RegisterRequest{
+				Username: fmt.Sprintf("legacy%d", i),
+				Password: "password123",
+				Phone:    fmt.Sprintf("1380013800%d", i),
+				Email:    fmt.Sprintf("legacy%d@example.com", i),
+			})
+			require.NoError(t, err)
+			require.NoError(t, db.Model(&models.User{}).Where("id = ?", user.ID).Update("password", tt.hash).Error)
+
+			_, err = service.Login(ctx, &LoginRequest{Account: user.Username, Password: "wrongpassword1"})
+			assert.Equal(t, common.ErrInvalidPassword, err)
+
+			// 密码错误时不重新加密
+			var stored models.User
+			require.NoError(t, db.First(&stored, user.ID).Error)
+			assert.Equal(t, tt.hash, stored.Password)
+
+			_, err = service.Login(ctx, &LoginRequest{Account: user.Username, Password: "password123"})
+			require.NoError(t, err)
+
+			// 登录成功后已使用当前配置重新加密，新哈希可以正常验证
+			require.NoError(t, db.First(&stored, user.ID).Error)
+			assert.NotEqual(t, tt.hash, stored.Password)
+			assert.False(t, stored.NeedsRehash())
+			assert.True(t, stored.CheckPassword("password123"))
+		})
+	}
+
+	// 使用当前配置加密的哈希不会重复加密
+	user, err := service.GetByUsername(ctx, "legacy0")
+	require.NoError(t, err)
+	_, err = service.Login(ctx, &LoginRequest{Account: "legacy0", Password: "password123"})
+	require.NoError(t, err)
+	var stored models.User
+	require.NoError(t, db.First(&stored, user.ID).Error)
+	assert.Equal(t, user.Password, stored.Password)
+}
+
+func TestUserService_LoginLockout(t *testing.T) {
+	config.Init()
+	logger.Init()
+	db := setupTestDB(t)
+	service := &userServiceImpl{
+		db:         db,
+		jwtService: NewJWTService(),
+		cache:      cache.NewMemoryCache(),
+		loginSecurity: &LoginSecurityConfig{
+			MaxAccountFailures: 3,
+			MaxIPFailures:      5,
+			FailureWindow:      time.Minute,
+			LockDuration:       time.Minute,
+			DelayAfter:         2,
+			DelayStep:          time.Millisecond,
+			MaxDelay:           5 * time.Millisecond,
+		},
+	}
+	ctx := WithClientInfo(context.Background(), &ClientInfo{IP: "10.0.0.1"})
+
+	_, err := service.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+
+	wrong := &LoginRequest{Username: "testuser", Password: "wrongpassword"}
+	right := &LoginRequest{Username: "testuser", Password: "password123"}
+
+	// 前两次失败返回密码错误，第三次失败锁定账号
+	for i := 0; i < 2; i++ {
+		_, err = service.Login(ctx, wrong)
+		assert.Equal(t, common.ErrInvalidPassword, err)
+	}
+	_, err = service.Login(ctx, wrong)
+	assert.Equal(t, common.ErrUserLocked, err)
+
+	// 锁定期间正确密码也无法登录
+	_, err = service.Login(ctx, right)
+	assert.Equal(t, common.ErrUserLocked, err)
+
+	var user models.User
+	require.NoError(t, db.Where("username = ?", "testuser").First(&user).Error)
+	assert.True(t, user.IsLocked())
+	require.NotNil(t, user.LockedUntil)
+
+	// 锁定到期后自动解锁
+	expired := time.Now().Add(-time.Second)
+	require.NoError(t, db.Model(&user).Update("locked_until", expired).Error)
+	resp, err := service.Login(ctx, right)
+	require.NoError(t, err)
+	assert.True(t, resp.User.IsActive())
+	assert.Nil(t, resp.User.LockedUntil)
+
+	// 同一IP失败次数过多后拒绝登录
+	for i := 0; i < 2; i++ {
+		_, err = service.Login(ctx, &LoginRequest{Username: "nobody", Password: "password123"})
+		assert.Equal(t, common.ErrInvalidPassword, err)
+	}
+	_, err = service.Login(ctx, right)
+	assert.Equal(t, common.ErrTooManyAttempts, err)
+
+	// 其他IP不受影响
+	otherCtx := WithClientInfo(context.Background(), &ClientInfo{IP: "10.0.0.2"})
+	_, err = service.Login(otherCtx, right)
+	assert.NoError(t, err)
+}
+
+func TestUserService_LoginLockoutUnknownAccount(t *testing.T) {
+	config.Init()
+	logger.Init()
+	db := setupTestDB(t)
+	memCache := cache.NewMemoryCache()
+	service := &userServiceImpl{
+		db:         db,
+		jwtService: NewJWTService(),
+		cache:      memCache,
+		loginSecurity: &LoginSecurityConfig{
+			MaxAccountFailures: 3,
+			FailureWindow:      time.Minute,
+			LockDuration:       time.Minute,
+		},
+	}
+	ctx := WithClientInfo(context.Background(), &ClientInfo{IP: "10.0.0.1"})
+
+	_, err := service.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+
+	attempt := func(username string) error {
+		_, err := service.Login(ctx, &LoginRequest{Username: username, Password: "wrongpassword"})
+		return err
+	}
+
+	// 已存在与不存在的账号连续失败时返回相同的结果，达到阈值后均提示账号锁定
+	for i := 0; i < 4; i++ {
+		existing, unknown := attempt("testuser"), attempt("nobody")
+		assert.Equal(t, existing, unknown, "第%d次登录", i+1)
+		if i < 2 {
+			assert.Equal(t, common.ErrInvalidPassword, unknown)
+		} else {
+			assert.Equal(t, common.ErrUserLocked, unknown)
+		}
+	}
+
+	// 锁定到期后重新计数
+	require.NoError(t, db.Model(&models.User{}).Where("username = ?", "testuser").
+		Update("locked_until", time.Now().Add(-time.Second)).Error)
+	guard := service.loginProtector()
+	require.NoError(t, memCache.Delete(ctx, guard.accountLockKey("default", "nobody")))
+
+	existing, unknown := attempt("testuser"), attempt("nobody")
+	assert.Equal(t, common.ErrInvalidPassword, existing)
+	assert.Equal(t, existing, unknown)
+}
+
+func TestUserService_LoginLockoutSharedAcrossIdentifiers(t *testing.T) {
+	config.Init()
+	logger.Init()
+	db := setupTestDB(t)
+	service := &userServiceImpl{
+		db:         db,
+		jwtService: NewJWTService(),
+		cache:      cache.NewMemoryCache(),
+		loginSecurity: &LoginSecurityConfig{
+			MaxAccountFailures: 3,
+			FailureWindow:      time.Minute,
+			LockDuration:       time.Minute,
+		},
+	}
+	ctx := context.Background()
+
+	_, err := service.Register(ctx, &RegisterRequest{
+		Username: "testuser",
+		Password: "password123",
+		Phone:    "13800138000",
+		Email:    "test@example.com",
+	})
+	require.NoError(t, err)
+
+	// 用户名、手机号、邮箱共用同一失败额度
+	_, err = service.Login(ctx, &LoginRequest{Account: "testuser", Password: "wrongpassword"})
+	assert.Equal(t, common.ErrInvalidPassword, err)
+	_, err = service.Login(ctx, &LoginRequest{Account: "13800138000", Password: "wrongpassword"})
+	assert.Equal(t, common.ErrInvalidPassword, err)
+	_, err = service.Login(ctx, &LoginRequest{Account: "test@example.com", Password: "wrongpassword"})
+	assert.Equal(t, common.ErrUserLocked, err)
+
+	// 到期锁定由后台任务解除后失败计数同时清零
+	require.NoError(t, db.Model(&models.User{}).Where("username = ?", "testuser").
+		Update("locked_until", time.Now().Add(-time.Second)).Error)
+	count, err := service.UnlockExpiredUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	_, err = service.Login(ctx, &LoginRequest{Account: "testuser", Password: "wrongpassword"})
+	assert.Equal(t, common.ErrInvalidPassword, err)
+}
+
+func TestUserService_UnlockExpiredUsers(t *testing.T) {
+	service, users := setupMemberService(t, 3)
+	ctx := context.WithValue(context.Background(), "tenant_id", "company1")
+
+	expired := time.Now().Add(-time.Second)
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, service.db.Model(users[0]).Updates(map[string]interface{}{
+		"status": models.UserStatusLocked, "locked_until": expired,
+	}).Error)
+	require.NoError(t, service.db.Model(users[1]).Updates(map[string]interface{}{
+		"status": models.UserStatusLocked, "locked_until": future,
+	}).Error)
+
+	// 仅解除已到期的锁定，无需等待用户再次登录
+	count, err := service.UnlockExpiredUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	var unlocked, locked models.User
+	require.NoError(t, service.db.First(&unlocked, users[0].ID).Error)
+	assert.True(t, unlocked.IsActive())
+	assert.Nil(t, unlocked.LockedUntil)
+	require.NoError(t, service.db.First(&locked, users[1].ID).Error)
+	assert.True(t, locked.IsLocked())
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"member-link-lite/config"
+	"member-link-lite/internal/models"
 	"net/http"
 	"net/url"
 	"strings"
@@ -39,6 +40,18 @@ type WeChatUserInfo struct {
 	Phone    string `json:"phone"`    // 手机号
 }
 
+// Identity 转换为第三方身份
+func (u *WeChatUserInfo) Identity() *ExternalIdentity {
+	return &ExternalIdentity{
+		Provider:       models.IdentityProviderWeChatMini,
+		ProviderUserID: u.OpenID,
+		UnionID:        u.UnionID,
+		Nickname:       u.Nickname,
+		Avatar:         u.Avatar,
+		Phone:          u.Phone,
+	}
+}
+
 // WeChatMiniProgramSessionResponse 微信小程序会话响应
 type WeChatMiniProgramSessionResponse struct {
 	OpenID     string `json:"openid"`
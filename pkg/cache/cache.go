@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound 缓存键不存在
+var ErrNotFound = errors.New("cache: key not found")
+
+// Cache 键值缓存接口
+// 统一Redis与进程内缓存的访问方式，ttl<=0 表示永不过期
+type Cache interface {
+	// Get 获取缓存值，键不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (string, error)
+	// Set 设置缓存值
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// SetNX 键不存在时设置缓存值，返回是否设置成功
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// Incr 计数器加一并返回新值，计数器新建时设置过期时间
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Delete 删除缓存键
+	Delete(ctx context.Context, keys ...string) error
+	// Exists 检查缓存键是否存在
+	Exists(ctx context.Context, key string) (bool, error)
+}
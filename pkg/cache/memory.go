@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memoryItem 进程内缓存项
+type memoryItem struct {
+	value    string
+	expireAt time.Time // 零值表示永不过期
+}
+
+// expired 检查缓存项是否已过期
+func (i *memoryItem) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && !now.Before(i.expireAt)
+}
+
+// MemoryCache 进程内缓存实现
+// 用于未配置Redis的单机部署和单元测试，数据不跨进程共享
+type MemoryCache struct {
+	mu    sync.Mutex
+	items map[string]*memoryItem
+}
+
+// NewMemoryCache 创建进程内缓存
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		items: make(map[string]*memoryItem),
+	}
+}
+
+// Get 获取缓存值
+func (c *MemoryCache) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.lookup(key, time.Now())
+	if !ok {
+		return "", ErrNotFound
+	}
+	return item.value, nil
+}
+
+// Set 设置缓存值
+func (c *MemoryCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[key] = newMemoryItem(value, ttl, time.Now())
+	return nil
+}
+
+// SetNX 键不存在时设置缓存值
+func (c *MemoryCache) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := c.lookup(key, now); ok {
+		return false, nil
+	}
+	c.items[key] = newMemoryItem(value, ttl, now)
+	return true, nil
+}
+
+// Incr 计数器加一
+func (c *MemoryCache) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	item, ok := c.lookup(key, now)
+	if !ok {
+		c.items[key] = newMemoryItem("1", ttl, now)
+		return 1, nil
+	}
+
+	n, err := strconv.ParseInt(item.value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	n++
+	item.value = strconv.FormatInt(n, 10)
+	return n, nil
+}
+
+// Delete 删除缓存键
+func (c *MemoryCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.items, key)
+	}
+	return nil
+}
+
+// Exists 检查缓存键是否存在
+func (c *MemoryCache) Exists(ctx context.Context, key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.lookup(key, time.Now())
+	return ok, nil
+}
+
+// lookup 查找未过期的缓存项，调用方需持有锁
+func (c *MemoryCache) lookup(key string, now time.Time) (*memoryItem, bool) {
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if item.expired(now) {
+		delete(c.items, key)
+		return nil, false
+	}
+	return item, true
+}
+
+// newMemoryItem 创建缓存项
+func newMemoryItem(value string, ttl time.Duration, now time.Time) *memoryItem {
+	item := &memoryItem{value: value}
+	if ttl > 0 {
+		item.expireAt = now.Add(ttl)
+	}
+	return item
+}
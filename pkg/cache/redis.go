@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisCache Redis缓存实现
+type RedisCache struct {
+	client *redis.Client
+}
+
+// NewRedisCache 创建Redis缓存
+func NewRedisCache(client *redis.Client) *RedisCache {
+	return &RedisCache{client: client}
+}
+
+// Get 获取缓存值
+func (c *RedisCache) Get(ctx context.Context, key string) (string, error) {
+	value, err := c.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+// Set 设置缓存值
+func (c *RedisCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+// SetNX 键不存在时设置缓存值
+func (c *RedisCache) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return c.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+// incrScript 计数器加一，并在键未设置过期时间时设置过期时间
+// INCR与PEXPIRE在同一脚本中执行，避免进程在两步之间退出导致计数器永不过期
+var incrScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if tonumber(ARGV[1]) > 0 and redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// Incr 计数器加一
+func (c *RedisCache) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return incrScript.Run(ctx, c.client, []string{key}, ttl.Milliseconds()).Int64()
+}
+
+// Delete 删除缓存键
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.client.Del(ctx, keys...).Err()
+}
+
+// Exists 检查缓存键是否存在
+func (c *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := c.client.Exists(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -40,21 +40,117 @@ var (
 	ErrServerError   = NewCustomError(CodeServerError, "服务器内部错误")
 
 	// 用户相关错误
-	ErrUserNotFound    = NewCustomError(CodeNotFound, "用户不存在")
-	ErrUserExists      = NewCustomError(CodeConflict, "用户已存在")
-	ErrPhoneExists     = NewCustomError(CodeConflict, "手机号已存在")
-	ErrEmailExists     = NewCustomError(CodeConflict, "邮箱已存在")
-	ErrInvalidPassword = NewCustomError(CodeBadRequest, "密码错误")
-	ErrPasswordTooWeak = NewCustomError(CodeBadRequest, "密码强度不足")
-	ErrInvalidEmail    = NewCustomError(CodeBadRequest, "邮箱格式错误")
-	ErrInvalidPhone    = NewCustomError(CodeBadRequest, "手机号格式错误")
-	ErrUserDisabled    = NewCustomError(CodeForbidden, "用户已被禁用")
-	ErrEditUserAvatar  = NewCustomError(CodeServerError, "编辑用户头像失败")
+	ErrUserNotFound      = NewCustomError(CodeNotFound, "用户不存在")
+	ErrUserExists        = NewCustomError(CodeConflict, "用户已存在")
+	ErrPhoneExists       = NewCustomError(CodeConflict, "手机号已存在")
+	ErrEmailExists       = NewCustomError(CodeConflict, "邮箱已存在")
+	ErrInvalidPassword   = NewCustomError(CodeBadRequest, "密码错误")
+	ErrPasswordTooWeak   = NewCustomError(CodeBadRequest, "密码强度不足")
+	ErrPasswordReused    = NewCustomError(CodeBadRequest, "不能使用最近使用过的密码")
+	ErrPasswordExpired   = NewCustomError(CodeForbidden, "密码已过期，请通过找回密码设置新密码")
+	ErrInvalidEmail      = NewCustomError(CodeBadRequest, "邮箱格式错误")
+	ErrInvalidPhone      = NewCustomError(CodeBadRequest, "手机号格式错误")
+	ErrUserDisabled      = NewCustomError(CodeForbidden, "用户已被禁用")
+	ErrAccountRequired   = NewCustomError(CodeBadRequest, "账号不能为空")
+	ErrUserLocked        = NewCustomError(CodeForbidden, "登录失败次数过多，账号已被临时锁定，请稍后再试")
+	ErrTooManyAttempts   = NewCustomError(CodeTooManyRequests, "登录尝试过于频繁，请稍后再试")
+	ErrEditUserAvatar    = NewCustomError(CodeServerError, "编辑用户头像失败")
+	ErrInvalidUserStatus = NewCustomError(CodeBadRequest, "无效的会员状态")
+	ErrOperateSelf       = NewCustomError(CodeBadRequest, "不能修改自己的账号状态或删除自己的账号")
 
 	// 认证相关错误
-	ErrInvalidToken   = NewCustomError(CodeUnauthorized, "令牌无效")
-	ErrTokenExpired   = NewCustomError(CodeUnauthorized, "令牌已过期")
-	ErrTokenMalformed = NewCustomError(CodeUnauthorized, "令牌格式错误")
+	ErrInvalidToken    = NewCustomError(CodeUnauthorized, "令牌无效")
+	ErrTokenExpired    = NewCustomError(CodeUnauthorized, "令牌已过期")
+	ErrTokenMalformed  = NewCustomError(CodeUnauthorized, "令牌格式错误")
+	ErrTokenRevoked    = NewCustomError(CodeUnauthorized, "令牌已失效")
+	ErrTokenReused     = NewCustomError(CodeUnauthorized, "刷新令牌已被使用，请重新登录")
+	ErrTenantMismatch  = NewCustomError(CodeForbidden, "令牌不属于当前租户")
+	ErrSessionNotFound = NewCustomError(CodeNotFound, "会话不存在")
+
+	// 权限相关错误
+	ErrPermissionDenied = NewCustomError(CodeForbidden, "权限不足")
+	ErrRoleNotFound     = NewCustomError(CodeNotFound, "角色不存在")
+
+	// 接入客户端相关错误
+	ErrInvalidSignature  = NewCustomError(CodeUnauthorized, "签名验证失败")
+	ErrRequestExpired    = NewCustomError(CodeUnauthorized, "请求时间戳已过期")
+	ErrNonceReused       = NewCustomError(CodeUnauthorized, "请求已处理，请勿重复提交")
+	ErrAPIClientDisabled = NewCustomError(CodeForbidden, "客户端已被停用")
+	ErrAPIClientNotFound = NewCustomError(CodeNotFound, "客户端不存在")
+
+	// 第三方应用授权相关错误
+	ErrInvalidOAuthClient      = NewCustomError(CodeBadRequest, "无效的应用")
+	ErrInvalidRedirectURI      = NewCustomError(CodeBadRequest, "回调地址未登记")
+	ErrUnsupportedResponseType = NewCustomError(CodeBadRequest, "仅支持授权码模式")
+	ErrInvalidOAuthScope       = NewCustomError(CodeBadRequest, "申请的授权范围无效")
+	ErrPKCERequired            = NewCustomError(CodeBadRequest, "必须使用PKCE（S256）")
+	ErrOAuthClientNotFound     = NewCustomError(CodeNotFound, "应用不存在")
+
+	// 第三方登录相关错误
+	ErrSocialProviderNotFound = NewCustomError(CodeBadRequest, "不支持的登录方式")
+	ErrInvalidSocialState     = NewCustomError(CodeBadRequest, "授权状态无效或已过期，请重新发起授权")
+	ErrSocialAuthFailed       = NewCustomError(CodeUnauthorized, "第三方授权失败")
+	ErrIdentityAlreadyBound   = NewCustomError(CodeConflict, "该第三方账号已绑定其他用户")
+	ErrProviderAlreadyBound   = NewCustomError(CodeConflict, "已绑定该登录方式，请先解绑")
+	ErrIdentityNotFound       = NewCustomError(CodeNotFound, "未绑定该登录方式")
+	ErrLastLoginMethod        = NewCustomError(CodeBadRequest, "这是唯一的登录方式，请先绑定手机号或验证邮箱")
+
+	// 账号合并相关错误
+	ErrMergeSameAccount      = NewCustomError(CodeBadRequest, "不能合并同一账号")
+	ErrMergeAccountInactive  = NewCustomError(CodeConflict, "账号已被合并或禁用")
+	ErrMergeIdentityConflict = NewCustomError(CodeConflict, "两个账号绑定了同一登录方式的不同第三方账号，无法合并")
+
+	// 会员标签及分群相关错误
+	ErrTagNotFound     = NewCustomError(CodeNotFound, "标签不存在")
+	ErrTagExists       = NewCustomError(CodeConflict, "标签名称已存在")
+	ErrSegmentNotFound = NewCustomError(CodeNotFound, "分群不存在")
+
+	// 会员导入相关错误
+	ErrImportNotFound       = NewCustomError(CodeNotFound, "导入任务不存在")
+	ErrImportReportNotFound = NewCustomError(CodeNotFound, "导入任务没有错误报告")
+
+	// 余额冻结相关错误
+	ErrBalanceHoldNotFound = NewCustomError(CodeNotFound, "冻结记录不存在")
+	ErrBalanceHoldExists   = NewCustomError(CodeConflict, "该订单已有冻结中的余额")
+	ErrBalanceHoldClosed   = NewCustomError(CodeConflict, "冻结已扣款或已释放")
+	ErrBalanceHoldExpired  = NewCustomError(CodeConflict, "冻结已过期")
+	ErrCaptureExceedsHold  = NewCustomError(CodeBadRequest, "扣款金额超过冻结金额")
+
+	// 积分转账相关错误
+	ErrTransferToSelf            = NewCustomError(CodeBadRequest, "不能转账给自己")
+	ErrTransferRecipientRequired = NewCustomError(CodeBadRequest, "请指定收款会员")
+	ErrTransferRecipientNotFound = NewCustomError(CodeNotFound, "收款会员不存在")
+	ErrTransferRecipientInactive = NewCustomError(CodeBadRequest, "收款会员未激活、已被禁用或锁定")
+	ErrTransferSenderInactive    = NewCustomError(CodeForbidden, "账号未激活、已被禁用或锁定，不能转账")
+	ErrTransferDailyLimit        = NewCustomError(CodeBadRequest, "超过每日转账限额")
+	ErrTransferAmountTooLarge    = NewCustomError(CodeBadRequest, "转账积分或手续费超过单笔上限")
+	ErrTransferPointsTooNew      = NewCustomError(CodeBadRequest, "可转账积分不足，新获得的积分需持有一段时间后才能转账")
+
+	// 个人数据导出及账号注销相关错误
+	ErrExportNotFound         = NewCustomError(CodeNotFound, "导出记录不存在")
+	ErrExportNotReady         = NewCustomError(CodeConflict, "导出文件尚未生成或已过期")
+	ErrDeletionNotScheduled   = NewCustomError(CodeBadRequest, "账号未申请注销")
+	ErrDeletionAlreadyPending = NewCustomError(CodeConflict, "账号已申请注销")
+	ErrDeletionBalanceRemains = NewCustomError(CodeConflict, "账户余额未清零或有冻结中的金额，请使用或退款后再申请注销")
+
+	// 两步验证相关错误
+	ErrMFANotEnabled     = NewCustomError(CodeBadRequest, "未启用两步验证")
+	ErrMFAAlreadyEnabled = NewCustomError(CodeConflict, "两步验证已启用")
+	ErrMFASetupRequired  = NewCustomError(CodeBadRequest, "请先获取两步验证密钥")
+	ErrInvalidMFACode    = NewCustomError(CodeBadRequest, "验证码错误")
+	ErrMFATokenInvalid   = NewCustomError(CodeUnauthorized, "两步验证已过期，请重新登录")
+
+	// 验证码相关错误
+	ErrSMSSendTooFrequent = NewCustomError(CodeTooManyRequests, "验证码发送过于频繁，请稍后再试")
+	ErrSMSSendLimit       = NewCustomError(CodeTooManyRequests, "验证码发送次数已达上限")
+	ErrSMSSendFailed      = NewCustomError(CodeServerError, "验证码发送失败")
+	ErrInvalidSMSCode     = NewCustomError(CodeBadRequest, "验证码错误或已过期")
+
+	// 邮件相关错误
+	ErrMailSendTooFrequent  = NewCustomError(CodeTooManyRequests, "邮件发送过于频繁，请稍后再试")
+	ErrMailSendFailed       = NewCustomError(CodeServerError, "邮件发送失败")
+	ErrInvalidLinkToken     = NewCustomError(CodeBadRequest, "链接无效或已过期")
+	ErrEmailAlreadyVerified = NewCustomError(CodeBadRequest, "邮箱已验证")
 
 	// 文件相关错误
 	ErrFileNotFound          = NewCustomError(CodeNotFound, "文件不存在")
@@ -82,6 +178,7 @@ var (
 	ErrInsufficientBalance = NewCustomError(CodeBadRequest, "余额不足")
 	ErrInsufficientPoints  = NewCustomError(CodeBadRequest, "积分不足")
 	ErrInvalidOperation    = NewCustomError(CodeBadRequest, "无效操作")
+	ErrIdempotencyConflict = NewCustomError(CodeConflict, "幂等键已被参数不同的请求使用")
 )
 
 // ValidationError 参数验证错误
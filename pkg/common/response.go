@@ -25,13 +25,14 @@ type PageResponse struct {
 
 // 响应码定义
 const (
-	CodeSuccess      = 200 // 成功
-	CodeBadRequest   = 400 // 请求错误
-	CodeUnauthorized = 401 // 未授权
-	CodeForbidden    = 403 // 禁止访问
-	CodeNotFound     = 404 // 未找到
-	CodeConflict     = 409 // 冲突
-	CodeServerError  = 500 // 服务器错误
+	CodeSuccess         = 200 // 成功
+	CodeBadRequest      = 400 // 请求错误
+	CodeUnauthorized    = 401 // 未授权
+	CodeForbidden       = 403 // 禁止访问
+	CodeNotFound        = 404 // 未找到
+	CodeConflict        = 409 // 冲突
+	CodeTooManyRequests = 429 // 请求过于频繁
+	CodeServerError     = 500 // 服务器错误
 )
 
 // 响应消息定义
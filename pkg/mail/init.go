@@ -0,0 +1,32 @@
+package mail
+
+import (
+	"member-link-lite/config"
+	"member-link-lite/pkg/logger"
+)
+
+// InitMail 初始化邮件发送器
+func InitMail() error {
+	provider := config.GetString("mail.provider")
+	if provider == "" {
+		provider = "log"
+	}
+
+	smtpCfg := &SMTPConfig{
+		Host:     config.GetString("mail.smtp.host"),
+		Port:     config.GetInt("mail.smtp.port"),
+		Username: config.GetString("mail.smtp.username"),
+		Password: config.GetString("mail.smtp.password"),
+		From:     config.GetString("mail.smtp.from"),
+		UseTLS:   config.GetBool("mail.smtp.use_tls"),
+	}
+
+	mailer, err := NewMailer(provider, smtpCfg, config.GetString("mail.file_dir"), config.GetBool("mail.log_body"))
+	if err != nil {
+		return err
+	}
+
+	SetMailer(mailer)
+	logger.Info("Mailer initialized with provider:", provider)
+	return nil
+}
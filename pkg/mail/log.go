@@ -0,0 +1,105 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLogMessages 日志发送器在内存中保留的最大邮件数，超出后丢弃最早的邮件
+const maxLogMessages = 100
+
+// LogMailer 日志/文件邮件发送实现
+// 邮件输出到日志，配置目录时同时写入 .eml 文件，并在内存中保留最近的邮件，用于开发和测试环境
+// 正文可能包含重置密码等有效链接，默认只记录脱敏后的收件人和主题，logBody开启时才输出正文，仅限本地开发使用
+type LogMailer struct {
+	dir      string
+	logBody  bool
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewLogMailer 创建日志邮件发送器，dir为空时不写文件
+func NewLogMailer(dir string, logBody bool) *LogMailer {
+	return &LogMailer{dir: dir, logBody: logBody}
+}
+
+// Send 发送邮件
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	sent := *msg
+	sent.SentAt = time.Now()
+
+	if m.dir != "" {
+		if err := os.MkdirAll(m.dir, 0700); err != nil {
+			return fmt.Errorf("创建邮件目录失败: %w", err)
+		}
+
+		name := fmt.Sprintf("%s_%s.eml", sent.SentAt.Format("20060102150405.000000000"), sanitizeFileName(sent.To))
+		if err := os.WriteFile(filepath.Join(m.dir, name), buildMessage("", &sent), 0600); err != nil {
+			return fmt.Errorf("写入邮件文件失败: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	m.messages = append(m.messages, sent)
+	if len(m.messages) > maxLogMessages {
+		m.messages = append([]Message(nil), m.messages[len(m.messages)-maxLogMessages:]...)
+	}
+	m.mu.Unlock()
+
+	if m.logBody {
+		log.Printf("[MAIL] to=%s subject=%s\n%s", sent.To, sent.Subject, sent.Body)
+	} else {
+		log.Printf("[MAIL] to=%s subject=%s", maskAddress(sent.To), sent.Subject)
+	}
+	return nil
+}
+
+// Messages 获取已发送的邮件
+func (m *LogMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]Message, len(m.messages))
+	copy(result, m.messages)
+	return result
+}
+
+// LastMessage 获取发送给指定地址的最后一封邮件
+func (m *LogMailer) LastMessage(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if strings.EqualFold(m.messages[i].To, to) {
+			return m.messages[i], true
+		}
+	}
+	return Message{}, false
+}
+
+// sanitizeFileName 将邮件地址转换为安全的文件名
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// maskAddress 邮件地址脱敏，仅保留用户名首字符和域名
+func maskAddress(addr string) string {
+	at := strings.LastIndex(addr, "@")
+	if at <= 0 {
+		return "***"
+	}
+	return addr[:1] + "***" + addr[at:]
+}
@@ -0,0 +1,58 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Message 邮件内容
+type Message struct {
+	To      string
+	Subject string
+	Body    string // 纯文本正文
+	SentAt  time.Time
+}
+
+// Mailer 邮件发送接口
+// 不同发送方式实现该接口，通过配置 mail.provider 选择
+type Mailer interface {
+	// Send 发送邮件
+	Send(ctx context.Context, msg *Message) error
+}
+
+var (
+	mailerMu      sync.RWMutex
+	currentMailer Mailer = NewLogMailer("", false)
+)
+
+// NewMailer 根据配置创建邮件发送器
+// dir及logBody仅对log方式生效，logBody开启后日志中输出邮件正文
+func NewMailer(provider string, cfg *SMTPConfig, dir string, logBody bool) (Mailer, error) {
+	switch provider {
+	case "", "log", "file":
+		return NewLogMailer(dir, logBody), nil
+	case "smtp":
+		if cfg == nil || cfg.Host == "" {
+			return nil, fmt.Errorf("SMTP服务器地址未配置")
+		}
+		return NewSMTPMailer(cfg), nil
+	default:
+		return nil, fmt.Errorf("不支持的邮件发送方式: %s", provider)
+	}
+}
+
+// SetMailer 设置当前邮件发送器
+func SetMailer(mailer Mailer) {
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+	currentMailer = mailer
+}
+
+// GetMailer 获取当前邮件发送器
+func GetMailer() Mailer {
+	mailerMu.RLock()
+	defer mailerMu.RUnlock()
+	return currentMailer
+}
@@ -0,0 +1,23 @@
+package sms
+
+import (
+	"member-link-lite/config"
+	"member-link-lite/pkg/logger"
+)
+
+// InitSMS 初始化短信发送器
+func InitSMS() error {
+	provider := config.GetString("sms.provider")
+	if provider == "" {
+		provider = "console"
+	}
+
+	sender, err := NewSender(provider, config.GetBool("sms.console_log_content"))
+	if err != nil {
+		return err
+	}
+
+	SetSender(sender)
+	logger.Info("SMS sender initialized with provider:", provider)
+	return nil
+}
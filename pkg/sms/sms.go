@@ -0,0 +1,119 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// SMSSender 短信发送接口
+// 不同短信服务商实现该接口，通过配置 sms.provider 选择
+type SMSSender interface {
+	// Send 发送短信
+	Send(ctx context.Context, phone, content string) error
+}
+
+// Message 已发送的短信
+type Message struct {
+	Phone   string
+	Content string
+	SentAt  time.Time
+}
+
+// maxConsoleMessages 控制台发送器在内存中保留的最大短信条数，超出后丢弃最早的短信
+const maxConsoleMessages = 100
+
+// ConsoleSender 控制台短信发送实现
+// 仅将短信输出到日志，并在内存中保留最近的短信，用于开发和测试环境
+// 默认只记录脱敏后的手机号和内容长度，logContent开启时才输出短信内容（含验证码），仅限本地开发使用
+type ConsoleSender struct {
+	logContent bool
+	mu         sync.Mutex
+	messages   []Message
+}
+
+// NewConsoleSender 创建控制台短信发送器
+func NewConsoleSender(logContent bool) *ConsoleSender {
+	return &ConsoleSender{logContent: logContent}
+}
+
+// Send 发送短信
+func (s *ConsoleSender) Send(ctx context.Context, phone, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, Message{Phone: phone, Content: content, SentAt: time.Now()})
+	if len(s.messages) > maxConsoleMessages {
+		s.messages = append([]Message(nil), s.messages[len(s.messages)-maxConsoleMessages:]...)
+	}
+
+	if s.logContent {
+		log.Printf("[SMS] to=%s content=%s", phone, content)
+	} else {
+		log.Printf("[SMS] to=%s length=%d", maskPhone(phone), len([]rune(content)))
+	}
+	return nil
+}
+
+// Messages 获取已发送的短信
+func (s *ConsoleSender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Message, len(s.messages))
+	copy(result, s.messages)
+	return result
+}
+
+// LastMessage 获取发送给指定手机号的最后一条短信
+func (s *ConsoleSender) LastMessage(phone string) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].Phone == phone {
+			return s.messages[i], true
+		}
+	}
+	return Message{}, false
+}
+
+var (
+	senderMu      sync.RWMutex
+	currentSender SMSSender = NewConsoleSender(false)
+)
+
+// NewSender 根据服务商名称创建短信发送器
+// logContent仅对console生效，开启后日志中输出短信内容
+func NewSender(provider string, logContent bool) (SMSSender, error) {
+	switch provider {
+	case "", "console":
+		return NewConsoleSender(logContent), nil
+	default:
+		return nil, fmt.Errorf("不支持的短信服务商: %s", provider)
+	}
+}
+
+// SetSender 设置当前短信发送器
+func SetSender(sender SMSSender) {
+	senderMu.Lock()
+	defer senderMu.Unlock()
+	currentSender = sender
+}
+
+// GetSender 获取当前短信发送器
+func GetSender() SMSSender {
+	senderMu.RLock()
+	defer senderMu.RUnlock()
+	return currentSender
+}
+
+// maskPhone 手机号脱敏，仅保留前3位和后4位
+func maskPhone(phone string) string {
+	if len(phone) <= 7 {
+		return "****"
+	}
+	return phone[:3] + "****" + phone[len(phone)-4:]
+}
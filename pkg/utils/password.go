@@ -1,15 +1,19 @@
 package utils
 
 import (
+	"crypto/md5"
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	mathrand "math/rand"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // PasswordConfig 密码加密配置
@@ -53,7 +57,15 @@ func HashPassword(password string, config *PasswordConfig) (string, error) {
 }
 
 // CheckPassword 验证密码
+// 除Argon2id外，兼容从旧会员系统导入的bcrypt及MD5加盐哈希，验证通过后应使用NeedsRehash判断是否需要重新加密
 func CheckPassword(password, encodedHash string) bool {
+	switch {
+	case isBcryptHash(encodedHash):
+		return bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password)) == nil
+	case strings.HasPrefix(encodedHash, legacyMD5Prefix):
+		return checkLegacyMD5(password, encodedHash)
+	}
+
 	// 解析编码的哈希
 	config, salt, hash, err := decodeHash(encodedHash)
 	if err != nil {
@@ -67,6 +79,60 @@ func CheckPassword(password, encodedHash string) bool {
 	return subtle.ConstantTimeCompare(hash, otherHash) == 1
 }
 
+// NeedsRehash 检查哈希是否需要按当前配置重新加密
+// 旧系统导入的哈希、无法解析的哈希及加密参数与当前配置不一致的Argon2id哈希均需要重新加密
+func NeedsRehash(encodedHash string, config *PasswordConfig) bool {
+	current, _, _, err := decodeHash(encodedHash)
+	if err != nil {
+		return true
+	}
+	return current.Time != config.Time ||
+		current.Memory != config.Memory ||
+		current.Threads != config.Threads ||
+		current.KeyLen != config.KeyLen ||
+		current.SaltLen != config.SaltLen
+}
+
+// UnusablePassword 不可用于登录的密码哈希
+// 批量导入时未提供密码的会员使用该值，需通过找回密码或验证码登录
+const UnusablePassword = "!"
+
+// IsPasswordHash 检查是否为支持的密码哈希（Argon2id、bcrypt及旧系统MD5加盐哈希）
+func IsPasswordHash(value string) bool {
+	if isBcryptHash(value) {
+		return true
+	}
+	if strings.HasPrefix(value, legacyMD5Prefix) {
+		return len(strings.Split(strings.TrimPrefix(value, legacyMD5Prefix), "$")) == 2
+	}
+	_, _, _, err := decodeHash(value)
+	return err == nil
+}
+
+// legacyMD5Prefix 旧系统MD5加盐哈希前缀
+// 导入时转换为 $md5$<盐>$<hex(MD5(密码+盐))> 格式
+const legacyMD5Prefix = "$md5$"
+
+// isBcryptHash 检查是否为bcrypt哈希（$2a$、$2b$、$2y$）
+func isBcryptHash(encodedHash string) bool {
+	return len(encodedHash) == 60 && encodedHash[0] == '$' && encodedHash[1] == '2' &&
+		encodedHash[3] == '$' && strings.ContainsRune("aby", rune(encodedHash[2]))
+}
+
+// checkLegacyMD5 验证旧系统MD5加盐哈希
+func checkLegacyMD5(password, encodedHash string) bool {
+	parts := strings.Split(strings.TrimPrefix(encodedHash, legacyMD5Prefix), "$")
+	if len(parts) != 2 {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.ToLower(parts[1]))
+	if err != nil {
+		return false
+	}
+	sum := md5.Sum([]byte(password + parts[0]))
+	return subtle.ConstantTimeCompare(sum[:], expected) == 1
+}
+
 // decodeHash 解码哈希字符串
 func decodeHash(encodedHash string) (*PasswordConfig, []byte, []byte, error) {
 	parts := strings.Split(encodedHash, "$")
@@ -102,37 +168,79 @@ func decodeHash(encodedHash string) (*PasswordConfig, []byte, []byte, error) {
 	return config, salt, hash, nil
 }
 
-// ValidatePassword 验证密码强度
-func ValidatePassword(password string) error {
-	if len(password) < 6 {
-		return fmt.Errorf("密码长度不能少于6位")
+// 密码字符类型
+const (
+	PasswordClassLower   = "lower"   // 小写字母
+	PasswordClassUpper   = "upper"   // 大写字母
+	PasswordClassLetter  = "letter"  // 字母（不区分大小写）
+	PasswordClassDigit   = "digit"   // 数字
+	PasswordClassSpecial = "special" // 字母、数字以外的字符
+)
+
+// passwordClassNames 字符类型名称
+var passwordClassNames = map[string]string{
+	PasswordClassLower:   "小写字母",
+	PasswordClassUpper:   "大写字母",
+	PasswordClassLetter:  "字母",
+	PasswordClassDigit:   "数字",
+	PasswordClassSpecial: "特殊字符",
+}
+
+// PasswordPolicy 密码规则
+type PasswordPolicy struct {
+	MinLength       int      // 最小长度
+	MaxLength       int      // 最大长度，0为不限制
+	RequiredClasses []string // 必须包含的字符类型
+}
+
+// DefaultPasswordPolicy 默认密码规则：6-64位，包含字母和数字
+var DefaultPasswordPolicy = &PasswordPolicy{
+	MinLength:       6,
+	MaxLength:       64,
+	RequiredClasses: []string{PasswordClassLetter, PasswordClassDigit},
+}
+
+// Validate 按规则验证密码
+func (p *PasswordPolicy) Validate(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("密码长度不能少于%d位", p.MinLength)
 	}
-	if len(password) > 20 {
-		return fmt.Errorf("密码长度不能超过20位")
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("密码长度不能超过%d位", p.MaxLength)
 	}
 
-	hasLetter := false
-	hasDigit := false
-
+	present := make(map[string]bool)
 	for _, char := range password {
 		switch {
-		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z':
-			hasLetter = true
+		case char >= 'a' && char <= 'z':
+			present[PasswordClassLower] = true
+			present[PasswordClassLetter] = true
+		case char >= 'A' && char <= 'Z':
+			present[PasswordClassUpper] = true
+			present[PasswordClassLetter] = true
 		case char >= '0' && char <= '9':
-			hasDigit = true
+			present[PasswordClassDigit] = true
+		default:
+			present[PasswordClassSpecial] = true
 		}
 	}
 
-	if !hasLetter {
-		return fmt.Errorf("密码必须包含字母")
-	}
-	if !hasDigit {
-		return fmt.Errorf("密码必须包含数字")
+	for _, class := range p.RequiredClasses {
+		name, ok := passwordClassNames[class]
+		if ok && !present[class] {
+			return fmt.Errorf("密码必须包含%s", name)
+		}
 	}
 
 	return nil
 }
 
+// ValidatePassword 按默认规则验证密码强度
+func ValidatePassword(password string) error {
+	return DefaultPasswordPolicy.Validate(password)
+}
+
 // GenerateRandomPassword 生成随机密码
 func GenerateRandomPassword(length int) (string, error) {
 	if length < 8 {
@@ -0,0 +1,315 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// XLSX读写仅支持导入导出所需的最小子集：读取第一个工作表的单元格文本，写入单个工作表的文本单元格
+
+// MaxXLSXPartSize 读取时压缩包内单个文件解压后的大小上限，防止高压缩比文件耗尽内存
+const MaxXLSXPartSize = 64 << 20
+
+// ErrXLSXTooLarge XLSX文件解压后过大
+var ErrXLSXTooLarge = errors.New("XLSX文件内容过大")
+
+// xlsxWorkbook 工作簿
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// xlsxRelationships 工作簿关系
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxRichText 富文本（共享字符串及内联字符串）
+type xlsxRichText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+// String 获取文本内容
+func (r *xlsxRichText) String() string {
+	if len(r.Runs) == 0 {
+		return r.T
+	}
+	var sb strings.Builder
+	for _, run := range r.Runs {
+		sb.WriteString(run.T)
+	}
+	return sb.String()
+}
+
+// xlsxSharedStrings 共享字符串表
+type xlsxSharedStrings struct {
+	Items []xlsxRichText `xml:"si"`
+}
+
+// xlsxSheet 工作表
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string        `xml:"r,attr"`
+			Type   string        `xml:"t,attr"`
+			Value  string        `xml:"v"`
+			Inline *xlsxRichText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadXLSX 读取XLSX文件第一个工作表的全部行，单元格均以文本返回
+func ReadXLSX(r io.ReaderAt, size int64) ([][]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("无法解析XLSX文件: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared xlsxSharedStrings
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeZipXML(f, &shared); err != nil {
+			return nil, fmt.Errorf("无法解析共享字符串: %w", err)
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, errors.New("XLSX文件中没有工作表")
+	}
+	var sheet xlsxSheet
+	if err := decodeZipXML(f, &sheet); err != nil {
+		return nil, fmt.Errorf("无法解析工作表: %w", err)
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		var values []string
+		for i, cell := range row.Cells {
+			col := i
+			if cell.Ref != "" {
+				if col, err = xlsxColumnIndex(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				idx, err := strconv.Atoi(cell.Value)
+				if err != nil || idx < 0 || idx >= len(shared.Items) {
+					return nil, fmt.Errorf("单元格%s引用的共享字符串不存在", cell.Ref)
+				}
+				values[col] = shared.Items[idx].String()
+			case "inlineStr":
+				if cell.Inline != nil {
+					values[col] = cell.Inline.String()
+				}
+			case "", "n":
+				values[col] = xlsxNumber(cell.Value)
+			default:
+				values[col] = cell.Value
+			}
+		}
+		rows = append(rows, values)
+	}
+
+	return rows, nil
+}
+
+// firstSheetPath 获取第一个工作表在压缩包中的路径
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	const fallback = "xl/worksheets/sheet1.xml"
+
+	wf, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", errors.New("XLSX文件缺少workbook.xml")
+	}
+	var workbook xlsxWorkbook
+	if err := decodeZipXML(wf, &workbook); err != nil {
+		return "", fmt.Errorf("无法解析工作簿: %w", err)
+	}
+	rf, ok := files["xl/_rels/workbook.xml.rels"]
+	if !ok || len(workbook.Sheets) == 0 {
+		return fallback, nil
+	}
+	var rels xlsxRelationships
+	if err := decodeZipXML(rf, &rels); err != nil {
+		return "", fmt.Errorf("无法解析工作簿关系: %w", err)
+	}
+
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return fallback, nil
+}
+
+// decodeZipXML 解析压缩包中的XML文件，解压后超过MaxXLSXPartSize时返回ErrXLSXTooLarge
+// 压缩包中记录的大小可以伪造，因此读取时同样限制实际解压的字节数
+func decodeZipXML(f *zip.File, v interface{}) error {
+	if f.UncompressedSize64 > MaxXLSXPartSize {
+		return ErrXLSXTooLarge
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	lr := &io.LimitedReader{R: rc, N: MaxXLSXPartSize}
+	if err := xml.NewDecoder(lr).Decode(v); err != nil {
+		if lr.N <= 0 {
+			return ErrXLSXTooLarge
+		}
+		return err
+	}
+	return nil
+}
+
+// xlsxColumnIndex 将单元格引用（如"AB12"）转换为从0开始的列序号
+func xlsxColumnIndex(ref string) (int, error) {
+	col := 0
+	for _, ch := range ref {
+		if ch >= 'A' && ch <= 'Z' {
+			col = col*26 + int(ch-'A'+1)
+			continue
+		}
+		break
+	}
+	if col == 0 {
+		return 0, fmt.Errorf("无效的单元格引用: %s", ref)
+	}
+	return col - 1, nil
+}
+
+// xlsxNumber 格式化数字单元格，避免手机号等长数字以科学计数法返回
+func xlsxNumber(value string) string {
+	if !strings.ContainsAny(value, "eE") {
+		return value
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// XLSXWriter 流式写入单个工作表的XLSX文件，单元格均为文本
+type XLSXWriter struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	row   int
+}
+
+// NewXLSXWriter 创建XLSX写入器，写入完成后必须调用Close
+func NewXLSXWriter(w io.Writer, sheetName string) (*XLSXWriter, error) {
+	zw := zip.NewWriter(w)
+
+	var name strings.Builder
+	if err := xml.EscapeText(&name, []byte(sheetName)); err != nil {
+		return nil, err
+	}
+
+	parts := []struct {
+		name, content string
+	}{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + name.String() + `" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+	}
+	for _, part := range parts {
+		pw, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(pw, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	// 工作表最后写入，行数据直接流式写入压缩包
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+
+	return &XLSXWriter{zw: zw, sheet: sheet}, nil
+}
+
+// WriteRow 写入一行
+func (x *XLSXWriter) WriteRow(values []string) error {
+	x.row++
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<row r="%d">`, x.row)
+	for _, value := range values {
+		sb.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		if err := xml.EscapeText(&sb, []byte(value)); err != nil {
+			return err
+		}
+		sb.WriteString(`</t></is></c>`)
+	}
+	sb.WriteString(`</row>`)
+
+	_, err := io.WriteString(x.sheet, sb.String())
+	return err
+}
+
+// Close 结束工作表并关闭压缩包
+func (x *XLSXWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
@@ -59,28 +59,17 @@ func main() {
 		fmt.Println("✗ 用户表 m_users 不存在")
 	}
 
-	// 检查微信字段是否存在
-	var wechatFields []struct {
-		ColumnName string `gorm:"column:COLUMN_NAME"`
-		DataType   string `gorm:"column:DATA_TYPE"`
-	}
-
-	result = db.Raw(`
-		SELECT COLUMN_NAME, DATA_TYPE 
-		FROM INFORMATION_SCHEMA.COLUMNS 
-		WHERE TABLE_SCHEMA = DATABASE() 
-		  AND TABLE_NAME = 'm_users' 
-		  AND COLUMN_NAME IN ('wechat_openid', 'wechat_unionid')
-		ORDER BY COLUMN_NAME
-	`).Scan(&wechatFields)
-
+	// 检查第三方身份表是否存在
+	var identityTableExists bool
+	result = db.Raw("SELECT COUNT(*) > 0 FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'm_user_identities'").Scan(&identityTableExists)
 	if result.Error != nil {
-		log.Printf("检查微信字段失败: %v", result.Error)
+		log.Printf("检查表存在性失败: %v", result.Error)
+	} else if identityTableExists {
+		var identityCount int64
+		db.Table("m_user_identities").Count(&identityCount)
+		fmt.Printf("✓ 第三方身份表 m_user_identities 存在，共 %d 条绑定记录\n", identityCount)
 	} else {
-		fmt.Printf("找到 %d 个微信相关字段:\n", len(wechatFields))
-		for _, field := range wechatFields {
-			fmt.Printf("  - %s (%s)\n", field.ColumnName, field.DataType)
-		}
+		fmt.Println("✗ 第三方身份表 m_user_identities 不存在")
 	}
 
 	fmt.Println("数据库初始化完成！")